@@ -0,0 +1,103 @@
+package curlhttp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Future is the pending result of a request started with Client.DoAsync
+type Future struct {
+	done chan struct{}
+	resp *Response
+	err  error
+}
+
+// Done returns a channel that is closed once the request has finished
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the request has finished and returns its result
+func (f *Future) Wait() (*Response, error) {
+	<-f.done
+	return f.resp, f.err
+}
+
+// DoAsync sends req in the background and returns a Future for its result.
+// Cancel the request through its context; the caller must close the
+// response body as with Do.
+func (c *Client) DoAsync(req *Request) *Future {
+	c.ensureInitialized()
+	f := &Future{done: make(chan struct{})}
+	go func() {
+		defer close(f.done)
+		f.resp, f.err = c.Do(req)
+	}()
+	return f
+}
+
+// BatchError reports the requests of a DoBatch call that failed
+type BatchError struct {
+	Total  int
+	Failed map[int]error // keyed by index into the request slice
+}
+
+// Error summarizes the failures, quoting the first failed request
+func (e *BatchError) Error() string {
+	first := -1
+	for i := range e.Failed {
+		if first == -1 || i < first {
+			first = i
+		}
+	}
+	return fmt.Sprintf("%d of %d requests failed; request %d: %v", len(e.Failed), e.Total, first, e.Failed[first])
+}
+
+// Unwrap returns the individual errors so errors.Is and errors.As see them
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, err := range e.Failed {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// DoBatch sends all reqs concurrently and waits for them to finish. The
+// responses line up with reqs; a failed request leaves a nil entry and the
+// returned error is a *BatchError listing every failure. Requests share the
+// transport's handle pool, so Transport.MaxConcurrentRequests and the
+// per-host limits bound how many run at once.
+func (c *Client) DoBatch(reqs []*Request) ([]*Response, error) {
+	c.ensureInitialized()
+
+	responses := make([]*Response, len(reqs))
+	errs := make([]error, len(reqs))
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		if req == nil {
+			errs[i] = errors.New("request cannot be nil")
+			continue
+		}
+		wg.Add(1)
+		go func(i int, req *Request) {
+			defer wg.Done()
+			responses[i], errs[i] = c.Do(req)
+		}(i, req)
+	}
+	wg.Wait()
+
+	batchErr := &BatchError{Total: len(reqs)}
+	for i, err := range errs {
+		if err != nil {
+			if batchErr.Failed == nil {
+				batchErr.Failed = make(map[int]error)
+			}
+			batchErr.Failed[i] = err
+		}
+	}
+	if batchErr.Failed != nil {
+		return responses, batchErr
+	}
+	return responses, nil
+}
@@ -0,0 +1,64 @@
+package curlhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// TestDoAsync tests that a Future delivers the response
+func TestDoAsync(t *testing.T) {
+	client := &Client{Client: http.Client{Transport: newFakeTransport(newFakeEngine("async"))}}
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+
+	future := client.DoAsync(req)
+	<-future.Done()
+	resp, err := future.Wait()
+	if err != nil {
+		t.Fatalf("DoAsync failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "async" {
+		t.Errorf("Expected body async, got %q", body)
+	}
+}
+
+// TestDoBatch tests that responses line up with requests and failures are aggregated
+func TestDoBatch(t *testing.T) {
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return newFakeEngine("batch") }
+	client := &Client{Client: http.Client{Transport: transport}}
+
+	reqs := make([]*Request, 4)
+	for i := range reqs {
+		if i != 2 {
+			reqs[i], _ = http.NewRequest("GET", "http://example.com/", nil)
+		}
+	}
+
+	responses, err := client.DoBatch(reqs)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected *BatchError, got %v", err)
+	}
+	if batchErr.Total != 4 || len(batchErr.Failed) != 1 || batchErr.Failed[2] == nil {
+		t.Errorf("Expected only request 2 to fail, got %v", batchErr.Failed)
+	}
+	for i, resp := range responses {
+		if i == 2 {
+			if resp != nil {
+				t.Error("Expected nil response for the failed request")
+			}
+			continue
+		}
+		if resp == nil {
+			t.Fatalf("Expected response %d", i)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "batch" {
+			t.Errorf("Expected body batch, got %q", body)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package curlhttp
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AuditRecord is one entry of the outbound request audit log. Records are
+// hash chained: each Hash covers the record's content and the previous
+// record's Hash, so removing, reordering or editing a record breaks every
+// hash after it. RequestBodySHA256 is hashed as the body streams to the
+// server and is only set if it was read to the end.
+type AuditRecord struct {
+	Seq               uint64    `json:"seq"`
+	Time              time.Time `json:"time"`
+	Method            string    `json:"method"`
+	URL               string    `json:"url"`
+	RequestBodySHA256 string    `json:"request_body_sha256,omitempty"`
+	StatusCode        int       `json:"status_code,omitempty"`
+	Error             string    `json:"error,omitempty"`
+	PrevHash          string    `json:"prev_hash"`
+	Hash              string    `json:"hash"`
+}
+
+// AuditLogger writes a signed, hash-chained record of every request to an
+// io.Writer as JSON lines. With a key, each hash is an HMAC-SHA256 so the
+// log cannot be rewritten without the key; without one it is a plain
+// SHA-256 chain that detects accidental or partial tampering.
+type AuditLogger struct {
+	// Clock timestamps records. Defaults to SystemClock.
+	Clock Clock
+
+	w    io.Writer
+	key  []byte
+	mu   sync.Mutex
+	seq  uint64
+	prev string
+}
+
+// NewAuditLogger creates an audit logger writing to w. key may be nil.
+func NewAuditLogger(w io.Writer, key []byte) *AuditLogger {
+	return &AuditLogger{w: w, key: key}
+}
+
+// Middleware returns middleware that appends a record for every request
+// once its outcome is known. Failing to write the record fails the request,
+// so no request goes unaudited.
+func (a *AuditLogger) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			var digest *bodyDigest
+			if req.Body != nil && req.Body != http.NoBody {
+				digest = &bodyDigest{}
+				getBody := req.GetBody
+				req = req.Clone(req.Context())
+				req.Body = digest.wrap(req.Body)
+				if getBody != nil {
+					// A replayed body starts the hash over
+					req.GetBody = func() (io.ReadCloser, error) {
+						body, err := getBody()
+						if err != nil {
+							return nil, err
+						}
+						return digest.wrap(body), nil
+					}
+				}
+			}
+
+			resp, rtErr := next.RoundTrip(req)
+
+			record := AuditRecord{
+				Method: req.Method,
+				URL:    req.URL.Redacted(),
+			}
+			if digest != nil {
+				record.RequestBodySHA256 = digest.sum()
+			}
+			if rtErr != nil {
+				record.Error = rtErr.Error()
+			} else {
+				record.StatusCode = resp.StatusCode
+			}
+
+			if err := a.append(record); err != nil {
+				if resp != nil {
+					resp.Body.Close()
+				}
+				return nil, err
+			}
+			return resp, rtErr
+		})
+	}
+}
+
+// bodyDigest hashes a request body while the transport reads it
+type bodyDigest struct {
+	mu   sync.Mutex
+	hash hash.Hash
+	eof  bool
+}
+
+// wrap returns body with its reads hashed into d, starting d over
+func (d *bodyDigest) wrap(body io.ReadCloser) io.ReadCloser {
+	d.mu.Lock()
+	d.hash, d.eof = sha256.New(), false
+	d.mu.Unlock()
+	return &digestBody{ReadCloser: body, d: d}
+}
+
+// sum returns the hex SHA-256 of the body, or "" if it was not read to the end
+func (d *bodyDigest) sum() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.eof {
+		return ""
+	}
+	return hex.EncodeToString(d.hash.Sum(nil))
+}
+
+// digestBody is a request body hashed as it is read
+type digestBody struct {
+	io.ReadCloser
+	d *bodyDigest
+}
+
+func (b *digestBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.d.mu.Lock()
+	b.d.hash.Write(p[:n])
+	if err == io.EOF {
+		b.d.eof = true
+	}
+	b.d.mu.Unlock()
+	return n, err
+}
+
+// append seals record into the chain and writes it. The chain only
+// advances once the write succeeds, so a failed write leaves no gap.
+func (a *AuditLogger) append(record AuditRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	record.Seq = a.seq + 1
+	record.Time = clockOrSystem(a.Clock).Now().UTC()
+	record.PrevHash = a.prev
+	hash, err := auditHash(a.key, record)
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	if _, err := a.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	a.seq, a.prev = record.Seq, hash
+	return nil
+}
+
+// auditHash computes the chained hash of record, ignoring its Hash field
+func auditHash(key []byte, record AuditRecord) (string, error) {
+	record.Hash = ""
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	if key != nil {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(data)
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyAuditLog checks an audit log written by AuditLogger with the same
+// key and returns the number of valid records. It fails at the first record
+// whose hash, chain link or sequence number does not match.
+func VerifyAuditLog(r io.Reader, key []byte) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	prev := ""
+	count := 0
+	for scanner.Scan() {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return count, fmt.Errorf("audit record %d: invalid JSON: %w", count+1, err)
+		}
+		if record.Seq != uint64(count+1) {
+			return count, fmt.Errorf("audit record %d: unexpected sequence number %d", count+1, record.Seq)
+		}
+		if record.PrevHash != prev {
+			return count, fmt.Errorf("audit record %d: chain broken", record.Seq)
+		}
+		want, err := auditHash(key, record)
+		if err != nil {
+			return count, err
+		}
+		if !hmac.Equal([]byte(want), []byte(record.Hash)) {
+			return count, fmt.Errorf("audit record %d: hash mismatch", record.Seq)
+		}
+		prev = record.Hash
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return count, nil
+}
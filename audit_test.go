@@ -0,0 +1,156 @@
+package curlhttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// writeTestAuditLog performs three requests through an audit logger
+func writeTestAuditLog(t *testing.T, key []byte) string {
+	var buf bytes.Buffer
+	logger := NewAuditLogger(&buf, key)
+	rt := Chain(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/fail" {
+			return nil, errors.New("connection reset")
+		}
+		return stubResponse("ok").RoundTrip(req)
+	}), logger.Middleware())
+
+	for _, target := range []string{"https://user:pw@example.com/a", "https://example.com/fail", "https://example.com/b"} {
+		req, _ := http.NewRequest("POST", target, strings.NewReader("body"))
+		if resp, err := rt.RoundTrip(req); err == nil {
+			resp.Body.Close()
+		}
+	}
+	return buf.String()
+}
+
+// TestAuditLogVerifies tests that an untouched log verifies
+func TestAuditLogVerifies(t *testing.T) {
+	key := []byte("secret")
+	log := writeTestAuditLog(t, key)
+
+	n, err := VerifyAuditLog(strings.NewReader(log), key)
+	if err != nil {
+		t.Fatalf("VerifyAuditLog failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 records, got %d", n)
+	}
+	if strings.Contains(log, "pw@") {
+		t.Error("Audit log must not contain URL passwords")
+	}
+	if !strings.Contains(log, "connection reset") {
+		t.Error("Expected failed request to be audited")
+	}
+}
+
+// TestAuditLogDetectsTampering tests edits, deletions and wrong keys
+func TestAuditLogDetectsTampering(t *testing.T) {
+	key := []byte("secret")
+	log := writeTestAuditLog(t, key)
+	lines := strings.SplitAfter(strings.TrimSpace(log), "\n")
+
+	edited := strings.Replace(log, `"status_code":200`, `"status_code":404`, 1)
+	if _, err := VerifyAuditLog(strings.NewReader(edited), key); err == nil {
+		t.Error("Expected edited record to fail verification")
+	}
+
+	deleted := lines[0] + lines[2]
+	if _, err := VerifyAuditLog(strings.NewReader(deleted), key); err == nil {
+		t.Error("Expected deleted record to fail verification")
+	}
+
+	if _, err := VerifyAuditLog(strings.NewReader(log), []byte("other")); err == nil {
+		t.Error("Expected wrong key to fail verification")
+	}
+}
+
+// flakyWriter fails the writes whose index is in fail
+type flakyWriter struct {
+	bytes.Buffer
+	writes int
+	fail   map[int]bool
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.fail[w.writes] {
+		return 0, errors.New("disk full")
+	}
+	return w.Buffer.Write(p)
+}
+
+// TestAuditLogWriteFailure tests that a failed write fails the request
+// without breaking the chain for later records
+func TestAuditLogWriteFailure(t *testing.T) {
+	w := &flakyWriter{fail: map[int]bool{2: true}}
+	rt := Chain(stubResponse("ok"), NewAuditLogger(w, nil).Middleware())
+
+	for i := 1; i <= 3; i++ {
+		req, _ := http.NewRequest("GET", "https://example.com/", nil)
+		resp, err := rt.RoundTrip(req)
+		if (err != nil) != (i == 2) {
+			t.Errorf("Request %d: unexpected error %v", i, err)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}
+
+	n, err := VerifyAuditLog(strings.NewReader(w.String()), nil)
+	if err != nil {
+		t.Fatalf("VerifyAuditLog failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 records, got %d", n)
+	}
+}
+
+// TestAuditLogStreamsBody tests that the body digest is taken as the transport reads the body
+func TestAuditLogStreamsBody(t *testing.T) {
+	body := strings.Repeat("x", 1<<20)
+	sum := sha256.Sum256([]byte(body))
+
+	for _, read := range []bool{true, false} {
+		var buf bytes.Buffer
+		logger := NewAuditLogger(&buf, []byte("secret"))
+		src := strings.NewReader(body)
+		rt := Chain(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if read {
+				if _, err := io.Copy(io.Discard, req.Body); err != nil {
+					return nil, err
+				}
+			} else if src.Len() != len(body) {
+				t.Error("Expected the middleware to leave the body unread")
+			}
+			return stubResponse("ok").RoundTrip(req)
+		}), logger.Middleware())
+
+		req, _ := http.NewRequest("PUT", "https://example.com/upload", io.NopCloser(src))
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		resp.Body.Close()
+
+		var record AuditRecord
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatalf("Failed to decode record: %v", err)
+		}
+		want := ""
+		if read {
+			want = hex.EncodeToString(sum[:])
+		}
+		if record.RequestBodySHA256 != want {
+			t.Errorf("Expected body digest %q when read=%v, got %q", want, read, record.RequestBodySHA256)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package curlhttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// ErrBackendUnavailable is returned (wrapped) by every request when libcurl
+// could not be initialized or cannot allocate handles. Check for it with
+// errors.Is; the wrapped error carries the underlying cause.
+var ErrBackendUnavailable = errors.New("curl backend unavailable")
+
+// Ready reports whether libcurl initialized successfully. It initializes
+// libcurl on first use.
+func Ready() bool {
+	return initCurl() == nil
+}
+
+// Err returns the error that prevented libcurl from initializing, or nil
+func Err() error {
+	return initCurl()
+}
+
+// fallbackRoundTrip sends req through the Fallback transport, restoring the
+// body that was already read for curl
+func (t *Transport) fallbackRoundTrip(req *http.Request, body []byte) (*http.Response, error) {
+	t.log(req.Context(), slog.LevelWarn, "curl backend unavailable, using fallback transport")
+	fallbackReq := req.Clone(req.Context())
+	// Streamed uploads have not been read yet and go out as they are
+	if req.Body != nil && !t.streamsUpload(req) {
+		fallbackReq.Body = io.NopCloser(bytes.NewReader(body))
+		fallbackReq.ContentLength = int64(len(body))
+	}
+	return t.Fallback.RoundTrip(fallbackReq)
+}
@@ -0,0 +1,78 @@
+package curlhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestBackendUnavailable tests that a missing curl handle yields ErrBackendUnavailable
+func TestBackendUnavailable(t *testing.T) {
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return nil }
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	_, err := transport.RoundTrip(req)
+	if !errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("Expected ErrBackendUnavailable, got %v", err)
+	}
+}
+
+// TestBackendFallback tests that requests go to Fallback when curl is unavailable
+func TestBackendFallback(t *testing.T) {
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return nil }
+
+	var gotBody string
+	transport.Fallback = RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		data, _ := io.ReadAll(req.Body)
+		gotBody = string(data)
+		return &http.Response{StatusCode: 204, Header: make(http.Header), Body: http.NoBody, Request: req}, nil
+	})
+
+	req, _ := http.NewRequest("POST", "http://example.com", strings.NewReader("payload"))
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Expected fallback to succeed, got %v", err)
+	}
+	if resp.StatusCode != 204 {
+		t.Errorf("Expected status 204, got %d", resp.StatusCode)
+	}
+	if gotBody != "payload" {
+		t.Errorf("Expected fallback to receive body payload, got %q", gotBody)
+	}
+	if resp.Request != req {
+		t.Error("Expected response to reference the original request")
+	}
+}
+
+// TestBackendFallbackCriticalCH tests that a fallback response asking for
+// client hints is returned rather than resent through curl
+func TestBackendFallbackCriticalCH(t *testing.T) {
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return nil }
+	calls := 0
+	transport.Fallback = RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		header := http.Header{"Accept-Ch": {"Sec-CH-UA-Arch"}, "Critical-Ch": {"Sec-CH-UA-Arch"}}
+		return &http.Response{StatusCode: 200, Header: header, Body: http.NoBody, Request: req}, nil
+	})
+
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Expected the fallback response, got %v", err)
+	}
+	if resp.StatusCode != 200 || calls != 1 {
+		t.Errorf("Expected one fallback response with status 200, got %d after %d calls", resp.StatusCode, calls)
+	}
+}
+
+// TestReady tests the package-level backend status
+func TestReady(t *testing.T) {
+	if Ready() != (Err() == nil) {
+		t.Errorf("Expected Ready to agree with Err, got Ready=%v Err=%v", Ready(), Err())
+	}
+}
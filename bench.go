@@ -0,0 +1,297 @@
+package curlhttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// Bench drives load against a list of URLs through a Transport and reports
+// throughput, latency percentiles, error classes and pool statistics. It
+// backs the curlhttp-bench command and can be used from tests:
+//
+//	report, err := (&curlhttp.Bench{URLs: urls, Concurrency: 50, Requests: 10000}).Run(ctx)
+//	fmt.Print(report)
+type Bench struct {
+	// Transport performs the requests. Defaults to NewTransport().
+	Transport *Transport
+
+	// URLs are requested in turn, wrapping around
+	URLs []string
+
+	// Method, Header and Body describe each request. Method defaults to
+	// GET.
+	Method string
+	Header http.Header
+	Body   []byte
+
+	// Concurrency is the number of requests in flight. Defaults to 10.
+	Concurrency int
+
+	// Requests is the total number of requests. Defaults to one per URL.
+	// It is ignored when Duration is set.
+	Requests int
+
+	// Duration, if set, keeps sending requests until it has elapsed
+	Duration time.Duration
+
+	// Clock measures latency. Defaults to SystemClock.
+	Clock Clock
+}
+
+// BenchReport is the result of a Bench run
+type BenchReport struct {
+	Requests  int
+	Succeeded int
+	Failed    int
+
+	// Elapsed is the wall time of the run and Throughput the completed
+	// requests per second
+	Elapsed    time.Duration
+	Throughput float64
+
+	// BytesReceived counts response body bytes
+	BytesReceived int64
+
+	Latency LatencyPercentiles
+
+	// StatusCodes counts responses by status code
+	StatusCodes map[int]int
+
+	// Errors counts failures by class, such as "timeout", "connect" or
+	// "http 5xx"
+	Errors map[string]int
+
+	// Pool is the Transport's statistics at the end of the run
+	Pool TransportStats
+}
+
+// LatencyPercentiles summarises request latencies, measured from sending
+// the request to reading the whole body
+type LatencyPercentiles struct {
+	Min, Mean, P50, P90, P99, Max time.Duration
+}
+
+// Run performs the benchmark. It stops early when ctx is done, reporting
+// the requests completed so far.
+func (b *Bench) Run(ctx context.Context) (*BenchReport, error) {
+	if len(b.URLs) == 0 {
+		return nil, errors.New("bench needs at least one URL")
+	}
+	transport := b.Transport
+	if transport == nil {
+		transport = NewTransport()
+	}
+	method := b.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	concurrency := b.Concurrency
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+	total := b.Requests
+	if total <= 0 {
+		total = len(b.URLs)
+	}
+	clock := clockOrSystem(b.Clock)
+
+	start := clock.Now()
+	var deadline time.Time
+	if b.Duration > 0 {
+		deadline = start.Add(b.Duration)
+	}
+
+	var next atomic.Int64
+	results := make([]benchWorker, concurrency)
+	var wg sync.WaitGroup
+	for w := range results {
+		wg.Add(1)
+		go func(worker *benchWorker) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := int(next.Add(1) - 1)
+				if (deadline.IsZero() && i >= total) || (!deadline.IsZero() && !clock.Now().Before(deadline)) {
+					return
+				}
+				worker.do(ctx, transport, clock, method, b.URLs[i%len(b.URLs)], b.Header, b.Body)
+			}
+		}(&results[w])
+	}
+	wg.Wait()
+
+	report := &BenchReport{
+		Elapsed:     clock.Now().Sub(start),
+		StatusCodes: map[int]int{},
+		Errors:      map[string]int{},
+		Pool:        transport.Stats(),
+	}
+	var latencies []time.Duration
+	for _, worker := range results {
+		latencies = append(latencies, worker.latencies...)
+		report.Succeeded += worker.succeeded
+		report.BytesReceived += worker.bytes
+		for code, n := range worker.statuses {
+			report.StatusCodes[code] += n
+		}
+		for class, n := range worker.errors {
+			report.Errors[class] += n
+			report.Failed += n
+		}
+	}
+	report.Requests = report.Succeeded + report.Failed
+	if report.Elapsed > 0 {
+		report.Throughput = float64(report.Requests) / report.Elapsed.Seconds()
+	}
+	report.Latency = latencyPercentiles(latencies)
+	return report, nil
+}
+
+// benchWorker holds the results of one Bench goroutine
+type benchWorker struct {
+	latencies []time.Duration
+	succeeded int
+	bytes     int64
+	statuses  map[int]int
+	errors    map[string]int
+}
+
+// do performs one request and records its outcome
+func (w *benchWorker) do(ctx context.Context, transport *Transport, clock Clock, method, rawURL string, header http.Header, body []byte) {
+	if w.statuses == nil {
+		w.statuses = map[int]int{}
+		w.errors = map[string]int{}
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, reader)
+	if err != nil {
+		w.errors["invalid request"]++
+		return
+	}
+	if header != nil {
+		req.Header = header.Clone()
+	}
+
+	start := clock.Now()
+	resp, err := transport.RoundTrip(req)
+	if err == nil {
+		var n int64
+		n, err = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		w.bytes += n
+		w.statuses[resp.StatusCode]++
+	}
+	w.latencies = append(w.latencies, clock.Now().Sub(start))
+
+	switch {
+	case err != nil:
+		w.errors[benchErrorClass(err)]++
+	case resp.StatusCode >= 400:
+		w.errors[fmt.Sprintf("http %dxx", resp.StatusCode/100)]++
+	default:
+		w.succeeded++
+	}
+}
+
+// benchErrorClass groups transport errors into a few classes
+func benchErrorClass(err error) string {
+	var curlErr curl.CurlError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, ErrPoolExhausted):
+		return "pool exhausted"
+	case errors.Is(err, ErrBackendUnavailable):
+		return "backend unavailable"
+	case errors.As(err, &curlErr):
+		switch curlErr {
+		case curl.E_OPERATION_TIMEDOUT:
+			return "timeout"
+		case curl.E_COULDNT_RESOLVE_HOST:
+			return "dns"
+		case curl.E_COULDNT_CONNECT:
+			return "connect"
+		case curl.E_SSL_CONNECT_ERROR:
+			return "tls"
+		case curl.E_PROXY, curl.E_COULDNT_RESOLVE_PROXY:
+			return "proxy"
+		case curl.E_SEND_ERROR, curl.E_RECV_ERROR, curl.E_GOT_NOTHING, curl.E_PARTIAL_FILE:
+			return "connection lost"
+		}
+		return fmt.Sprintf("curl %d", int(curlErr))
+	}
+	return "other"
+}
+
+// latencyPercentiles summarises latencies using the nearest-rank method
+func latencyPercentiles(latencies []time.Duration) LatencyPercentiles {
+	if len(latencies) == 0 {
+		return LatencyPercentiles{}
+	}
+	slices.Sort(latencies)
+	var sum time.Duration
+	for _, d := range latencies {
+		sum += d
+	}
+	rank := func(p int) time.Duration {
+		i := (p*len(latencies)+99)/100 - 1
+		return latencies[max(i, 0)]
+	}
+	return LatencyPercentiles{
+		Min:  latencies[0],
+		Mean: sum / time.Duration(len(latencies)),
+		P50:  rank(50),
+		P90:  rank(90),
+		P99:  rank(99),
+		Max:  latencies[len(latencies)-1],
+	}
+}
+
+// String formats the report for terminals
+func (r *BenchReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "requests:    %d (%d ok, %d failed) in %v\n", r.Requests, r.Succeeded, r.Failed, r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(&b, "throughput:  %.1f req/s, %d body bytes\n", r.Throughput, r.BytesReceived)
+	l := r.Latency
+	fmt.Fprintf(&b, "latency:     min %v  mean %v  p50 %v  p90 %v  p99 %v  max %v\n",
+		l.Min.Round(time.Microsecond), l.Mean.Round(time.Microsecond), l.P50.Round(time.Microsecond),
+		l.P90.Round(time.Microsecond), l.P99.Round(time.Microsecond), l.Max.Round(time.Microsecond))
+
+	codes := make([]int, 0, len(r.StatusCodes))
+	for code := range r.StatusCodes {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	for _, code := range codes {
+		fmt.Fprintf(&b, "status %d:  %d\n", code, r.StatusCodes[code])
+	}
+	classes := make([]string, 0, len(r.Errors))
+	for class := range r.Errors {
+		classes = append(classes, class)
+	}
+	slices.Sort(classes)
+	for _, class := range classes {
+		fmt.Fprintf(&b, "error %s: %d\n", class, r.Errors[class])
+	}
+
+	p := r.Pool
+	fmt.Fprintf(&b, "pool:        %d created, %d destroyed, %d idle of %d, %d blocked acquisitions\n",
+		p.HandlesCreated, p.HandlesDestroyed, p.IdleHandles, p.MaxPoolSize, p.AcquisitionsBlocked)
+	fmt.Fprintf(&b, "connections: %d opened, %d reused\n", p.ConnectionsOpened, p.ConnectionsReused)
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package curlhttp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// TestBenchRun tests request counts, bytes and pool statistics of a run
+func TestBenchRun(t *testing.T) {
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return newFakeEngine("hello") }
+
+	bench := &Bench{
+		Transport:   transport,
+		URLs:        []string{"http://example.com/a", "http://example.com/b"},
+		Concurrency: 4,
+		Requests:    20,
+	}
+	report, err := bench.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Requests != 20 || report.Succeeded != 20 || report.Failed != 0 {
+		t.Errorf("Expected 20 successful requests, got %+v", report)
+	}
+	if report.BytesReceived != 100 || report.StatusCodes[200] != 20 {
+		t.Errorf("Expected 100 bytes and 20 OK responses, got %d, %v", report.BytesReceived, report.StatusCodes)
+	}
+	if report.Pool.HandlesCreated == 0 || report.Pool.HandlesCreated > 4 {
+		t.Errorf("Expected at most 4 handles, got %d", report.Pool.HandlesCreated)
+	}
+	if !strings.Contains(report.String(), "requests:    20 (20 ok, 0 failed)") {
+		t.Errorf("Expected a summary line, got:\n%s", report)
+	}
+
+	if _, err := (&Bench{}).Run(context.Background()); err == nil {
+		t.Errorf("Expected an error without URLs")
+	}
+}
+
+// TestBenchDuration tests runs bounded by time instead of a request count
+func TestBenchDuration(t *testing.T) {
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return newFakeEngine("") }
+
+	report, err := (&Bench{Transport: transport, URLs: []string{"http://example.com/"}, Concurrency: 2, Duration: 50 * time.Millisecond}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Requests == 0 || report.Elapsed < 50*time.Millisecond {
+		t.Errorf("Expected requests for at least 50ms, got %d in %v", report.Requests, report.Elapsed)
+	}
+}
+
+// TestLatencyPercentiles tests nearest-rank percentiles
+func TestLatencyPercentiles(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	p := latencyPercentiles(latencies)
+	if p.Min != time.Millisecond || p.P50 != 50*time.Millisecond || p.P90 != 90*time.Millisecond || p.P99 != 99*time.Millisecond || p.Max != 100*time.Millisecond {
+		t.Errorf("Expected nearest-rank percentiles, got %+v", p)
+	}
+	if p.Mean != 50500*time.Microsecond {
+		t.Errorf("Expected mean 50.5ms, got %v", p.Mean)
+	}
+}
+
+// TestBenchErrorClass tests error classification
+func TestBenchErrorClass(t *testing.T) {
+	for err, want := range map[error]string{
+		context.DeadlineExceeded: "timeout",
+		fmt.Errorf("failed to perform request: %w", curl.CurlError(curl.E_OPERATION_TIMEDOUT)): "timeout",
+		fmt.Errorf("failed to perform request: %w", curl.CurlError(curl.E_COULDNT_CONNECT)):    "connect",
+		ErrPoolExhausted: "pool exhausted",
+		fmt.Errorf("wrapped: %w", curl.CurlError(curl.E_RECV_ERROR)): "connection lost",
+	} {
+		if got := benchErrorClass(err); got != want {
+			t.Errorf("Expected %q for %v, got %q", want, err, got)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package curlhttp
+
+import (
+	"bytes"
+	"sync"
+)
+
+// bufferClasses are the capacities pooled buffers are grouped by. Buffers
+// that grew beyond the largest class are left to the garbage collector so
+// one huge response does not pin memory in the pool.
+var bufferClasses = [...]int{4 << 10, 64 << 10, 1 << 20, 8 << 20}
+
+var bufferPools [len(bufferClasses)]sync.Pool
+
+// getBuffer returns an empty buffer with room for at least sizeHint bytes,
+// or for the smallest class if the size is unknown
+func getBuffer(sizeHint int) *bytes.Buffer {
+	for i, size := range bufferClasses {
+		if sizeHint <= size {
+			if buf, ok := bufferPools[i].Get().(*bytes.Buffer); ok {
+				return buf
+			}
+			return bytes.NewBuffer(make([]byte, 0, size))
+		}
+	}
+	return bytes.NewBuffer(make([]byte, 0, sizeHint))
+}
+
+// putBuffer returns buf to the pool of the largest class it can serve.
+// The caller must not use buf or any slice obtained from it afterwards.
+func putBuffer(buf *bytes.Buffer) {
+	capacity := buf.Cap()
+	if capacity < bufferClasses[0] || capacity > 2*bufferClasses[len(bufferClasses)-1] {
+		return
+	}
+	buf.Reset()
+	for i := len(bufferClasses) - 1; i >= 0; i-- {
+		if capacity >= bufferClasses[i] {
+			bufferPools[i].Put(buf)
+			return
+		}
+	}
+}
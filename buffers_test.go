@@ -0,0 +1,70 @@
+package curlhttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestGetBufferClasses tests that buffers are sized by class
+func TestGetBufferClasses(t *testing.T) {
+	tests := []struct {
+		hint int
+		want int
+	}{
+		{0, 4 << 10},
+		{5000, 64 << 10},
+		{1 << 20, 1 << 20},
+		{16 << 20, 16 << 20},
+	}
+	for _, tt := range tests {
+		if got := getBuffer(tt.hint).Cap(); got < tt.want {
+			t.Errorf("getBuffer(%d): expected capacity of at least %d, got %d", tt.hint, tt.want, got)
+		}
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, 100<<20))
+	putBuffer(buf) // too large to pool; must not panic
+}
+
+// TestResponseBodyClose tests that a closed body rejects reads
+func TestResponseBodyClose(t *testing.T) {
+	buf := getBuffer(0)
+	buf.WriteString("hello")
+	body := newResponseBody(buf.Bytes())
+	body.buf = buf
+
+	if err := body.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := body.Read(make([]byte, 5)); !errors.Is(err, http.ErrBodyReadAfterClose) {
+		t.Errorf("Expected ErrBodyReadAfterClose, got %v", err)
+	}
+	if err := body.Close(); err != nil {
+		t.Errorf("Expected second Close to be a no-op, got %v", err)
+	}
+}
+
+// BenchmarkRoundTripAllocs measures allocations of a POST round trip with
+// a 32KB response through a fake engine
+func BenchmarkRoundTripAllocs(b *testing.B) {
+	fake := newFakeEngine(strings.Repeat("x", 32<<10))
+	transport := newFakeTransport(fake)
+	payload := strings.Repeat("y", 8<<10)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req, _ := http.NewRequest("POST", "http://example.com", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "text/plain")
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			b.Fatalf("RoundTrip failed: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,176 @@
+package curlhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type transferTimeoutKey struct{}
+
+// WithTransferTimeout returns a context that limits the curl transfer of
+// requests carrying it to d, in place of the Transport's TimeoutMs. A
+// Client's Timeout still applies on top.
+func WithTransferTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, transferTimeoutKey{}, d)
+}
+
+// transferTimeout returns the timeout attached to ctx
+func transferTimeout(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(transferTimeoutKey{}).(time.Duration)
+	if !ok || d <= 0 {
+		return 0, false
+	}
+	// curl treats 0 as no timeout, so round sub-millisecond values up
+	return max(d, time.Millisecond), true
+}
+
+// RequestBuilder assembles an *http.Request in a single expression:
+//
+//	req, err := curlhttp.NewRequestBuilder("GET", "https://example.com/api").
+//		Header("Accept", "application/json").
+//		Query("page", "2").
+//		Target("firefox135").
+//		Timeout(5 * time.Second).
+//		Build()
+//
+// The result is an ordinary request for Client.Do or Transport.RoundTrip.
+// Errors from any step are reported by Build.
+type RequestBuilder struct {
+	method  string
+	rawURL  string
+	ctx     context.Context
+	header  http.Header
+	query   url.Values
+	body    []byte
+	hasBody bool
+	target  string
+	timeout time.Duration
+	err     error
+}
+
+// NewRequestBuilder starts a request for method and rawURL
+func NewRequestBuilder(method, rawURL string) *RequestBuilder {
+	return &RequestBuilder{
+		method: method,
+		rawURL: rawURL,
+		header: make(http.Header),
+		query:  make(url.Values),
+	}
+}
+
+// Context sets the request's context. Defaults to context.Background().
+func (b *RequestBuilder) Context(ctx context.Context) *RequestBuilder {
+	b.ctx = ctx
+	return b
+}
+
+// Header adds a header value
+func (b *RequestBuilder) Header(key, value string) *RequestBuilder {
+	b.header.Add(key, value)
+	return b
+}
+
+// Query adds a query parameter to those already in the URL
+func (b *RequestBuilder) Query(key, value string) *RequestBuilder {
+	b.query.Add(key, value)
+	return b
+}
+
+// Body sets the request body, read in full right away so the request can be
+// replayed, and its Content-Type
+func (b *RequestBuilder) Body(contentType string, body io.Reader) *RequestBuilder {
+	data, err := io.ReadAll(body)
+	if err != nil && b.err == nil {
+		b.err = fmt.Errorf("failed to read request body: %w", err)
+	}
+	return b.setBody(contentType, data)
+}
+
+// JSON sets the request body to v encoded as JSON
+func (b *RequestBuilder) JSON(v any) *RequestBuilder {
+	data, err := json.Marshal(v)
+	if err != nil && b.err == nil {
+		b.err = fmt.Errorf("failed to encode JSON body: %w", err)
+	}
+	return b.setBody("application/json", data)
+}
+
+// Form sets the request body to URL-encoded form values
+func (b *RequestBuilder) Form(values url.Values) *RequestBuilder {
+	return b.setBody("application/x-www-form-urlencoded", []byte(values.Encode()))
+}
+
+// OrderedForm sets the request body to URL-encoded form fields in order
+func (b *RequestBuilder) OrderedForm(form OrderedForm) *RequestBuilder {
+	return b.setBody("application/x-www-form-urlencoded", []byte(form.Encode()))
+}
+
+// setBody stores the body and sets Content-Type unless already set
+func (b *RequestBuilder) setBody(contentType string, data []byte) *RequestBuilder {
+	b.body, b.hasBody = data, true
+	if contentType != "" && b.header.Get("Content-Type") == "" {
+		b.header.Set("Content-Type", contentType)
+	}
+	return b
+}
+
+// Target impersonates target for this request instead of the Transport's
+// ImpersonateTarget
+func (b *RequestBuilder) Target(target string) *RequestBuilder {
+	b.target = target
+	return b
+}
+
+// Timeout limits the request's transfer to d; see WithTransferTimeout
+func (b *RequestBuilder) Timeout(d time.Duration) *RequestBuilder {
+	b.timeout = d
+	return b
+}
+
+// Build returns the request, or the first error from building it
+func (b *RequestBuilder) Build() (*http.Request, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	u, err := url.Parse(b.rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+	if len(b.query) > 0 {
+		query := u.Query()
+		for key, values := range b.query {
+			query[key] = append(query[key], values...)
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	ctx := b.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if b.target != "" {
+		ctx = WithTarget(ctx, b.target)
+	}
+	if b.timeout > 0 {
+		ctx = WithTransferTimeout(ctx, b.timeout)
+	}
+
+	var body io.Reader
+	if b.hasBody {
+		body = bytes.NewReader(b.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, b.method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range b.header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	return req, nil
+}
@@ -0,0 +1,103 @@
+package curlhttp
+
+import (
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// TestRequestBuilder tests the request produced by the builder
+func TestRequestBuilder(t *testing.T) {
+	req, err := NewRequestBuilder("POST", "https://example.com/api?a=1").
+		Header("Accept", "application/json").
+		Header("X-Tag", "one").
+		Header("X-Tag", "two").
+		Query("b", "2").
+		Query("a", "3").
+		JSON(map[string]int{"n": 1}).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if req.Method != "POST" {
+		t.Errorf("Expected POST, got %s", req.Method)
+	}
+	if got := req.URL.Query(); got.Get("b") != "2" || len(got["a"]) != 2 {
+		t.Errorf("Expected merged query, got %s", req.URL.RawQuery)
+	}
+	if req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON content type, got %q", req.Header.Get("Content-Type"))
+	}
+	if len(req.Header.Values("X-Tag")) != 2 {
+		t.Errorf("Expected two X-Tag values, got %v", req.Header.Values("X-Tag"))
+	}
+	if req.ContentLength != 7 || req.GetBody == nil {
+		t.Errorf("Expected a replayable 7 byte body, got %d", req.ContentLength)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != `{"n":1}` {
+		t.Errorf("Expected JSON body, got %s", body)
+	}
+}
+
+// TestRequestBuilderErrors tests that errors surface from Build
+func TestRequestBuilderErrors(t *testing.T) {
+	if _, err := NewRequestBuilder("POST", "https://example.com").JSON(func() {}).Build(); err == nil {
+		t.Error("Expected an error for an unencodable body")
+	}
+	if _, err := NewRequestBuilder("GET", "://bad").Build(); err == nil {
+		t.Error("Expected an error for an invalid URL")
+	}
+
+	req, err := NewRequestBuilder("POST", "https://example.com").
+		Header("Content-Type", "application/x-custom").
+		Form(url.Values{"q": {"go"}}).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if req.Header.Get("Content-Type") != "application/x-custom" {
+		t.Errorf("Expected explicit Content-Type to be kept, got %q", req.Header.Get("Content-Type"))
+	}
+}
+
+// TestRequestBuilderTargetAndTimeout tests per-request targets and timeouts
+func TestRequestBuilderTargetAndTimeout(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+
+	req, err := NewRequestBuilder("GET", "https://example.com").
+		Target("firefox135").
+		Timeout(1500 * time.Millisecond).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if timeout := fake.performed[curl.OPT_TIMEOUT_MS]; timeout != 1500 {
+		t.Errorf("Expected a 1500ms timeout, got %v", timeout)
+	}
+	if _, ok := sentHeaders(fake)["Sec-Ch-Ua"]; ok {
+		t.Error("Expected no client hints when impersonating firefox")
+	}
+	// The handle goes back to the pool with the Transport's own target
+	if fake.target != "chrome136" {
+		t.Errorf("Expected pooled handle to impersonate chrome136, got %s", fake.target)
+	}
+
+	req, _ = NewRequestBuilder("GET", "https://example.com").Build()
+	resp, _ = transport.RoundTrip(req)
+	resp.Body.Close()
+	if timeout := fake.performed[curl.OPT_TIMEOUT_MS]; timeout != transport.TimeoutMs {
+		t.Errorf("Expected the default timeout, got %v", timeout)
+	}
+}
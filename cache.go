@@ -0,0 +1,273 @@
+package curlhttp
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResponseCache keeps recent successful GET responses in memory and
+// serves repeated identical requests from it until they expire. It is a
+// plain keyed LRU for API clients, not an HTTP cache: Cache-Control and
+// validators are ignored and freshness comes from TTL and Routes only.
+// Install it with Transport.Use(c.Middleware()).
+//
+// Requests are identical under the same rules as Singleflight. Only 2xx
+// responses other than 206 are stored, and a successful request with an
+// unsafe method (POST, PUT, PATCH, DELETE) invalidates its URL. Cached
+// responses carry a NoteCached note and an Age header.
+type ResponseCache struct {
+	// MaxEntries caps the number of stored responses; the least recently
+	// used one is evicted first. Defaults to 1000.
+	MaxEntries int
+
+	// MaxEntrySize caps the body size of a stored response. Larger
+	// responses are passed through uncached. Defaults to 1 MiB.
+	MaxEntrySize int64
+
+	// TTL is how long responses stay fresh unless a route says otherwise.
+	// Defaults to 1 minute.
+	TTL time.Duration
+
+	// Routes override TTL for matching requests; the first match wins
+	Routes []CacheRoute
+
+	// Key, if set, replaces the default request key. An empty key
+	// bypasses the cache for the request.
+	Key func(*http.Request) string
+
+	// KeyFunc, if set, names the resource in the default key and for
+	// invalidation in place of the normalized URL
+	KeyFunc KeyFunc
+
+	// Clock times expiry. Defaults to SystemClock.
+	Clock Clock
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List // of *cacheEntry, most recently used first
+}
+
+// CacheRoute sets the TTL of requests to a host and path prefix
+type CacheRoute struct {
+	// Host matches like URLAllowlist.Hosts; empty matches any host
+	Host string
+
+	// PathPrefix matches the start of the URL path; empty matches any path
+	PathPrefix string
+
+	// TTL is how long matching responses stay fresh. Zero or negative
+	// disables caching for the route.
+	TTL time.Duration
+}
+
+// cacheEntry is a stored response
+type cacheEntry struct {
+	key     string
+	url     string // resource key of the request, for Invalidate
+	resp    *flightCall
+	stored  time.Time
+	expires time.Time
+}
+
+// Middleware returns middleware that answers from and fills the cache
+func (c *ResponseCache) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if isUnsafeMethod(req.Method) {
+				resp, err := next.RoundTrip(req)
+				if err == nil && resp.StatusCode < 400 {
+					c.invalidate(keyOrDefault(c.KeyFunc, req))
+				}
+				return resp, err
+			}
+
+			key := c.key(req)
+			ttl := c.ttl(req)
+			if key == "" || ttl <= 0 {
+				return next.RoundTrip(req)
+			}
+			clock := clockOrSystem(c.Clock)
+			if resp := c.lookup(key, req, clock.Now()); resp != nil {
+				return resp, nil
+			}
+
+			resp, err := next.RoundTrip(req)
+			if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 || resp.StatusCode == http.StatusPartialContent {
+				return resp, err
+			}
+			maxSize := c.MaxEntrySize
+			if maxSize <= 0 {
+				maxSize = 1 << 20
+			}
+			if resp.ContentLength > maxSize {
+				return resp, nil
+			}
+			body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+			if err != nil {
+				resp.Body.Close()
+				return nil, fmt.Errorf("failed to read response body for cache: %w", err)
+			}
+			if int64(len(body)) > maxSize {
+				// Too large to store: hand back what was read plus the rest
+				resp.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+				return resp, nil
+			}
+			resp.Body.Close()
+
+			call := &flightCall{resp: resp, body: body}
+			now := clock.Now()
+			c.store(&cacheEntry{key: key, url: keyOrDefault(c.KeyFunc, req), resp: call, stored: now, expires: now.Add(ttl)})
+			return call.response(req, false), nil
+		})
+	}
+}
+
+// key returns the cache key for req, or "" if it must not be cached
+func (c *ResponseCache) key(req *http.Request) string {
+	if c.Key != nil {
+		return c.Key(req)
+	}
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return ""
+	}
+	return requestKey(req, c.KeyFunc)
+}
+
+// ttl returns how long the response to req stays fresh
+func (c *ResponseCache) ttl(req *http.Request) time.Duration {
+	host := strings.ToLower(strings.TrimSuffix(req.URL.Hostname(), "."))
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	for _, route := range c.Routes {
+		if (route.Host == "" || hostMatches(route.Host, host)) && strings.HasPrefix(path, route.PathPrefix) {
+			return route.TTL
+		}
+	}
+	if c.TTL <= 0 {
+		return time.Minute
+	}
+	return c.TTL
+}
+
+// lookup returns a copy of the fresh response stored under key, if any
+func (c *ResponseCache) lookup(key string, req *http.Request, now time.Time) *http.Response {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem := c.entries[key]
+	if elem == nil {
+		return nil
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !now.Before(entry.expires) {
+		c.remove(elem)
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+
+	resp := entry.resp.response(req, false)
+	resp.Header.Set("Age", strconv.Itoa(int(now.Sub(entry.stored)/time.Second)))
+	if m := metaOf(resp); m != nil {
+		m.addNote(NoteCached, "response served from the in-memory cache")
+	}
+	return resp
+}
+
+// store adds entry, replacing any entry with the same key and evicting
+// the least recently used entries beyond MaxEntries
+func (c *ResponseCache) store(entry *cacheEntry) {
+	maxEntries := c.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+	if elem := c.entries[entry.key]; elem != nil {
+		c.remove(elem)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops a stored entry; c.mu must be held
+func (c *ResponseCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+// Invalidate removes every stored response for rawURL, whatever the
+// headers it was requested with. URLs are compared by KeyFunc.
+func (c *ResponseCache) Invalidate(rawURL string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+	req := &http.Request{Method: http.MethodGet, URL: u, Host: u.Host, Header: make(http.Header)}
+	c.invalidate(keyOrDefault(c.KeyFunc, req))
+}
+
+// invalidate removes every stored response for a resource key
+func (c *ResponseCache) invalidate(target string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, elem := range c.entries {
+		if elem.Value.(*cacheEntry).url == target {
+			c.remove(elem)
+		}
+	}
+}
+
+// InvalidatePrefix removes every stored response whose resource key
+// starts with prefix, such as "https://api.example.com/users/"
+func (c *ResponseCache) InvalidatePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, elem := range c.entries {
+		if strings.HasPrefix(elem.Value.(*cacheEntry).url, prefix) {
+			c.remove(elem)
+		}
+	}
+}
+
+// Purge removes every stored response
+func (c *ResponseCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+	c.lru.Init()
+}
+
+// Len returns the number of stored responses, including expired ones
+// not yet evicted
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// isUnsafeMethod reports whether method may change server state
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package curlhttp
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingResponses answers every request with the number of requests seen
+func countingResponses(calls *int) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		*calls++
+		return stubResponse(strconv.Itoa(*calls)).RoundTrip(req)
+	})
+}
+
+// cacheGet performs a GET through rt and returns the body
+func cacheGet(t *testing.T, rt http.RoundTripper, rawURL string) (string, *http.Response) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", rawURL, nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return string(data), resp
+}
+
+// TestResponseCacheTTL tests that responses are reused until they expire
+func TestResponseCacheTTL(t *testing.T) {
+	calls := 0
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	cache := &ResponseCache{TTL: time.Minute, Clock: clock}
+	rt := Chain(countingResponses(&calls), cache.Middleware())
+
+	if body, resp := cacheGet(t, rt, "http://example.com/a?y=2&x=1"); body != "1" || HasNote(resp, NoteCached) {
+		t.Errorf("Expected fresh response 1, got %q (cached %v)", body, HasNote(resp, NoteCached))
+	}
+	clock.Advance(30 * time.Second)
+	body, resp := cacheGet(t, rt, "http://EXAMPLE.com:80/a?x=1&y=2")
+	if body != "1" || !HasNote(resp, NoteCached) {
+		t.Errorf("Expected cached response 1, got %q (cached %v)", body, HasNote(resp, NoteCached))
+	}
+	if age := resp.Header.Get("Age"); age != "30" {
+		t.Errorf("Expected Age 30, got %q", age)
+	}
+
+	clock.Advance(30 * time.Second)
+	if body, _ := cacheGet(t, rt, "http://example.com/a?x=1&y=2"); body != "2" {
+		t.Errorf("Expected expired entry to be refetched, got %q", body)
+	}
+}
+
+// TestResponseCacheRoutes tests per-route TTLs and uncached routes
+func TestResponseCacheRoutes(t *testing.T) {
+	calls := 0
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	cache := &ResponseCache{
+		TTL:   time.Minute,
+		Clock: clock,
+		Routes: []CacheRoute{
+			{Host: "api.example.com", PathPrefix: "/live/", TTL: 0},
+			{Host: "*.example.com", PathPrefix: "/static/", TTL: time.Hour},
+		},
+	}
+	rt := Chain(countingResponses(&calls), cache.Middleware())
+
+	cacheGet(t, rt, "http://api.example.com/live/feed")
+	if body, _ := cacheGet(t, rt, "http://api.example.com/live/feed"); body != "2" {
+		t.Errorf("Expected uncached route to be refetched, got %q", body)
+	}
+
+	cacheGet(t, rt, "http://cdn.example.com/static/app.js")
+	clock.Advance(10 * time.Minute)
+	if body, _ := cacheGet(t, rt, "http://cdn.example.com/static/app.js"); body != "3" {
+		t.Errorf("Expected long-lived route to stay cached, got %q", body)
+	}
+}
+
+// TestResponseCacheLRU tests that the least recently used entry is evicted
+func TestResponseCacheLRU(t *testing.T) {
+	calls := 0
+	cache := &ResponseCache{MaxEntries: 2}
+	rt := Chain(countingResponses(&calls), cache.Middleware())
+
+	cacheGet(t, rt, "http://example.com/a")
+	cacheGet(t, rt, "http://example.com/b")
+	cacheGet(t, rt, "http://example.com/a") // a is now most recently used
+	cacheGet(t, rt, "http://example.com/c") // evicts b
+
+	if n := cache.Len(); n != 2 {
+		t.Errorf("Expected 2 entries, got %d", n)
+	}
+	if body, _ := cacheGet(t, rt, "http://example.com/a"); body != "1" {
+		t.Errorf("Expected a to stay cached, got %q", body)
+	}
+	if body, _ := cacheGet(t, rt, "http://example.com/b"); body != "4" {
+		t.Errorf("Expected b to be evicted and refetched, got %q", body)
+	}
+}
+
+// TestResponseCacheInvalidate tests manual and automatic invalidation
+func TestResponseCacheInvalidate(t *testing.T) {
+	calls := 0
+	cache := &ResponseCache{}
+	rt := Chain(countingResponses(&calls), cache.Middleware())
+
+	cacheGet(t, rt, "http://example.com/users/1")
+	cacheGet(t, rt, "http://example.com/users/2")
+	cacheGet(t, rt, "http://example.com/items/1")
+
+	cache.Invalidate("http://EXAMPLE.com/users/1")
+	if body, _ := cacheGet(t, rt, "http://example.com/users/1"); body != "4" {
+		t.Errorf("Expected invalidated entry to be refetched, got %q", body)
+	}
+
+	cache.InvalidatePrefix("http://example.com/users/")
+	if n := cache.Len(); n != 1 {
+		t.Errorf("Expected 1 entry after InvalidatePrefix, got %d", n)
+	}
+
+	req, _ := http.NewRequest("DELETE", "http://example.com/items/1", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+	if n := cache.Len(); n != 0 {
+		t.Errorf("Expected DELETE to invalidate the URL, got %d entries", n)
+	}
+
+	cacheGet(t, rt, "http://example.com/items/1")
+	cache.Purge()
+	if n := cache.Len(); n != 0 {
+		t.Errorf("Expected empty cache after Purge, got %d entries", n)
+	}
+}
+
+// TestResponseCacheSkips tests that errors, ranges and large bodies are not stored
+func TestResponseCacheSkips(t *testing.T) {
+	status := http.StatusNotFound
+	body := "missing"
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    status,
+			Header:        make(http.Header),
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: -1,
+			Request:       req,
+		}, nil
+	})
+	cache := &ResponseCache{MaxEntrySize: 8}
+	rt := Chain(next, cache.Middleware())
+
+	cacheGet(t, rt, "http://example.com/missing")
+	if n := cache.Len(); n != 0 {
+		t.Errorf("Expected 404 not to be cached, got %d entries", n)
+	}
+
+	status, body = http.StatusOK, strings.Repeat("x", 20)
+	if got, _ := cacheGet(t, rt, "http://example.com/large"); got != body {
+		t.Errorf("Expected large body to pass through intact, got %q", got)
+	}
+	if n := cache.Len(); n != 0 {
+		t.Errorf("Expected large body not to be cached, got %d entries", n)
+	}
+
+	body = "small"
+	req, _ := http.NewRequest("GET", "http://example.com/range", nil)
+	req.Header.Set("Range", "bytes=0-1")
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+	if n := cache.Len(); n != 0 {
+		t.Errorf("Expected range request not to be cached, got %d entries", n)
+	}
+}
@@ -0,0 +1,26 @@
+package curlhttp
+
+import (
+	"context"
+	"net/http"
+)
+
+// legacyCancel returns req with a context that is also done once
+// req.Cancel, the deprecated cancellation channel older libraries still
+// set, is closed, so Cancel aborts waits and transfers as the context
+// does. stop releases the watcher once the request is over. Requests
+// without Cancel are returned unchanged.
+func legacyCancel(req *http.Request) (_ *http.Request, stop func()) {
+	if req.Cancel == nil {
+		return req, func() {}
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	go func() {
+		select {
+		case <-req.Cancel:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return req.WithContext(ctx), cancel
+}
@@ -0,0 +1,73 @@
+package curlhttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestRequestCancelWaiting tests that closing Request.Cancel ends a wait for a slot
+func TestRequestCancelWaiting(t *testing.T) {
+	transport := newFakeTransport(newFakeEngine("ok"))
+	transport.MaxConcurrentRequests = 1
+	release, err := transport.acquireRequestSlot(limitRequest(t, "http://example.com/", time.Second))
+	if err != nil {
+		t.Fatalf("Expected slot, got %v", err)
+	}
+	defer release()
+
+	cancel := make(chan struct{})
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	req.Cancel = cancel
+	errs := make(chan error)
+	go func() {
+		_, err := transport.RoundTrip(req)
+		errs <- err
+	}()
+	close(cancel)
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected RoundTrip to return after Cancel was closed")
+	}
+}
+
+// TestRequestCancelStream tests that closing Request.Cancel aborts a streamed transfer
+func TestRequestCancelStream(t *testing.T) {
+	engine := newChunkedEngine("a", "b", "c")
+	transport := NewTransport()
+	transport.StreamResponses = true
+	transport.newEngine = func() curlEngine { return engine }
+
+	cancel := make(chan struct{})
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	req.Cancel = cancel
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	buf := make([]byte, 1)
+	if _, err := resp.Body.Read(buf); err != nil {
+		t.Fatalf("Failed to read first chunk: %v", err)
+	}
+	close(cancel)
+
+	select {
+	case <-resp.Body.(*streamBody).done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected transfer to end after Cancel was closed")
+	}
+	if !engine.aborted {
+		t.Error("Expected transfer to be aborted")
+	}
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Error("Expected reading the rest of the body to fail")
+	}
+}
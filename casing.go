@@ -0,0 +1,67 @@
+package curlhttp
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HeaderCasing controls the case of request header names on the wire.
+// HTTP/2 and HTTP/3 send every name in lowercase, so it only matters for
+// HTTP/1.1 connections.
+type HeaderCasing int
+
+const (
+	// HeaderCasingPreserve sends names as they are keyed in Request.Header:
+	// canonical for headers set with Set or Add, and exactly as written for
+	// keys assigned directly, as in req.Header["x-trace-id"]. This is the
+	// default.
+	HeaderCasingPreserve HeaderCasing = iota
+
+	// HeaderCasingBrowser sends canonical names the way the impersonated
+	// browser writes them, such as Chrome's lowercase sec-ch-ua hints and
+	// DNT and TE in capitals. Names written directly into Request.Header
+	// keep their casing.
+	HeaderCasingBrowser
+)
+
+// browserHeaderNames holds the names browsers write differently from Go's
+// canonical form
+var browserHeaderNames = map[string]string{
+	"Dnt": "DNT",
+	"Te":  "TE",
+}
+
+// originalCasing returns the names of h that are not in canonical form,
+// keyed by their canonical form
+func originalCasing(h http.Header) map[string]string {
+	var names map[string]string
+	for name := range h {
+		canonical := http.CanonicalHeaderKey(name)
+		if canonical == name {
+			continue
+		}
+		if names == nil {
+			names = make(map[string]string)
+		}
+		names[canonical] = name
+	}
+	return names
+}
+
+// wireHeaderName returns the name to send for the canonical header name,
+// given the caller's casing and the impersonation target
+func (t *Transport) wireHeaderName(name string, original map[string]string, target string) string {
+	if written, ok := original[name]; ok {
+		return written
+	}
+	if t.HeaderCasing != HeaderCasingBrowser {
+		return name
+	}
+	if browserFamily(target) == "chrome" && strings.HasPrefix(name, "Sec-Ch-") {
+		return strings.ToLower(name)
+	}
+	if browser, ok := browserHeaderNames[name]; ok {
+		return browser
+	}
+	return name
+}
@@ -0,0 +1,81 @@
+package curlhttp
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// sentHeaderNames returns the names of the header lines passed to curl
+func sentHeaderNames(fake *fakeEngine) []string {
+	lines, _ := fake.performed[curl.OPT_HTTPHEADER].([]string)
+	names := make([]string, 0, len(lines))
+	for _, line := range lines {
+		name, _, _ := strings.Cut(line, ":")
+		names = append(names, name)
+	}
+	return names
+}
+
+// TestHeaderCasingPreserve tests that names keyed directly keep their casing
+func TestHeaderCasingPreserve(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+	transport.UseDefaultHeaders = false
+
+	req, _ := http.NewRequestWithContext(WithNavigation(context.Background(), Navigation{Type: ResourceFetch}), "GET", "http://example.com", nil)
+	req.Header["x-trace-id"] = []string{"1"}
+	req.Header["accept"] = []string{"application/json"}
+	req.Header.Set("X-Custom", "v")
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+
+	names := sentHeaderNames(fake)
+	for _, want := range []string{"x-trace-id", "accept", "X-Custom"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("Expected %s to be sent as written, got %v", want, names)
+		}
+	}
+	if slices.Contains(names, "Accept") {
+		t.Errorf("Expected the request's accept to replace the navigation Accept, got %v", names)
+	}
+	if sentHeaders(fake)["accept"] != "application/json" {
+		t.Errorf("Expected the caller's accept value, got %v", sentHeaders(fake))
+	}
+}
+
+// TestHeaderCasingBrowser tests the impersonated browser's casing
+func TestHeaderCasingBrowser(t *testing.T) {
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"chrome136", []string{"sec-ch-ua", "sec-ch-ua-mobile", "sec-ch-ua-platform", "DNT", "x-lower", "User-Agent"}},
+		{"firefox135", []string{"DNT", "TE", "x-lower"}},
+	}
+	for _, tt := range tests {
+		fake := newFakeEngine("")
+		transport := newFakeTransport(fake)
+		transport.ImpersonateTarget = tt.target
+		transport.HeaderCasing = HeaderCasingBrowser
+
+		req, _ := http.NewRequest("GET", "https://example.com", nil)
+		req.Header.Set("Dnt", "1")
+		req.Header.Set("Te", "trailers")
+		req.Header["x-lower"] = []string{"1"}
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatalf("%s: RoundTrip failed: %v", tt.target, err)
+		}
+		names := sentHeaderNames(fake)
+		for _, want := range tt.want {
+			if !slices.Contains(names, want) {
+				t.Errorf("%s: Expected %s to be sent, got %v", tt.target, want, names)
+			}
+		}
+	}
+}
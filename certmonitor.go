@@ -0,0 +1,189 @@
+package curlhttp
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// CertEventKind identifies why a CertEvent was fired.
+type CertEventKind int
+
+const (
+	// CertExpiringSoon fires when a host's certificate expires within the
+	// monitor's ExpiryWindow. It fires once per certificate.
+	CertExpiringSoon CertEventKind = iota + 1
+
+	// CertIssuerChanged fires when a host presents a certificate from a
+	// different issuer than the last time it was seen.
+	CertIssuerChanged
+)
+
+// String returns a short name for the event kind
+func (k CertEventKind) String() string {
+	switch k {
+	case CertExpiringSoon:
+		return "expiring-soon"
+	case CertIssuerChanged:
+		return "issuer-changed"
+	default:
+		return "unknown"
+	}
+}
+
+// CertEvent describes a noteworthy change in a host's leaf certificate
+type CertEvent struct {
+	Kind           CertEventKind
+	Host           string
+	Subject        string
+	Issuer         string
+	PreviousIssuer string
+	NotAfter       time.Time
+}
+
+// CertMonitor watches the certificates of visited hosts and calls OnEvent
+// when one is close to expiry or its issuer changes. Assign it to
+// Transport.CertMonitor to enable certificate collection.
+//
+// Certificate details are only available when a new TLS connection is made,
+// so reused connections do not produce observations.
+type CertMonitor struct {
+	// ExpiryWindow is how long before expiry CertExpiringSoon fires.
+	// Defaults to 14 days.
+	ExpiryWindow time.Duration
+
+	// OnEvent is called synchronously from the request goroutine.
+	OnEvent func(CertEvent)
+
+	// Clock decides when a certificate counts as expiring. Defaults to
+	// SystemClock.
+	Clock Clock
+
+	mu    sync.Mutex
+	hosts map[string]*certState
+}
+
+// certState is what the monitor remembers about a host
+type certState struct {
+	issuer string
+	expiry time.Time
+	warned bool
+}
+
+// certInfo is the subset of curl's CERTINFO data the monitor uses
+type certInfo struct {
+	Subject  string
+	Issuer   string
+	NotAfter time.Time
+}
+
+// certDateLayouts are the formats different TLS backends use for CERTINFO dates
+var certDateLayouts = []string{
+	"Jan _2 15:04:05 2006 MST",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+}
+
+// parseCertInfo extracts the leaf certificate from curl CERTINFO data.
+// Each entry has the form "Name:value", for example "Expire date:...".
+// Only the first certificate in the chain (the leaf) is returned.
+func parseCertInfo(raw interface{}) (certInfo, bool) {
+	var entries []string
+	switch v := raw.(type) {
+	case []string:
+		entries = v
+	case [][]string:
+		if len(v) == 0 {
+			return certInfo{}, false
+		}
+		entries = v[0]
+	default:
+		return certInfo{}, false
+	}
+
+	var info certInfo
+	found := false
+	for _, entry := range entries {
+		name, value, ok := strings.Cut(entry, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "subject":
+			if info.Subject != "" {
+				// Start of the next certificate in the chain
+				return info, found
+			}
+			info.Subject, found = value, true
+		case "issuer":
+			if info.Issuer == "" {
+				info.Issuer, found = value, true
+			}
+		case "expire date":
+			if info.NotAfter.IsZero() {
+				for _, layout := range certDateLayouts {
+					if t, err := time.Parse(layout, value); err == nil {
+						info.NotAfter, found = t, true
+						break
+					}
+				}
+			}
+		}
+	}
+	return info, found
+}
+
+// observe records the certificate seen for host and fires events
+func (m *CertMonitor) observe(host string, info certInfo) {
+	now := clockOrSystem(m.Clock).Now
+	window := m.ExpiryWindow
+	if window == 0 {
+		window = 14 * 24 * time.Hour
+	}
+
+	var events []CertEvent
+	m.mu.Lock()
+	if m.hosts == nil {
+		m.hosts = make(map[string]*certState)
+	}
+	state, seen := m.hosts[host]
+	if !seen {
+		state = &certState{}
+		m.hosts[host] = state
+	}
+	if seen && info.Issuer != "" && state.issuer != "" && info.Issuer != state.issuer {
+		events = append(events, CertEvent{
+			Kind:           CertIssuerChanged,
+			Host:           host,
+			Subject:        info.Subject,
+			Issuer:         info.Issuer,
+			PreviousIssuer: state.issuer,
+			NotAfter:       info.NotAfter,
+		})
+	}
+	if !info.NotAfter.Equal(state.expiry) {
+		state.expiry = info.NotAfter
+		state.warned = false
+	}
+	if !info.NotAfter.IsZero() && !state.warned && info.NotAfter.Sub(now()) < window {
+		state.warned = true
+		events = append(events, CertEvent{
+			Kind:     CertExpiringSoon,
+			Host:     host,
+			Subject:  info.Subject,
+			Issuer:   info.Issuer,
+			NotAfter: info.NotAfter,
+		})
+	}
+	if info.Issuer != "" {
+		state.issuer = info.Issuer
+	}
+	m.mu.Unlock()
+
+	if m.OnEvent != nil {
+		for _, event := range events {
+			m.OnEvent(event)
+		}
+	}
+}
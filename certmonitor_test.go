@@ -0,0 +1,93 @@
+package curlhttp
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// TestParseCertInfo tests extraction of the leaf certificate from CERTINFO data
+func TestParseCertInfo(t *testing.T) {
+	raw := []string{
+		"Subject:CN=example.com",
+		"Issuer:CN=Example CA",
+		"Expire date:Mar  5 12:00:00 2027 GMT",
+		"Subject:CN=Example CA",
+		"Issuer:CN=Example Root",
+		"Expire date:Jan  1 00:00:00 2030 GMT",
+	}
+
+	info, ok := parseCertInfo(raw)
+	if !ok {
+		t.Fatal("Expected certificate info to be parsed")
+	}
+	if info.Subject != "CN=example.com" || info.Issuer != "CN=Example CA" {
+		t.Errorf("Unexpected leaf certificate: %+v", info)
+	}
+	want := time.Date(2027, time.March, 5, 12, 0, 0, 0, time.UTC)
+	if !info.NotAfter.Equal(want) {
+		t.Errorf("Expected NotAfter %v, got %v", want, info.NotAfter)
+	}
+
+	if _, ok := parseCertInfo(nil); ok {
+		t.Error("Expected nil CERTINFO to be rejected")
+	}
+}
+
+// TestCertMonitorEvents tests expiry and issuer change events
+func TestCertMonitorEvents(t *testing.T) {
+	now := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var events []CertEvent
+	monitor := &CertMonitor{
+		ExpiryWindow: 7 * 24 * time.Hour,
+		OnEvent:      func(e CertEvent) { events = append(events, e) },
+		Clock:        NewFakeClock(now),
+	}
+
+	monitor.observe("example.com", certInfo{Issuer: "CA 1", NotAfter: now.Add(30 * 24 * time.Hour)})
+	if len(events) != 0 {
+		t.Fatalf("Expected no events for healthy certificate, got %v", events)
+	}
+
+	monitor.observe("example.com", certInfo{Issuer: "CA 2", NotAfter: now.Add(3 * 24 * time.Hour)})
+	if len(events) != 2 {
+		t.Fatalf("Expected issuer change and expiry events, got %v", events)
+	}
+	if events[0].Kind != CertIssuerChanged || events[0].PreviousIssuer != "CA 1" {
+		t.Errorf("Unexpected issuer event: %+v", events[0])
+	}
+	if events[1].Kind != CertExpiringSoon {
+		t.Errorf("Unexpected expiry event: %+v", events[1])
+	}
+
+	// The same expiring certificate is only reported once
+	monitor.observe("example.com", certInfo{Issuer: "CA 2", NotAfter: now.Add(3 * 24 * time.Hour)})
+	if len(events) != 2 {
+		t.Errorf("Expected no duplicate events, got %v", events)
+	}
+}
+
+// TestCertMonitorEnablesCertInfo tests that the transport requests CERTINFO for HTTPS only
+func TestCertMonitorEnablesCertInfo(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+	transport.CertMonitor = &CertMonitor{}
+
+	req, _ := http.NewRequest("GET", "https://example.com", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if fake.performed[curl.OPT_CERTINFO] != true {
+		t.Error("Expected OPT_CERTINFO to be enabled for HTTPS request")
+	}
+
+	req, _ = http.NewRequest("GET", "http://example.com", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if fake.performed[curl.OPT_CERTINFO] == true {
+		t.Error("Expected OPT_CERTINFO to stay off for plain HTTP")
+	}
+}
@@ -17,13 +17,21 @@ package curlhttp
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
 	"net/url"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	curl "github.com/BridgeSenseDev/go-curl-impersonate"
@@ -201,13 +209,20 @@ var (
 	ListenAndServeTLS     = http.ListenAndServeTLS
 )
 
-var globalInitOnce sync.Once
+var (
+	globalInitOnce sync.Once
+	globalInitErr  error
+)
 
-// initCurl ensures curl is globally initialized
-func initCurl() {
+// initCurl ensures curl is globally initialized and returns the
+// initialization error, if any
+func initCurl() error {
 	globalInitOnce.Do(func() {
-		curl.GlobalInit(curl.GLOBAL_ALL)
+		if err := curl.GlobalInit(curl.GLOBAL_ALL); err != nil {
+			globalInitErr = fmt.Errorf("%w: curl global init failed: %w", ErrBackendUnavailable, err)
+		}
 	})
+	return globalInitErr
 }
 
 // responseBuffer is a thread-safe buffer for collecting response data in memory
@@ -216,6 +231,14 @@ type responseBuffer struct {
 	mu     sync.Mutex
 }
 
+// newResponseBuffer creates an empty response buffer backed by pooled
+// storage; the storage is released when the response body is closed
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{
+		buffer: getBuffer(0),
+	}
+}
+
 // Write implements io.Writer for thread-safe writing
 func (rb *responseBuffer) Write(p []byte) (int, error) {
 	rb.mu.Lock()
@@ -247,29 +270,23 @@ func writeDataToBuffer(ptr []byte, userdata interface{}) bool {
 	return err == nil
 }
 
-// writeHeaderToMap is the callback function for writing header data to a map
-func writeHeaderToMap(data []byte, userdata interface{}) bool {
-	headerMap, ok := userdata.(http.Header)
+// writeHeaderToParser is the callback function for feeding header lines to a headerParser
+func writeHeaderToParser(data []byte, userdata interface{}) bool {
+	parser, ok := userdata.(*headerParser)
 	if !ok {
 		return false
 	}
-	line := string(data)
-
-	line = strings.TrimSpace(line)
-	if line == "" {
-		return true
-	}
-
-	if strings.HasPrefix(line, "HTTP/") {
-		return true
-	}
-	parts := strings.SplitN(line, ":", 2)
-	if len(parts) == 2 {
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		headerMap.Add(key, value)
+	if !parser.started {
+		parser.started = true
+		if parser.onStart != nil {
+			if err := parser.onStart(); err != nil {
+				parser.err = err
+				return false
+			}
+		}
 	}
-	return true
+	parser.parseLine(data)
+	return parser.err == nil
 }
 
 // Transport implements http.RoundTripper interface using go-curl-impersonate.
@@ -279,19 +296,243 @@ func writeHeaderToMap(data []byte, userdata interface{}) bool {
 type Transport struct {
 	// ImpersonateTarget specifies which browser to impersonate (e.g., "chrome136").
 	// Supported targets: chrome136, firefox102, safari17_0, edge122
+	// Aliases such as "chrome", "firefox", "safari" or "chrome_android"
+	// resolve to the newest target the loaded library supports.
 	ImpersonateTarget string
 
 	// Proxy specifies a function to return a proxy for a given
 	// Request. a verbatim copy from the net/http.Transport struct definition
 	Proxy *url.URL
 
+	// ProxyTLS configures TLS to https:// proxies, separately from the
+	// origin. When nil, proxy certificates are not verified, like origin
+	// certificates.
+	ProxyTLS *ProxyTLSConfig
+
+	// ProxyConnectHeader optionally specifies headers to send to proxies
+	// during CONNECT requests, such as Proxy-Authorization or the session
+	// headers of commercial proxy providers. Proxies that forward http://
+	// URLs without CONNECT receive them with the request instead; origins
+	// never see them. To set the header dynamically, see
+	// GetProxyConnectHeader.
+	ProxyConnectHeader http.Header
+
+	// GetProxyConnectHeader optionally specifies a func to return headers
+	// to send to proxyURL during a CONNECT request to the host:port target.
+	// If it returns an error, the request fails with that error. If it
+	// returns (nil, nil), no headers are added. If GetProxyConnectHeader
+	// is non-nil, ProxyConnectHeader is ignored.
+	GetProxyConnectHeader func(ctx context.Context, proxyURL *url.URL, target string) (http.Header, error)
+
 	// UseDefaultHeaders whether to use default headers for the impersonated browser.
 	UseDefaultHeaders bool
 
+	// MmapResponses writes response bodies to a temporary file and serves
+	// them from a read-only memory mapping instead of the heap. Use it for
+	// very large payloads that are read randomly; see BodyReaderAt.
+	// MmapTempDir selects the directory for the temporary files.
+	MmapResponses bool
+	MmapTempDir   string
+
+	// CertMonitor, if set, is notified about the certificates of visited
+	// HTTPS hosts so expiring or re-issued certificates can be reported.
+	CertMonitor *CertMonitor
+
+	// ServerFingerprints, if set, records the TLS fingerprint (JA3S/JA4S)
+	// of HTTPS hosts, probing in the background when a new connection is
+	// opened to a host. The probe dials the host itself with the net
+	// package, so it is skipped for transfers made through a proxy, a
+	// bound device or network namespace, or addresses pinned with Resolve,
+	// which it would bypass.
+	ServerFingerprints *FingerprintTracker
+
+	// BindDevice binds all sockets to a network device (SO_BINDTODEVICE),
+	// e.g. a WireGuard interface or a VRF device. Linux only; binding to a
+	// device usually requires CAP_NET_RAW.
+	BindDevice string
+
+	// NetworkNamespace is the path of a network namespace (for example
+	// /var/run/netns/blue) that requests are performed in, so routing
+	// policy can differ per client. Linux only; requires CAP_SYS_ADMIN.
+	NetworkNamespace string
+
+	// SocketControl, if set, is a post-connect hook called with each
+	// connection curl opens. Unlike net.Dialer.Control it does not run
+	// before connecting: the binding has no CURLOPT_SOCKOPTFUNCTION or
+	// CURLOPT_OPENSOCKETFUNCTION, so it runs once the first response header
+	// arrives, after the connect, the TLS handshake and the request. Options
+	// it sets, such as TCP_USER_TIMEOUT, only affect the rest of the
+	// connection and later requests that reuse it. Options that must shape
+	// the connection setup, such as SO_MARK, IP_TOS, SO_BINDTODEVICE or a
+	// VRF, cannot be set here; use BindDevice or NetworkNamespace. network
+	// is "tcp4" or "tcp6" and address is the peer, which is the proxy when
+	// one is used. An error aborts the request.
+	SocketControl func(network, address string, c syscall.RawConn) error
+
+	// Logger, if set, receives structured events for request start and
+	// finish, curl errors and handle pool activity. Sensitive headers such
+	// as Authorization and Cookie are redacted.
+	Logger *slog.Logger
+
+	// OnRequest, OnResponse and OnError are lifecycle hooks called from the
+	// request goroutine before a request is sent to curl, after a response
+	// is received, and when the transfer fails. They run inside any
+	// middleware, so they see every hop and every retry.
+	OnRequest  func(HookEvent)
+	OnResponse func(HookEvent)
+	OnError    func(HookEvent)
+
+	// KeyFunc names the requested resource in HookEvent.Key. Defaults to
+	// the normalized URL.
+	KeyFunc KeyFunc
+
+	// RetryBudget, if set, must fund each resend of a request that failed
+	// on a dead reused connection
+	RetryBudget *RetryBudget
+
+	// Signer, if set, signs every request with HMAC after all other
+	// header changes, including on each retry
+	Signer *RequestSigner
+
+	// Fallback, if set, performs requests when the curl backend is
+	// unavailable (see ErrBackendUnavailable), for example
+	// http.DefaultTransport. Fallback responses are not impersonated.
+	Fallback http.RoundTripper
+
+	// ContentLengthPolicy controls responses whose body does not match
+	// their declared Content-Length. Defaults to ContentLengthRejectShort.
+	ContentLengthPolicy ContentLengthPolicy
+
+	// Clock is the time source for timing requests and for time-based
+	// features such as backoff and expiry. Defaults to SystemClock; tests
+	// can substitute a FakeClock.
+	Clock Clock
+
+	// Credentials, if set, is consulted by request host to add authentication
+	// headers. Lookups happen per request, so credentials are never carried
+	// across hosts when following redirects.
+	Credentials CredentialStore
+
+	// RequestPolicy, if set, can refuse or rewrite every request, including
+	// redirect hops and retries, before it is handed to curl. Refused
+	// requests fail with a *PolicyError; see URLAllowlist.
+	RequestPolicy RequestPolicy
+
+	// middleware wraps the curl round trip, outermost first
+	middleware []Middleware
+
+	// TargetPolicy checks ImpersonateTarget against the installed
+	// curl-impersonate library before the first request. With
+	// TargetPolicyDowngrade, OnTargetDowngrade is told which target was
+	// used instead of the requested one.
+	TargetPolicy      TargetPolicy
+	OnTargetDowngrade func(requested, used string)
+
+	targets    targetResolver
+	libVersion func() (Version, error)
+
+	// IdleHandleTimeout destroys pooled handles, and the connections they
+	// keep open, after they have been idle this long. MaxHandleAge and
+	// MaxHandleRequests recycle handles once they are this old or have
+	// served this many requests. Zero disables each limit.
+	IdleHandleTimeout time.Duration
+	MaxHandleAge      time.Duration
+	MaxHandleRequests int
+
+	reaping atomic.Bool
+
+	// DisableKeepAlives gives every request a new connection that is
+	// closed after it, with Connection: close sent over HTTP/1.1, as the
+	// net/http option does. Requests setting Close or a Connection: close
+	// header get the same treatment on their own. This suits sticky load
+	// balancers that pin connections rather than clients, at the cost of
+	// a handshake per request and a fingerprint browsers do not show.
+	DisableKeepAlives bool
+
+	// PoolOverflow decides what a request does when all maxPoolSize
+	// handles are in use. Defaults to PoolOverflowBlock.
+	PoolOverflow PoolOverflowPolicy
+
+	// AcquireTimeout bounds how long a request waits for a handle under
+	// PoolOverflowBlock and for a slot under the concurrency limits below.
+	// A request that waits longer fails with ErrPoolExhausted, counted in
+	// TransportStats.PoolExhausted. Zero waits for as long as the
+	// request's context allows.
+	AcquireTimeout time.Duration
+
+	// MaxConcurrentRequests caps the requests in flight across all hosts;
+	// MaxConcurrentRequestsPerHost caps them per host (host:port), and
+	// HostConcurrencyLimits overrides that cap for individual hosts, keyed
+	// by host:port or hostname. Requests over a limit wait until a slot
+	// frees up or their context is done. Zero means unlimited.
+	MaxConcurrentRequests        int
+	MaxConcurrentRequestsPerHost int
+	HostConcurrencyLimits        map[string]int
+
+	limits requestLimits
+
+	// Platform, if set, overrides the operating system named in the
+	// User-Agent of Chromium-based targets, and in the Sec-CH-UA client
+	// hints that are derived from it. Client hints are sent when Platform
+	// is set or UseDefaultHeaders is true, and high-entropy hints are added
+	// for HTTPS origins that ask for them with Accept-CH.
+	Platform *Platform
+
+	acceptedHints acceptedHints
+
+	// StreamResponses returns responses as soon as their headers arrive and
+	// streams the body while the transfer runs, instead of buffering it
+	// first. This suits httputil.ReverseProxy and long-lived responses such
+	// as server-sent events. The handle stays in use until the body is read
+	// or closed, and MmapResponses is ignored. WithResponseStreaming
+	// enables it for individual requests.
+	StreamResponses bool
+
+	// StreamUploadThreshold is the request body size above which bodies
+	// of known length are sent as curl reads them instead of being
+	// buffered in memory first, so multi-gigabyte uploads do not need
+	// that much memory. Defaults to 64 MiB; a negative value buffers every
+	// body. Bodies of unknown length (ContentLength -1) are streamed too,
+	// with chunked transfer coding over HTTP/1.1, unless it is negative.
+	// Multipart forms from NewMultipartForm are always streamed.
+	StreamUploadThreshold int64
+
+	// KeepFormBoundary sends multipart bodies built with mime/multipart
+	// with their boundary unchanged. By default buffered bodies whose
+	// boundary has Go's random hex format are rewritten to the target
+	// browser's format; see RegisterFormBoundary.
+	KeepFormBoundary bool
+
+	// HeaderCasing controls the case of request header names sent over
+	// HTTP/1.1. Defaults to HeaderCasingPreserve.
+	HeaderCasing HeaderCasing
+
+	// OmitHeaders names headers never sent, even those the impersonated
+	// browser or curl would add. See WithoutHeaders.
+	OmitHeaders []string
+
+	// UserAgentPolicy decides whether a User-Agent set on the request
+	// replaces the target's. Defaults to UserAgentRequest.
+	UserAgentPolicy UserAgentPolicy
+
 	// Connection pooling for performance
-	curlHandles chan *curl.CURL
+	pool        atomic.Pointer[handlePool]
+	newEngine   func() curlEngine
 	maxPoolSize int
 	poolOnce    sync.Once
+	stats       transportStats
+	traffic     trafficCounter
+	dns         dnsCache
+
+	// configMu guards the settings ApplyConfig changes. Readers hold it
+	// only to copy settings, never while waiting for a handle or a request
+	// slot or during a transfer, so ApplyConfig does not wait on requests.
+	// Handles keep a copy of the settings they were configured with and are
+	// recycled without it. generation counts the changes that retire
+	// pooled handles.
+	configMu   sync.RWMutex
+	generation atomic.Uint64
+	configured configuredMiddleware
 
 	// Connection pool settings
 	MaxConnects       int
@@ -303,67 +544,199 @@ type Transport struct {
 	BufferSize        int
 	EnableTCPFastOpen bool
 
+	// Resolve pins host names to addresses, bypassing DNS, as entries in
+	// curl's --resolve format "host:port:address[,address]". Load them
+	// from a hosts-style file with LoadPreresolveFile. DNSCache reports the
+	// addresses actually used.
+	Resolve []string
+
+	// KeepAliveInterval is how long a connection may be idle before TCP
+	// keep-alive probes are sent, and the interval between probes. Probes
+	// keep NAT and firewall mappings of pooled connections alive and let
+	// curl notice connections whose peer has gone before reusing them.
+	// Defaults to 60 seconds; a negative value disables keep-alive. curl
+	// only sends HTTP/2 PING frames from curl_easy_upkeep, which the
+	// binding does not expose, so this works at the TCP level.
+	KeepAliveInterval time.Duration
+
+	// KeepAliveIdle, if set, is how long a connection may be idle before
+	// the first keep-alive probe, leaving KeepAliveInterval as the time
+	// between probes. KeepAliveCount is how many unanswered probes drop
+	// the connection; zero keeps the system default (9 on Linux).
+	KeepAliveIdle  time.Duration
+	KeepAliveCount int
+
+	// TCPUserTimeout is how long sent data may remain unacknowledged
+	// before the connection is dropped (TCP_USER_TIMEOUT), so a dead peer
+	// is noticed while a request is stalled rather than after the
+	// system's retransmission timeout of many minutes. Zero keeps the
+	// system default.
+	//
+	// curl has no options for TCPUserTimeout and KeepAliveCount, so they
+	// are set on new connections once the first response header arrives,
+	// as for SocketControl. Linux only.
+	TCPUserTimeout time.Duration
+
 	// HttpVersion controls the HTTP version to use:
 	// 0 = default (let curl decide)
 	// 1 = HTTP/1.0
 	// 2 = HTTP/1.1 (forces HTTP/1.1, disables HTTP/2)
-	// 3 = HTTP/2
+	// 3 = HTTP/2 (cleartext connections upgrade from HTTP/1.1 via h2c)
+	// 4 = HTTP/2 over TLS only, HTTP/1.1 for cleartext
+	// 5 = HTTP/2 prior knowledge (h2c without upgrade for cleartext)
+	// The HTTPVersion constants name these values.
 	HttpVersion int
 }
 
+// Values for Transport.HttpVersion, matching curl's CURL_HTTP_VERSION options
+const (
+	HTTPVersionDefault = 0
+	HTTPVersion1_0     = 1
+	HTTPVersion1_1     = 2
+	HTTPVersion2       = 3
+	HTTPVersion2TLS    = 4
+
+	// HTTPVersion2PriorKnowledge speaks HTTP/2 straight away on http://
+	// URLs, for servers known to support h2c such as internal services and
+	// test rigs. HTTPS still negotiates the version during the handshake.
+	HTTPVersion2PriorKnowledge = 5
+)
+
 // initPool initializes the connection pool for the transport
 func (t *Transport) initPool() {
 	t.poolOnce.Do(func() {
 		if t.maxPoolSize == 0 {
 			t.maxPoolSize = 200 // Default pool size
 		}
-		t.curlHandles = make(chan *curl.CURL, t.maxPoolSize)
+		t.pool.Store(newHandlePool(t.maxPoolSize))
 	})
 }
 
-// getCurlHandle gets a curl handle from the pool or creates a new one
-func (t *Transport) getCurlHandle() *curl.CURL {
+// handles returns the current handle pool
+func (t *Transport) handles() *handlePool {
 	t.initPool()
+	return t.pool.Load()
+}
 
-	select {
-	case handle := <-t.curlHandles:
-		return handle
-	default:
-		// No available handle, create new one
-		initCurl()
-		easy := curl.EasyInit()
-		if easy == nil {
-			return nil
+// getCurlHandle takes an idle handle from the pool, or creates one if the
+// pool has not reached maxPoolSize handles. When every handle is in use,
+// PoolOverflow decides whether to wait for one (respecting ctx), fail, or
+// create a temporary handle.
+func (t *Transport) getCurlHandle(ctx context.Context) (curlEngine, error) {
+	return t.getRouteHandle(ctx, "")
+}
+
+// getRouteHandle is getCurlHandle for a request on the proxy route with the
+// given key. Idle handles of that route are preferred; a handle of another
+// route is only taken when the pool is full, and is recycled first so the
+// connections it holds are not reused.
+func (t *Transport) getRouteHandle(ctx context.Context, route string) (curlEngine, error) {
+	pool := t.handles()
+	var timeout <-chan time.Time
+	var wait time.Duration
+
+	for {
+		if handle := t.takeIdleHandle(pool, route, false); handle != nil {
+			return handle, nil
+		}
+		select {
+		case pool.slots <- struct{}{}:
+			return t.createPooledHandle(pool, route)
+		default:
+		}
+		if handle := t.takeIdleHandle(pool, route, true); handle != nil {
+			return t.reroute(handle, route)
 		}
 
-		// Apply configuration
-		t.configureCurlHandle(easy)
+		switch t.PoolOverflow {
+		case PoolOverflowFail:
+			t.stats.poolExhausted.Add(1)
+			return nil, ErrPoolExhausted
+		case PoolOverflowCreate:
+			// returnCurlHandle destroys the extra handle when it comes back
+			t.stats.poolMisses.Add(1)
+			handle, err := t.createCurlHandle()
+			if err != nil {
+				return nil, err
+			}
+			handle.route = route
+			return handle, nil
+		}
 
-		return easy
+		if timeout == nil {
+			timeout, wait = t.acquireTimeout()
+		}
+		t.stats.acquisitionsBlocked.Add(1)
+		select {
+		case handle := <-pool.idle:
+			if reason := t.handleExpired(handle, t.clock().Now()); reason != "" {
+				t.destroyHandle(handle, reason)
+				continue
+			}
+			return t.reroute(handle, route)
+		case pool.slots <- struct{}{}:
+			return t.createPooledHandle(pool, route)
+		case <-timeout:
+			t.stats.poolExhausted.Add(1)
+			return nil, fmt.Errorf("failed to acquire curl handle within %v: %w", wait, ErrPoolExhausted)
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to acquire curl handle: %w", ctx.Err())
+		}
 	}
 }
 
-// configureCurlHandle applies all settings to a curl handle
-func (t *Transport) configureCurlHandle(handle *curl.CURL) {
-	// Set defaults if not specified
-	if t.ImpersonateTarget == "" {
-		t.ImpersonateTarget = "chrome136"
+// createPooledHandle creates a handle for route in a slot of pool already
+// taken, giving the slot back if creation fails
+func (t *Transport) createPooledHandle(pool *handlePool, route string) (curlEngine, error) {
+	t.stats.poolMisses.Add(1)
+	easy, err := t.createCurlHandle()
+	if err != nil {
+		<-pool.slots
+		return nil, err
 	}
-	if t.MaxConnects == 0 {
-		t.MaxConnects = 50 // Conservative default for library
+	easy.pool = pool
+	easy.route = route
+	return easy, nil
+}
+
+// createCurlHandle allocates and configures a new handle
+func (t *Transport) createCurlHandle() (*pooledHandle, error) {
+	newEngine := t.newEngine
+	if newEngine == nil {
+		newEngine = newCurlEngine
+	}
+	easy := newEngine()
+	if easy == nil {
+		t.log(context.Background(), slog.LevelError, "failed to create curl handle")
+		if err := initCurl(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: failed to get curl handle", ErrBackendUnavailable)
 	}
+	t.stats.handlesCreated.Add(1)
+	t.log(context.Background(), slog.LevelDebug, "curl handle created")
+
+	// Apply configuration
+	t.configMu.RLock()
+	settings, generation := t.handleSettings(), t.generation.Load()
+	t.configMu.RUnlock()
+	target, _ := t.resolveTargetName(settings.target)
+	t.configureCurlHandle(easy, settings, target)
+
+	now := t.clock().Now()
+	return &pooledHandle{curlEngine: easy, target: target, settings: settings, generation: generation, created: now, idleSince: now}, nil
+}
+
+// configureCurlHandle applies settings and the remaining Transport options
+// to a curl handle, impersonating target
+func (t *Transport) configureCurlHandle(handle curlEngine, settings handleSettings, target string) {
+	// Set defaults if not specified
 	if t.MaxAgeConn == 0 {
 		t.MaxAgeConn = 300
 	}
 	if t.MaxLifetimeConn == 0 {
 		t.MaxLifetimeConn = 600
 	}
-	if t.ConnectTimeoutMs == 0 {
-		t.ConnectTimeoutMs = 5000 // Conservative 5s default
-	}
-	if t.TimeoutMs == 0 {
-		t.TimeoutMs = 30000 // Conservative 30s default
-	}
 	if t.DNSCacheTimeout == 0 {
 		t.DNSCacheTimeout = 300
 	}
@@ -374,7 +747,7 @@ func (t *Transport) configureCurlHandle(handle *curl.CURL) {
 	// Basic options
 	handle.Setopt(curl.OPT_HEADER, false)
 	handle.Setopt(curl.OPT_NOPROGRESS, true)
-	handle.Impersonate(t.ImpersonateTarget, t.UseDefaultHeaders)
+	handle.Impersonate(target, t.UseDefaultHeaders)
 
 	// disable SSL verification
 	handle.Setopt(curl.OPT_SSL_VERIFYPEER, false)
@@ -384,19 +757,30 @@ func (t *Transport) configureCurlHandle(handle *curl.CURL) {
 	// Connection reuse and persistence
 	handle.Setopt(curl.OPT_FRESH_CONNECT, false)
 	handle.Setopt(curl.OPT_FORBID_REUSE, false)
-	handle.Setopt(curl.OPT_TCP_KEEPALIVE, true)
-	handle.Setopt(curl.OPT_TCP_KEEPIDLE, 60)
-	handle.Setopt(curl.OPT_TCP_KEEPINTVL, 60)
+	if keepAlive := t.keepAliveSeconds(); keepAlive > 0 {
+		idle := keepAlive
+		if t.KeepAliveIdle > 0 {
+			idle = wholeSeconds(t.KeepAliveIdle)
+		}
+		handle.Setopt(curl.OPT_TCP_KEEPALIVE, true)
+		handle.Setopt(curl.OPT_TCP_KEEPIDLE, idle)
+		handle.Setopt(curl.OPT_TCP_KEEPINTVL, keepAlive)
+	} else {
+		handle.Setopt(curl.OPT_TCP_KEEPALIVE, false)
+	}
 
 	// Connection pool settings
-	handle.Setopt(curl.OPT_MAXCONNECTS, t.MaxConnects)
+	handle.Setopt(curl.OPT_MAXCONNECTS, settings.maxConnects)
 	handle.Setopt(curl.OPT_MAXAGE_CONN, t.MaxAgeConn)
 	handle.Setopt(curl.OPT_MAXLIFETIME_CONN, t.MaxLifetimeConn)
 
 	// Timeout settings
-	handle.Setopt(curl.OPT_CONNECTTIMEOUT_MS, t.ConnectTimeoutMs)
-	handle.Setopt(curl.OPT_TIMEOUT_MS, t.TimeoutMs)
+	handle.Setopt(curl.OPT_CONNECTTIMEOUT_MS, settings.connectTimeoutMs)
+	handle.Setopt(curl.OPT_TIMEOUT_MS, settings.timeoutMs)
 	handle.Setopt(curl.OPT_DNS_CACHE_TIMEOUT, t.DNSCacheTimeout)
+	if settings.resolve != "" {
+		handle.Setopt(curl.OPT_RESOLVE, strings.Split(settings.resolve, "\n"))
+	}
 
 	// TCP optimizations
 	handle.Setopt(curl.OPT_TCP_NODELAY, true)
@@ -409,41 +793,167 @@ func (t *Transport) configureCurlHandle(handle *curl.CURL) {
 	handle.Setopt(curl.OPT_BUFFERSIZE, t.BufferSize)
 
 	// Proxy SSL settings
-	if t.Proxy != nil {
-		handle.Setopt(curl.OPT_PROXY_SSL_VERIFYPEER, false)
-		handle.Setopt(curl.OPT_PROXY_SSL_VERIFYHOST, false)
+	if settings.proxied {
+		t.applyProxyTLS(handle, &settings.proxyTLS)
 	}
 
 	// HTTP version setting (0=default, 1=HTTP/1.0, 2=HTTP/1.1, 3=HTTP/2)
-	if t.HttpVersion > 0 {
-		handle.Setopt(curl.OPT_HTTP_VERSION, t.HttpVersion)
+	if settings.httpVersion > 0 {
+		handle.Setopt(curl.OPT_HTTP_VERSION, settings.httpVersion)
+	}
+}
+
+// keepAliveSeconds returns the TCP keep-alive interval in whole seconds,
+// or 0 if keep-alive is disabled
+func (t *Transport) keepAliveSeconds() int {
+	switch {
+	case t.KeepAliveInterval < 0:
+		return 0
+	case t.KeepAliveInterval == 0:
+		return 60
 	}
+	return wholeSeconds(t.KeepAliveInterval)
 }
 
-// returnCurlHandle returns a handle to the pool for reuse
-func (t *Transport) returnCurlHandle(handle *curl.CURL) {
-	if handle == nil {
+// wholeSeconds returns a positive d in whole seconds, at least 1
+func wholeSeconds(d time.Duration) int {
+	if d < time.Second {
+		return 1
+	}
+	return int(d / time.Second)
+}
+
+// returnCurlHandle returns a handle to the pool for reuse, or destroys it
+// if it is a temporary handle, has reached its age or request limit or was
+// configured before ApplyConfig changed its settings. It does not take
+// configMu: a handle that is still current is reset with its own settings.
+func (t *Transport) returnCurlHandle(engine curlEngine) {
+	handle, ok := engine.(*pooledHandle)
+	if !ok || handle == nil {
 		return
 	}
+	handle.requests++
 
-	// Reset handle for reuse (but keep connection alive)
-	handle.Reset()
+	if handle.pool == nil {
+		t.destroyHandle(handle, "overflow handle released")
+		return
+	}
+	now := t.clock().Now()
+	if reason := t.handleExpired(handle, now); reason != "" {
+		t.destroyHandle(handle, reason)
+		return
+	}
 
-	// Reconfigure handle after reset
-	t.configureCurlHandle(handle)
+	// Clear only what the last request set, keeping the impersonation and
+	// connection settings applied when the handle was configured. A changed
+	// target, a streamed upload or a failed clear falls back to a full reset.
+	if target, _ := t.resolveTargetName(handle.settings.target); target != handle.target || handle.needsReset || !t.clearRequestOptions(handle) {
+		t.retarget(handle, target)
+	}
 
+	handle.idleSince = now
 	select {
-	case t.curlHandles <- handle:
+	case handle.pool.idle <- handle:
 		// Successfully returned to pool
+		t.startReaper(handle.settings)
 	default:
 		// Pool is full, cleanup the handle
-		handle.Cleanup()
+		t.destroyHandle(handle, "pool full")
 	}
 }
 
-// NewTransport creates a new Transport with default settings and connection pooling
+// setMethod configures easy to send method with the request body, which
+// is either body or, when src is set, read from src: size bytes or, when
+// size is negative, a chunked body. Any method but HEAD can carry a body.
+// curl sends it the way it sends a POST, and CUSTOMREQUEST puts the real
+// method on the wire. As with net/http, POST, PUT and PATCH without a body
+// send an empty one with Content-Length: 0.
+func (t *Transport) setMethod(easy curlEngine, method string, body []byte, src *uploadSource, size int64) error {
+	if method == "" {
+		method = "GET"
+	}
+	withBody := sendsBody(method, len(body) > 0 || src != nil)
+	switch {
+	case method == "HEAD":
+		if err := easy.Setopt(curl.OPT_NOBODY, true); err != nil {
+			return fmt.Errorf("failed to set HEAD method: %w", err)
+		}
+		return nil
+	case !withBody && method == "GET":
+		if err := easy.Setopt(curl.OPT_HTTPGET, true); err != nil {
+			return fmt.Errorf("failed to set GET method: %w", err)
+		}
+		return nil
+	case !withBody:
+		if err := easy.Setopt(curl.OPT_CUSTOMREQUEST, method); err != nil {
+			return fmt.Errorf("failed to set custom method %s: %w", method, err)
+		}
+		return nil
+	}
+
+	if err := easy.Setopt(curl.OPT_POST, true); err != nil {
+		return fmt.Errorf("failed to set POST method: %w", err)
+	}
+	if method != "POST" {
+		if err := easy.Setopt(curl.OPT_CUSTOMREQUEST, method); err != nil {
+			return fmt.Errorf("failed to set custom method %s: %w", method, err)
+		}
+	}
+	if src == nil {
+		if len(body) > 0 {
+			if err := easy.Setopt(curl.OPT_POSTFIELDS, body); err != nil {
+				return fmt.Errorf("failed to set request body: %w", err)
+			}
+		}
+		if err := easy.Setopt(curl.OPT_POSTFIELDSIZE_LARGE, int64(len(body))); err != nil {
+			return fmt.Errorf("failed to set post field size: %w", err)
+		}
+		return nil
+	}
+
+	if err := easy.Setopt(curl.OPT_READFUNCTION, readUpload); err != nil {
+		return fmt.Errorf("failed to set read function: %w", err)
+	}
+	if err := easy.Setopt(curl.OPT_READDATA, src); err != nil {
+		return fmt.Errorf("failed to set read data: %w", err)
+	}
+	if err := easy.Setopt(curl.OPT_POSTFIELDSIZE_LARGE, size); err != nil {
+		return fmt.Errorf("failed to set upload size: %w", err)
+	}
+	// The read callback is not among the options cleared between requests
+	if handle, ok := easy.(*pooledHandle); ok {
+		handle.needsReset = true
+	}
+	return nil
+}
+
+// sendsBody reports whether a request with method sends a body, which
+// net/http declares with Content-Length: 0 for POST, PUT and PATCH even
+// when there is none
+func sendsBody(method string, hasBody bool) bool {
+	if method == "HEAD" {
+		return false
+	}
+	return hasBody || method == "POST" || method == "PUT" || method == "PATCH"
+}
+
+// curlBodyDefaults are headers curl adds to requests it sends as a POST
+// that net/http does not send unless asked to
+var curlBodyDefaults = []string{"Content-Type", "Expect"}
+
+// retarget fully resets handle and configures it with its settings to
+// impersonate target
+func (t *Transport) retarget(handle *pooledHandle, target string) {
+	handle.Reset()
+	t.configureCurlHandle(handle, handle.settings, target)
+	handle.target = target
+	handle.needsReset = false
+}
+
+// NewTransport creates a new Transport with default settings and connection pooling.
+// The CURLHTTP_ environment variables, such as CURLHTTP_TARGET, replace the defaults.
 func NewTransport() *Transport {
-	return &Transport{
+	t := &Transport{
 		ImpersonateTarget: "chrome136",
 		UseDefaultHeaders: true,
 		maxPoolSize:       10,
@@ -456,6 +966,8 @@ func NewTransport() *Transport {
 		BufferSize:        16384,
 		EnableTCPFastOpen: false,
 	}
+	applyEnv(t)
+	return t
 }
 
 // NewTransportWithPoolSize creates a new Transport with a custom pool size
@@ -465,6 +977,90 @@ func NewTransportWithPoolSize(poolSize int) *Transport {
 	return t
 }
 
+// Clone returns a deep copy of t's configuration with its own, empty handle
+// pool and statistics, mirroring net/http.Transport.Clone. Shared helpers
+// such as Credentials, CertMonitor, ServerFingerprints, Logger and Clock
+// are carried over by reference; Proxy, HostConcurrencyLimits, Resolve and
+// the middleware list are copied.
+func (t *Transport) Clone() *Transport {
+	clone := &Transport{
+		ImpersonateTarget:            t.ImpersonateTarget,
+		UseDefaultHeaders:            t.UseDefaultHeaders,
+		MmapResponses:                t.MmapResponses,
+		StreamResponses:              t.StreamResponses,
+		StreamUploadThreshold:        t.StreamUploadThreshold,
+		KeepFormBoundary:             t.KeepFormBoundary,
+		HeaderCasing:                 t.HeaderCasing,
+		OmitHeaders:                  append([]string(nil), t.OmitHeaders...),
+		UserAgentPolicy:              t.UserAgentPolicy,
+		Platform:                     t.Platform,
+		MmapTempDir:                  t.MmapTempDir,
+		CertMonitor:                  t.CertMonitor,
+		ServerFingerprints:           t.ServerFingerprints,
+		BindDevice:                   t.BindDevice,
+		NetworkNamespace:             t.NetworkNamespace,
+		SocketControl:                t.SocketControl,
+		Logger:                       t.Logger,
+		OnRequest:                    t.OnRequest,
+		OnResponse:                   t.OnResponse,
+		OnError:                      t.OnError,
+		Fallback:                     t.Fallback,
+		ContentLengthPolicy:          t.ContentLengthPolicy,
+		Clock:                        t.Clock,
+		Credentials:                  t.Credentials,
+		RequestPolicy:                t.RequestPolicy,
+		KeyFunc:                      t.KeyFunc,
+		RetryBudget:                  t.RetryBudget,
+		Signer:                       t.Signer,
+		middleware:                   append([]Middleware(nil), t.middleware...),
+		configured:                   t.configured.clone(),
+		TargetPolicy:                 t.TargetPolicy,
+		OnTargetDowngrade:            t.OnTargetDowngrade,
+		libVersion:                   t.libVersion,
+		IdleHandleTimeout:            t.IdleHandleTimeout,
+		MaxHandleAge:                 t.MaxHandleAge,
+		MaxHandleRequests:            t.MaxHandleRequests,
+		DisableKeepAlives:            t.DisableKeepAlives,
+		PoolOverflow:                 t.PoolOverflow,
+		AcquireTimeout:               t.AcquireTimeout,
+		MaxConcurrentRequests:        t.MaxConcurrentRequests,
+		MaxConcurrentRequestsPerHost: t.MaxConcurrentRequestsPerHost,
+		newEngine:                    t.newEngine,
+		maxPoolSize:                  t.maxPoolSize,
+		MaxConnects:                  t.MaxConnects,
+		MaxAgeConn:                   t.MaxAgeConn,
+		MaxLifetimeConn:              t.MaxLifetimeConn,
+		ConnectTimeoutMs:             t.ConnectTimeoutMs,
+		TimeoutMs:                    t.TimeoutMs,
+		DNSCacheTimeout:              t.DNSCacheTimeout,
+		Resolve:                      append([]string(nil), t.Resolve...),
+		BufferSize:                   t.BufferSize,
+		EnableTCPFastOpen:            t.EnableTCPFastOpen,
+		KeepAliveInterval:            t.KeepAliveInterval,
+		KeepAliveIdle:                t.KeepAliveIdle,
+		KeepAliveCount:               t.KeepAliveCount,
+		TCPUserTimeout:               t.TCPUserTimeout,
+		HttpVersion:                  t.HttpVersion,
+	}
+	if t.Proxy != nil {
+		proxy := *t.Proxy
+		clone.Proxy = &proxy
+	}
+	if t.ProxyTLS != nil {
+		proxyTLS := *t.ProxyTLS
+		clone.ProxyTLS = &proxyTLS
+	}
+	clone.ProxyConnectHeader = t.ProxyConnectHeader.Clone()
+	clone.GetProxyConnectHeader = t.GetProxyConnectHeader
+	if t.HostConcurrencyLimits != nil {
+		clone.HostConcurrencyLimits = make(map[string]int, len(t.HostConcurrencyLimits))
+		for host, limit := range t.HostConcurrencyLimits {
+			clone.HostConcurrencyLimits[host] = limit
+		}
+	}
+	return clone
+}
+
 // RoundTrip executes a single HTTP transaction using go-curl-impersonate.
 // It implements the http.RoundTripper interface and provides browser impersonation.
 func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -475,97 +1071,289 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 		return nil, fmt.Errorf("request URL cannot be nil")
 	}
 
-	// Convert headers to simple map
-	headers := make(map[string]string)
-	for name, values := range req.Header {
-		if len(values) > 0 {
-			headers[name] = values[0] // Take first value for simplicity
-		}
+	t.configMu.RLock()
+	middleware := t.allMiddleware()
+	t.configMu.RUnlock()
+	if len(middleware) > 0 {
+		return Chain(RoundTripperFunc(t.roundTrip), middleware...).RoundTrip(req)
+	}
+	return t.roundTrip(req)
+}
+
+// roundTrip performs the request with curl; it is the innermost RoundTripper
+// of the middleware chain.
+func (t *Transport) roundTrip(req *http.Request) (*http.Response, error) {
+	req, err := t.applyRequestPolicy(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.checkUserAgentPolicy(req); err != nil {
+		return nil, err
+	}
+	if req, err = t.mimicFormBoundary(req); err != nil {
+		return nil, err
 	}
+	headers := t.requestHeaders(req)
 
-	// Read request body if present
+	// Read request body if present, into pooled storage that is released
+	// once curl is done with it
 	var body []byte
-	if req.Body != nil {
-		var err error
-		body, err = io.ReadAll(req.Body)
+	var upload io.Reader
+	if streamed, ok := streamedUpload(req); ok {
+		// Multipart forms are encoded while curl sends them
+		upload = streamed
+		defer streamed.Close()
+	} else if t.streamsUpload(req) {
+		upload = req.Body
+		defer req.Body.Close()
+	} else if req.Body != nil {
+		buf := getBuffer(int(req.ContentLength))
+		_, err := buf.ReadFrom(req.Body)
+		req.Body.Close()
 		if err != nil {
+			putBuffer(buf)
 			return nil, fmt.Errorf("failed to read request body: %w", err)
 		}
-		req.Body.Close()
+		body = buf.Bytes()
+		defer func() {
+			// A fallback transport or a streamed transfer may still be
+			// sending the body
+			if t.Fallback == nil && !t.streamsResponse(req) {
+				putBuffer(buf)
+			}
+		}()
 	}
 
-	// Use optimized request with connection pooling and in-memory responses
-	resp, err := t.performOptimizedRequest(req.URL.String(), req.Method, headers, body)
+	req, stopCancel := legacyCancel(req)
+	releaseSlot, err := t.acquireRequestSlot(req)
 	if err != nil {
+		stopCancel()
 		return nil, err
 	}
+	release := func() {
+		releaseSlot()
+		stopCancel()
+	}
+	streaming := false
+	defer func() {
+		if !streaming {
+			release()
+		}
+	}()
 
-	// Set the request reference
-	resp.Request = req
+	// Use optimized request with connection pooling and in-memory responses
+	t.logRequestStart(req, headers)
+	t.runRequestHook(req)
+	t.configMu.RLock()
+	budget := t.RetryBudget
+	t.configMu.RUnlock()
+	start := t.clock().Now()
+	resp, err := t.performOptimizedRequest(req, headers, body, upload)
+	attempts := 1
+	for retries := 0; retries < maxDeadConnRetries && shouldRetryDeadConn(req, err) && allowRetry(budget, req); retries++ {
+		if upload != nil {
+			// Part of a streamed body may be gone; start it over
+			if req.GetBody == nil {
+				break
+			}
+			replay, replayErr := req.GetBody()
+			if replayErr != nil {
+				break
+			}
+			defer replay.Close()
+			upload = replay
+		}
+		t.noteDeadConnRetry(req, err)
+		resp, err = t.performOptimizedRequest(req, headers, body, upload)
+		attempts++
+	}
+	fellBack := false
+	if err != nil && t.Fallback != nil && errors.Is(err, ErrBackendUnavailable) {
+		resp, err = t.fallbackRoundTrip(req, body)
+		fellBack = true
+	}
+	if err == nil && t.observeClientHints(req, resp, headers) && upload == nil && !fellBack {
+		// Critical-CH asked for hints the request lacked; send it again
+		// with them, as browsers do. Fallback responses are kept: curl is
+		// unavailable and Fallback does not send hints.
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		headers = t.requestHeaders(req)
+		resp, err = t.performOptimizedRequest(req, headers, body, nil)
+		attempts++
+	}
+	elapsed := t.clock().Now().Sub(start)
+	if resp != nil {
+		// Set the request reference
+		resp.Request = req
+		if m := metaOf(resp); m != nil {
+			m.target, _ = t.requestTarget(req)
+			m.attempts = attempts
+		}
+
+		// Streamed transfers hold their concurrency slots until they end
+		if body, ok := resp.Body.(*streamBody); ok {
+			streaming = true
+			go func() {
+				<-body.done
+				release()
+			}()
+		}
+	}
+	t.logRequestDone(req, resp, err, elapsed)
+	t.runResultHook(req, resp, err, elapsed)
+	if err != nil {
+		return nil, err
+	}
 
 	return resp, nil
 }
 
-// performOptimizedRequest performs HTTP request using in-memory buffer and connection pooling
-func (t *Transport) performOptimizedRequest(url, method string, headers map[string]string, body []byte) (*http.Response, error) {
-	// Get curl handle from pool
-	easy := t.getCurlHandle()
-	if easy == nil {
-		return nil, fmt.Errorf("failed to get curl handle")
+// requestHeaders converts the request headers, with stored credentials
+// applied, hop-by-hop headers removed, navigation headers and client hints
+// added and omitted headers marked for removal, to the simple map sent to
+// curl
+func (t *Transport) requestHeaders(req *http.Request) map[string]string {
+	headers := make(map[string]string)
+	header := applyCredentials(t.Credentials, req)
+	listed := connectionHeaders(header)
+	for name, values := range header {
+		// Names keyed directly keep their casing on the wire but are
+		// handled in canonical form
+		if canonical := http.CanonicalHeaderKey(name); canonical != name {
+			if _, dup := header[canonical]; dup {
+				continue
+			}
+			name = canonical
+		}
+		if hopHeaders[name] || listed[name] {
+			// TE: trailers is end-to-end and allowed over HTTP/2
+			if name != "Te" || len(values) == 0 || !strings.EqualFold(values[0], "trailers") {
+				continue
+			}
+		}
+		if len(values) > 0 {
+			headers[name] = values[0] // Take first value for simplicity
+		}
 	}
-	defer t.returnCurlHandle(easy)
+	t.applyUserAgentPolicy(headers)
+	// Like net/http, ask the server to close connections that are not
+	// kept alive; curl drops the header over HTTP/2
+	if t.closesConnection(req) {
+		headers["Connection"] = "close"
+	}
+	// Like net/http, send Request.Host in place of the URL's host
+	if req.Host != "" && req.Host != req.URL.Host {
+		if host, err := asciiHostPort(req.Host); err == nil {
+			headers["Host"] = host
+		}
+	}
+	t.applyNavigation(req, headers)
+	t.applyClientHints(req, headers)
+	t.applyOmitHeaders(req, headers)
+	return headers
+}
 
-	// Set the URL
-	if err := easy.Setopt(curl.OPT_URL, url); err != nil {
-		return nil, fmt.Errorf("failed to set URL: %w", err)
+// performOptimizedRequest performs HTTP request using in-memory buffer and connection pooling.
+// The request body is either body or, for streamed uploads, read from upload.
+func (t *Transport) performOptimizedRequest(req *http.Request, headers map[string]string, body []byte, upload io.Reader) (*http.Response, error) {
+	url, err := wireURL(req.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare URL: %w", err)
 	}
+	method := req.Method
 
-	// Set HTTP method
-	switch method {
-	case "GET":
-		if err := easy.Setopt(curl.OPT_HTTPGET, true); err != nil {
-			return nil, fmt.Errorf("failed to set GET method: %w", err)
-		}
-	case "HEAD":
-		if err := easy.Setopt(curl.OPT_NOBODY, true); err != nil {
-			return nil, fmt.Errorf("failed to set HEAD method: %w", err)
+	// Get curl handle from pool
+	// Fail fast if the impersonation target is unsupported
+	target, err := t.requestTarget(req)
+	if err != nil {
+		return nil, err
+	}
+
+	route, err := t.route(req.Context(), req.URL)
+	if err != nil {
+		return nil, err
+	}
+	easy, err := t.getRouteHandle(req.Context(), route.key())
+	if err != nil {
+		return nil, err
+	}
+	handleHandedOff := false
+	defer func() {
+		// Streamed transfers return the handle when they finish
+		if !handleHandedOff {
+			t.returnCurlHandle(easy)
 		}
-	case "POST":
-		if err := easy.Setopt(curl.OPT_POST, true); err != nil {
-			return nil, fmt.Errorf("failed to set POST method: %w", err)
+	}()
+
+	// A handle set up before the Transport's fields were assigned directly
+	// takes the current settings. A request for another target reconfigures
+	// the handle; it is reset to the Transport's target when it returns to
+	// the pool.
+	if handle, ok := easy.(*pooledHandle); ok {
+		t.configMu.RLock()
+		settings := t.handleSettings()
+		t.configMu.RUnlock()
+		if handle.settings != settings {
+			handle.settings = settings
+			t.retarget(handle, target)
+		} else if handle.target != target {
+			t.retarget(handle, target)
 		}
-		if len(body) > 0 {
-			if err := easy.Setopt(curl.OPT_POSTFIELDS, body); err != nil {
-				return nil, fmt.Errorf("failed to set request body: %w", err)
-			}
-			if err := easy.Setopt(curl.OPT_POSTFIELDSIZE, len(body)); err != nil {
-				return nil, fmt.Errorf("failed to set post field size: %w", err)
-			}
+	}
+	if timeout, ok := transferTimeout(req.Context()); ok {
+		if err := easy.Setopt(curl.OPT_TIMEOUT_MS, int(timeout.Milliseconds())); err != nil {
+			return nil, fmt.Errorf("failed to set timeout: %w", err)
 		}
-	case "PUT":
-		if err := easy.Setopt(curl.OPT_UPLOAD, true); err != nil {
-			return nil, fmt.Errorf("failed to set PUT method: %w", err)
+	}
+	if t.closesConnection(req) {
+		if err := setConnectionClose(easy); err != nil {
+			return nil, err
 		}
-		if len(body) > 0 {
-			if err := easy.Setopt(curl.OPT_POSTFIELDS, body); err != nil {
-				return nil, fmt.Errorf("failed to set request body: %w", err)
-			}
+	}
+	if unsafeVerbose(req.Context()) {
+		if err := easy.Setopt(curl.OPT_VERBOSE, true); err != nil {
+			return nil, fmt.Errorf("failed to enable verbose output: %w", err)
 		}
-	case "DELETE":
-		if err := easy.Setopt(curl.OPT_CUSTOMREQUEST, "DELETE"); err != nil {
-			return nil, fmt.Errorf("failed to set DELETE method: %w", err)
+	}
+
+	// Set the URL
+	if err := easy.Setopt(curl.OPT_URL, url); err != nil {
+		return nil, fmt.Errorf("failed to set URL: %w", err)
+	}
+
+	// Set the method and body; streamed uploads are read through curl's
+	// read callback
+	var uploadSrc *uploadSource
+	if upload != nil {
+		uploadSrc = &uploadSource{r: upload}
+		if req.ContentLength < 0 {
+			headers["Transfer-Encoding"] = "chunked"
 		}
-	default:
-		if err := easy.Setopt(curl.OPT_CUSTOMREQUEST, method); err != nil {
-			return nil, fmt.Errorf("failed to set custom method %s: %w", method, err)
+	}
+	if err := t.setMethod(easy, method, body, uploadSrc, req.ContentLength); err != nil {
+		return nil, err
+	}
+
+	// Sign last, over the headers as they will be sent
+	if t.Signer != nil {
+		if err := t.Signer.sign(req, url, headers, body, upload != nil); err != nil {
+			return nil, err
 		}
 	}
 
 	// Set headers
-	var requestHeaders []string
-	if len(headers) > 0 {
-		for name, value := range headers {
-			requestHeaders = append(requestHeaders, fmt.Sprintf("%s: %s", name, value))
+	requestHeaders := make([]string, 0, len(headers)+len(curlBodyDefaults))
+	casing := originalCasing(req.Header)
+	for name, value := range headers {
+		requestHeaders = append(requestHeaders, t.wireHeaderName(name, casing, target)+": "+value)
+	}
+	if sendsBody(method, len(body) > 0 || upload != nil) {
+		// A header without a value stops curl from adding its own
+		for _, name := range curlBodyDefaults {
+			if _, ok := headers[name]; !ok {
+				requestHeaders = append(requestHeaders, name+":")
+			}
 		}
 	}
 
@@ -576,88 +1364,277 @@ func (t *Transport) performOptimizedRequest(url, method string, headers map[stri
 		}
 	}
 
-	// Create in-memory response buffer instead of temporary file
-	responseBuffer := &responseBuffer{
-		buffer: bytes.NewBuffer(make([]byte, 0, 4096)), // Pre-allocate 4KB
+	// Create in-memory response buffer, or a temporary file to be mapped
+	// into memory when MmapResponses is enabled
+	var writeFunc func([]byte, interface{}) bool
+	var writeData interface{}
+	var responseBuffer *responseBuffer
+	var sink *fileSink
+	var stream *streamSink
+	if t.streamsResponse(req) {
+		stream = newStreamSink()
+		writeFunc, writeData = writeDataToStream, stream
+	} else if t.MmapResponses {
+		var err error
+		if sink, err = newFileSink(t.MmapTempDir); err != nil {
+			return nil, err
+		}
+		writeFunc, writeData = writeDataToFile, sink
+	} else {
+		responseBuffer = newResponseBuffer()
+		writeFunc, writeData = writeDataToBuffer, responseBuffer
 	}
+	sinkHandedOff := false
+	defer func() {
+		if sinkHandedOff {
+			return
+		}
+		if sink != nil {
+			sink.discard()
+		} else if responseBuffer != nil {
+			putBuffer(responseBuffer.buffer)
+		}
+	}()
 
-	// Set response callback function with buffer as userdata
-	if err := easy.Setopt(curl.OPT_WRITEFUNCTION, writeDataToBuffer); err != nil {
+	// Set response callback function with the sink as userdata
+	if err := easy.Setopt(curl.OPT_WRITEFUNCTION, writeFunc); err != nil {
 		return nil, fmt.Errorf("failed to set write function: %w", err)
 	}
-	if err := easy.Setopt(curl.OPT_WRITEDATA, responseBuffer); err != nil {
+	if err := easy.Setopt(curl.OPT_WRITEDATA, writeData); err != nil {
 		return nil, fmt.Errorf("failed to set write data: %w", err)
 	}
 
 	// Set proxy if provided
-	if t.Proxy != nil {
-		// Set the proxy URL
-		if err := easy.Setopt(curl.OPT_PROXY, t.Proxy.String()); err != nil {
-			return nil, fmt.Errorf("failed to set proxy: %w", err)
-		}
+	if err := t.setProxy(easy, route); err != nil {
+		return nil, err
 	}
 
-	// Create response headers map
-	responseHeaders := make(http.Header)
+	// Create response header parser
+	parser := newHeaderParser()
+	if t.controlsSockets() {
+		parser.onStart = func() error { return t.controlSocket(easy) }
+	}
+	if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.Got1xxResponse != nil {
+		parser.onInformational = func(code int, header http.Header) error {
+			return trace.Got1xxResponse(code, textproto.MIMEHeader(header))
+		}
+	}
 
 	// Set header callback to capture response headers
-	if err := easy.Setopt(curl.OPT_HEADERFUNCTION, writeHeaderToMap); err != nil {
+	if err := easy.Setopt(curl.OPT_HEADERFUNCTION, writeHeaderToParser); err != nil {
 		return nil, fmt.Errorf("failed to set header function: %w", err)
 	}
-	if err := easy.Setopt(curl.OPT_HEADERDATA, responseHeaders); err != nil {
+	if err := easy.Setopt(curl.OPT_HEADERDATA, parser); err != nil {
 		return nil, fmt.Errorf("failed to set header data: %w", err)
 	}
 
-	// Perform the request
-	if err := easy.Perform(); err != nil {
+	// Collect certificate details for the monitor on TLS requests
+	collectCerts := t.CertMonitor != nil && req.URL.Scheme == "https"
+	if collectCerts {
+		if err := easy.Setopt(curl.OPT_CERTINFO, true); err != nil {
+			return nil, fmt.Errorf("failed to enable certificate info: %w", err)
+		}
+	}
+
+	if err := t.applySocketBinding(easy); err != nil {
+		return nil, err
+	}
 
-		runtime.KeepAlive(body)
-		runtime.KeepAlive(responseBuffer)
-		runtime.KeepAlive(responseHeaders)
-		return nil, fmt.Errorf("request failed: %w", err)
+	if stream != nil {
+		handleHandedOff, sinkHandedOff = true, true
+		return t.performStreaming(req, easy, route, parser, stream, body, collectCerts)
 	}
 
+	// Perform the request
+	t.stats.inFlight.Add(1)
+	performErr := t.performInNamespace(easy.Perform)
+	t.stats.inFlight.Add(-1)
+	t.stats.requests.Add(1)
+	sizes := t.recordTraffic(req, easy)
+
 	runtime.KeepAlive(body)
-	runtime.KeepAlive(responseBuffer)
-	runtime.KeepAlive(responseHeaders)
+	runtime.KeepAlive(writeData)
+	runtime.KeepAlive(parser)
+
+	if uploadSrc != nil && uploadSrc.err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", uploadSrc.err)
+	}
+	if parser.err != nil {
+		return nil, parser.err
+	}
+
+	// curl reports a body shorter than its Content-Length as a partial
+	// file; ContentLengthPolicy decides what happens to it below
+	var curlErr curl.CurlError
+	if performErr != nil && (!errors.As(performErr, &curlErr) || curlErr != curl.CurlError(curl.E_PARTIAL_FILE)) {
+		return nil, t.requestFailed(req, easy, route.proxy, performErr)
+	}
+
+	responseHeaders := parser.header
 
 	// Get response code
-	responseCodeInfo, err := easy.Getinfo(curl.INFO_RESPONSE_CODE)
+	responseCodeInfo, err := easy.Getinfo(infoResponseCode)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get response code: %w", err)
 	}
-	responseCode := int(responseCodeInfo.(int64))
+	code, ok := responseCodeInfo.(int64)
+	if !ok {
+		return nil, fmt.Errorf("unexpected response code type %T", responseCodeInfo)
+	}
+	responseCode := int(code)
 
-	// Get response body from buffer
-	responseBodyData := responseBuffer.Bytes()
+	t.observeConnection(req, easy, route, collectCerts)
 
 	// Get Content-Type from curl if not already captured
 	if responseHeaders.Get("Content-Type") == "" {
-		if contentType, err := easy.Getinfo(curl.INFO_CONTENT_TYPE); err == nil && contentType != nil {
+		if contentType, err := easy.Getinfo(infoContentType); err == nil && contentType != nil {
 			if ct, ok := contentType.(string); ok && ct != "" {
 				responseHeaders.Set("Content-Type", ct)
 			}
 		}
 	}
 
+	// Compare the body with its declared length
+	var received int64
+	if sink != nil {
+		received = sink.size
+	} else {
+		received = int64(len(responseBuffer.Bytes()))
+	}
+	keep, mismatch := received, false
+	if declared, ok := declaredContentLength(method, responseCode, responseHeaders); ok && declared != received {
+		if keep, err = applyContentLengthPolicy(t.ContentLengthPolicy, declared, received); err != nil {
+			return nil, err
+		}
+		mismatch = true
+	} else if performErr != nil {
+		return nil, fmt.Errorf("request failed: %w", performErr)
+	}
+
+	// Hand the collected body over to the response
+	var respBody metaBody
+	if sink != nil {
+		sinkHandedOff = true
+		mapped, err := sink.mappedBody()
+		if err != nil {
+			return nil, err
+		}
+		mapped.SectionReader = io.NewSectionReader(mapped.readerAt, 0, keep)
+		respBody = mapped
+	} else {
+		sinkHandedOff = true
+		body := newResponseBody(responseBuffer.Bytes()[:keep])
+		body.buf = responseBuffer.buffer
+		respBody = body
+	}
+	if mismatch {
+		respBody.meta().addNote(NoteContentLengthMismatch, fmt.Sprintf("Content-Length declared %s bytes but %d were received", responseHeaders.Get("Content-Length"), received))
+	}
+	contentLength := keep
+
+	respBody.meta().timings = collectTimings(easy)
+	respBody.meta().sizes = sizes
+	conn := collectConnInfo(easy, route.proxy)
+	respBody.meta().conn = conn
+	t.recordResolution(req, conn)
+	if conn.Reused {
+		t.stats.connectionsReused.Add(1)
+	} else {
+		t.stats.connectionsOpened.Add(1)
+	}
+
+	return buildResponse(responseCode, parser, respBody, contentLength), nil
+}
+
+// observeConnection feeds the certificate monitor and fingerprint tracker
+// with details of the transfer just performed on easy over route
+func (t *Transport) observeConnection(req *http.Request, easy curlEngine, route proxyRoute, collectCerts bool) {
+	if collectCerts {
+		if raw, err := easy.Getinfo(infoCertInfo); err == nil {
+			if info, ok := parseCertInfo(raw); ok {
+				t.CertMonitor.observe(strings.ToLower(req.URL.Hostname()), info)
+			}
+		}
+	}
+
+	if t.ServerFingerprints != nil && req.URL.Scheme == "https" && t.probesLikeTransfer(req, route) {
+		// Probe only when this transfer had to open a new connection
+		if connects, err := easy.Getinfo(infoNumConnects); err == nil {
+			if n, ok := connects.(int64); ok && n > 0 {
+				port := req.URL.Port()
+				if port == "" {
+					port = "443"
+				}
+				t.ServerFingerprints.maybeProbe(net.JoinHostPort(req.URL.Hostname(), port))
+			}
+		}
+	}
+}
+
+// probesLikeTransfer reports whether a fingerprint probe, which dials
+// directly with the net package, would reach the host the way the transfer
+// of req over route did. It would not when the transfer used a proxy,
+// including one from the environment, a bound device or network namespace,
+// or pinned addresses.
+func (t *Transport) probesLikeTransfer(req *http.Request, route proxyRoute) bool {
+	if route.proxy != nil || t.BindDevice != "" || t.NetworkNamespace != "" {
+		return false
+	}
+	if !route.override {
+		if proxy, err := http.ProxyFromEnvironment(req); err != nil || proxy != nil {
+			return false
+		}
+	}
+	t.configMu.RLock()
+	defer t.configMu.RUnlock()
+	return len(t.Resolve) == 0
+}
+
+// buildResponse assembles an http.Response from the status code, parsed
+// headers and body collected during a transfer. It only works on data
+// already copied out of curl, so it never touches handle state.
+func buildResponse(responseCode int, parser *headerParser, respBody metaBody, contentLength int64) *http.Response {
+	responseHeaders := parser.header
+
 	// Set default Content-Type if still not available
 	if responseHeaders.Get("Content-Type") == "" {
 		responseHeaders.Set("Content-Type", "application/json")
 	}
 
+	meta := respBody.meta()
+	meta.notes = append(meta.notes, parser.notes...)
+	meta.rawHeader = parser.raw
+	checkRedirectLocation(responseCode, responseHeaders, respBody)
+
+	// curl has already removed the transfer coding, so like net/http
+	// report it in TransferEncoding rather than the header
+	var transferEncoding []string
+	if values := responseHeaders.Values("Transfer-Encoding"); len(values) > 0 {
+		transferEncoding = []string{"chunked"}
+		responseHeaders.Del("Transfer-Encoding")
+	}
+
+	// Report the protocol from the status line, e.g. HTTP/2.0 for h2c
+	major, minor, ok := parseProtoVersion(parser.proto)
+	if !ok {
+		major, minor = 1, 1
+	}
+
 	// Create http.Response
 	resp := &http.Response{
-		Status:        fmt.Sprintf("%d %s", responseCode, http.StatusText(responseCode)),
-		StatusCode:    responseCode,
-		Proto:         "HTTP/1.1",
-		ProtoMajor:    1,
-		ProtoMinor:    1,
-		Header:        responseHeaders,
-		Body:          io.NopCloser(bytes.NewReader(responseBodyData)),
-		ContentLength: int64(len(responseBodyData)),
+		Status:           fmt.Sprintf("%d %s", responseCode, http.StatusText(responseCode)),
+		StatusCode:       responseCode,
+		Proto:            fmt.Sprintf("HTTP/%d.%d", major, minor),
+		ProtoMajor:       major,
+		ProtoMinor:       minor,
+		Header:           responseHeaders,
+		Body:             respBody,
+		ContentLength:    contentLength,
+		TransferEncoding: transferEncoding,
+		Trailer:          announcedTrailers(responseHeaders),
 	}
-
-	return resp, nil
+	fillTrailers(resp, parser.trailer)
+	return resp
 }
 
 // Client wraps http.Client to use our custom Transport that provides
@@ -666,6 +1643,7 @@ func (t *Transport) performOptimizedRequest(url, method string, headers map[stri
 type Client struct {
 	http.Client
 	initialized bool
+	scope       *clientScope // set on clients derived with WithBase, WithHeader or WithQuery
 }
 
 // ensureInitialized initializes the Client with default settings if it hasn't been initialized yet.
@@ -683,12 +1661,13 @@ func (c *Client) ensureInitialized() {
 }
 
 // NewClient creates a new Client that uses go-curl-impersonate with default settings.
-// The client will impersonate Chrome 136 by default with a 30-second timeout.
+// The client will impersonate Chrome 136 by default with a 30-second timeout,
+// or CURLHTTP_TIMEOUT_MS when set.
 func NewClient() *Client {
 	return &Client{
 		Client: http.Client{
 			Transport: NewTransport(),
-			Timeout:   30 * time.Second,
+			Timeout:   envClientTimeout(),
 		},
 		initialized: true,
 	}
@@ -714,7 +1693,7 @@ func NewClientWithTarget(target string) *Client {
 var DefaultClient = &Client{
 	Client: http.Client{
 		Transport: NewTransport(),
-		Timeout:   30 * time.Second,
+		Timeout:   envClientTimeout(),
 	},
 	initialized: true,
 }
@@ -724,30 +1703,48 @@ var DefaultClient = &Client{
 // Get makes a GET request. Ensures the client is initialized if needed for zero-value compatibility.
 func (c *Client) Get(url string) (*Response, error) {
 	c.ensureInitialized()
+	if c.scope != nil {
+		return c.scopedRequest(http.MethodGet, url, "", nil)
+	}
 	return c.Client.Get(url)
 }
 
 // Post makes a POST request. Ensures the client is initialized if needed for zero-value compatibility.
 func (c *Client) Post(url, contentType string, body io.Reader) (*Response, error) {
 	c.ensureInitialized()
+	if c.scope != nil {
+		return c.scopedRequest(http.MethodPost, url, contentType, body)
+	}
 	return c.Client.Post(url, contentType, body)
 }
 
 // PostForm makes a POST request with form data. Ensures the client is initialized if needed for zero-value compatibility.
 func (c *Client) PostForm(url string, data url.Values) (*Response, error) {
 	c.ensureInitialized()
+	if c.scope != nil {
+		return c.scopedRequest(http.MethodPost, url, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+	}
 	return c.Client.PostForm(url, data)
 }
 
 // Head makes a HEAD request. Ensures the client is initialized if needed for zero-value compatibility.
 func (c *Client) Head(url string) (*Response, error) {
 	c.ensureInitialized()
+	if c.scope != nil {
+		return c.scopedRequest(http.MethodHead, url, "", nil)
+	}
 	return c.Client.Head(url)
 }
 
 // Do sends an HTTP request. Ensures the client is initialized if needed for zero-value compatibility.
 func (c *Client) Do(req *Request) (*Response, error) {
 	c.ensureInitialized()
+	if c.scope != nil && req != nil && req.URL != nil {
+		var err error
+		if req, err = c.scope.apply(req); err != nil {
+			return nil, err
+		}
+	}
 	return c.Client.Do(req)
 }
 
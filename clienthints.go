@@ -0,0 +1,431 @@
+package curlhttp
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Platform overrides the operating system a Transport claims to run on. The
+// User-Agent and the Sec-CH-UA-* client hints are both derived from it, so
+// they stay consistent. Empty fields use typical values for Name.
+type Platform struct {
+	// Name is the Sec-CH-UA-Platform value: "Windows", "macOS", "Linux",
+	// "Android" or "Chrome OS".
+	Name string
+
+	// Version, Arch, Bitness and Model are the high-entropy hints sent
+	// when a server asks for them with Accept-CH. Model is only reported
+	// on Android.
+	Version string
+	Arch    string
+	Bitness string
+	Model   string
+}
+
+// Known platform names
+const (
+	PlatformWindows  = "Windows"
+	PlatformMacOS    = "macOS"
+	PlatformLinux    = "Linux"
+	PlatformAndroid  = "Android"
+	PlatformChromeOS = "Chrome OS"
+)
+
+// platformDefaults holds the high-entropy values reported for each platform
+var platformDefaults = map[string]Platform{
+	PlatformWindows:  {Name: PlatformWindows, Version: "15.0.0", Arch: "x86", Bitness: "64"},
+	PlatformMacOS:    {Name: PlatformMacOS, Version: "14.5.0", Arch: "arm", Bitness: "64"},
+	PlatformLinux:    {Name: PlatformLinux, Version: "6.5.0", Arch: "x86", Bitness: "64"},
+	PlatformAndroid:  {Name: PlatformAndroid, Version: "14.0.0", Model: "Pixel 7"},
+	PlatformChromeOS: {Name: PlatformChromeOS, Version: "16002.44.0", Arch: "x86", Bitness: "64"},
+}
+
+// withDefaults fills empty fields from the defaults for p's platform
+func (p Platform) withDefaults() Platform {
+	d := platformDefaults[p.Name]
+	if p.Version == "" {
+		p.Version = d.Version
+	}
+	if p.Arch == "" {
+		p.Arch = d.Arch
+	}
+	if p.Bitness == "" {
+		p.Bitness = d.Bitness
+	}
+	if p.Model == "" {
+		p.Model = d.Model
+	}
+	return p
+}
+
+// Brand is one entry of a Sec-CH-UA brand list
+type Brand struct {
+	Brand   string
+	Version string
+}
+
+// ClientHints are the User-Agent client hints a Chromium-based browser sends
+type ClientHints struct {
+	Brands          []Brand // major versions, for Sec-CH-UA
+	FullVersionList []Brand // full versions, for Sec-CH-UA-Full-Version-List
+	FullVersion     string
+	Mobile          bool
+	Platform        Platform
+}
+
+// Header returns the value of the named client hint header and whether the
+// hints have one
+func (h *ClientHints) Header(name string) (string, bool) {
+	switch strings.ToLower(name) {
+	case "sec-ch-ua":
+		return formatBrands(h.Brands), true
+	case "sec-ch-ua-full-version-list":
+		return formatBrands(h.FullVersionList), true
+	case "sec-ch-ua-full-version":
+		return strconv.Quote(h.FullVersion), true
+	case "sec-ch-ua-mobile":
+		if h.Mobile {
+			return "?1", true
+		}
+		return "?0", true
+	case "sec-ch-ua-platform":
+		return strconv.Quote(h.Platform.Name), true
+	case "sec-ch-ua-platform-version":
+		return strconv.Quote(h.Platform.Version), true
+	case "sec-ch-ua-arch":
+		return strconv.Quote(h.Platform.Arch), true
+	case "sec-ch-ua-bitness":
+		return strconv.Quote(h.Platform.Bitness), true
+	case "sec-ch-ua-model":
+		if h.Platform.Name != PlatformAndroid {
+			return `""`, true
+		}
+		return strconv.Quote(h.Platform.Model), true
+	case "sec-ch-ua-wow64":
+		return "?0", true
+	case "sec-ch-ua-form-factors":
+		if h.Mobile {
+			return `"Mobile"`, true
+		}
+		return `"Desktop"`, true
+	}
+	return "", false
+}
+
+// formatBrands serializes a brand list as a structured header list
+func formatBrands(brands []Brand) string {
+	parts := make([]string, len(brands))
+	for i, b := range brands {
+		parts[i] = fmt.Sprintf("%s;v=%s", strconv.Quote(b.Brand), strconv.Quote(b.Version))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// lowEntropyHints are sent on every request by Chromium-based browsers
+var lowEntropyHints = []string{"Sec-Ch-Ua", "Sec-Ch-Ua-Mobile", "Sec-Ch-Ua-Platform"}
+
+// chromeFullVersions holds the full version of the Chrome release each
+// known target was captured from; other versions report major.0.0.0
+var chromeFullVersions = map[int]string{
+	99:  "99.0.4844.51",
+	100: "100.0.4896.127",
+	101: "101.0.4951.67",
+	104: "104.0.5112.81",
+	107: "107.0.5304.121",
+	110: "110.0.5481.177",
+	116: "116.0.5845.180",
+	119: "119.0.6045.199",
+	120: "120.0.6099.109",
+	123: "123.0.6312.86",
+	124: "124.0.6367.91",
+	131: "131.0.6778.86",
+	133: "133.0.6943.127",
+	136: "136.0.7103.93",
+}
+
+// edgeFullVersions holds the Edge versions of the known edge targets
+var edgeFullVersions = map[int]string{
+	99:  "99.0.1150.30",
+	101: "101.0.1210.47",
+}
+
+// chromeFullVersion returns the full version for a Chrome major version
+func chromeFullVersion(major int) string {
+	if v, ok := chromeFullVersions[major]; ok {
+		return v
+	}
+	return fmt.Sprintf("%d.0.0.0", major)
+}
+
+// defaultPlatform is the platform a target's User-Agent names. Android
+// targets run on Android, early Chrome and Edge targets on Windows and
+// later ones on macOS.
+func defaultPlatform(target string) string {
+	family, variant, version := splitTarget(target)
+	switch {
+	case variant == "android":
+		return PlatformAndroid
+	case family == "edge" || version < 116_000_000:
+		return PlatformWindows
+	default:
+		return PlatformMacOS
+	}
+}
+
+// TargetUserAgent returns the User-Agent a Chromium-based target sends on
+// platform, or on its own platform if platform is empty. It returns false
+// for Firefox and Safari targets.
+func TargetUserAgent(target, platform string) (string, bool) {
+	family, _, version := splitTarget(target)
+	if family != "chrome" && family != "edge" {
+		return "", false
+	}
+	major := version / 1_000_000
+	if platform == "" {
+		platform = defaultPlatform(target)
+	}
+
+	// Chrome 104 and later report a reduced version in the User-Agent
+	chrome := chromeFullVersion(major)
+	if major >= 104 {
+		chrome = fmt.Sprintf("%d.0.0.0", major)
+	}
+
+	var os string
+	switch platform {
+	case PlatformWindows:
+		os = "Windows NT 10.0; Win64; x64"
+	case PlatformMacOS:
+		os = "Macintosh; Intel Mac OS X 10_15_7"
+	case PlatformLinux:
+		os = "X11; Linux x86_64"
+	case PlatformChromeOS:
+		os = "X11; CrOS x86_64 14541.0.0"
+	case PlatformAndroid:
+		return fmt.Sprintf("Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%s Mobile Safari/537.36", chrome), true
+	default:
+		return "", false
+	}
+	ua := fmt.Sprintf("Mozilla/5.0 (%s) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%s Safari/537.36", os, chrome)
+	if family == "edge" {
+		edge, ok := edgeFullVersions[major]
+		if !ok {
+			edge = fmt.Sprintf("%d.0.0.0", major)
+		}
+		ua += " Edg/" + edge
+	}
+	return ua, true
+}
+
+var (
+	chromeVersionPattern = regexp.MustCompile(`Chrome/(\d+)((?:\.\d+)*)`)
+	edgeVersionPattern   = regexp.MustCompile(`Edg/(\d+(?:\.\d+)*)`)
+)
+
+// ParseClientHints derives the client hints a Chromium-based browser with
+// the given User-Agent sends. Values the User-Agent does not determine,
+// such as the platform version, come from platform when its Name matches
+// and from typical values otherwise. It returns false for User-Agents of
+// browsers that do not send client hints.
+func ParseClientHints(userAgent string, platform *Platform) (*ClientHints, bool) {
+	m := chromeVersionPattern.FindStringSubmatch(userAgent)
+	if m == nil || strings.Contains(userAgent, "Firefox/") {
+		return nil, false
+	}
+	major, err := strconv.Atoi(m[1])
+	if err != nil {
+		return nil, false
+	}
+
+	hints := &ClientHints{Mobile: strings.Contains(userAgent, "Mobile")}
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		hints.Platform.Name = PlatformAndroid
+	case strings.Contains(userAgent, "Windows"):
+		hints.Platform.Name = PlatformWindows
+	case strings.Contains(userAgent, "CrOS"):
+		hints.Platform.Name = PlatformChromeOS
+	case strings.Contains(userAgent, "Macintosh"):
+		hints.Platform.Name = PlatformMacOS
+	default:
+		hints.Platform.Name = PlatformLinux
+	}
+	if platform != nil && platform.Name == hints.Platform.Name {
+		hints.Platform = *platform
+	}
+	hints.Platform = hints.Platform.withDefaults()
+
+	full := m[1] + m[2]
+	if m[2] == "" || m[2] == ".0.0.0" {
+		full = chromeFullVersion(major)
+	}
+	hints.FullVersion = full
+
+	brand, brandFull := "Google Chrome", full
+	if e := edgeVersionPattern.FindStringSubmatch(userAgent); e != nil {
+		brand, brandFull = "Microsoft Edge", e[1]
+	}
+	hints.Brands = greaseBrands(major, brand, strconv.Itoa(major), strconv.Itoa(major))
+	hints.FullVersionList = greaseBrands(major, brand, full, brandFull)
+	for i, b := range hints.FullVersionList {
+		if !strings.Contains(b.Version, ".") {
+			hints.FullVersionList[i].Version = b.Version + ".0.0.0"
+		}
+	}
+	return hints, true
+}
+
+// greaseBrands builds the brand list Chromium sends for a major version:
+// the real brand, Chromium and a GREASE brand whose name, version and
+// position are derived from the major version
+func greaseBrands(major int, brand, chromiumVersion, brandVersion string) []Brand {
+	chromium := Brand{Brand: "Chromium", Version: chromiumVersion}
+	browser := Brand{Brand: brand, Version: brandVersion}
+
+	// Releases before 105 used a fixed GREASE brand
+	if major < 105 {
+		grease := Brand{Brand: " Not A;Brand", Version: "99"}
+		if major == 104 {
+			return []Brand{chromium, grease, browser}
+		}
+		return []Brand{grease, chromium, browser}
+	}
+
+	chars := []string{" ", "(", ":", "-", ".", "/", ")", ";", "=", "?", "_"}
+	versions := []string{"8", "99", "24"}
+	orders := [][3]int{{0, 1, 2}, {0, 2, 1}, {1, 0, 2}, {1, 2, 0}, {2, 0, 1}, {2, 1, 0}}
+
+	grease := Brand{
+		Brand:   "Not" + chars[major%len(chars)] + "A" + chars[(major+1)%len(chars)] + "Brand",
+		Version: versions[major%len(versions)],
+	}
+	order := orders[major%len(orders)]
+	brands := make([]Brand, 3)
+	brands[order[0]] = grease
+	brands[order[1]] = chromium
+	brands[order[2]] = browser
+	return brands
+}
+
+// acceptedHints remembers, per origin, the client hints servers asked for
+// with Accept-CH
+type acceptedHints struct {
+	mu      sync.Mutex
+	origins map[string][]string
+}
+
+// get returns the hints accepted by origin
+func (a *acceptedHints) get(origin string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.origins[origin]
+}
+
+// set replaces the hints accepted by origin
+func (a *acceptedHints) set(origin string, hints []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.origins == nil {
+		a.origins = make(map[string][]string)
+	}
+	if len(hints) == 0 {
+		delete(a.origins, origin)
+		return
+	}
+	a.origins[origin] = hints
+}
+
+// parseHintList splits an Accept-CH or Critical-CH header into canonical
+// header names
+func parseHintList(values []string) []string {
+	var hints []string
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			if name = trimOWS(name); name != "" {
+				hints = append(hints, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return hints
+}
+
+// sendsClientHints reports whether requests carry client hints: when the
+// target's default headers are used or a Platform is set
+func (t *Transport) sendsClientHints() bool {
+	return t.UseDefaultHeaders || t.Platform != nil
+}
+
+// applyClientHints sets the User-Agent and the client hints that go with
+// it, including high-entropy hints the origin asked for through Accept-CH.
+// Headers set on the request take precedence, and hints are derived from a
+// User-Agent the request sets, as UserAgentPolicy allows, so the two cannot
+// disagree.
+func (t *Transport) applyClientHints(req *http.Request, headers map[string]string) {
+	if !t.sendsClientHints() {
+		return
+	}
+	target, _ := t.requestTarget(req)
+	userAgent, set := headers["User-Agent"]
+	if !set {
+		platform := ""
+		if t.Platform != nil {
+			platform = t.Platform.Name
+		}
+		ua, ok := TargetUserAgent(target, platform)
+		if !ok {
+			return
+		}
+		userAgent = ua
+		headers["User-Agent"] = ua
+	}
+
+	hints, ok := ParseClientHints(userAgent, t.Platform)
+	if !ok {
+		return
+	}
+	names := lowEntropyHints
+	if req.URL.Scheme == "https" {
+		names = append(append([]string(nil), names...), t.acceptedHints.get(origin(req.URL))...)
+	}
+	for _, name := range names {
+		if _, set := headers[name]; set {
+			continue
+		}
+		if value, ok := hints.Header(name); ok {
+			headers[name] = value
+		}
+	}
+}
+
+// observeClientHints records the hints an HTTPS response asks for with
+// Accept-CH and reports whether the request should be retried because
+// Critical-CH names a hint it now has but did not send
+func (t *Transport) observeClientHints(req *http.Request, resp *http.Response, sent map[string]string) bool {
+	if !t.sendsClientHints() || req.URL.Scheme != "https" {
+		return false
+	}
+	values, ok := resp.Header["Accept-Ch"]
+	if !ok {
+		return false
+	}
+	accepted := parseHintList(values)
+	t.acceptedHints.set(origin(req.URL), accepted)
+
+	known := make(map[string]bool, len(accepted))
+	for _, name := range accepted {
+		known[name] = true
+	}
+	for _, name := range parseHintList(resp.Header.Values("Critical-Ch")) {
+		isUA := strings.HasPrefix(name, "Sec-Ch-Ua")
+		if _, was := sent[name]; isUA && known[name] && !was {
+			// The body has already been buffered, so only the method
+			// decides whether sending it again is safe
+			return isIdempotent(req)
+		}
+	}
+	return false
+}
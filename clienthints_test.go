@@ -0,0 +1,195 @@
+package curlhttp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// sentHeaders returns the headers the fake engine was asked to send
+func sentHeaders(fake *fakeEngine) map[string]string {
+	sent := make(map[string]string)
+	lines, _ := fake.performed[curl.OPT_HTTPHEADER].([]string)
+	for _, line := range lines {
+		name, value, _ := strings.Cut(line, ": ")
+		sent[name] = value
+	}
+	return sent
+}
+
+// TestGreaseBrands tests brand lists against values sent by real Chrome releases
+func TestGreaseBrands(t *testing.T) {
+	tests := map[int]string{
+		99:  `" Not A;Brand";v="99", "Chromium";v="99", "Google Chrome";v="99"`,
+		107: `"Google Chrome";v="107", "Chromium";v="107", "Not=A?Brand";v="24"`,
+		110: `"Chromium";v="110", "Not A(Brand";v="24", "Google Chrome";v="110"`,
+		120: `"Not_A Brand";v="8", "Chromium";v="120", "Google Chrome";v="120"`,
+		131: `"Google Chrome";v="131", "Chromium";v="131", "Not_A Brand";v="24"`,
+		133: `"Not(A:Brand";v="99", "Google Chrome";v="133", "Chromium";v="133"`,
+		136: `"Chromium";v="136", "Google Chrome";v="136", "Not.A/Brand";v="99"`,
+	}
+	for major, want := range tests {
+		v := strconv.Itoa(major)
+		if got := formatBrands(greaseBrands(major, "Google Chrome", v, v)); got != want {
+			t.Errorf("Chrome %d: expected %s, got %s", major, want, got)
+		}
+	}
+}
+
+// TestTargetClientHints tests that hints agree with each target's User-Agent
+func TestTargetClientHints(t *testing.T) {
+	tests := []struct {
+		target   string
+		platform string
+		uaPart   string
+		wantPlat string
+		mobile   bool
+	}{
+		{"chrome136", "", "Macintosh", `"macOS"`, false},
+		{"chrome136", PlatformWindows, "Windows NT 10.0", `"Windows"`, false},
+		{"chrome131_android", "", "Android", `"Android"`, true},
+		{"edge101", "", "Edg/101.0.1210.47", `"Windows"`, false},
+		{"chrome124", PlatformLinux, "X11; Linux", `"Linux"`, false},
+	}
+	for _, tt := range tests {
+		ua, ok := TargetUserAgent(tt.target, tt.platform)
+		if !ok || !strings.Contains(ua, tt.uaPart) {
+			t.Errorf("%s: expected User-Agent containing %q, got %q", tt.target, tt.uaPart, ua)
+			continue
+		}
+		hints, ok := ParseClientHints(ua, nil)
+		if !ok {
+			t.Errorf("%s: expected client hints for %q", tt.target, ua)
+			continue
+		}
+		if got, _ := hints.Header("Sec-CH-UA-Platform"); got != tt.wantPlat {
+			t.Errorf("%s: expected platform %s, got %s", tt.target, tt.wantPlat, got)
+		}
+		if hints.Mobile != tt.mobile {
+			t.Errorf("%s: expected mobile %v, got %v", tt.target, tt.mobile, hints.Mobile)
+		}
+	}
+
+	edge, _ := TargetUserAgent("edge99", "")
+	hints, _ := ParseClientHints(edge, nil)
+	if got, _ := hints.Header("Sec-CH-UA"); !strings.Contains(got, `"Microsoft Edge";v="99"`) {
+		t.Errorf("Expected Microsoft Edge brand, got %s", got)
+	}
+	if got, _ := hints.Header("Sec-CH-UA-Full-Version-List"); !strings.Contains(got, `"Microsoft Edge";v="99.0.1150.30"`) {
+		t.Errorf("Expected Edge full version, got %s", got)
+	}
+
+	for _, target := range []string{"firefox133", "safari18_0"} {
+		if ua, ok := TargetUserAgent(target, ""); ok {
+			t.Errorf("Expected no User-Agent for %s, got %q", target, ua)
+		}
+	}
+}
+
+// TestClientHintsSent tests the User-Agent and hints added to requests
+func TestClientHintsSent(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+	transport.Platform = &Platform{Name: PlatformWindows}
+
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+
+	sent := sentHeaders(fake)
+	if !strings.Contains(sent["User-Agent"], "Windows NT 10.0") {
+		t.Errorf("Expected a Windows User-Agent, got %q", sent["User-Agent"])
+	}
+	if sent["Sec-Ch-Ua-Platform"] != `"Windows"` {
+		t.Errorf("Expected Windows platform hint, got %q", sent["Sec-Ch-Ua-Platform"])
+	}
+	if sent["Sec-Ch-Ua-Mobile"] != "?0" {
+		t.Errorf("Expected ?0 mobile hint, got %q", sent["Sec-Ch-Ua-Mobile"])
+	}
+	if _, ok := sent["Sec-Ch-Ua-Arch"]; ok {
+		t.Error("Expected no high-entropy hints without Accept-CH")
+	}
+
+	// Hints follow a User-Agent set on the request
+	req, _ = http.NewRequest("GET", "https://example.com/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Mobile Safari/537.36")
+	resp, _ = transport.RoundTrip(req)
+	resp.Body.Close()
+	sent = sentHeaders(fake)
+	if sent["Sec-Ch-Ua-Platform"] != `"Android"` || sent["Sec-Ch-Ua-Mobile"] != "?1" {
+		t.Errorf("Expected Android mobile hints, got %q and %q", sent["Sec-Ch-Ua-Platform"], sent["Sec-Ch-Ua-Mobile"])
+	}
+
+	// Firefox does not send client hints
+	fake = newFakeEngine("")
+	transport = newFakeTransport(fake)
+	transport.ImpersonateTarget = "firefox133"
+	req, _ = http.NewRequest("GET", "https://example.com/", nil)
+	resp, _ = transport.RoundTrip(req)
+	resp.Body.Close()
+	if ua, ok := sentHeaders(fake)["Sec-Ch-Ua"]; ok {
+		t.Errorf("Expected no Sec-CH-UA for firefox, got %q", ua)
+	}
+}
+
+// countingEngine counts transfers and stops sending Accept-CH after the first
+type countingEngine struct {
+	*fakeEngine
+	performs int
+}
+
+func (c *countingEngine) Perform() error {
+	c.performs++
+	if c.performs > 1 {
+		c.headers = []string{"HTTP/1.1 200 OK\r\n", "\r\n"}
+	}
+	return c.fakeEngine.Perform()
+}
+
+// TestAcceptCH tests that requested hints are sent and Critical-CH retries
+func TestAcceptCH(t *testing.T) {
+	fake := &countingEngine{fakeEngine: newFakeEngine("")}
+	fake.headers = []string{
+		"HTTP/1.1 200 OK\r\n",
+		"Accept-CH: Sec-CH-UA-Full-Version-List, Sec-CH-UA-Arch, Sec-CH-UA-Model\r\n",
+		"Critical-CH: Sec-CH-UA-Arch\r\n",
+		"\r\n",
+	}
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return fake }
+
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if fake.performs != 2 {
+		t.Errorf("Expected a Critical-CH retry, got %d transfers", fake.performs)
+	}
+	sent := sentHeaders(fake.fakeEngine)
+	if sent["Sec-Ch-Ua-Arch"] != `"arm"` {
+		t.Errorf("Expected arch hint, got %q", sent["Sec-Ch-Ua-Arch"])
+	}
+	if sent["Sec-Ch-Ua-Model"] != `""` {
+		t.Errorf("Expected empty model hint, got %q", sent["Sec-Ch-Ua-Model"])
+	}
+	if !strings.Contains(sent["Sec-Ch-Ua-Full-Version-List"], `"Google Chrome";v="136.0.7103.93"`) {
+		t.Errorf("Expected full version list, got %q", sent["Sec-Ch-Ua-Full-Version-List"])
+	}
+
+	// Other origins do not get them
+	req, _ = http.NewRequest("GET", "https://other.example.com/", nil)
+	resp, _ = transport.RoundTrip(req)
+	resp.Body.Close()
+	if arch, ok := sentHeaders(fake.fakeEngine)["Sec-Ch-Ua-Arch"]; ok {
+		t.Errorf("Expected no arch hint for another origin, got %q", arch)
+	}
+}
@@ -0,0 +1,80 @@
+package curlhttp
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestFakeClock tests that After channels fire only when the clock is advanced
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	short := clock.After(time.Second)
+	long := clock.After(time.Minute)
+	if clock.Waiters() != 2 {
+		t.Fatalf("Expected 2 waiters, got %d", clock.Waiters())
+	}
+
+	clock.Advance(2 * time.Second)
+	select {
+	case got := <-short:
+		if !got.Equal(start.Add(2 * time.Second)) {
+			t.Errorf("Expected fire time %v, got %v", start.Add(2*time.Second), got)
+		}
+	default:
+		t.Error("Expected short timer to fire")
+	}
+	select {
+	case <-long:
+		t.Error("Expected long timer not to fire yet")
+	default:
+	}
+
+	clock.Set(start.Add(time.Hour))
+	select {
+	case <-long:
+	default:
+		t.Error("Expected long timer to fire after Set")
+	}
+	if !clock.Now().Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected now %v, got %v", start.Add(time.Hour), clock.Now())
+	}
+	if clock.Waiters() != 0 {
+		t.Errorf("Expected no waiters, got %d", clock.Waiters())
+	}
+}
+
+// TestFingerprintTrackerClock tests probe intervals against a fake clock
+func TestFingerprintTrackerClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC))
+	tracker := &FingerprintTracker{Interval: time.Hour, Clock: clock}
+	probes := make(chan struct{}, 2)
+	tracker.probe = func(ctx context.Context, address string) (*ServerFingerprint, error) {
+		probes <- struct{}{}
+		return &ServerFingerprint{Host: "example.com"}, nil
+	}
+
+	if _, err := tracker.Check(context.Background(), "example.com:443"); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	<-probes
+	fp, _ := tracker.Fingerprint("example.com")
+	if !fp.ProbedAt.Equal(clock.Now()) {
+		t.Errorf("Expected ProbedAt from the clock, got %v", fp.ProbedAt)
+	}
+
+	// Within the interval no probe is started
+	tracker.maybeProbe("example.com:443")
+	tracker.mu.Lock()
+	probing := tracker.probing["example.com"]
+	tracker.mu.Unlock()
+	if probing {
+		t.Error("Expected no probe within the interval")
+	}
+
+	clock.Advance(2 * time.Hour)
+	tracker.maybeProbe("example.com:443")
+	<-probes
+}
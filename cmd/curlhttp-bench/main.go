@@ -0,0 +1,165 @@
+// Command curlhttp-bench drives concurrent load against one or more URLs
+// through the curl-impersonate transport and reports throughput, latency
+// percentiles, error classes and pool statistics.
+//
+//	curlhttp-bench -c 50 -n 10000 https://example.com/
+//	curlhttp-bench -c 20 -d 30s -urls urls.txt -config scraper.yaml
+//	curlhttp-bench -X POST -H "Content-Type: application/json" -data '{"q":1}' https://api.example.com/search
+//
+// URLs come from the arguments and from -urls, one per line. Settings
+// from -config and the CURLHTTP_ environment variables apply as for any
+// other Transport; -target overrides the impersonation target.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	curlhttp "github.com/dstockton/go-curl-impersonate-net-http-wrapper"
+)
+
+// headerFlags collects repeated -H flags
+type headerFlags []string
+
+func (h *headerFlags) String() string     { return strings.Join(*h, ", ") }
+func (h *headerFlags) Set(v string) error { *h = append(*h, v); return nil }
+
+func main() {
+	concurrency := flag.Int("c", 10, "requests in flight")
+	requests := flag.Int("n", 0, "total requests (default one per URL)")
+	duration := flag.Duration("d", 0, "run for this long instead of -n requests")
+	method := flag.String("X", "GET", "request method")
+	data := flag.String("data", "", "request body")
+	urlsFile := flag.String("urls", "", "file with one URL per line")
+	configFile := flag.String("config", "", "JSON or YAML transport configuration")
+	target := flag.String("target", "", "impersonation target")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	var headers headerFlags
+	flag.Var(&headers, "H", `request header "Name: value" (repeatable)`)
+	flag.Parse()
+
+	urls := flag.Args()
+	if *urlsFile != "" {
+		listed, err := readURLs(*urlsFile)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		urls = append(urls, listed...)
+	}
+	if len(urls) == 0 {
+		fatalf("no URLs given")
+	}
+
+	transport := curlhttp.NewTransport()
+	if *configFile != "" {
+		cfg, err := curlhttp.LoadConfig(*configFile)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		if transport, err = cfg.NewTransport(); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	if *target != "" {
+		transport.ImpersonateTarget = *target
+	}
+
+	bench := &curlhttp.Bench{
+		Transport:   transport,
+		URLs:        urls,
+		Method:      *method,
+		Concurrency: *concurrency,
+		Requests:    *requests,
+		Duration:    *duration,
+	}
+	if len(headers) > 0 {
+		bench.Header = http.Header{}
+		for _, h := range headers {
+			name, value, ok := strings.Cut(h, ":")
+			if !ok {
+				fatalf("invalid header %q, want \"Name: value\"", h)
+			}
+			bench.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+	if *data != "" {
+		bench.Body = []byte(*data)
+	}
+
+	// Interrupting reports what has completed so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := bench.Run(ctx)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if *asJSON {
+		out, err := json.MarshalIndent(jsonReport(report), "", "  ")
+		if err != nil {
+			fatalf("failed to encode report: %v", err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Print(report)
+}
+
+// readURLs reads one URL per line, skipping blank lines and # comments
+func readURLs(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read URL list: %w", err)
+	}
+	defer f.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			urls = append(urls, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read URL list: %w", err)
+	}
+	return urls, nil
+}
+
+// jsonReport converts durations to milliseconds for machine consumption
+func jsonReport(r *curlhttp.BenchReport) map[string]any {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return map[string]any{
+		"requests":       r.Requests,
+		"succeeded":      r.Succeeded,
+		"failed":         r.Failed,
+		"elapsed_ms":     ms(r.Elapsed),
+		"throughput_rps": r.Throughput,
+		"bytes_received": r.BytesReceived,
+		"latency_ms": map[string]float64{
+			"min":  ms(r.Latency.Min),
+			"mean": ms(r.Latency.Mean),
+			"p50":  ms(r.Latency.P50),
+			"p90":  ms(r.Latency.P90),
+			"p99":  ms(r.Latency.P99),
+			"max":  ms(r.Latency.Max),
+		},
+		"status_codes": r.StatusCodes,
+		"errors":       r.Errors,
+		"pool":         r.Pool,
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "curlhttp-bench: "+format+"\n", args...)
+	os.Exit(2)
+}
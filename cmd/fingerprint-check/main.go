@@ -0,0 +1,110 @@
+// Command fingerprint-check verifies that impersonation still produces the
+// expected TLS and HTTP/2 fingerprints, for example after upgrading
+// curl-impersonate.
+//
+// Record a baseline with a known-good build, then compare later builds
+// against it:
+//
+//	fingerprint-check -target chrome136 -record chrome136.json
+//	fingerprint-check -target chrome136 -baseline chrome136.json
+//
+// The command exits with status 1 when the fingerprint has drifted.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	curlhttp "github.com/dstockton/go-curl-impersonate-net-http-wrapper"
+)
+
+func main() {
+	target := flag.String("target", "chrome136", "impersonation target to check")
+	endpoint := flag.String("endpoint", curlhttp.DefaultFingerprintEndpoint, "fingerprint echo service (tls.peet.ws format)")
+	baseline := flag.String("baseline", "", "JSON file with the expected fingerprint")
+	record := flag.String("record", "", "write the observed fingerprint to this JSON file as a new baseline")
+	timeout := flag.Duration("timeout", 30*time.Second, "request timeout")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	verifier := &curlhttp.FingerprintVerifier{Endpoint: *endpoint}
+	if *baseline != "" {
+		expected, err := readBaseline(*baseline)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		verifier.Expected = expected
+	}
+
+	report, err := verifier.Verify(ctx, *target)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	observed := report.Observed
+	fmt.Printf("target:       %s\n", report.Target)
+	fmt.Printf("http version: %s\n", observed.HTTPVersion)
+	fmt.Printf("ja3 hash:     %s\n", observed.JA3Hash)
+	fmt.Printf("ja4:          %s\n", observed.JA4)
+	fmt.Printf("akamai:       %s (%s)\n", observed.Akamai, observed.AkamaiHash)
+	fmt.Printf("header order: %v\n", observed.HeaderOrder)
+
+	if *record != "" {
+		if err := writeBaseline(*record, observed); err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Printf("baseline written to %s\n", *record)
+	}
+
+	if !report.OK() {
+		fmt.Println("\nfingerprint drift detected:")
+		for _, drift := range report.Drift {
+			fmt.Printf("  %s\n", drift)
+		}
+		os.Exit(1)
+	}
+	if *baseline != "" {
+		fmt.Println("\nfingerprint matches baseline")
+	}
+}
+
+// readBaseline loads an expected fingerprint
+func readBaseline(path string) (*curlhttp.ExpectedFingerprint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+	var expected curlhttp.ExpectedFingerprint
+	if err := json.Unmarshal(data, &expected); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline: %w", err)
+	}
+	return &expected, nil
+}
+
+// writeBaseline saves the stable parts of observed as an expected
+// fingerprint; JA3 is left out since browsers shuffle TLS extensions
+func writeBaseline(path string, observed curlhttp.ClientFingerprint) error {
+	data, err := json.MarshalIndent(curlhttp.ExpectedFingerprint{
+		JA4:         observed.JA4,
+		AkamaiHash:  observed.AkamaiHash,
+		HeaderOrder: observed.HeaderOrder,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode baseline: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write baseline: %w", err)
+	}
+	return nil
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "fingerprint-check: "+format+"\n", args...)
+	os.Exit(2)
+}
@@ -0,0 +1,199 @@
+package compat
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// get returns a Request function for a GET to path
+func get(path string) func(base string) (*http.Request, error) {
+	return func(base string) (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, base+path, nil)
+	}
+}
+
+// DefaultCases returns the standard matrix: redirects, cookies, request
+// and response headers, timeouts, chunked and compressed bodies, trailers,
+// HEAD requests, error statuses, request bodies and query strings
+func DefaultCases() []Case {
+	return []Case{
+		{
+			Name: "redirects",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/":
+					http.Redirect(w, r, "a", http.StatusFound)
+				case "/a":
+					http.Redirect(w, r, "/redirects/b", http.StatusMovedPermanently)
+				default:
+					fmt.Fprint(w, "done")
+				}
+			},
+			Request: get(""),
+		},
+		{
+			Name: "redirect-post",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/" {
+					http.Redirect(w, r, "result", http.StatusSeeOther)
+					return
+				}
+				body, _ := io.ReadAll(r.Body)
+				fmt.Fprintf(w, "%s %q", r.Method, body)
+			},
+			Request: func(base string) (*http.Request, error) {
+				return http.NewRequest(http.MethodPost, base, strings.NewReader("form=1"))
+			},
+		},
+		{
+			Name: "too-many-redirects",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+				http.Redirect(w, r, fmt.Sprintf("?n=%d", n+1), http.StatusFound)
+			},
+			Request: get(""),
+		},
+		{
+			Name: "cookies",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/login" {
+					http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+					http.Redirect(w, r, "whoami", http.StatusFound)
+					return
+				}
+				cookie, err := r.Cookie("session")
+				if err != nil {
+					fmt.Fprint(w, "anonymous")
+					return
+				}
+				fmt.Fprint(w, cookie.Value)
+			},
+			Request: get("login"),
+			Jar:     true,
+		},
+		{
+			Name: "request-headers",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, "%q %q", r.Header.Get("X-Custom"), r.Header.Values("X-Multi"))
+			},
+			Request: func(base string) (*http.Request, error) {
+				req, err := http.NewRequest(http.MethodGet, base, nil)
+				if err != nil {
+					return nil, err
+				}
+				req.Header.Set("X-Custom", "value")
+				req.Header.Add("X-Multi", "1")
+				req.Header.Add("X-Multi", "2")
+				return req, nil
+			},
+		},
+		{
+			Name: "response-headers",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Add("X-Values", "one")
+				w.Header().Add("X-Values", "two")
+				w.Header().Set("Cache-Control", "no-store")
+				fmt.Fprint(w, "{}")
+			},
+			Request: get(""),
+			Headers: []string{"Content-Type", "X-Values", "Cache-Control", "Content-Length"},
+		},
+		{
+			Name: "timeout",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(time.Second):
+				case <-r.Context().Done():
+				}
+				fmt.Fprint(w, "late")
+			},
+			Request: get(""),
+			Timeout: 100 * time.Millisecond,
+		},
+		{
+			Name: "chunked",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				flusher, _ := w.(http.Flusher)
+				for i := 0; i < 3; i++ {
+					fmt.Fprintf(w, "chunk%d;", i)
+					if flusher != nil {
+						flusher.Flush()
+					}
+				}
+			},
+			Request: get(""),
+		},
+		{
+			Name: "trailers",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Trailer", "X-Checksum")
+				fmt.Fprint(w, "body")
+				w.Header().Set("X-Checksum", "abc123")
+			},
+			Request: get(""),
+		},
+		{
+			Name: "head",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "11")
+				if r.Method != http.MethodHead {
+					fmt.Fprint(w, "hello world")
+				}
+			},
+			Request: func(base string) (*http.Request, error) {
+				return http.NewRequest(http.MethodHead, base, nil)
+			},
+			Headers: []string{"Content-Length"},
+		},
+		{
+			Name: "gzip",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+					fmt.Fprint(w, "compressed text")
+					return
+				}
+				w.Header().Set("Content-Encoding", "gzip")
+				gz := gzip.NewWriter(w)
+				fmt.Fprint(gz, "compressed text")
+				gz.Close()
+			},
+			Request: get(""),
+		},
+		{
+			Name: "not-found",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "no such page", http.StatusNotFound)
+			},
+			Request: get("missing"),
+			Headers: []string{"Content-Type"},
+		},
+		{
+			Name: "post-body",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				fmt.Fprintf(w, "%s %d %q %q", r.Method, r.ContentLength, r.Header.Get("Content-Type"), body)
+			},
+			Request: func(base string) (*http.Request, error) {
+				req, err := http.NewRequest(http.MethodPost, base, strings.NewReader(`{"a":1}`))
+				if err != nil {
+					return nil, err
+				}
+				req.Header.Set("Content-Type", "application/json")
+				return req, nil
+			},
+		},
+		{
+			Name: "query",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, "%s %q", r.URL.RawQuery, r.URL.Query())
+			},
+			Request: get("?a=1&b=x%20y&c=%E2%9C%93"),
+		},
+	}
+}
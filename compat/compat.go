@@ -0,0 +1,203 @@
+// Package compat verifies that an http.RoundTripper, usually a
+// *curlhttp.Transport, behaves like net/http's own transport. It runs a
+// matrix of behaviors against a local server with both transports and
+// reports every difference, which keeps the drop-in claim checkable:
+//
+//	report := compat.Run(curlhttp.NewTransport(), compat.DefaultCases())
+//	for _, d := range report.Divergences {
+//		t.Errorf("%s", d)
+//	}
+//
+// Each Case serves one handler and makes one request with an http.Client
+// using the transport under test. The observed outcome (status, selected
+// headers, body, trailers and the kind of error) must match what
+// http.DefaultTransport produced for the same case.
+package compat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Case is one behavior to compare
+type Case struct {
+	Name string
+
+	// Handler serves the case. It is mounted at /<Name>/ on the test
+	// server, so it sees paths below that prefix.
+	Handler http.HandlerFunc
+
+	// Request builds the request; base is the server URL including the
+	// case prefix, such as http://127.0.0.1:1234/redirects/.
+	Request func(base string) (*http.Request, error)
+
+	// Headers lists the response headers to compare.
+	Headers []string
+
+	// Jar gives the client a cookie jar, and Timeout sets its Timeout.
+	Jar     bool
+	Timeout time.Duration
+}
+
+// Observation is what a request produced
+type Observation struct {
+	Status   int
+	Header   map[string]string
+	Body     string
+	Trailer  map[string]string
+	FinalURL string // path of the last request after redirects
+	Err      string // kind of error, such as "timeout", or ""
+}
+
+// Divergence is a case whose outcome differs between the transports
+type Divergence struct {
+	Case      string
+	Field     string
+	Reference string // what net/http produced
+	Candidate string // what the transport under test produced
+}
+
+func (d Divergence) String() string {
+	return fmt.Sprintf("%s: %s differs: net/http %q, candidate %q", d.Case, d.Field, d.Reference, d.Candidate)
+}
+
+// Report is the outcome of Run
+type Report struct {
+	Cases       int
+	Divergences []Divergence
+}
+
+// Diverged reports whether the named case diverged
+func (r *Report) Diverged(name string) bool {
+	for _, d := range r.Divergences {
+		if d.Case == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Run runs cases against a local server with http.DefaultTransport and
+// with candidate and reports where they differ
+func Run(candidate http.RoundTripper, cases []Case) *Report {
+	mux := http.NewServeMux()
+	for _, c := range cases {
+		prefix := "/" + c.Name + "/"
+		mux.Handle(prefix, http.StripPrefix(strings.TrimSuffix(prefix, "/"), c.Handler))
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	report := &Report{Cases: len(cases)}
+	for _, c := range cases {
+		base := server.URL + "/" + c.Name + "/"
+		reference := observe(http.DefaultTransport, c, base)
+		got := observe(candidate, c, base)
+		report.Divergences = append(report.Divergences, compare(c.Name, reference, got)...)
+	}
+	return report
+}
+
+// observe runs c with transport
+func observe(transport http.RoundTripper, c Case, base string) Observation {
+	client := &http.Client{Transport: transport, Timeout: c.Timeout}
+	if c.Jar {
+		client.Jar, _ = cookiejar.New(nil)
+	}
+	req, err := c.Request(base)
+	if err != nil {
+		return Observation{Err: "request: " + err.Error()}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Observation{Err: errorKind(err)}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	obs := Observation{
+		Status:  resp.StatusCode,
+		Header:  make(map[string]string),
+		Body:    string(body),
+		Trailer: make(map[string]string),
+	}
+	if err != nil {
+		obs.Err = errorKind(err)
+	}
+	if resp.Request != nil {
+		obs.FinalURL = resp.Request.URL.Path
+	}
+	for _, name := range c.Headers {
+		obs.Header[name] = strings.Join(resp.Header.Values(name), ", ")
+	}
+	for name := range resp.Trailer {
+		obs.Trailer[name] = resp.Trailer.Get(name)
+	}
+	return obs
+}
+
+// errorKind classifies err so that transports with different error
+// messages compare equal
+func errorKind(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case strings.Contains(err.Error(), "stopped after"):
+		return "too many redirects"
+	default:
+		return "error"
+	}
+}
+
+// compare lists the differences between two observations
+func compare(name string, reference, candidate Observation) []Divergence {
+	var out []Divergence
+	add := func(field, ref, got string) {
+		if ref != got {
+			out = append(out, Divergence{Case: name, Field: field, Reference: ref, Candidate: got})
+		}
+	}
+	add("error", reference.Err, candidate.Err)
+	if reference.Err != "" || candidate.Err != "" {
+		return out
+	}
+	add("status", fmt.Sprint(reference.Status), fmt.Sprint(candidate.Status))
+	add("body", reference.Body, candidate.Body)
+	add("final URL", reference.FinalURL, candidate.FinalURL)
+	for _, key := range sortedKeys(reference.Header, candidate.Header) {
+		add("header "+key, reference.Header[key], candidate.Header[key])
+	}
+	for _, key := range sortedKeys(reference.Trailer, candidate.Trailer) {
+		add("trailer "+key, reference.Trailer[key], candidate.Trailer[key])
+	}
+	return out
+}
+
+// sortedKeys returns the keys of both maps in order
+func sortedKeys(a, b map[string]string) []string {
+	seen := make(map[string]bool)
+	for k := range a {
+		seen[k] = true
+	}
+	for k := range b {
+		seen[k] = true
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,51 @@
+package compat
+
+import (
+	"net/http"
+	"testing"
+
+	curlhttp "github.com/dstockton/go-curl-impersonate-net-http-wrapper"
+)
+
+// TestRunReference tests that net/http agrees with itself on every case
+func TestRunReference(t *testing.T) {
+	report := Run(http.DefaultTransport, DefaultCases())
+	if report.Cases != len(DefaultCases()) {
+		t.Errorf("Expected %d cases, got %d", len(DefaultCases()), report.Cases)
+	}
+	for _, d := range report.Divergences {
+		t.Errorf("Unexpected divergence: %s", d)
+	}
+}
+
+// TestRunDetectsDivergence tests that a transport dropping a header is reported
+func TestRunDetectsDivergence(t *testing.T) {
+	candidate := curlhttp.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err == nil {
+			resp.Header.Del("X-Values")
+		}
+		return resp, err
+	})
+	report := Run(candidate, DefaultCases())
+	if len(report.Divergences) != 1 || !report.Diverged("response-headers") || report.Divergences[0].Field != "header X-Values" {
+		t.Errorf("Expected a single X-Values divergence, got %v", report.Divergences)
+	}
+}
+
+// knownDivergences are the cases Transport is known to handle differently
+var knownDivergences = map[string]string{
+	"timeout": "buffered transfers are not interrupted when the request context ends",
+}
+
+// TestTransportCompatibility tests Transport against net/http
+func TestTransportCompatibility(t *testing.T) {
+	report := Run(curlhttp.NewTransport(), DefaultCases())
+	for _, d := range report.Divergences {
+		if reason, ok := knownDivergences[d.Case]; ok {
+			t.Logf("Known divergence (%s): %s", reason, d)
+			continue
+		}
+		t.Errorf("Divergence from net/http: %s", d)
+	}
+}
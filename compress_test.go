@@ -0,0 +1,140 @@
+package curlhttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// gunzip decompresses data or fails the test
+func gunzip(t *testing.T, data []byte) string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected gzip data: %v", err)
+	}
+	plain, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Failed to decompress: %v", err)
+	}
+	return string(plain)
+}
+
+// TestRequestCompression tests that large bodies are streamed to curl compressed
+func TestRequestCompression(t *testing.T) {
+	fake := newFakeEngine("ok")
+	transport := newFakeTransport(fake)
+	transport.Use((&RequestCompression{MinSize: 16}).Middleware())
+
+	payload := strings.Repeat(`{"field":"value"}`, 100)
+	req, _ := http.NewRequest("POST", "http://example.com/upload", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if got := gunzip(t, fake.uploaded); got != payload {
+		t.Errorf("Expected the payload to round-trip, got %d bytes", len(got))
+	}
+	if len(fake.uploaded) >= len(payload) {
+		t.Errorf("Expected a smaller body, got %d bytes for %d", len(fake.uploaded), len(payload))
+	}
+	headers := sentHeaders(fake)
+	if headers["Content-Encoding"] != "gzip" || headers["Transfer-Encoding"] != "chunked" {
+		t.Errorf("Expected gzip content sent chunked, got %v", headers)
+	}
+	if req.Header.Get("Content-Encoding") != "" {
+		t.Errorf("Expected the caller's request to be left unchanged")
+	}
+}
+
+// TestRequestCompressionSmall tests that bodies below MinSize are sent as they are
+func TestRequestCompressionSmall(t *testing.T) {
+	for _, knownLength := range []bool{true, false} {
+		fake := newFakeEngine("ok")
+		transport := newFakeTransport(fake)
+		transport.Use((&RequestCompression{MinSize: 64}).Middleware())
+
+		var body io.Reader = strings.NewReader("tiny")
+		if !knownLength {
+			body = io.MultiReader(body)
+		}
+		req, _ := http.NewRequest("POST", "http://example.com/upload", body)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		resp.Body.Close()
+
+		if sent, _ := fake.performed[curl.OPT_POSTFIELDS].([]byte); string(sent) != "tiny" {
+			t.Errorf("Expected the small body as is (known length %v), got %q", knownLength, sent)
+		}
+		if _, ok := sentHeaders(fake)["Content-Encoding"]; ok {
+			t.Errorf("Expected no Content-Encoding (known length %v)", knownLength)
+		}
+	}
+}
+
+// TestRequestCompressionUnknownLength tests that long bodies of unknown length are compressed
+func TestRequestCompressionUnknownLength(t *testing.T) {
+	fake := newFakeEngine("ok")
+	transport := newFakeTransport(fake)
+	transport.Use((&RequestCompression{MinSize: 8}).Middleware())
+
+	payload := strings.Repeat("abcdef", 50)
+	req, _ := http.NewRequest("PUT", "http://example.com/upload", io.MultiReader(strings.NewReader(payload)))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(payload)), nil }
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := gunzip(t, fake.uploaded); got != payload {
+		t.Errorf("Expected the payload to round-trip, got %q", got)
+	}
+
+	// Replays compress the body again
+	compressed := (&RequestCompression{MinSize: 8}).Middleware()(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		replay, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		data, _ := io.ReadAll(replay)
+		replay.Close()
+		if got := gunzip(t, data); got != payload {
+			t.Errorf("Expected GetBody to return the compressed payload, got %q", got)
+		}
+		r.Body.Close()
+		return stubResponse("ok").RoundTrip(r)
+	}))
+	req, _ = http.NewRequest("PUT", "http://example.com/upload", io.MultiReader(strings.NewReader(payload)))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(payload)), nil }
+	if _, err := compressed.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+}
+
+// TestRequestCompressionEncoded tests that already encoded bodies are not compressed again
+func TestRequestCompressionEncoded(t *testing.T) {
+	fake := newFakeEngine("ok")
+	transport := newFakeTransport(fake)
+	transport.Use((&RequestCompression{MinSize: 1}).Middleware())
+
+	req, _ := http.NewRequest("POST", "http://example.com/upload", strings.NewReader("already-br"))
+	req.Header.Set("Content-Encoding", "br")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+	if sent, _ := fake.performed[curl.OPT_POSTFIELDS].([]byte); string(sent) != "already-br" {
+		t.Errorf("Expected the encoded body as is, got %q", sent)
+	}
+}
@@ -0,0 +1,101 @@
+package curlhttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ContentLengthPolicy controls what Transport does when a response body
+// does not match its declared Content-Length.
+type ContentLengthPolicy int
+
+const (
+	// ContentLengthRejectShort fails requests whose body is shorter than
+	// its Content-Length, as curl delivers when a connection is cut
+	// mid-body, with a *ContentLengthError that matches io.ErrUnexpectedEOF
+	// like the error net/http returns. Longer bodies are returned as
+	// received with a NoteContentLengthMismatch. This is the default.
+	ContentLengthRejectShort ContentLengthPolicy = iota
+
+	// ContentLengthAccept returns the body as received, short or long, and
+	// records a NoteContentLengthMismatch on the response
+	ContentLengthAccept
+
+	// ContentLengthTruncate cuts bodies longer than Content-Length down to
+	// the declared size. Short bodies are returned as received. Both cases
+	// record a NoteContentLengthMismatch.
+	ContentLengthTruncate
+
+	// ContentLengthStrict fails the request with a *ContentLengthError.
+	ContentLengthStrict
+)
+
+// ErrTruncatedBody matches a *ContentLengthError for a body shorter than
+// its Content-Length, as curl delivers when a connection is cut mid-body
+var ErrTruncatedBody = errors.New("response body truncated")
+
+// ContentLengthError is returned under ContentLengthStrict, or by the
+// Integrity middleware, when the body size differs from the declared
+// Content-Length
+type ContentLengthError struct {
+	Declared int64
+	Received int64
+}
+
+func (e *ContentLengthError) Error() string {
+	return fmt.Sprintf("response body has %d bytes but Content-Length declared %d", e.Received, e.Declared)
+}
+
+// Is reports short bodies as ErrTruncatedBody and io.ErrUnexpectedEOF
+func (e *ContentLengthError) Is(target error) bool {
+	return (target == ErrTruncatedBody || target == io.ErrUnexpectedEOF) && e.Received < e.Declared
+}
+
+// declaredContentLength returns the Content-Length a response declared, if
+// it describes the body curl hands over. Bodies that curl decodes or that
+// are not allowed to have a body are excluded.
+func declaredContentLength(method string, code int, header http.Header) (int64, bool) {
+	if method == "HEAD" || code < 200 || code == 204 || code == 304 {
+		return 0, false
+	}
+	if header.Get("Transfer-Encoding") != "" {
+		return 0, false
+	}
+	if enc := header.Get("Content-Encoding"); enc != "" && !strings.EqualFold(enc, "identity") {
+		return 0, false
+	}
+	value := header.Get("Content-Length")
+	if value == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// applyContentLengthPolicy compares the received body size with the
+// declared one and returns how many bytes to keep
+func applyContentLengthPolicy(policy ContentLengthPolicy, declared, received int64) (int64, error) {
+	if declared == received {
+		return received, nil
+	}
+	switch policy {
+	case ContentLengthStrict:
+		return 0, &ContentLengthError{Declared: declared, Received: received}
+	case ContentLengthRejectShort:
+		if received < declared {
+			return 0, &ContentLengthError{Declared: declared, Received: received}
+		}
+	case ContentLengthTruncate:
+		if received > declared {
+			return declared, nil
+		}
+	}
+	return received, nil
+}
@@ -0,0 +1,117 @@
+package curlhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// newLengthFake returns a fake that declares contentLength but sends body
+func newLengthFake(contentLength, body string) *fakeEngine {
+	fake := newFakeEngine(body)
+	fake.headers = []string{"HTTP/1.1 200 OK\r\n", "Content-Length: " + contentLength + "\r\n", "\r\n"}
+	return fake
+}
+
+// TestContentLengthPolicies tests each policy against long and short bodies
+func TestContentLengthPolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   ContentLengthPolicy
+		declared string
+		body     string
+		partial  bool
+		want     string
+		wantErr  bool
+	}{
+		{"default long", ContentLengthRejectShort, "3", "hello", false, "hello", false},
+		{"default short", ContentLengthRejectShort, "10", "hello", true, "", true},
+		{"accept long", ContentLengthAccept, "3", "hello", false, "hello", false},
+		{"truncate long", ContentLengthTruncate, "3", "hello", false, "hel", false},
+		{"strict long", ContentLengthStrict, "3", "hello", false, "", true},
+		{"accept short", ContentLengthAccept, "10", "hello", true, "hello", false},
+		{"truncate short", ContentLengthTruncate, "10", "hello", true, "hello", false},
+		{"strict short", ContentLengthStrict, "10", "hello", true, "", true},
+	}
+
+	for _, tt := range tests {
+		fake := newLengthFake(tt.declared, tt.body)
+		if tt.partial {
+			fake.performErr = curl.CurlError(curl.E_PARTIAL_FILE)
+		}
+		transport := newFakeTransport(fake)
+		transport.ContentLengthPolicy = tt.policy
+
+		req, _ := http.NewRequest("GET", "http://example.com", nil)
+		resp, err := transport.RoundTrip(req)
+		if tt.wantErr {
+			var lengthErr *ContentLengthError
+			if !errors.As(err, &lengthErr) {
+				t.Errorf("%s: expected ContentLengthError, got %v", tt.name, err)
+			}
+			if errors.Is(err, ErrTruncatedBody) != tt.partial {
+				t.Errorf("%s: expected ErrTruncatedBody to match only short bodies, got %v", tt.name, err)
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) != tt.partial {
+				t.Errorf("%s: expected io.ErrUnexpectedEOF to match only short bodies, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: RoundTrip failed: %v", tt.name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != tt.want {
+			t.Errorf("%s: expected body %q, got %q", tt.name, tt.want, body)
+		}
+		if resp.ContentLength != int64(len(tt.want)) {
+			t.Errorf("%s: expected ContentLength %d, got %d", tt.name, len(tt.want), resp.ContentLength)
+		}
+		if !HasNote(resp, NoteContentLengthMismatch) {
+			t.Errorf("%s: expected content-length mismatch note", tt.name)
+		}
+	}
+}
+
+// TestContentLengthMatch tests that matching bodies carry no note
+func TestContentLengthMatch(t *testing.T) {
+	transport := newFakeTransport(newLengthFake("5", "hello"))
+	transport.ContentLengthPolicy = ContentLengthStrict
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if HasNote(resp, NoteContentLengthMismatch) {
+		t.Error("Expected no mismatch note for a matching body")
+	}
+}
+
+// TestContentLengthEncoded tests that encoded bodies are not compared
+func TestContentLengthEncoded(t *testing.T) {
+	fake := newLengthFake("3", "hello")
+	fake.headers = append([]string{fake.headers[0], "Content-Encoding: gzip\r\n"}, fake.headers[1:]...)
+	transport := newFakeTransport(fake)
+	transport.ContentLengthPolicy = ContentLengthStrict
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Errorf("Expected decoded body not to be checked, got %v", err)
+	}
+}
+
+// TestPartialWithoutContentLength tests that other partial transfers still fail
+func TestPartialWithoutContentLength(t *testing.T) {
+	fake := newFakeEngine("hello")
+	fake.performErr = curl.CurlError(curl.E_PARTIAL_FILE)
+	transport := newFakeTransport(fake)
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Error("Expected partial transfer without Content-Length to fail")
+	}
+}
@@ -0,0 +1,217 @@
+package curlhttp
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// FetchOptions adjusts Client.Fetch
+type FetchOptions struct {
+	// Credentials sends cookies with cross-origin requests, as fetch()
+	// with credentials: "include" does. The server must then allow
+	// credentials and name the origin rather than "*". Without it only
+	// same-origin requests carry cookies, as with the fetch() default.
+	Credentials bool
+}
+
+// CORSError is returned by Client.Fetch when the preflight response does
+// not allow the request, in which case a browser would not send it
+type CORSError struct {
+	URL    string
+	Status int // status of the preflight response
+	Reason string
+}
+
+func (e *CORSError) Error() string {
+	return fmt.Sprintf("CORS preflight for %s failed: %s", e.URL, e.Reason)
+}
+
+// Fetch sends req the way fetch() called by a script on the page at page
+// would. Cross-origin requests that are not simple get the OPTIONS
+// preflight a browser sends first, with Origin and the
+// Access-Control-Request-Method and Access-Control-Request-Headers the
+// server expects, and the real request follows only if the preflight
+// allows it. Both carry the Sec-Fetch-*, Referer and Origin headers of a
+// fetch from page, as with WithNavigation.
+//
+// Preflight results are not cached, so every call makes its own, and the
+// response is returned whether or not its own CORS headers would let the
+// page read it. opts may be nil.
+func (c *Client) Fetch(req *http.Request, page string, opts *FetchOptions) (*http.Response, error) {
+	if opts == nil {
+		opts = &FetchOptions{}
+	}
+	c.ensureInitialized()
+	if c.scope != nil {
+		var err error
+		if req, err = c.scope.apply(req); err != nil {
+			return nil, err
+		}
+	}
+	from, err := url.Parse(page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page URL: %w", err)
+	}
+
+	ctx := req.Context()
+	if _, ok := navigationFrom(ctx); !ok {
+		ctx = WithNavigation(ctx, Navigation{Type: ResourceFetch, Referrer: page})
+	}
+	req = req.WithContext(ctx)
+
+	crossOrigin := !sameOrigin(from, req.URL)
+	if crossOrigin && NeedsPreflight(req) {
+		if err := c.preflight(req, origin(from), opts.Credentials); err != nil {
+			return nil, err
+		}
+	}
+
+	client := c.Client
+	if crossOrigin && !opts.Credentials {
+		client.Jar = nil
+	}
+	return client.Do(req)
+}
+
+// preflight sends the OPTIONS request for req and checks that its response
+// allows req from origin
+func (c *Client) preflight(req *http.Request, origin string, credentials bool) error {
+	preflight, err := NewPreflightRequest(req)
+	if err != nil {
+		return err
+	}
+	// Preflights never carry cookies and are not redirected
+	resp, err := c.Transport.RoundTrip(preflight)
+	if err != nil {
+		return fmt.Errorf("failed to send CORS preflight: %w", err)
+	}
+	resp.Body.Close()
+
+	fail := func(reason string) error {
+		return &CORSError{URL: req.URL.String(), Status: resp.StatusCode, Reason: reason}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fail(fmt.Sprintf("status %d", resp.StatusCode))
+	}
+	allowOrigin := resp.Header.Get("Access-Control-Allow-Origin")
+	if allowOrigin != origin && (allowOrigin != "*" || credentials) {
+		return fail(fmt.Sprintf("origin %s not allowed by %q", origin, allowOrigin))
+	}
+	if credentials && resp.Header.Get("Access-Control-Allow-Credentials") != "true" {
+		return fail("credentials not allowed")
+	}
+
+	methods := corsList(resp.Header.Values("Access-Control-Allow-Methods"), false)
+	if !corsSafelistedMethod(req.Method) && !slices.Contains(methods, req.Method) && (credentials || !slices.Contains(methods, "*")) {
+		return fail(fmt.Sprintf("method %s not allowed", req.Method))
+	}
+	allowed := corsList(resp.Header.Values("Access-Control-Allow-Headers"), true)
+	for _, name := range unsafeRequestHeaders(req.Header) {
+		wildcard := !credentials && name != "authorization" && slices.Contains(allowed, "*")
+		if !wildcard && !slices.Contains(allowed, name) {
+			return fail(fmt.Sprintf("header %s not allowed", name))
+		}
+	}
+	return nil
+}
+
+// NewPreflightRequest returns the CORS preflight a browser sends before
+// req: an OPTIONS request for the same URL naming req's method in
+// Access-Control-Request-Method and its non-safelisted headers, lowercased
+// and sorted, in Access-Control-Request-Headers. It keeps req's context,
+// so a Navigation attached to it adds the Origin and Sec-Fetch-* headers.
+func NewPreflightRequest(req *http.Request) (*http.Request, error) {
+	preflight, err := http.NewRequestWithContext(req.Context(), http.MethodOptions, req.URL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create preflight request: %w", err)
+	}
+	preflight.Header.Set("Access-Control-Request-Method", req.Method)
+	if names := unsafeRequestHeaders(req.Header); len(names) > 0 {
+		preflight.Header.Set("Access-Control-Request-Headers", strings.Join(names, ","))
+	}
+	return preflight, nil
+}
+
+// NeedsPreflight reports whether a cross-origin fetch() of req would be
+// preceded by a preflight: its method is not GET, HEAD or POST, or it sets
+// headers outside the CORS safelist
+func NeedsPreflight(req *http.Request) bool {
+	return !corsSafelistedMethod(req.Method) || len(unsafeRequestHeaders(req.Header)) > 0
+}
+
+// corsSafelistedMethod reports whether method can be sent without a
+// preflight
+func corsSafelistedMethod(method string) bool {
+	return method == "" || method == http.MethodGet || method == http.MethodHead || method == http.MethodPost
+}
+
+// unsafeRequestHeaders returns the lowercased, sorted names of the headers
+// in h that scripts may set but that are not CORS-safelisted. Headers the
+// browser sets itself are left out.
+func unsafeRequestHeaders(h http.Header) []string {
+	var names []string
+	for name, values := range h {
+		if browserControlledHeader(name) || corsSafelistedHeader(name, values) {
+			continue
+		}
+		names = append(names, strings.ToLower(name))
+	}
+	slices.Sort(names)
+	return names
+}
+
+// browserControlledHeader reports whether name is a header that fetch()
+// does not let scripts set, so the browser supplies it
+func browserControlledHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	if strings.HasPrefix(name, "Sec-") || strings.HasPrefix(name, "Proxy-") {
+		return true
+	}
+	switch name {
+	case "Accept-Charset", "Accept-Encoding", "Access-Control-Request-Headers", "Access-Control-Request-Method",
+		"Connection", "Content-Length", "Cookie", "Cookie2", "Date", "Dnt", "Expect", "Host", "Keep-Alive",
+		"Origin", "Referer", "Set-Cookie", "Te", "Trailer", "Transfer-Encoding", "Upgrade", "User-Agent", "Via":
+		return true
+	}
+	return false
+}
+
+// corsSafelistedHeader reports whether a header can be sent without a
+// preflight
+func corsSafelistedHeader(name string, values []string) bool {
+	value := strings.Join(values, ", ")
+	if len(value) > 128 {
+		return false
+	}
+	switch http.CanonicalHeaderKey(name) {
+	case "Accept", "Accept-Language", "Content-Language":
+		return true
+	case "Content-Type":
+		mediaType, _, err := mime.ParseMediaType(value)
+		return err == nil && (mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data" || mediaType == "text/plain")
+	case "Range":
+		return strings.HasPrefix(value, "bytes=") && !strings.Contains(value, ",")
+	}
+	return false
+}
+
+// corsList splits comma-separated header values, lowercasing them if
+// fold is set
+func corsList(values []string, fold bool) []string {
+	var list []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				if fold {
+					item = strings.ToLower(item)
+				}
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
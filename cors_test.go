@@ -0,0 +1,158 @@
+package curlhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// corsServer answers preflights with allow and records every request
+func corsServer(allow http.Header) (*Client, func() []*http.Request) {
+	var mu sync.Mutex
+	var seen []*http.Request
+	rt := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		seen = append(seen, req)
+		mu.Unlock()
+		resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader("data")), Request: req}
+		if req.Method == http.MethodOptions {
+			resp.StatusCode = http.StatusNoContent
+			resp.Header = allow.Clone()
+		}
+		return resp, nil
+	})
+	client := &Client{Client: http.Client{Transport: rt}}
+	return client, func() []*http.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*http.Request(nil), seen...)
+	}
+}
+
+// apiRequest is a cross-origin PUT with a JSON body and an API key
+func apiRequest() *http.Request {
+	req, _ := http.NewRequest("PUT", "https://api.example.net/items/1", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", "k")
+	req.Header.Set("Accept", "application/json")
+	return req
+}
+
+const corsPage = "https://app.example.com/dashboard"
+
+// TestNewPreflightRequest tests the preflight headers and when one is needed
+func TestNewPreflightRequest(t *testing.T) {
+	preflight, err := NewPreflightRequest(apiRequest())
+	if err != nil {
+		t.Fatalf("NewPreflightRequest failed: %v", err)
+	}
+	if preflight.Method != "OPTIONS" || preflight.URL.String() != "https://api.example.net/items/1" {
+		t.Errorf("Expected OPTIONS for the same URL, got %s %s", preflight.Method, preflight.URL)
+	}
+	if got := preflight.Header.Get("Access-Control-Request-Method"); got != "PUT" {
+		t.Errorf("Expected request method PUT, got %q", got)
+	}
+	if got := preflight.Header.Get("Access-Control-Request-Headers"); got != "content-type,x-api-key" {
+		t.Errorf("Expected sorted lowercase header names, got %q", got)
+	}
+
+	simple, _ := http.NewRequest("POST", "https://api.example.net/", strings.NewReader("a=1"))
+	simple.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	simple.Header.Set("User-Agent", "browser")
+	if NeedsPreflight(simple) {
+		t.Error("Expected a form POST not to need a preflight")
+	}
+	if !NeedsPreflight(apiRequest()) {
+		t.Error("Expected a JSON PUT to need a preflight")
+	}
+}
+
+// TestFetchPreflight tests that an allowed preflight is followed by the request
+func TestFetchPreflight(t *testing.T) {
+	allow := http.Header{}
+	allow.Set("Access-Control-Allow-Origin", "https://app.example.com")
+	allow.Set("Access-Control-Allow-Methods", "GET, PUT")
+	allow.Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key")
+	client, seen := corsServer(allow)
+
+	resp, err := client.Fetch(apiRequest(), corsPage, nil)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	resp.Body.Close()
+
+	reqs := seen()
+	if len(reqs) != 2 || reqs[0].Method != "OPTIONS" || reqs[1].Method != "PUT" {
+		t.Fatalf("Expected a preflight then the PUT, got %d requests", len(reqs))
+	}
+	for _, req := range reqs {
+		nav, ok := navigationFrom(req.Context())
+		if !ok || nav.Type != ResourceFetch || nav.Referrer != corsPage {
+			t.Errorf("Expected %s to be made as a fetch from the page, got %+v", req.Method, nav)
+		}
+		if got := navigationHeaders(req, nav, "chrome136")["Origin"]; got != "https://app.example.com" {
+			t.Errorf("Expected %s to carry the page origin, got %q", req.Method, got)
+		}
+	}
+}
+
+// TestFetchPreflightRejected tests the preflight checks a browser applies
+func TestFetchPreflightRejected(t *testing.T) {
+	tests := []struct {
+		name        string
+		allow       map[string]string
+		credentials bool
+	}{
+		{"origin", map[string]string{"Access-Control-Allow-Origin": "https://other.example.com", "Access-Control-Allow-Methods": "PUT", "Access-Control-Allow-Headers": "*"}, false},
+		{"method", map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Headers": "*"}, false},
+		{"header", map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Methods": "PUT", "Access-Control-Allow-Headers": "content-type"}, false},
+		{"wildcard origin with credentials", map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Methods": "PUT", "Access-Control-Allow-Headers": "content-type, x-api-key", "Access-Control-Allow-Credentials": "true"}, true},
+	}
+	for _, tt := range tests {
+		allow := http.Header{}
+		for k, v := range tt.allow {
+			allow.Set(k, v)
+		}
+		client, seen := corsServer(allow)
+		_, err := client.Fetch(apiRequest(), corsPage, &FetchOptions{Credentials: tt.credentials})
+		var corsErr *CORSError
+		if !errors.As(err, &corsErr) {
+			t.Errorf("%s: Expected a CORSError, got %v", tt.name, err)
+		}
+		if n := len(seen()); n != 1 {
+			t.Errorf("%s: Expected only the preflight to be sent, got %d requests", tt.name, n)
+		}
+	}
+}
+
+// TestFetchCredentials tests that cross-origin fetches only send cookies when asked
+func TestFetchCredentials(t *testing.T) {
+	allow := http.Header{}
+	allow.Set("Access-Control-Allow-Origin", "https://app.example.com")
+	client, seen := corsServer(allow)
+	client.Jar, _ = cookiejar.New(nil)
+	target, _ := url.Parse("https://api.example.net/")
+	client.Jar.SetCookies(target, []*http.Cookie{{Name: "session", Value: "s"}})
+
+	for _, credentials := range []bool{false, true} {
+		req, _ := http.NewRequest("GET", "https://api.example.net/me", nil)
+		resp, err := client.Fetch(req, corsPage, &FetchOptions{Credentials: credentials})
+		if err != nil {
+			t.Fatalf("Fetch failed: %v", err)
+		}
+		resp.Body.Close()
+		reqs := seen()
+		last := reqs[len(reqs)-1]
+		if sent := last.Header.Get("Cookie") != ""; sent != credentials {
+			t.Errorf("Expected cookies to be sent only with credentials (%v), got %q", credentials, last.Header.Get("Cookie"))
+		}
+	}
+	if n := len(seen()); n != 2 {
+		t.Errorf("Expected simple requests not to be preflighted, got %d requests", n)
+	}
+}
@@ -0,0 +1,210 @@
+package curlhttp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Credential applies authentication to the headers of an outgoing request.
+type Credential interface {
+	Apply(h http.Header)
+}
+
+// BasicAuth is a Credential that sets an HTTP Basic Authorization header.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Apply sets the Authorization header using the basic scheme
+func (c BasicAuth) Apply(h http.Header) {
+	token := base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.Password))
+	h.Set("Authorization", "Basic "+token)
+}
+
+// String redacts the password so credentials never end up in logs
+func (c BasicAuth) String() string {
+	return fmt.Sprintf("BasicAuth{Username: %q, Password: <redacted>}", c.Username)
+}
+
+// BearerToken is a Credential that sets a Bearer Authorization header.
+type BearerToken struct {
+	Token string
+}
+
+// Apply sets the Authorization header using the bearer scheme
+func (c BearerToken) Apply(h http.Header) {
+	h.Set("Authorization", "Bearer "+c.Token)
+}
+
+// String redacts the token so credentials never end up in logs
+func (c BearerToken) String() string {
+	return "BearerToken{Token: <redacted>}"
+}
+
+// HeaderCredential is a Credential that sets an arbitrary header, such as an API key.
+type HeaderCredential struct {
+	Name  string
+	Value string
+}
+
+// Apply sets the configured header
+func (c HeaderCredential) Apply(h http.Header) {
+	h.Set(c.Name, c.Value)
+}
+
+// String redacts the header value so credentials never end up in logs
+func (c HeaderCredential) String() string {
+	return fmt.Sprintf("HeaderCredential{Name: %q, Value: <redacted>}", c.Name)
+}
+
+// CredentialStore looks up the credential to use for a host. The host passed
+// in is lowercased and may include a port (e.g. "api.example.com:8443").
+type CredentialStore interface {
+	Credential(host string) (Credential, bool)
+}
+
+// CredentialStoreFunc adapts a function to the CredentialStore interface.
+// It can be used to plug in external secret backends such as an OS keychain.
+type CredentialStoreFunc func(host string) (Credential, bool)
+
+// Credential calls f(host)
+func (f CredentialStoreFunc) Credential(host string) (Credential, bool) {
+	return f(host)
+}
+
+// MemoryCredentialStore is a thread-safe in-memory CredentialStore.
+// Entries may be keyed by "host" or "host:port"; a host:port entry takes
+// precedence over a bare host entry.
+type MemoryCredentialStore struct {
+	mu    sync.RWMutex
+	creds map[string]Credential
+}
+
+// NewMemoryCredentialStore creates an empty in-memory credential store
+func NewMemoryCredentialStore() *MemoryCredentialStore {
+	return &MemoryCredentialStore{
+		creds: make(map[string]Credential),
+	}
+}
+
+// Set stores the credential for host, replacing any existing entry
+func (s *MemoryCredentialStore) Set(host string, cred Credential) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.creds == nil {
+		s.creds = make(map[string]Credential)
+	}
+	s.creds[strings.ToLower(host)] = cred
+}
+
+// Delete removes the credential for host
+func (s *MemoryCredentialStore) Delete(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.creds, strings.ToLower(host))
+}
+
+// Clear removes all stored credentials
+func (s *MemoryCredentialStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.creds = make(map[string]Credential)
+}
+
+// Credential implements CredentialStore
+func (s *MemoryCredentialStore) Credential(host string) (Credential, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	host = strings.ToLower(host)
+	if cred, ok := s.creds[host]; ok {
+		return cred, true
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		if cred, ok := s.creds[hostname]; ok {
+			return cred, true
+		}
+	}
+	return nil, false
+}
+
+// credentialFileEntry is the on-disk representation of a single credential
+type credentialFileEntry struct {
+	Type     string `json:"type"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Header   string `json:"header,omitempty"`
+	Value    string `json:"value,omitempty"`
+}
+
+// LoadCredentialFile reads a JSON file mapping hosts to credentials and
+// returns an in-memory store populated from it. The file looks like:
+//
+//	{
+//	  "api.example.com": {"type": "bearer", "token": "..."},
+//	  "intranet:8443":   {"type": "basic", "username": "u", "password": "p"},
+//	  "data.example.org": {"type": "header", "header": "X-Api-Key", "value": "..."}
+//	}
+func LoadCredentialFile(path string) (*MemoryCredentialStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credential file: %w", err)
+	}
+
+	var entries map[string]credentialFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse credential file: %w", err)
+	}
+
+	store := NewMemoryCredentialStore()
+	for host, entry := range entries {
+		switch strings.ToLower(entry.Type) {
+		case "basic":
+			store.Set(host, BasicAuth{Username: entry.Username, Password: entry.Password})
+		case "bearer":
+			store.Set(host, BearerToken{Token: entry.Token})
+		case "header":
+			if entry.Header == "" {
+				return nil, fmt.Errorf("credential for %s: header name is required", host)
+			}
+			store.Set(host, HeaderCredential{Name: entry.Header, Value: entry.Value})
+		default:
+			return nil, fmt.Errorf("credential for %s: unknown type %q", host, entry.Type)
+		}
+	}
+	return store, nil
+}
+
+// applyCredentials returns the headers to send for req with any stored
+// credential for the request host applied. The request's own headers are
+// never modified, and a header the request sets explicitly wins over the
+// credential's.
+func applyCredentials(store CredentialStore, req *http.Request) http.Header {
+	if store == nil || req.URL == nil {
+		return req.Header
+	}
+	cred, ok := store.Credential(strings.ToLower(req.URL.Host))
+	if !ok || cred == nil {
+		return req.Header
+	}
+	name := "Authorization"
+	if c, ok := cred.(HeaderCredential); ok {
+		name = c.Name
+	}
+	if req.Header.Get(name) != "" {
+		return req.Header
+	}
+	headers := req.Header.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	cred.Apply(headers)
+	return headers
+}
@@ -0,0 +1,129 @@
+package curlhttp
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMemoryCredentialStoreLookup tests host and host:port lookups
+func TestMemoryCredentialStoreLookup(t *testing.T) {
+	store := NewMemoryCredentialStore()
+	store.Set("API.example.com", BearerToken{Token: "host-token"})
+	store.Set("api.example.com:8443", BearerToken{Token: "port-token"})
+
+	cred, ok := store.Credential("api.example.com")
+	if !ok || cred.(BearerToken).Token != "host-token" {
+		t.Errorf("Expected host-token for bare host, got %v", cred)
+	}
+
+	cred, ok = store.Credential("api.example.com:8443")
+	if !ok || cred.(BearerToken).Token != "port-token" {
+		t.Errorf("Expected port-token for host:port, got %v", cred)
+	}
+
+	cred, ok = store.Credential("api.example.com:9000")
+	if !ok || cred.(BearerToken).Token != "host-token" {
+		t.Errorf("Expected fallback to host-token, got %v", cred)
+	}
+
+	if _, ok := store.Credential("other.example.com"); ok {
+		t.Error("Expected no credential for unrelated host")
+	}
+
+	store.Delete("api.example.com")
+	if _, ok := store.Credential("api.example.com"); ok {
+		t.Error("Expected credential to be deleted")
+	}
+}
+
+// TestApplyCredentials tests that credentials are applied per host without mutating the request
+func TestApplyCredentials(t *testing.T) {
+	store := NewMemoryCredentialStore()
+	store.Set("secure.example.com", BasicAuth{Username: "user", Password: "pass"})
+
+	req, _ := http.NewRequest("GET", "https://secure.example.com/data", nil)
+	headers := applyCredentials(store, req)
+	if headers.Get("Authorization") != "Basic dXNlcjpwYXNz" {
+		t.Errorf("Expected basic auth header, got %q", headers.Get("Authorization"))
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Error("applyCredentials must not modify the request headers")
+	}
+
+	// Simulate a redirect hop to another host
+	req.URL, _ = url.Parse("https://evil.example.net/")
+	headers = applyCredentials(store, req)
+	if headers.Get("Authorization") != "" {
+		t.Error("Credentials leaked to a different host")
+	}
+
+	// Explicit Authorization on the request wins
+	req.URL, _ = url.Parse("https://secure.example.com/")
+	req.Header.Set("Authorization", "Bearer explicit")
+	headers = applyCredentials(store, req)
+	if headers.Get("Authorization") != "Bearer explicit" {
+		t.Errorf("Expected explicit header to win, got %q", headers.Get("Authorization"))
+	}
+
+	// An explicit header also wins over a header credential
+	store.Set("api.example.com", HeaderCredential{Name: "X-Api-Key", Value: "stored"})
+	req, _ = http.NewRequest("GET", "https://api.example.com/", nil)
+	if headers = applyCredentials(store, req); headers.Get("X-Api-Key") != "stored" {
+		t.Errorf("Expected the stored API key, got %q", headers.Get("X-Api-Key"))
+	}
+	req.Header.Set("X-Api-Key", "explicit")
+	if headers = applyCredentials(store, req); headers.Get("X-Api-Key") != "explicit" {
+		t.Errorf("Expected explicit header to win over the stored one, got %q", headers.Get("X-Api-Key"))
+	}
+}
+
+// TestCredentialStringRedacted tests that secrets are not printed
+func TestCredentialStringRedacted(t *testing.T) {
+	for _, cred := range []interface{ String() string }{
+		BasicAuth{Username: "user", Password: "s3cret"},
+		BearerToken{Token: "s3cret"},
+		HeaderCredential{Name: "X-Api-Key", Value: "s3cret"},
+	} {
+		if strings.Contains(cred.String(), "s3cret") {
+			t.Errorf("Secret leaked in String(): %s", cred.String())
+		}
+	}
+}
+
+// TestLoadCredentialFile tests loading credentials from a JSON file
+func TestLoadCredentialFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creds.json")
+	data := `{
+		"api.example.com": {"type": "bearer", "token": "abc"},
+		"data.example.org": {"type": "header", "header": "X-Api-Key", "value": "key"}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := LoadCredentialFile(path)
+	if err != nil {
+		t.Fatalf("LoadCredentialFile failed: %v", err)
+	}
+
+	h := make(http.Header)
+	cred, ok := store.Credential("data.example.org")
+	if !ok {
+		t.Fatal("Expected credential for data.example.org")
+	}
+	cred.Apply(h)
+	if h.Get("X-Api-Key") != "key" {
+		t.Errorf("Expected X-Api-Key header, got %v", h)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"x": {"type": "magic"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCredentialFile(path); err == nil {
+		t.Error("Expected error for unknown credential type")
+	}
+}
@@ -0,0 +1,198 @@
+package curlhttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// AsCurlCommand renders the curl-impersonate command line that reproduces
+// req as t would send it: impersonation target, headers (including stored
+// credentials), proxy, timeouts, HTTP version and body. A nil Transport
+// renders with the defaults of NewTransport.
+//
+// The command uses the curl_<target> wrapper scripts shipped with
+// curl-impersonate, e.g. curl_chrome136. The request body is read and
+// restored, so req can still be sent afterwards.
+func AsCurlCommand(req *http.Request, t *Transport) (string, error) {
+	if req == nil || req.URL == nil {
+		return "", fmt.Errorf("request and URL cannot be nil")
+	}
+	if t == nil {
+		t = NewTransport()
+	}
+
+	target := t.ImpersonateTarget
+	if target == "" {
+		target = "chrome136"
+	}
+	if IsTargetAlias(target) {
+		target, _ = t.resolveTarget()
+	}
+	args := []string{"curl_" + target}
+
+	body, err := peekRequestBody(req)
+	if err != nil {
+		return "", err
+	}
+
+	// As setMethod does, POST, PUT and PATCH always send a body, if only
+	// an empty one with Content-Length: 0
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	withBody := sendsBody(method, len(body) > 0)
+	// --data-binary makes curl send a POST unless -X names the method
+	switch {
+	case method == http.MethodHead:
+		args = append(args, "--head")
+	case method == http.MethodGet && !withBody:
+	case method == http.MethodPost && withBody:
+	default:
+		args = append(args, "-X", method)
+	}
+
+	if err := t.checkUserAgentPolicy(req); err != nil {
+		return "", err
+	}
+	headers := t.requestHeaders(req)
+	if t.Signer != nil {
+		wire, err := wireURL(req.URL)
+		if err != nil {
+			return "", fmt.Errorf("failed to prepare URL: %w", err)
+		}
+		if err := t.Signer.sign(req, wire, headers, body, false); err != nil {
+			return "", err
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-H", shellQuote(name+": "+headers[name]))
+	}
+	if withBody {
+		// Keep curl from adding the headers it sends with a POST
+		for _, name := range curlBodyDefaults {
+			if _, ok := headers[name]; !ok {
+				args = append(args, "-H", shellQuote(name+":"))
+			}
+		}
+		args = append(args, "--data-binary", shellQuote(string(body)))
+	}
+
+	if proxy, override := t.proxyFor(req.Context()); proxy != nil {
+		args = append(args, "-x", shellQuote(proxy.String()))
+		args = append(args, proxyTLSArgs(t.ProxyTLS)...)
+		lines, err := t.proxyConnectHeader(req.Context(), proxy, req.URL)
+		if err != nil {
+			return "", err
+		}
+		for _, line := range lines {
+			args = append(args, "--proxy-header", shellQuote(line))
+		}
+	} else if override {
+		args = append(args, "--noproxy", shellQuote("*"))
+	}
+
+	// The transport does not verify certificates
+	args = append(args, "-k")
+
+	connectTimeout, timeout := t.ConnectTimeoutMs, t.TimeoutMs
+	if connectTimeout == 0 {
+		connectTimeout = 5000
+	}
+	if timeout == 0 {
+		timeout = 30000
+	}
+	args = append(args,
+		"--connect-timeout", formatSeconds(connectTimeout),
+		"--max-time", formatSeconds(timeout))
+
+	switch t.HttpVersion {
+	case HTTPVersion1_0:
+		args = append(args, "--http1.0")
+	case HTTPVersion1_1:
+		args = append(args, "--http1.1")
+	case HTTPVersion2:
+		args = append(args, "--http2")
+	case HTTPVersion2TLS:
+		// Cleartext requests stay on HTTP/1.1, curl's default for them
+		if req.URL.Scheme == "https" {
+			args = append(args, "--http2")
+		}
+	case HTTPVersion2PriorKnowledge:
+		args = append(args, "--http2-prior-knowledge")
+	}
+
+	args = append(args, shellQuote(req.URL.String()))
+	return strings.Join(args, " "), nil
+}
+
+// peekRequestBody reads the request body and puts an equivalent reader back
+func peekRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get request body: %w", err)
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// formatSeconds renders milliseconds as fractional seconds for curl flags
+func formatSeconds(ms int) string {
+	if ms%1000 == 0 {
+		return fmt.Sprint(ms / 1000)
+	}
+	return fmt.Sprintf("%.3f", float64(ms)/1000)
+}
+
+// shellQuote quotes s for POSIX shells
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// proxyTLSArgs returns the curl flags for a proxy TLS configuration
+func proxyTLSArgs(cfg *ProxyTLSConfig) []string {
+	if cfg == nil {
+		return []string{"--proxy-insecure"}
+	}
+	var args []string
+	if cfg.InsecureSkipVerify {
+		args = append(args, "--proxy-insecure")
+	}
+	for _, flag := range []struct{ name, value string }{
+		{"--proxy-cacert", cfg.CAFile},
+		{"--proxy-cert", cfg.CertFile},
+		{"--proxy-key", cfg.KeyFile},
+		{"--proxy-pinnedpubkey", cfg.PinnedPublicKey},
+	} {
+		if flag.value != "" {
+			args = append(args, flag.name, shellQuote(flag.value))
+		}
+	}
+	return args
+}
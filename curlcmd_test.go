@@ -0,0 +1,122 @@
+package curlhttp
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestAsCurlCommand tests rendering of a POST through a proxy
+func TestAsCurlCommand(t *testing.T) {
+	transport := NewTransportWithPoolSize(1)
+	transport.ImpersonateTarget = "firefox102"
+	transport.Proxy, _ = url.Parse("http://proxy.local:8080")
+	transport.HttpVersion = 2
+	transport.TimeoutMs = 1500
+
+	req, _ := http.NewRequest("POST", "https://example.com/api?q=1", strings.NewReader(`{"name":"it's"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	cmd, err := AsCurlCommand(req, transport)
+	if err != nil {
+		t.Fatalf("AsCurlCommand failed: %v", err)
+	}
+
+	want := `curl_firefox102 -H 'Content-Type: application/json' -H Expect: --data-binary '{"name":"it'\''s"}' ` +
+		`-x http://proxy.local:8080 --proxy-insecure -k --connect-timeout 5 --max-time 1.500 --http1.1 ` +
+		`'https://example.com/api?q=1'`
+	if cmd != want {
+		t.Errorf("Unexpected command:\n got: %s\nwant: %s", cmd, want)
+	}
+
+	// The body must still be readable afterwards
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != `{"name":"it's"}` {
+		t.Errorf("Request body was consumed, got %q", body)
+	}
+}
+
+// TestAsCurlCommandCredentials tests that stored credentials are included
+func TestAsCurlCommandCredentials(t *testing.T) {
+	store := NewMemoryCredentialStore()
+	store.Set("example.com", BearerToken{Token: "tok"})
+	transport := NewTransport()
+	transport.Credentials = store
+
+	req, _ := http.NewRequest("DELETE", "https://example.com/item/1", nil)
+	cmd, err := AsCurlCommand(req, transport)
+	if err != nil {
+		t.Fatalf("AsCurlCommand failed: %v", err)
+	}
+	if !strings.HasPrefix(cmd, "curl_chrome136 -X DELETE -H 'Authorization: Bearer tok'") {
+		t.Errorf("Unexpected command: %s", cmd)
+	}
+}
+
+// TestAsCurlCommandBodyDefaults tests that the command suppresses curl's
+// default body headers, sends an empty body where the transport does and
+// keeps the method of requests with a body
+func TestAsCurlCommandBodyDefaults(t *testing.T) {
+	tests := []struct {
+		method string
+		body   string
+		want   string
+	}{
+		{"POST", "a=1", "curl_chrome136 -H Content-Type: -H Expect: --data-binary a=1"},
+		{"POST", "", "curl_chrome136 -H Content-Type: -H Expect: --data-binary ''"},
+		{"PUT", "", "curl_chrome136 -X PUT -H Content-Type: -H Expect: --data-binary ''"},
+		{"DELETE", "", "curl_chrome136 -X DELETE -k"},
+		{"GET", "q=1", "curl_chrome136 -X GET -H Content-Type: -H Expect: --data-binary q=1"},
+		{"DELETE", "id=1", "curl_chrome136 -X DELETE -H Content-Type: -H Expect: --data-binary id=1"},
+		{"GET", "", "curl_chrome136 -k"},
+	}
+	for _, tt := range tests {
+		transport := NewTransport()
+		transport.UseDefaultHeaders = false
+		req, _ := http.NewRequest(tt.method, "https://example.com/", strings.NewReader(tt.body))
+		cmd, err := AsCurlCommand(req, transport)
+		if err != nil {
+			t.Fatalf("AsCurlCommand failed: %v", err)
+		}
+		if !strings.HasPrefix(cmd, tt.want) {
+			t.Errorf("%s %q: expected command to start with %q, got %s", tt.method, tt.body, tt.want, cmd)
+		}
+	}
+}
+
+// TestAsCurlCommandHTTPVersion tests the flag rendered for each HttpVersion
+func TestAsCurlCommandHTTPVersion(t *testing.T) {
+	tests := []struct {
+		version int
+		url     string
+		flag    string
+	}{
+		{HTTPVersionDefault, "https://example.com/", ""},
+		{HTTPVersion1_0, "https://example.com/", "--http1.0"},
+		{HTTPVersion1_1, "https://example.com/", "--http1.1"},
+		{HTTPVersion2, "http://example.com/", "--http2"},
+		{HTTPVersion2TLS, "https://example.com/", "--http2"},
+		{HTTPVersion2TLS, "http://example.com/", ""},
+		{HTTPVersion2PriorKnowledge, "http://example.com/", "--http2-prior-knowledge"},
+	}
+	for _, tt := range tests {
+		transport := NewTransport()
+		transport.HttpVersion = tt.version
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		cmd, err := AsCurlCommand(req, transport)
+		if err != nil {
+			t.Fatalf("AsCurlCommand failed: %v", err)
+		}
+		flag := ""
+		for _, arg := range strings.Fields(cmd) {
+			if strings.HasPrefix(arg, "--http") {
+				flag = arg
+			}
+		}
+		if flag != tt.flag {
+			t.Errorf("HttpVersion %d for %s: Expected %q, got %q in %s", tt.version, tt.url, tt.flag, flag, cmd)
+		}
+	}
+}
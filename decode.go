@@ -0,0 +1,123 @@
+package curlhttp
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBodySize is the largest response body Bytes and DecodeXML accept
+const DefaultMaxBodySize = 10 << 20
+
+var (
+	// ErrBodyTooLarge matches every *BodyTooLargeError, whichever helper
+	// returned it
+	ErrBodyTooLarge = errors.New("response body too large")
+
+	// ErrNotXML is returned for responses whose Content-Type is not XML
+	ErrNotXML = errors.New("response is not XML")
+)
+
+// BodyTooLargeError is returned by the response helpers of this package,
+// such as BytesLimit, TextLimit, DecodeJSONLimit and DecodeXMLLimit, when a
+// body exceeds their limit. Only limit+1 bytes are read, so hostile or
+// runaway responses cannot exhaust memory. It matches ErrBodyTooLarge and
+// the helper's own error, such as ErrJSONTooLarge.
+type BodyTooLargeError struct {
+	Limit int64
+	Err   error
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("%v: more than %d bytes", e.Err, e.Limit)
+}
+
+func (e *BodyTooLargeError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports every BodyTooLargeError as ErrBodyTooLarge
+func (e *BodyTooLargeError) Is(target error) bool {
+	return target == ErrBodyTooLarge
+}
+
+// readLimited reads at most limit bytes of body, returning a
+// *BodyTooLargeError wrapping tooLarge if there is more
+func readLimited(body io.Reader, limit int64, tooLarge error) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, &BodyTooLargeError{Limit: limit, Err: tooLarge}
+	}
+	return data, nil
+}
+
+// Bytes reads resp's body, at most DefaultMaxBodySize bytes, and closes
+// it. See BytesLimit.
+func Bytes(resp *http.Response) ([]byte, error) {
+	return BytesLimit(resp, DefaultMaxBodySize)
+}
+
+// BytesLimit reads resp's body and closes it, whatever the status. Bodies
+// over limit bytes return a *BodyTooLargeError.
+func BytesLimit(resp *http.Response, limit int64) ([]byte, error) {
+	defer resp.Body.Close()
+	data, err := readLimited(resp.Body, limit, ErrBodyTooLarge)
+	var tooLarge *BodyTooLargeError
+	if err != nil && !errors.As(err, &tooLarge) {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return data, err
+}
+
+// DecodeXML decodes resp's body into v, reading at most
+// DefaultMaxBodySize bytes, and closes the body. See DecodeXMLLimit.
+func DecodeXML(resp *http.Response, v any) error {
+	return DecodeXMLLimit(resp, v, DefaultMaxBodySize)
+}
+
+// DecodeXMLLimit decodes resp's body into v and closes the body, following
+// the rules of DecodeJSONLimit: it returns an *HTTPError for statuses
+// outside 2xx, ErrNotXML when the Content-Type is neither application/xml,
+// text/xml nor a +xml type, and a *BodyTooLargeError when the body exceeds
+// limit bytes.
+func DecodeXMLLimit(resp *http.Response, v any, limit int64) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return httpError(resp)
+	}
+	if v == nil || resp.StatusCode == http.StatusNoContent || resp.ContentLength == 0 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, limit))
+		return nil
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || !isXMLMediaType(mediaType) {
+		return fmt.Errorf("%w: Content-Type %q", ErrNotXML, contentType)
+	}
+
+	data, err := readLimited(resp.Body, limit, ErrBodyTooLarge)
+	var tooLarge *BodyTooLargeError
+	if errors.As(err, &tooLarge) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read XML response: %w", err)
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode XML response: %w", err)
+	}
+	return nil
+}
+
+// isXMLMediaType reports whether mediaType is an XML type
+func isXMLMediaType(mediaType string) bool {
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
@@ -0,0 +1,60 @@
+package curlhttp
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestBytesLimit tests reading raw bodies within and over the limit
+func TestBytesLimit(t *testing.T) {
+	data, err := BytesLimit(jsonResponse(500, "text/plain", "oops"), 10)
+	if err != nil || string(data) != "oops" {
+		t.Errorf("Expected the body whatever the status, got %q, %v", data, err)
+	}
+
+	_, err = BytesLimit(jsonResponse(200, "text/plain", "0123456789"), 4)
+	var tooLarge *BodyTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 4 || !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("Expected a BodyTooLargeError with limit 4, got %v", err)
+	}
+}
+
+// TestDecodeXML tests XML decoding, content type checks and limits
+func TestDecodeXML(t *testing.T) {
+	var v struct {
+		Name string `xml:"name"`
+	}
+	if err := DecodeXML(jsonResponse(200, "application/atom+xml; charset=utf-8", "<feed><name>news</name></feed>"), &v); err != nil || v.Name != "news" {
+		t.Errorf("Expected name news, got %q, %v", v.Name, err)
+	}
+	if err := DecodeXML(jsonResponse(200, "application/json", "{}"), &v); !errors.Is(err, ErrNotXML) {
+		t.Errorf("Expected ErrNotXML, got %v", err)
+	}
+	var httpErr *HTTPError
+	if err := DecodeXML(jsonResponse(404, "text/xml", "<error/>"), &v); !errors.As(err, &httpErr) || httpErr.StatusCode != 404 {
+		t.Errorf("Expected an HTTPError for 404, got %v", err)
+	}
+	if err := DecodeXMLLimit(jsonResponse(200, "text/xml", "<a>0123456789</a>"), &v, 8); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("Expected ErrBodyTooLarge, got %v", err)
+	}
+}
+
+// TestBodyTooLargeErrorMatchesHelpers tests that every helper returns the typed error
+func TestBodyTooLargeErrorMatchesHelpers(t *testing.T) {
+	var v any
+	jsonErr := DecodeJSONLimit(jsonResponse(200, "application/json", `"0123456789"`), &v, 4)
+	_, textErr := TextLimit(jsonResponse(200, "text/plain", "0123456789"), 4)
+
+	for name, tt := range map[string]struct {
+		err      error
+		sentinel error
+	}{
+		"json": {jsonErr, ErrJSONTooLarge},
+		"text": {textErr, ErrTextTooLarge},
+	} {
+		var tooLarge *BodyTooLargeError
+		if !errors.As(tt.err, &tooLarge) || !errors.Is(tt.err, ErrBodyTooLarge) || !errors.Is(tt.err, tt.sentinel) {
+			t.Errorf("%s: expected a BodyTooLargeError matching both sentinels, got %v", name, tt.err)
+		}
+	}
+}
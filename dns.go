@@ -0,0 +1,171 @@
+package curlhttp
+
+import (
+	"bufio"
+	"container/list"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoadPreresolveFile reads a hosts-style preresolve file and returns its
+// entries in the format of Transport.Resolve. See ParsePreresolve.
+func LoadPreresolveFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preresolve file: %w", err)
+	}
+	defer f.Close()
+	return ParsePreresolve(f)
+}
+
+// ParsePreresolve parses hosts-style lines of an address followed by the
+// names it serves, with # comments:
+//
+//	93.184.215.14   example.com www.example.com
+//	2606:2800::1    example.com
+//	10.0.0.7        api.internal:8443
+//
+// A name without a port is pinned for ports 80 and 443. Addresses listed
+// for the same name and port are merged, in file order, so curl tries
+// them in turn. The result is in the "host:port:address[,address]" format
+// of Transport.Resolve; WriteHostsFile produces files it reads.
+func ParsePreresolve(r io.Reader) ([]string, error) {
+	var order []string
+	addrs := map[string][]string{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			return nil, fmt.Errorf("preresolve line %d: invalid address %q", line, fields[0])
+		}
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("preresolve line %d: no host names", line)
+		}
+		addr := ip.String()
+		if ip.To4() == nil {
+			addr = "[" + addr + "]"
+		}
+		for _, name := range fields[1:] {
+			ports := []string{"80", "443"}
+			if host, port, err := net.SplitHostPort(name); err == nil {
+				if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+					return nil, fmt.Errorf("preresolve line %d: invalid port in %q", line, name)
+				}
+				name, ports = host, []string{port}
+			}
+			for _, port := range ports {
+				key := strings.ToLower(name) + ":" + port
+				if _, ok := addrs[key]; !ok {
+					order = append(order, key)
+				}
+				if !slices.Contains(addrs[key], addr) {
+					addrs[key] = append(addrs[key], addr)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read preresolve file: %w", err)
+	}
+
+	entries := make([]string, len(order))
+	for i, key := range order {
+		entries[i] = key + ":" + strings.Join(addrs[key], ",")
+	}
+	return entries, nil
+}
+
+// ResolvedHost is an address the Transport connected to for a host
+type ResolvedHost struct {
+	Host string
+	Port int
+	Addr string
+
+	// Time is when the address was last seen
+	Time time.Time
+}
+
+// maxResolvedHosts bounds the host and port pairs DNSCache remembers
+const maxResolvedHosts = 4096
+
+// dnsCache records the addresses of direct connections
+type dnsCache struct {
+	mu    sync.Mutex
+	hosts map[string]*list.Element // keyed by host:port
+	lru   list.List                // of ResolvedHost, most recently seen first
+}
+
+// recordResolution remembers the address req's host resolved to, for
+// connections made without a proxy
+func (t *Transport) recordResolution(req *http.Request, conn *ConnectionInfo) {
+	if conn.ViaProxy || conn.PrimaryIP == "" || net.ParseIP(req.URL.Hostname()) != nil {
+		return
+	}
+	host := strings.ToLower(req.URL.Hostname())
+	key := net.JoinHostPort(host, strconv.Itoa(conn.PrimaryPort))
+	resolved := ResolvedHost{Host: host, Port: conn.PrimaryPort, Addr: conn.PrimaryIP, Time: t.clock().Now()}
+	t.dns.mu.Lock()
+	defer t.dns.mu.Unlock()
+	if t.dns.hosts == nil {
+		t.dns.hosts = make(map[string]*list.Element)
+	}
+	if elem := t.dns.hosts[key]; elem != nil {
+		elem.Value = resolved
+		t.dns.lru.MoveToFront(elem)
+		return
+	}
+	t.dns.hosts[key] = t.dns.lru.PushFront(resolved)
+	for t.dns.lru.Len() > maxResolvedHosts {
+		oldest := t.dns.lru.Remove(t.dns.lru.Back()).(ResolvedHost)
+		delete(t.dns.hosts, net.JoinHostPort(oldest.Host, strconv.Itoa(oldest.Port)))
+	}
+}
+
+// DNSCache returns the address each host and port was last connected to
+// directly, sorted by host and port. curl does not expose its own DNS
+// cache, so this records the addresses of completed transfers; comparing
+// the caches of workers shows geo-DNS differences between them. Only the
+// 4096 most recently used host and port pairs are kept.
+func (t *Transport) DNSCache() []ResolvedHost {
+	t.dns.mu.Lock()
+	hosts := make([]ResolvedHost, 0, t.dns.lru.Len())
+	for elem := t.dns.lru.Front(); elem != nil; elem = elem.Next() {
+		hosts = append(hosts, elem.Value.(ResolvedHost))
+	}
+	t.dns.mu.Unlock()
+
+	slices.SortFunc(hosts, func(a, b ResolvedHost) int {
+		if c := strings.Compare(a.Host, b.Host); c != 0 {
+			return c
+		}
+		return a.Port - b.Port
+	})
+	return hosts
+}
+
+// WriteHostsFile writes DNSCache as a preresolve file, so the resolution
+// one worker saw can be pinned on others with LoadPreresolveFile
+func (t *Transport) WriteHostsFile(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, h := range t.DNSCache() {
+		fmt.Fprintf(bw, "%s %s # %s\n", h.Addr, net.JoinHostPort(h.Host, strconv.Itoa(h.Port)), h.Time.UTC().Format(time.RFC3339))
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write hosts file: %w", err)
+	}
+	return nil
+}
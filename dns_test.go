@@ -0,0 +1,134 @@
+package curlhttp
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// TestParsePreresolve tests hosts-style parsing, ports and merging
+func TestParsePreresolve(t *testing.T) {
+	input := `# pinned for the eu fleet
+93.184.215.14   example.com www.example.com
+2606:2800::1    Example.com   # second address
+10.0.0.7        api.internal:8443
+`
+	entries, err := ParsePreresolve(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParsePreresolve failed: %v", err)
+	}
+	want := []string{
+		"example.com:80:93.184.215.14,[2606:2800::1]",
+		"example.com:443:93.184.215.14,[2606:2800::1]",
+		"www.example.com:80:93.184.215.14",
+		"www.example.com:443:93.184.215.14",
+		"api.internal:8443:10.0.0.7",
+	}
+	if !slices.Equal(entries, want) {
+		t.Errorf("Expected %v, got %v", want, entries)
+	}
+
+	for _, bad := range []string{"example.com 1.2.3.4", "1.2.3.4", "1.2.3.4 host:99999"} {
+		if _, err := ParsePreresolve(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+// TestTransportResolve tests that pins reach curl and are reloaded with the config
+func TestTransportResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	os.WriteFile(path, []byte("10.0.0.7 api.internal:8443\n"), 0o644)
+	entries, err := LoadPreresolveFile(path)
+	if err != nil {
+		t.Fatalf("LoadPreresolveFile failed: %v", err)
+	}
+
+	fake := newFakeEngine("ok")
+	transport := newFakeTransport(fake)
+	transport.Resolve = entries
+	fetch(t, transport)
+	if got, _ := fake.performed[curl.OPT_RESOLVE].([]string); !slices.Equal(got, entries) {
+		t.Errorf("Expected OPT_RESOLVE %v, got %v", entries, got)
+	}
+
+	if err := transport.ApplyConfig(&Config{Resolve: []string{"10.0.0.8 api.internal:8443"}}); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if stats := transport.Stats(); stats.HandlesDestroyed != 1 {
+		t.Errorf("Expected the pinned handle to be retired, got %+v", stats)
+	}
+	fetch(t, transport)
+	if got, _ := fake.performed[curl.OPT_RESOLVE].([]string); !slices.Equal(got, []string{"api.internal:8443:10.0.0.8"}) {
+		t.Errorf("Expected the new pin, got %v", got)
+	}
+
+	if _, err := ParseConfig([]byte("resolve:\n  - bogus example.com\n")); err == nil {
+		t.Error("Expected an error for an invalid resolve line")
+	}
+}
+
+// TestDNSCacheExport tests recording and exporting connected addresses
+func TestDNSCacheExport(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	fake := newFakeEngine("ok")
+	fake.info = map[curl.CurlInfo]interface{}{
+		infoPrimaryIP:   "93.184.215.14",
+		infoPrimaryPort: int64(443),
+	}
+	transport := newFakeTransport(fake)
+	transport.Clock = clock
+	fetch(t, transport)
+
+	cache := transport.DNSCache()
+	if len(cache) != 1 || cache[0].Host != "example.com" || cache[0].Port != 443 || cache[0].Addr != "93.184.215.14" {
+		t.Fatalf("Expected example.com:443 at 93.184.215.14, got %+v", cache)
+	}
+
+	var out strings.Builder
+	if err := transport.WriteHostsFile(&out); err != nil {
+		t.Fatalf("WriteHostsFile failed: %v", err)
+	}
+	want := "93.184.215.14 example.com:443 # 2026-01-02T03:04:05Z\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+	entries, err := ParsePreresolve(strings.NewReader(out.String()))
+	if err != nil || !slices.Equal(entries, []string{"example.com:443:93.184.215.14"}) {
+		t.Errorf("Expected the export to load back, got %v, %v", entries, err)
+	}
+}
+
+// TestDNSCacheBounded tests that the least recently seen hosts are dropped
+// beyond maxResolvedHosts
+func TestDNSCacheBounded(t *testing.T) {
+	transport := NewTransport()
+	record := func(host string) {
+		req, _ := http.NewRequest("GET", "https://"+host+"/", nil)
+		transport.recordResolution(req, &ConnectionInfo{PrimaryIP: "192.0.2.1", PrimaryPort: 443})
+	}
+	for i := 0; i < maxResolvedHosts; i++ {
+		record(fmt.Sprintf("h%d.example", i))
+	}
+	record("h0.example") // seen again, so h1 is the oldest
+	record("new.example")
+
+	cache := transport.DNSCache()
+	if len(cache) != maxResolvedHosts {
+		t.Fatalf("Expected %d hosts, got %d", maxResolvedHosts, len(cache))
+	}
+	hosts := make(map[string]bool, len(cache))
+	for _, h := range cache {
+		hosts[h.Host] = true
+	}
+	if !hosts["h0.example"] || !hosts["new.example"] || hosts["h1.example"] {
+		t.Errorf("Expected h1.example to be evicted, got h0 %v, new %v, h1 %v", hosts["h0.example"], hosts["new.example"], hosts["h1.example"])
+	}
+}
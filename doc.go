@@ -38,6 +38,17 @@ By default, the package impersonates Chrome 136. Supported targets include:
 
 The impersonation includes proper TLS fingerprints and headers to avoid detection.
 
+# Connections
+
+Each pooled curl handle performs its transfers through curl's easy interface
+and keeps its own connection cache, so a connection, including an HTTP/2
+connection, is only reused by the handle that opened it. Requests are not
+multiplexed as streams over a shared HTTP/2 connection, and curl's
+multiplexing controls (PIPEWAIT, the per-connection stream limit and
+connection coalescing across hostnames) do not apply, since they belong to
+curl's multi interface. The pool size therefore also bounds the number of
+connections kept open per host.
+
 # Compatibility
 
 This package provides 100% API compatibility with net/http. All types, constants,
@@ -0,0 +1,253 @@
+package curlhttp
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrChecksumMismatch is returned by Download when the body does not match
+// the response's Content-MD5
+var ErrChecksumMismatch = errors.New("download checksum mismatch")
+
+// DownloadProgress describes a running download
+type DownloadProgress struct {
+	// Written is the size of the destination file so far, including
+	// bytes kept from a previous attempt when resuming.
+	Written int64
+
+	// Total is the expected final size, or -1 if the server did not say.
+	Total int64
+
+	// Rate is the average transfer rate in bytes per second since this
+	// download started, and ETA the time left at that rate, or -1 if
+	// it cannot be estimated.
+	Rate float64
+	ETA  time.Duration
+}
+
+// DownloadOptions configures Client.Download
+type DownloadOptions struct {
+	// Resume continues a partial destination file with a Range request
+	// instead of starting over. Servers that ignore the range send the
+	// whole file, which then replaces the partial one.
+	Resume bool
+
+	// Progress, if set, is called at most every ProgressInterval
+	// (default 500ms) while data arrives, and once when it is complete.
+	Progress         func(DownloadProgress)
+	ProgressInterval time.Duration
+
+	// Header holds extra request headers.
+	Header http.Header
+}
+
+// DownloadResult describes a finished download
+type DownloadResult struct {
+	Size    int64 // final size of the destination file
+	Written int64 // bytes transferred by this call
+	Resumed bool  // the download continued a partial file
+}
+
+// Download streams url into the file dest without holding the body in
+// memory. The received length is checked against Content-Length and the
+// body against Content-MD5 when the server sends them; a mismatch returns
+// a *ContentLengthError or ErrChecksumMismatch. A failed download leaves
+// the partial file in place so it can be resumed. The Client's Timeout
+// does not apply, since large files legitimately take long; bound the
+// download with ctx instead.
+func (c *Client) Download(ctx context.Context, url, dest string, opts *DownloadOptions) (*DownloadResult, error) {
+	c.ensureInitialized()
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+
+	var offset int64
+	if opts.Resume {
+		if info, err := os.Stat(dest); err == nil && info.Mode().IsRegular() {
+			offset = info.Size()
+		}
+	}
+
+	req, err := http.NewRequestWithContext(WithResponseStreaming(ctx), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range opts.Header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	if c.scope != nil {
+		if req, err = c.scope.apply(req); err != nil {
+			return nil, err
+		}
+	}
+
+	client := c.Client
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &DownloadResult{}
+	total := int64(-1)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return nil, fmt.Errorf("server resumed at an unexpected range %q", resp.Header.Get("Content-Range"))
+		}
+		total = size
+		flags = os.O_WRONLY | os.O_APPEND
+		result.Resumed = true
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The partial file may already be complete
+		if _, size, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && size == offset {
+			result.Size, result.Resumed = offset, true
+			return result, nil
+		}
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status}
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		offset = 0
+		total = resp.ContentLength
+	default:
+		return nil, httpError(resp)
+	}
+
+	file, err := os.OpenFile(dest, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", dest, err)
+	}
+	defer file.Close()
+
+	progress := &progressWriter{
+		w:        file,
+		report:   opts.Progress,
+		interval: opts.ProgressInterval,
+		offset:   offset,
+		total:    total,
+		start:    time.Now(),
+	}
+	var sum hash.Hash
+	dst := io.Writer(progress)
+	if resp.Header.Get("Content-Md5") != "" {
+		sum = md5.New()
+		dst = io.MultiWriter(progress, sum)
+	}
+
+	written, copyErr := io.Copy(dst, resp.Body)
+	result.Written = written
+	result.Size = offset + written
+	progress.flush()
+	if copyErr != nil {
+		return result, fmt.Errorf("failed to download %s: %w", url, copyErr)
+	}
+	if err := file.Close(); err != nil {
+		return result, fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+
+	if resp.ContentLength >= 0 && written != resp.ContentLength {
+		return result, &ContentLengthError{Declared: resp.ContentLength, Received: written}
+	}
+	if sum != nil {
+		expected := strings.TrimSpace(resp.Header.Get("Content-Md5"))
+		if actual := base64.StdEncoding.EncodeToString(sum.Sum(nil)); actual != expected {
+			return result, fmt.Errorf("%w: Content-MD5 %s, received %s", ErrChecksumMismatch, expected, actual)
+		}
+	}
+	return result, nil
+}
+
+// parseContentRange parses "bytes start-end/size" and "bytes */size",
+// returning -1 for an unknown size
+func parseContentRange(value string) (start, size int64, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(value), "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, sizeText, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+	size = -1
+	if sizeText != "*" {
+		n, err := strconv.ParseInt(sizeText, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		size = n
+	}
+	if rng == "*" {
+		return 0, size, true
+	}
+	first, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, size, true
+}
+
+// progressWriter counts the bytes written through it and reports progress
+type progressWriter struct {
+	w        io.Writer
+	report   func(DownloadProgress)
+	interval time.Duration
+	offset   int64 // bytes already in the file when the download started
+	total    int64
+	written  int64
+	start    time.Time
+	last     time.Time
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	if p.report != nil {
+		interval := p.interval
+		if interval <= 0 {
+			interval = 500 * time.Millisecond
+		}
+		if now := time.Now(); now.Sub(p.last) >= interval {
+			p.last = now
+			p.report(p.progress(now))
+		}
+	}
+	return n, err
+}
+
+// flush reports the final progress
+func (p *progressWriter) flush() {
+	if p.report != nil {
+		p.report(p.progress(time.Now()))
+	}
+}
+
+// progress computes the current progress
+func (p *progressWriter) progress(now time.Time) DownloadProgress {
+	pr := DownloadProgress{Written: p.offset + p.written, Total: p.total, ETA: -1}
+	if elapsed := now.Sub(p.start).Seconds(); elapsed > 0 {
+		pr.Rate = float64(p.written) / elapsed
+	}
+	if p.total >= 0 && pr.Rate > 0 {
+		pr.ETA = time.Duration(float64(p.total-pr.Written) / pr.Rate * float64(time.Second))
+	}
+	return pr
+}
@@ -0,0 +1,188 @@
+package curlhttp
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fileServer serves content, honoring open-ended Range requests
+func fileServer(content string, seen *[]*http.Request) *Client {
+	return &Client{Client: http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		*seen = append(*seen, req)
+		resp := jsonResponse(200, "application/octet-stream", content)
+		var start int
+		if rng := req.Header.Get("Range"); rng != "" {
+			start, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			if start >= len(content) {
+				resp = jsonResponse(416, "", "")
+				resp.Header.Set("Content-Range", "bytes */"+strconv.Itoa(len(content)))
+				return resp, nil
+			}
+			resp = jsonResponse(206, "application/octet-stream", content[start:])
+			resp.Header.Set("Content-Range", "bytes "+strconv.Itoa(start)+"-"+strconv.Itoa(len(content)-1)+"/"+strconv.Itoa(len(content)))
+		}
+		return resp, nil
+	})}}
+}
+
+// TestDownload tests a full download with progress reports
+func TestDownload(t *testing.T) {
+	var seen []*http.Request
+	client := fileServer("hello world", &seen)
+	dest := filepath.Join(t.TempDir(), "file")
+
+	var reports []DownloadProgress
+	result, err := client.Download(context.Background(), "http://example.com/file", dest, &DownloadOptions{
+		Progress: func(p DownloadProgress) { reports = append(reports, p) },
+	})
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	data, _ := os.ReadFile(dest)
+	if string(data) != "hello world" {
+		t.Errorf("Expected file contents 'hello world', got %q", data)
+	}
+	if result.Size != 11 || result.Written != 11 || result.Resumed {
+		t.Errorf("Expected a fresh 11 byte download, got %+v", result)
+	}
+	if len(reports) == 0 {
+		t.Fatal("Expected progress reports")
+	}
+	if last := reports[len(reports)-1]; last.Written != 11 || last.Total != 11 {
+		t.Errorf("Expected final progress 11/11, got %+v", last)
+	}
+	if !seen[0].Context().Value(streamResponseKey{}).(bool) {
+		t.Error("Expected the download to request response streaming")
+	}
+}
+
+// TestDownloadResume tests continuing a partial file with a Range request
+func TestDownloadResume(t *testing.T) {
+	var seen []*http.Request
+	client := fileServer("hello world", &seen)
+	dest := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(dest, []byte("hello"), 0o644)
+
+	result, err := client.Download(context.Background(), "http://example.com/file", dest, &DownloadOptions{Resume: true})
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if got := seen[0].Header.Get("Range"); got != "bytes=5-" {
+		t.Errorf("Expected Range bytes=5-, got %q", got)
+	}
+	data, _ := os.ReadFile(dest)
+	if string(data) != "hello world" {
+		t.Errorf("Expected file contents 'hello world', got %q", data)
+	}
+	if !result.Resumed || result.Written != 6 || result.Size != 11 {
+		t.Errorf("Expected 6 bytes appended to 11, got %+v", result)
+	}
+
+	// Resuming a complete file transfers nothing
+	result, err = client.Download(context.Background(), "http://example.com/file", dest, &DownloadOptions{Resume: true})
+	if err != nil {
+		t.Fatalf("Download of a complete file failed: %v", err)
+	}
+	if result.Size != 11 || result.Written != 0 {
+		t.Errorf("Expected complete file to be kept, got %+v", result)
+	}
+}
+
+// TestDownloadIgnoredRange tests that a full response replaces the partial file
+func TestDownloadIgnoredRange(t *testing.T) {
+	client := &Client{Client: http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(200, "", "fresh"), nil
+	})}}
+	dest := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(dest, []byte("stale data"), 0o644)
+
+	result, err := client.Download(context.Background(), "http://example.com/file", dest, &DownloadOptions{Resume: true})
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	data, _ := os.ReadFile(dest)
+	if string(data) != "fresh" || result.Resumed {
+		t.Errorf("Expected partial file to be replaced, got %q and %+v", data, result)
+	}
+}
+
+// TestDownloadVerification tests Content-MD5, Content-Length and status checks
+func TestDownloadVerification(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "file")
+	respond := func(resp *http.Response) *Client {
+		return &Client{Client: http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return resp, nil
+		})}}
+	}
+
+	sum := md5.Sum([]byte("payload"))
+	resp := jsonResponse(200, "", "payload")
+	resp.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	if _, err := respond(resp).Download(context.Background(), "http://example.com/", dest, nil); err != nil {
+		t.Errorf("Expected matching Content-MD5 to pass, got %v", err)
+	}
+
+	resp = jsonResponse(200, "", "tampered")
+	resp.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	if _, err := respond(resp).Download(context.Background(), "http://example.com/", dest, nil); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+
+	resp = jsonResponse(200, "", "short")
+	resp.ContentLength = 10
+	var lengthErr *ContentLengthError
+	if _, err := respond(resp).Download(context.Background(), "http://example.com/", dest, nil); !errors.As(err, &lengthErr) {
+		t.Errorf("Expected *ContentLengthError, got %v", err)
+	}
+
+	var httpErr *HTTPError
+	if _, err := respond(jsonResponse(404, "", "missing")).Download(context.Background(), "http://example.com/", dest, nil); !errors.As(err, &httpErr) || httpErr.StatusCode != 404 {
+		t.Errorf("Expected *HTTPError with status 404, got %v", err)
+	}
+}
+
+// TestDownloadStreamsResponse tests that a download streams through the Transport
+func TestDownloadStreamsResponse(t *testing.T) {
+	engine := newChunkedEngine("first ", "second")
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return engine }
+	client := &Client{Client: http.Client{Transport: transport}}
+	dest := filepath.Join(t.TempDir(), "file")
+
+	if _, err := client.Download(context.Background(), "http://example.com/", dest, nil); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	data, _ := os.ReadFile(dest)
+	if string(data) != "first second" {
+		t.Errorf("Expected 'first second', got %q", data)
+	}
+}
+
+// TestParseContentRange tests Content-Range parsing
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		value       string
+		start, size int64
+		ok          bool
+	}{
+		{"bytes 5-10/11", 5, 11, true},
+		{"bytes 0-99/*", 0, -1, true},
+		{"bytes */42", 0, 42, true},
+		{"items 0-1/2", 0, 0, false},
+		{"bytes 5-10", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, size, ok := parseContentRange(tt.value)
+		if start != tt.start || size != tt.size || ok != tt.ok {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v, expected %d, %d, %v", tt.value, start, size, ok, tt.start, tt.size, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package curlhttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+)
+
+// PeekBody returns up to n bytes from the start of resp's body without
+// consuming them: resp.Body is replaced by a body that replays them before
+// the rest, keeping the response's Notes, Timings and Close behaviour. For
+// streamed responses it waits until n bytes, the end of the body or an
+// error arrive.
+func PeekBody(resp *http.Response, n int) ([]byte, error) {
+	if n <= 0 || resp.Body == nil || resp.Body == http.NoBody {
+		return nil, nil
+	}
+	data := make([]byte, n)
+	read, err := io.ReadFull(resp.Body, data)
+	data = data[:read]
+	resp.Body = &prefixedBody{prefix: bytes.NewReader(data), ReadCloser: resp.Body}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	if err != nil {
+		return data, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return data, nil
+}
+
+// DumpResponse is like httputil.DumpResponse, but includes at most bodyLimit
+// bytes of the body and leaves all of it for the caller to read, so it can
+// log responses, including streamed ones, from middleware. The body is
+// dumped as received, after curl has removed any content and transfer
+// coding. A bodyLimit of zero dumps the status line and headers only.
+func DumpResponse(resp *http.Response, bodyLimit int) ([]byte, error) {
+	dump, err := httputil.DumpResponse(resp, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump response: %w", err)
+	}
+	body, err := PeekBody(resp, bodyLimit)
+	return append(dump, body...), err
+}
+
+// ResponseDumper passes a dump of every response to Log, for debugging.
+// Install it with Transport.Use(d.Middleware()).
+type ResponseDumper struct {
+	// BodyLimit is the number of body bytes included in each dump. Zero
+	// dumps the status line and headers only. For streamed responses the
+	// response is returned once BodyLimit bytes have arrived.
+	BodyLimit int
+
+	// Log receives the request and the dump of its response
+	Log func(req *http.Request, dump []byte)
+}
+
+// Middleware returns middleware that dumps each response before returning
+// it. A body that fails to read is still returned to the caller, whose
+// reads see the same error.
+func (d *ResponseDumper) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err != nil || d.Log == nil {
+				return resp, err
+			}
+			dump, _ := DumpResponse(resp, d.BodyLimit)
+			d.Log(req, dump)
+			return resp, nil
+		})
+	}
+}
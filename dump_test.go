@@ -0,0 +1,110 @@
+package curlhttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestPeekBody tests that peeked bytes stay readable and metadata is kept
+func TestPeekBody(t *testing.T) {
+	transport := newFakeTransport(newFakeEngine("hello world"))
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+
+	prefix, err := PeekBody(resp, 5)
+	if err != nil {
+		t.Fatalf("PeekBody failed: %v", err)
+	}
+	if string(prefix) != "hello" {
+		t.Errorf("Expected 'hello', got %q", prefix)
+	}
+	if metaOf(resp) == nil {
+		t.Error("Expected response metadata to survive peeking")
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello world" {
+		t.Errorf("Expected full body after peeking, got %q", body)
+	}
+
+	// Peeking past the end returns the whole body
+	resp, _ = transport.RoundTrip(req)
+	prefix, err = PeekBody(resp, 100)
+	if err != nil || string(prefix) != "hello world" {
+		t.Errorf("Expected whole body without error, got %q, %v", prefix, err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	if string(body) != "hello world" {
+		t.Errorf("Expected full body after peeking past the end, got %q", body)
+	}
+}
+
+// TestDumpResponse tests that dumps hold headers and a body prefix of streamed responses
+func TestDumpResponse(t *testing.T) {
+	engine := newChunkedEngine("first ", "second")
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return engine }
+
+	req, _ := http.NewRequestWithContext(WithResponseStreaming(context.Background()), "GET", "http://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+
+	dump, err := DumpResponse(resp, 4)
+	if err != nil {
+		t.Fatalf("DumpResponse failed: %v", err)
+	}
+	if !strings.HasPrefix(string(dump), "HTTP/1.1 200 OK\r\n") {
+		t.Errorf("Expected status line, got %q", dump)
+	}
+	if !strings.Contains(string(dump), "Content-Type: text/plain\r\n") {
+		t.Errorf("Expected headers in dump, got %q", dump)
+	}
+	if !strings.HasSuffix(string(dump), "\r\n\r\nfirs") {
+		t.Errorf("Expected body prefix after headers, got %q", dump)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "first second" {
+		t.Errorf("Expected full streamed body, got %q, %v", body, err)
+	}
+}
+
+// TestResponseDumper tests that the middleware logs dumps without consuming bodies
+func TestResponseDumper(t *testing.T) {
+	var logged []string
+	dumper := &ResponseDumper{
+		BodyLimit: 3,
+		Log: func(req *http.Request, dump []byte) {
+			logged = append(logged, req.URL.Path+" "+string(dump))
+		},
+	}
+	transport := newFakeTransport(newFakeEngine("abcdef"))
+	transport.Use(dumper.Middleware())
+
+	req, _ := http.NewRequest("GET", "http://example.com/page", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if len(logged) != 1 {
+		t.Fatalf("Expected 1 dump, got %d", len(logged))
+	}
+	if !strings.HasPrefix(logged[0], "/page HTTP/") || !strings.HasSuffix(logged[0], "\r\n\r\nabc") {
+		t.Errorf("Expected dump of /page ending in 'abc', got %q", logged[0])
+	}
+	if string(body) != "abcdef" {
+		t.Errorf("Expected 'abcdef', got %q", body)
+	}
+}
@@ -0,0 +1,128 @@
+package curlhttp
+
+import (
+	"net/url"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// curlEngine is the subset of a curl easy handle used by Transport. The real
+// implementation is *curl.CURL; tests substitute a fake so that method
+// mapping, header handling and pooling can be exercised without libcurl or
+// network access.
+type curlEngine interface {
+	Setopt(opt curl.EasyOpt, param interface{}) error
+	Getinfo(info uint32) (interface{}, error)
+	Perform() error
+	Reset()
+	Cleanup()
+	Impersonate(target string, defaultHeaders bool) error
+}
+
+// CURLINFO values read through Getinfo. The binding only exports the type
+// masks, so the values are spelled out as curl.h defines them.
+const (
+	curlInfoString = 0x100000
+	curlInfoLong   = 0x200000
+	curlInfoDouble = 0x300000
+	curlInfoPtr    = 0x400000
+
+	infoResponseCode      = curlInfoLong + 2
+	infoTotalTime         = curlInfoDouble + 3
+	infoNameLookupTime    = curlInfoDouble + 4
+	infoConnectTime       = curlInfoDouble + 5
+	infoPretransferTime   = curlInfoDouble + 6
+	infoSizeUpload        = curlInfoDouble + 7
+	infoSizeDownload      = curlInfoDouble + 8
+	infoHeaderSize        = curlInfoLong + 11
+	infoRequestSize       = curlInfoLong + 12
+	infoStartTransferTime = curlInfoDouble + 17
+	infoContentType       = curlInfoString + 18
+	infoHTTPConnectCode   = curlInfoLong + 22
+	infoOSErrno           = curlInfoLong + 25
+	infoNumConnects       = curlInfoLong + 26
+	infoLastSocket        = curlInfoLong + 29
+	infoPrimaryIP         = curlInfoString + 32
+	infoAppConnectTime    = curlInfoDouble + 33
+	infoCertInfo          = curlInfoPtr + 34
+	infoPrimaryPort       = curlInfoLong + 40
+	infoLocalIP           = curlInfoString + 41
+	infoLocalPort         = curlInfoLong + 42
+)
+
+// newCurlEngine creates a real curl easy handle, or returns nil if libcurl
+// is not initialized or could not allocate one
+func newCurlEngine() curlEngine {
+	if initCurl() != nil {
+		return nil
+	}
+	easy := curl.EasyInit()
+	if easy == nil {
+		return nil
+	}
+	return easy
+}
+
+// getinfoDuration reads a curl timing value reported in seconds
+func getinfoDuration(easy curlEngine, info curl.CurlInfo) time.Duration {
+	value, err := easy.Getinfo(uint32(info))
+	if err != nil {
+		return 0
+	}
+	seconds, ok := value.(float64)
+	if !ok {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// collectTimings reads the phase timings of the last transfer
+func collectTimings(easy curlEngine) *Timings {
+	return &Timings{
+		NameLookup:    getinfoDuration(easy, infoNameLookupTime),
+		Connect:       getinfoDuration(easy, infoConnectTime),
+		AppConnect:    getinfoDuration(easy, infoAppConnectTime),
+		PreTransfer:   getinfoDuration(easy, infoPretransferTime),
+		StartTransfer: getinfoDuration(easy, infoStartTransferTime),
+		Total:         getinfoDuration(easy, infoTotalTime),
+	}
+}
+
+// getinfoString reads a string info value, or "" if unavailable
+func getinfoString(easy curlEngine, info curl.CurlInfo) string {
+	value, err := easy.Getinfo(uint32(info))
+	if err != nil {
+		return ""
+	}
+	str, _ := value.(string)
+	return str
+}
+
+// getinfoInt reads a long info value, or 0 if unavailable
+func getinfoInt(easy curlEngine, info curl.CurlInfo) int64 {
+	value, err := easy.Getinfo(uint32(info))
+	if err != nil {
+		return 0
+	}
+	n, _ := value.(int64)
+	return n
+}
+
+// collectConnInfo reads the endpoints of the connection used by the last
+// transfer, made through proxy unless it is nil
+func collectConnInfo(easy curlEngine, proxy *url.URL) *ConnectionInfo {
+	info := &ConnectionInfo{
+		PrimaryIP:   getinfoString(easy, infoPrimaryIP),
+		PrimaryPort: int(getinfoInt(easy, infoPrimaryPort)),
+		LocalIP:     getinfoString(easy, infoLocalIP),
+		LocalPort:   int(getinfoInt(easy, infoLocalPort)),
+		Reused:      getinfoInt(easy, infoNumConnects) == 0,
+		ViaProxy:    proxy != nil,
+	}
+	if proxy != nil {
+		info.Proxy = proxy.Redacted()
+		info.ProxyConnectStatus = int(getinfoInt(easy, infoHTTPConnectCode))
+	}
+	return info
+}
@@ -0,0 +1,508 @@
+package curlhttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// fakeEngine is a curlEngine that records options and replays a canned response
+type fakeEngine struct {
+	opts       map[curl.EasyOpt]interface{}
+	performed  map[curl.EasyOpt]interface{} // options in effect at the last Perform
+	status     int64
+	info       map[curl.CurlInfo]interface{} // other Getinfo values
+	headers    []string
+	body       []byte
+	performErr error
+	uploaded   []byte // body read through the read callback
+	uploadSize int64  // bytes read through the read callback
+	countOnly  bool   // count uploaded bytes, in large reads, without keeping them
+	target     string
+	resets     int
+	setopts    int
+	cleanups   int
+}
+
+// newFakeEngine creates a fake answering 200 OK with body
+func newFakeEngine(body string) *fakeEngine {
+	return &fakeEngine{
+		opts:    make(map[curl.EasyOpt]interface{}),
+		status:  200,
+		headers: []string{"HTTP/1.1 200 OK\r\n", "Content-Type: text/plain\r\n", "\r\n"},
+		body:    []byte(body),
+	}
+}
+
+func (f *fakeEngine) Setopt(opt curl.EasyOpt, param interface{}) error {
+	f.setopts++
+	f.opts[opt] = param
+	return nil
+}
+
+func (f *fakeEngine) Getinfo(info uint32) (interface{}, error) {
+	switch info {
+	case infoResponseCode:
+		return f.status, nil
+	}
+	return f.info[curl.CurlInfo(info)], nil
+}
+
+func (f *fakeEngine) Perform() error {
+	f.performed = make(map[curl.EasyOpt]interface{}, len(f.opts))
+	for k, v := range f.opts {
+		f.performed[k] = v
+	}
+	if cb, ok := f.opts[curl.OPT_READFUNCTION].(func([]byte, interface{}) int); ok {
+		f.uploaded, f.uploadSize = f.uploaded[:0], 0
+		buf := make([]byte, 7) // small reads exercise part boundaries
+		if f.countOnly {
+			buf = make([]byte, 1<<20)
+		}
+		for {
+			n := cb(buf, f.opts[curl.OPT_READDATA])
+			if n == 0 || n == readFuncAbort {
+				break
+			}
+			f.uploadSize += int64(n)
+			if !f.countOnly {
+				f.uploaded = append(f.uploaded, buf[:n]...)
+			}
+		}
+	}
+	if cb, ok := f.opts[curl.OPT_HEADERFUNCTION].(func([]byte, interface{}) bool); ok {
+		for _, line := range f.headers {
+			if !cb([]byte(line), f.opts[curl.OPT_HEADERDATA]) {
+				return curl.CurlError(curl.E_WRITE_ERROR)
+			}
+		}
+	}
+	if cb, ok := f.opts[curl.OPT_WRITEFUNCTION].(func([]byte, interface{}) bool); ok && len(f.body) > 0 {
+		cb(f.body, f.opts[curl.OPT_WRITEDATA])
+	}
+	return f.performErr
+}
+
+func (f *fakeEngine) Reset() {
+	f.resets++
+	f.opts = make(map[curl.EasyOpt]interface{})
+}
+
+func (f *fakeEngine) Cleanup() {
+	f.cleanups++
+}
+
+func (f *fakeEngine) Impersonate(target string, defaultHeaders bool) error {
+	f.target = target
+	return nil
+}
+
+// newFakeTransport returns a Transport whose handles are all the given fake
+func newFakeTransport(fake *fakeEngine) *Transport {
+	t := NewTransport()
+	t.newEngine = func() curlEngine { return fake }
+	return t
+}
+
+// TestFakeEngineRoundTrip tests a full round trip through a fake engine
+func TestFakeEngineRoundTrip(t *testing.T) {
+	fake := newFakeEngine("hello")
+	transport := newFakeTransport(fake)
+
+	req, _ := http.NewRequest("GET", "http://example.com/path", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "hello" {
+		t.Errorf("Expected body hello, got %q", body)
+	}
+	if resp.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected Content-Type text/plain, got %q", resp.Header.Get("Content-Type"))
+	}
+	if fake.performed[curl.OPT_URL] != "http://example.com/path" {
+		t.Errorf("Expected URL option to be set, got %v", fake.performed[curl.OPT_URL])
+	}
+	if fake.target != "chrome136" {
+		t.Errorf("Expected impersonation target chrome136, got %q", fake.target)
+	}
+}
+
+// TestFakeEngineMethodMapping tests how HTTP methods map to curl options
+func TestFakeEngineMethodMapping(t *testing.T) {
+	tests := []struct {
+		method string
+		body   string
+		opt    curl.EasyOpt
+		want   interface{}
+	}{
+		{"GET", "", curl.OPT_HTTPGET, true},
+		{"HEAD", "", curl.OPT_NOBODY, true},
+		{"POST", "data", curl.OPT_POST, true},
+		{"DELETE", "", curl.OPT_CUSTOMREQUEST, "DELETE"},
+		{"PATCH", "", curl.OPT_CUSTOMREQUEST, "PATCH"},
+	}
+
+	for _, tt := range tests {
+		fake := newFakeEngine("")
+		transport := newFakeTransport(fake)
+
+		var body io.Reader
+		if tt.body != "" {
+			body = strings.NewReader(tt.body)
+		}
+		req, _ := http.NewRequest(tt.method, "http://example.com", body)
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatalf("%s: RoundTrip failed: %v", tt.method, err)
+		}
+		if got := fake.performed[tt.opt]; got != tt.want {
+			t.Errorf("%s: expected option %d = %v, got %v", tt.method, tt.opt, tt.want, got)
+		}
+	}
+}
+
+// TestMethodBodies tests every method with and without a body, buffered
+// and streamed
+func TestMethodBodies(t *testing.T) {
+	tests := []struct {
+		method   string
+		body     string
+		custom   interface{} // CUSTOMREQUEST
+		post     bool        // sent the way curl sends a POST
+		wantSize interface{} // POSTFIELDSIZE_LARGE
+	}{
+		{"GET", "", nil, false, nil},
+		{"GET", "query", "GET", true, int64(5)},
+		{"HEAD", "", nil, false, nil},
+		{"POST", "", nil, true, int64(0)},
+		{"POST", "form", nil, true, int64(4)},
+		{"PUT", "", "PUT", true, int64(0)},
+		{"PUT", "file", "PUT", true, int64(4)},
+		{"PATCH", "", "PATCH", true, int64(0)},
+		{"PATCH", "diff", "PATCH", true, int64(4)},
+		{"DELETE", "", "DELETE", false, nil},
+		{"DELETE", "ids", "DELETE", true, int64(3)},
+		{"OPTIONS", "", "OPTIONS", false, nil},
+		{"PROPFIND", "<propfind/>", "PROPFIND", true, int64(11)},
+	}
+
+	for _, tt := range tests {
+		for _, streamed := range []bool{false, true} {
+			if streamed && tt.body == "" {
+				continue
+			}
+			name := fmt.Sprintf("%s %q streamed=%v", tt.method, tt.body, streamed)
+			fake := newFakeEngine("")
+			transport := newFakeTransport(fake)
+			if streamed {
+				transport.StreamUploadThreshold = 1
+			}
+
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req, _ := http.NewRequest(tt.method, "http://example.com", body)
+			if _, err := transport.RoundTrip(req); err != nil {
+				t.Fatalf("%s: RoundTrip failed: %v", name, err)
+			}
+
+			if got := fake.performed[curl.OPT_CUSTOMREQUEST]; got != tt.custom {
+				t.Errorf("%s: expected CUSTOMREQUEST %v, got %v", name, tt.custom, got)
+			}
+			if got := fake.performed[curl.OPT_POST] == true; got != tt.post {
+				t.Errorf("%s: expected POST %v, got %v", name, tt.post, got)
+			}
+			if got := fake.performed[curl.OPT_POSTFIELDSIZE_LARGE]; got != tt.wantSize {
+				t.Errorf("%s: expected size %v, got %v", name, tt.wantSize, got)
+			}
+			if _, ok := fake.performed[curl.OPT_UPLOAD]; ok {
+				t.Errorf("%s: expected UPLOAD not to be used", name)
+			}
+			sent := string(fake.uploaded)
+			if !streamed {
+				data, _ := fake.performed[curl.OPT_POSTFIELDS].([]byte)
+				sent = string(data)
+			}
+			if sent != tt.body {
+				t.Errorf("%s: expected body %q to be sent, got %q", name, tt.body, sent)
+			}
+			if _, ok := sentHeaders(fake)["Content-Type:"]; ok != tt.post {
+				t.Errorf("%s: expected curl's default Content-Type to be suppressed: %v", name, tt.post)
+			}
+		}
+	}
+}
+
+// TestFakeEngineHeaders tests that request headers are passed to curl
+func TestFakeEngineHeaders(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	req.Header.Set("X-Custom", "value")
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+
+	headers, _ := fake.performed[curl.OPT_HTTPHEADER].([]string)
+	found := false
+	for _, h := range headers {
+		if h == "X-Custom: value" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected X-Custom header in %v", headers)
+	}
+}
+
+// TestFakeEnginePooling tests that handles are reset and reused
+func TestFakeEnginePooling(t *testing.T) {
+	created := 0
+	fake := newFakeEngine("")
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine {
+		created++
+		return fake
+	}
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", "http://example.com", nil)
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+	}
+
+	if created != 1 {
+		t.Errorf("Expected a single handle to be created and reused, got %d", created)
+	}
+	if fake.resets != 0 {
+		t.Errorf("Expected handle to be reused without a full reset, got %d resets", fake.resets)
+	}
+	if fake.cleanups != 0 {
+		t.Errorf("Expected no cleanups while pool has room, got %d", fake.cleanups)
+	}
+}
+
+// TestFakeEnginePerformError tests that curl errors are surfaced
+func TestFakeEnginePerformError(t *testing.T) {
+	fake := newFakeEngine("")
+	fake.performErr = curl.CurlError(7)
+	transport := newFakeTransport(fake)
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Error("Expected error when Perform fails")
+	}
+}
+
+// TestFakeEngineConnInfo tests that connection details are attached to responses
+func TestFakeEngineConnInfo(t *testing.T) {
+	fake := newFakeEngine("")
+	fake.info = map[curl.CurlInfo]interface{}{
+		infoPrimaryIP:   "203.0.113.7",
+		infoPrimaryPort: int64(443),
+		infoLocalIP:     "192.0.2.10",
+		infoLocalPort:   int64(51234),
+		infoNumConnects: int64(1),
+	}
+	transport := newFakeTransport(fake)
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+
+	info, ok := ConnInfo(resp)
+	if !ok {
+		t.Fatal("Expected connection info on response")
+	}
+	want := ConnectionInfo{PrimaryIP: "203.0.113.7", PrimaryPort: 443, LocalIP: "192.0.2.10", LocalPort: 51234}
+	if info != want {
+		t.Errorf("Expected %+v, got %+v", want, info)
+	}
+
+	fake.info[infoNumConnects] = int64(0)
+	resp, _ = transport.RoundTrip(req)
+	if info, _ := ConnInfo(resp); !info.Reused {
+		t.Error("Expected connection to be reported as reused")
+	}
+
+	if _, ok := ConnInfo(&http.Response{Body: http.NoBody}); ok {
+		t.Error("Expected no connection info for foreign response")
+	}
+}
+
+// TestFakeEngineClearsRequestOptions tests that a reused handle keeps no request state
+func TestFakeEngineClearsRequestOptions(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+
+	req, _ := http.NewRequest("PATCH", "http://example.com", nil)
+	req.Header.Set("X-Custom", "value")
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if fake.opts[curl.OPT_CUSTOMREQUEST] != nil || fake.opts[curl.OPT_HTTPHEADER] != nil {
+		t.Errorf("Expected request options to be cleared, got method %v and headers %v", fake.opts[curl.OPT_CUSTOMREQUEST], fake.opts[curl.OPT_HTTPHEADER])
+	}
+
+	transport.ImpersonateTarget = "safari17_0"
+	req, _ = http.NewRequest("GET", "http://example.com", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if fake.resets != 1 || fake.target != "safari17_0" {
+		t.Errorf("Expected a full reset for the new target, got %d resets and target %s", fake.resets, fake.target)
+	}
+}
+
+// BenchmarkReturnCurlHandle compares clearing request options with a full
+// reset and reconfiguration on a real curl handle, where each option set is
+// a cgo call. It is skipped when libcurl is unavailable.
+func BenchmarkReturnCurlHandle(b *testing.B) {
+	if engine := newCurlEngine(); engine == nil {
+		b.Skip("libcurl is not available")
+	} else {
+		engine.Cleanup()
+	}
+	for _, bm := range []struct {
+		name  string
+		reuse bool
+	}{{"clear", true}, {"reset", false}} {
+		b.Run(bm.name, func(b *testing.B) {
+			transport := NewTransport()
+			defer transport.CloseIdleConnections()
+			ctx := context.Background()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				handle, err := transport.getCurlHandle(ctx)
+				if err != nil {
+					b.Fatalf("getCurlHandle failed: %v", err)
+				}
+				if !bm.reuse {
+					// A different target forces the full reset path
+					handle.(*pooledHandle).target = ""
+				}
+				transport.returnCurlHandle(handle)
+			}
+		})
+	}
+}
+
+// TestTransportClone tests that Clone copies configuration but not pool state
+func TestTransportClone(t *testing.T) {
+	fake := newFakeEngine("")
+	original := newFakeTransport(fake)
+	original.ImpersonateTarget = "safari17_0"
+	original.Proxy, _ = url.Parse("http://proxy.example.com:8080")
+	original.TimeoutMs = 1234
+	original.Use(func(next http.RoundTripper) http.RoundTripper { return next })
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if _, err := original.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+
+	clone := original.Clone()
+	if clone.ImpersonateTarget != "safari17_0" || clone.TimeoutMs != 1234 || clone.maxPoolSize != original.maxPoolSize {
+		t.Errorf("Expected configuration to be copied, got %+v", clone)
+	}
+	if clone.Proxy == original.Proxy || clone.Proxy.String() != original.Proxy.String() {
+		t.Error("Expected Proxy to be deep copied")
+	}
+	clone.Use(func(next http.RoundTripper) http.RoundTripper { return next })
+	if len(original.middleware) != 1 {
+		t.Errorf("Expected clone middleware not to affect the original, got %d", len(original.middleware))
+	}
+	if stats := clone.Stats(); stats.HandlesCreated != 0 || stats.IdleHandles != 0 {
+		t.Errorf("Expected clone to start with an empty pool, got %+v", stats)
+	}
+}
+
+// TestFakeEngineH2CPriorKnowledge tests the prior-knowledge option and Proto reporting
+func TestFakeEngineH2CPriorKnowledge(t *testing.T) {
+	fake := newFakeEngine("h2c")
+	fake.headers = []string{"HTTP/2 200\r\n", "Content-Type: text/plain\r\n", "\r\n"}
+	transport := newFakeTransport(fake)
+	transport.HttpVersion = HTTPVersion2PriorKnowledge
+
+	req, _ := http.NewRequest("GET", "http://internal.example/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if got := fake.performed[curl.OPT_HTTP_VERSION]; got != HTTPVersion2PriorKnowledge {
+		t.Errorf("Expected HTTP version option %d, got %v", HTTPVersion2PriorKnowledge, got)
+	}
+	if resp.Proto != "HTTP/2.0" || resp.ProtoMajor != 2 || resp.ProtoMinor != 0 {
+		t.Errorf("Expected HTTP/2.0, got %s (%d.%d)", resp.Proto, resp.ProtoMajor, resp.ProtoMinor)
+	}
+}
+
+// TestFakeEngineKeepAliveInterval tests how KeepAliveInterval configures TCP keep-alive
+func TestFakeEngineKeepAliveInterval(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		enabled  bool
+		seconds  int
+	}{
+		{0, true, 60},
+		{15 * time.Second, true, 15},
+		{100 * time.Millisecond, true, 1},
+		{-1, false, 0},
+	}
+	for _, tt := range tests {
+		fake := newFakeEngine("")
+		transport := newFakeTransport(fake)
+		transport.KeepAliveInterval = tt.interval
+
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		resp.Body.Close()
+
+		if got := fake.performed[curl.OPT_TCP_KEEPALIVE]; got != tt.enabled {
+			t.Errorf("KeepAliveInterval %v: expected keep-alive %v, got %v", tt.interval, tt.enabled, got)
+		}
+		if tt.enabled && fake.performed[curl.OPT_TCP_KEEPIDLE] != tt.seconds {
+			t.Errorf("KeepAliveInterval %v: expected %d seconds, got %v", tt.interval, tt.seconds, fake.performed[curl.OPT_TCP_KEEPIDLE])
+		}
+	}
+}
+
+// TestFakeEngineKeepAliveIdle tests that KeepAliveIdle only sets the first probe delay
+func TestFakeEngineKeepAliveIdle(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+	transport.KeepAliveIdle = 2 * time.Minute
+	transport.KeepAliveInterval = 10 * time.Second
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if got := fake.performed[curl.OPT_TCP_KEEPIDLE]; got != 120 {
+		t.Errorf("Expected keep-alive idle of 120 seconds, got %v", got)
+	}
+	if got := fake.performed[curl.OPT_TCP_KEEPINTVL]; got != 10 {
+		t.Errorf("Expected keep-alive interval of 10 seconds, got %v", got)
+	}
+}
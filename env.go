@@ -0,0 +1,142 @@
+package curlhttp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables that replace the built-in defaults of NewTransport,
+// NewClient and DefaultClient, so deployments can be reconfigured without
+// code changes. Settings made in code or by a Config take precedence.
+const (
+	// EnvTarget sets the impersonation target, such as "firefox"
+	EnvTarget = "CURLHTTP_TARGET"
+
+	// EnvProxy sets the proxy URL; a comma separated list fails over
+	// between proxies as Config.Proxies does
+	EnvProxy = "CURLHTTP_PROXY"
+
+	// EnvTimeoutMs sets the transfer timeout, and the client timeout of
+	// NewClient and DefaultClient, in milliseconds
+	EnvTimeoutMs = "CURLHTTP_TIMEOUT_MS"
+
+	// EnvConnectTimeoutMs sets the connect timeout in milliseconds
+	EnvConnectTimeoutMs = "CURLHTTP_CONNECT_TIMEOUT_MS"
+
+	// EnvPoolSize sets the number of pooled curl handles
+	EnvPoolSize = "CURLHTTP_POOL_SIZE"
+
+	// EnvMaxConnects sets the connection cache size of each handle
+	EnvMaxConnects = "CURLHTTP_MAX_CONNECTS"
+
+	// EnvHTTPVersion sets the HTTP version, as Config.HTTPVersion
+	EnvHTTPVersion = "CURLHTTP_HTTP_VERSION"
+
+	// EnvInsecure, when true, skips certificate verification of HTTPS
+	// proxies. Origin certificates are never verified by the Transport.
+	EnvInsecure = "CURLHTTP_INSECURE"
+)
+
+// EnvConfig returns the Config described by the CURLHTTP_ environment
+// variables. Invalid values are reported in the error and left out of
+// the Config; NewTransport ignores them and records the error for EnvErr,
+// so call EnvConfig or EnvErr at startup to catch mistakes.
+func EnvConfig() (*Config, error) {
+	cfg := &Config{}
+	var errs []error
+
+	envInt := func(name string, dst *int) {
+		raw := os.Getenv(name)
+		if raw == "" {
+			return
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || n <= 0 {
+			errs = append(errs, fmt.Errorf("%s: expected a positive integer, got %q", name, raw))
+			return
+		}
+		*dst = n
+	}
+	envMs := func(name string, dst *Duration) {
+		var ms int
+		envInt(name, &ms)
+		*dst = Duration(time.Duration(ms) * time.Millisecond)
+	}
+
+	cfg.Target = strings.TrimSpace(os.Getenv(EnvTarget))
+	envMs(EnvTimeoutMs, &cfg.Timeout)
+	envMs(EnvConnectTimeoutMs, &cfg.ConnectTimeout)
+	envInt(EnvPoolSize, &cfg.PoolSize)
+	envInt(EnvMaxConnects, &cfg.MaxConnects)
+
+	if raw := os.Getenv(EnvProxy); raw != "" {
+		for _, proxy := range strings.Split(raw, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				cfg.Proxies = append(cfg.Proxies, proxy)
+			}
+		}
+		if _, err := cfg.proxies(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", EnvProxy, err))
+			cfg.Proxies = nil
+		}
+	}
+	if raw := VersionString(strings.TrimSpace(os.Getenv(EnvHTTPVersion))); raw != "" {
+		if _, ok := configHTTPVersions[raw]; ok {
+			cfg.HTTPVersion = raw
+		} else {
+			errs = append(errs, fmt.Errorf("%s: unknown HTTP version %q", EnvHTTPVersion, raw))
+		}
+	}
+	if raw := os.Getenv(EnvInsecure); raw != "" {
+		insecure, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: expected a boolean, got %q", EnvInsecure, raw))
+		} else if insecure {
+			cfg.ProxyTLS = &ConfigTLS{InsecureSkipVerify: true}
+		}
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// envErr holds the error of the last environment read for EnvErr
+var envErr struct {
+	mu  sync.Mutex
+	err error
+}
+
+// EnvErr returns the error in the CURLHTTP_ environment variables found
+// the last time NewTransport, NewClient or DefaultClient read them, or nil
+// if they were valid. The invalid settings were ignored.
+func EnvErr() error {
+	envErr.mu.Lock()
+	defer envErr.mu.Unlock()
+	return envErr.err
+}
+
+// readEnv returns EnvConfig, recording its error for EnvErr
+func readEnv() *Config {
+	cfg, err := EnvConfig()
+	envErr.mu.Lock()
+	envErr.err = err
+	envErr.mu.Unlock()
+	return cfg
+}
+
+// applyEnv applies the valid CURLHTTP_ settings to a new Transport
+func applyEnv(t *Transport) {
+	readEnv().applyTo(t)
+}
+
+// envClientTimeout returns the client timeout of NewClient and DefaultClient
+func envClientTimeout() time.Duration {
+	cfg := readEnv()
+	if cfg.Timeout > 0 {
+		return time.Duration(cfg.Timeout)
+	}
+	return 30 * time.Second
+}
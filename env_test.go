@@ -0,0 +1,71 @@
+package curlhttp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestEnvOverrides tests that environment variables replace the defaults
+func TestEnvOverrides(t *testing.T) {
+	t.Setenv(EnvTarget, "firefox")
+	t.Setenv(EnvTimeoutMs, "12000")
+	t.Setenv(EnvPoolSize, "3")
+	t.Setenv(EnvHTTPVersion, "1.1")
+	t.Setenv(EnvInsecure, "true")
+	t.Setenv(EnvProxy, "http://proxy-a:3128, http://proxy-b:3128")
+
+	transport := NewTransport()
+	if transport.ImpersonateTarget != "firefox" || transport.TimeoutMs != 12000 || transport.maxPoolSize != 3 {
+		t.Errorf("Expected env settings, got %s, %d, %d", transport.ImpersonateTarget, transport.TimeoutMs, transport.maxPoolSize)
+	}
+	if transport.HttpVersion != HTTPVersion1_1 || transport.ProxyTLS == nil || !transport.ProxyTLS.InsecureSkipVerify {
+		t.Errorf("Expected HTTP/1.1 and insecure proxy TLS, got %d, %+v", transport.HttpVersion, transport.ProxyTLS)
+	}
+	if transport.Proxy == nil || transport.Proxy.Host != "proxy-a:3128" {
+		t.Errorf("Expected the first proxy, got %v", transport.Proxy)
+	}
+	if client := NewClient(); client.Timeout != 12*time.Second {
+		t.Errorf("Expected client timeout 12s, got %v", client.Timeout)
+	}
+
+	// Explicit settings win over the environment
+	if transport := NewTransportWithPoolSize(7); transport.maxPoolSize != 7 {
+		t.Errorf("Expected pool size 7, got %d", transport.maxPoolSize)
+	}
+}
+
+// TestEnvConfigInvalid tests that invalid values are reported and ignored
+func TestEnvConfigInvalid(t *testing.T) {
+	t.Setenv(EnvPoolSize, "many")
+	t.Setenv(EnvConnectTimeoutMs, "-1")
+	t.Setenv(EnvProxy, "http://user:secret@")
+	t.Setenv(EnvTimeoutMs, "2500")
+
+	cfg, err := EnvConfig()
+	if err == nil || !strings.Contains(err.Error(), EnvPoolSize) || !strings.Contains(err.Error(), EnvConnectTimeoutMs) {
+		t.Errorf("Expected errors naming the invalid variables, got %v", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "secret") {
+		t.Errorf("Expected proxy credentials to stay out of errors, got %v", err)
+	}
+	if time.Duration(cfg.Timeout) != 2500*time.Millisecond || cfg.PoolSize != 0 || cfg.Proxies != nil {
+		t.Errorf("Expected only the valid timeout, got %+v", cfg)
+	}
+
+	transport := NewTransport()
+	if transport.maxPoolSize != 10 || transport.ConnectTimeoutMs != 5000 || transport.Proxy != nil {
+		t.Errorf("Expected defaults for invalid values, got %d, %d, %v", transport.maxPoolSize, transport.ConnectTimeoutMs, transport.Proxy)
+	}
+	if err := EnvErr(); err == nil || !strings.Contains(err.Error(), EnvPoolSize) {
+		t.Errorf("Expected EnvErr to report the invalid pool size, got %v", err)
+	}
+
+	t.Setenv(EnvPoolSize, "4")
+	t.Setenv(EnvConnectTimeoutMs, "")
+	t.Setenv(EnvProxy, "")
+	NewTransport()
+	if err := EnvErr(); err != nil {
+		t.Errorf("Expected EnvErr to be cleared by a valid environment, got %v", err)
+	}
+}
@@ -8,5 +8,8 @@ require github.com/dstockton/go-curl-impersonate-net-http-wrapper v0.0.1
 
 require (
 	github.com/BridgeSenseDev/go-curl-impersonate v0.0.0-20250711173909-b592b23236d1 // indirect
+	golang.org/x/net v0.40.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
+	golang.org/x/text v0.25.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,171 @@
+package curlhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ProxyFailover sends requests through the first healthy proxy of a list
+// and fails over to the next when a proxy fails with a ProxyError or
+// answers 407 Proxy Authentication Required. Errors from the origin are
+// returned as they are. A proxy that failed is skipped for Backoff,
+// doubling with each consecutive failure up to MaxBackoff; when every
+// proxy is backing off, they are tried in the order they recover.
+// Install it with Transport.Use(f.Middleware()).
+//
+// Requests that choose their own proxy with WithProxy pass through
+// unchanged, and requests with a body only fail over if it can be
+// replayed through GetBody.
+type ProxyFailover struct {
+	// Proxies are tried in order. A nil entry connects directly.
+	Proxies []*url.URL
+
+	// Backoff is how long a failed proxy is skipped at first. Defaults to
+	// 30 seconds. MaxBackoff caps it; defaults to 10 minutes.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// OnFailover, if set, is called when proxy failed with err and the
+	// request moves on to the next proxy
+	OnFailover func(proxy *url.URL, err error)
+
+	// Budget, if set, must fund each failover; when it is spent the
+	// proxy's failure is returned to the caller
+	Budget *RetryBudget
+
+	// Clock times the backoff. Defaults to SystemClock.
+	Clock Clock
+
+	mu     sync.Mutex
+	health map[string]*proxyHealth // keyed by proxy URL, "" for direct
+}
+
+// proxyHealth tracks the failures of one proxy
+type proxyHealth struct {
+	failures int
+	until    time.Time // skipped until then
+}
+
+// proxyKeyString identifies a proxy in the health map
+func proxyKeyString(proxy *url.URL) string {
+	if proxy == nil {
+		return ""
+	}
+	return proxy.String()
+}
+
+// order returns the proxies to try at now: healthy ones in list order,
+// then those backing off by the time they recover
+func (f *ProxyFailover) order(now time.Time) []*url.URL {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var healthy, backingOff []*url.URL
+	for _, proxy := range f.Proxies {
+		if h := f.health[proxyKeyString(proxy)]; h != nil && now.Before(h.until) {
+			backingOff = append(backingOff, proxy)
+		} else {
+			healthy = append(healthy, proxy)
+		}
+	}
+	slices.SortStableFunc(backingOff, func(a, b *url.URL) int {
+		return f.health[proxyKeyString(a)].until.Compare(f.health[proxyKeyString(b)].until)
+	})
+	return append(healthy, backingOff...)
+}
+
+// failed records a failure of proxy at now
+func (f *ProxyFailover) failed(proxy *url.URL, now time.Time) {
+	backoff, maxBackoff := f.Backoff, f.MaxBackoff
+	if backoff <= 0 {
+		backoff = 30 * time.Second
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = 10 * time.Minute
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.health == nil {
+		f.health = make(map[string]*proxyHealth)
+	}
+	h := f.health[proxyKeyString(proxy)]
+	if h == nil {
+		h = &proxyHealth{}
+		f.health[proxyKeyString(proxy)] = h
+	}
+	for i := 0; i < h.failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	h.failures++
+	h.until = now.Add(min(backoff, maxBackoff))
+}
+
+// succeeded clears the failures of proxy
+func (f *ProxyFailover) succeeded(proxy *url.URL) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.health, proxyKeyString(proxy))
+}
+
+// Middleware returns middleware that spreads requests over Proxies and
+// fails over between them
+func (f *ProxyFailover) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if _, ok := req.Context().Value(proxyKey{}).(*url.URL); ok || len(f.Proxies) == 0 {
+				return next.RoundTrip(req)
+			}
+			clock := clockOrSystem(f.Clock)
+			ctx := req.Context()
+			proxies := f.order(clock.Now())
+			if !canReplayBody(req) {
+				proxies = proxies[:1]
+			}
+
+			for i := 0; ; i++ {
+				proxy := proxies[i]
+				attemptCtx := WithProxy(ctx, proxy)
+				if i > 0 {
+					attemptCtx = WithAttempt(attemptCtx, Attempt(ctx)+i)
+				}
+				attempt := req.Clone(attemptCtx)
+				if i > 0 && req.GetBody != nil {
+					var err error
+					if attempt.Body, err = req.GetBody(); err != nil {
+						return nil, err
+					}
+				}
+
+				resp, err := next.RoundTrip(attempt)
+				failure := err
+				var proxyErr *ProxyError
+				switch {
+				case err != nil && !errors.As(err, &proxyErr):
+					return nil, err
+				case err == nil && (proxy == nil || resp.StatusCode != http.StatusProxyAuthRequired):
+					f.succeeded(proxy)
+					return resp, nil
+				case err == nil:
+					failure = errors.New(resp.Status)
+				}
+
+				f.failed(proxy, clock.Now())
+				if i+1 >= len(proxies) || ctx.Err() != nil || !allowRetry(f.Budget, req) {
+					return resp, err
+				}
+				if resp != nil {
+					io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+					resp.Body.Close()
+				}
+				if f.OnFailover != nil {
+					f.OnFailover(proxy, failure)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package curlhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// proxyExits answers requests per proxy host: hosts in down fail at the
+// proxy, the rest answer 200 with the proxy host as body
+func proxyExits(down map[string]bool, used *[]string) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		proxy, _ := req.Context().Value(proxyKey{}).(*url.URL)
+		host := "direct"
+		if proxy != nil {
+			host = proxy.Host
+		}
+		*used = append(*used, host)
+		if req.Body != nil {
+			if body, _ := io.ReadAll(req.Body); string(body) != "payload" {
+				return nil, errors.New("request body not replayed")
+			}
+		}
+		if down[host] {
+			return nil, &ProxyError{Proxy: host, Err: errors.New("connection refused")}
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(host)), Header: make(http.Header)}, nil
+	})
+}
+
+// mustParseURLs parses proxy URLs for tests
+func mustParseURLs(raw ...string) []*url.URL {
+	urls := make([]*url.URL, len(raw))
+	for i, s := range raw {
+		urls[i], _ = url.Parse(s)
+	}
+	return urls
+}
+
+// TestProxyFailover tests failing over and backing off failed proxies
+func TestProxyFailover(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	down := map[string]bool{"a:1": true}
+	var used []string
+	var failovers []string
+	f := &ProxyFailover{
+		Proxies:    mustParseURLs("http://a:1", "http://b:1"),
+		Backoff:    time.Minute,
+		Clock:      clock,
+		OnFailover: func(proxy *url.URL, err error) { failovers = append(failovers, proxy.Host) },
+	}
+	rt := Chain(proxyExits(down, &used), f.Middleware())
+
+	send := func() string {
+		t.Helper()
+		req, _ := http.NewRequest("POST", "http://example.com/", strings.NewReader("payload"))
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	if got := send(); got != "b:1" || strings.Join(used, ",") != "a:1,b:1" || strings.Join(failovers, ",") != "a:1" {
+		t.Fatalf("Expected a failover from a to b, got %q via %v", got, used)
+	}
+
+	used = nil
+	if got := send(); got != "b:1" || len(used) != 1 {
+		t.Errorf("Expected the failed proxy to be skipped while backing off, got %q via %v", got, used)
+	}
+
+	// After the backoff a is tried again; it is still down, so it backs off twice as long
+	clock.Advance(time.Minute)
+	used = nil
+	send()
+	clock.Advance(time.Minute)
+	used = nil
+	send()
+	if strings.Join(used, ",") != "b:1" {
+		t.Errorf("Expected the backoff to double after another failure, got %v", used)
+	}
+
+	delete(down, "a:1")
+	clock.Advance(time.Minute)
+	used = nil
+	if got := send(); got != "a:1" {
+		t.Errorf("Expected the recovered proxy to be used again, got %q via %v", got, used)
+	}
+}
+
+// TestProxyFailoverPassThrough tests the requests that are not failed over
+func TestProxyFailoverPassThrough(t *testing.T) {
+	var used []string
+	f := &ProxyFailover{Proxies: mustParseURLs("http://a:1", "http://b:1")}
+	origin := errors.New("origin reset the connection")
+	rt := Chain(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		used = append(used, "x")
+		return nil, origin
+	}), f.Middleware())
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	if _, err := rt.RoundTrip(req); !errors.Is(err, origin) || len(used) != 1 {
+		t.Errorf("Expected origin errors to be returned without failover, got %v after %d attempts", err, len(used))
+	}
+
+	used = nil
+	allDown := Chain(proxyExits(map[string]bool{"a:1": true, "b:1": true}, &used), f.Middleware())
+	req, _ = http.NewRequest("POST", "http://example.com/", io.NopCloser(strings.NewReader("payload")))
+	var proxyErr *ProxyError
+	if _, err := allDown.RoundTrip(req); !errors.As(err, &proxyErr) || len(used) != 1 {
+		t.Errorf("Expected a body that cannot be replayed to be sent once, got %v after %v", err, used)
+	}
+
+	used = nil
+	pinned, _ := url.Parse("http://pinned:1")
+	req, _ = http.NewRequestWithContext(WithProxy(req.Context(), pinned), "GET", "http://example.com/", nil)
+	allDown.RoundTrip(req)
+	if strings.Join(used, ",") != "pinned:1" {
+		t.Errorf("Expected a request with its own proxy to pass through, got %v", used)
+	}
+}
+
+// TestProxyFailoverBudget tests that failovers stop once the retry budget is spent
+func TestProxyFailoverBudget(t *testing.T) {
+	var used []string
+	f := &ProxyFailover{
+		Proxies: mustParseURLs("http://a:1", "http://b:1", "http://c:1"),
+		Budget:  &RetryBudget{MinRetries: 1},
+	}
+	rt := Chain(proxyExits(map[string]bool{"a:1": true, "b:1": true}, &used), f.Middleware())
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	var proxyErr *ProxyError
+	if _, err := rt.RoundTrip(req); !errors.As(err, &proxyErr) || strings.Join(used, ",") != "a:1,b:1" {
+		t.Errorf("Expected the proxy error after a single funded failover, got %v via %v", err, used)
+	}
+}
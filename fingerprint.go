@@ -0,0 +1,262 @@
+package curlhttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultFingerprintEndpoint is the echo service VerifyFingerprint queries.
+// It must answer with the JSON format of https://tls.peet.ws/api/all, which
+// self-hosted deployments of the same service also produce.
+const DefaultFingerprintEndpoint = "https://tls.peet.ws/api/all"
+
+// ClientFingerprint is how an echo service saw a request from this package
+type ClientFingerprint struct {
+	HTTPVersion string   `json:"http_version,omitempty"`
+	UserAgent   string   `json:"user_agent,omitempty"`
+	JA3         string   `json:"ja3,omitempty"`
+	JA3Hash     string   `json:"ja3_hash,omitempty"`
+	JA4         string   `json:"ja4,omitempty"`
+	Akamai      string   `json:"akamai,omitempty"`      // HTTP/2 SETTINGS, WINDOW_UPDATE, PRIORITY and pseudo-header order
+	AkamaiHash  string   `json:"akamai_hash,omitempty"` // MD5 of Akamai
+	HeaderOrder []string `json:"header_order,omitempty"`
+}
+
+// ExpectedFingerprint holds the values a target should produce. Empty
+// fields are not compared. HeaderOrder lists lowercase header names in the
+// order the browser sends them; headers the request did not include and
+// extra headers are ignored, only the relative order is checked.
+//
+// JA3 is not compared by default since current browsers shuffle their TLS
+// extensions, which changes the JA3 hash on every connection.
+type ExpectedFingerprint struct {
+	JA3Hash     string   `json:"ja3_hash,omitempty"`
+	JA4         string   `json:"ja4,omitempty"`
+	AkamaiHash  string   `json:"akamai_hash,omitempty"`
+	HeaderOrder []string `json:"header_order,omitempty"`
+}
+
+// FingerprintDrift is one value that differs from the expectation
+type FingerprintDrift struct {
+	Field    string
+	Expected string
+	Observed string
+}
+
+func (d FingerprintDrift) String() string {
+	return fmt.Sprintf("%s: expected %q, observed %q", d.Field, d.Expected, d.Observed)
+}
+
+// FingerprintReport is the result of VerifyFingerprint
+type FingerprintReport struct {
+	Target   string
+	Observed ClientFingerprint
+	Expected ExpectedFingerprint
+	Drift    []FingerprintDrift
+}
+
+// OK reports whether the observed fingerprint matched every expectation
+func (r *FingerprintReport) OK() bool {
+	return len(r.Drift) == 0
+}
+
+var (
+	expectedFingerprintsMu sync.RWMutex
+	expectedFingerprints   = map[string]ExpectedFingerprint{}
+)
+
+// RegisterExpectedFingerprint records the values target is expected to
+// produce, typically captured from the real browser or from a known-good
+// release, for use by VerifyFingerprint
+func RegisterExpectedFingerprint(target string, expected ExpectedFingerprint) {
+	expectedFingerprintsMu.Lock()
+	defer expectedFingerprintsMu.Unlock()
+	expectedFingerprints[target] = expected
+}
+
+// expectedFingerprint returns the registered expectation for target
+func expectedFingerprint(target string) (ExpectedFingerprint, bool) {
+	expectedFingerprintsMu.RLock()
+	defer expectedFingerprintsMu.RUnlock()
+	expected, ok := expectedFingerprints[target]
+	return expected, ok
+}
+
+// FingerprintVerifier checks the fingerprint the Transport produces for a
+// target against its expected values
+type FingerprintVerifier struct {
+	// Endpoint is the echo service to query. Defaults to
+	// DefaultFingerprintEndpoint.
+	Endpoint string
+
+	// Transport is cloned for each check with ImpersonateTarget set to the
+	// target being verified. Defaults to NewTransport().
+	Transport *Transport
+
+	// Expected, if set, is used instead of the registered expectation.
+	Expected *ExpectedFingerprint
+}
+
+// VerifyFingerprint requests DefaultFingerprintEndpoint impersonating
+// target and compares the result with the values registered through
+// RegisterExpectedFingerprint. With nothing registered for target the
+// report only carries the observed fingerprint.
+func VerifyFingerprint(ctx context.Context, target string) (*FingerprintReport, error) {
+	return (&FingerprintVerifier{}).Verify(ctx, target)
+}
+
+// Verify requests the endpoint impersonating target and reports drift
+func (v *FingerprintVerifier) Verify(ctx context.Context, target string) (*FingerprintReport, error) {
+	observed, err := v.Observe(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &FingerprintReport{Target: target, Observed: *observed}
+	if v.Expected != nil {
+		report.Expected = *v.Expected
+	} else {
+		report.Expected, _ = expectedFingerprint(target)
+	}
+	report.Drift = compareFingerprint(report.Expected, report.Observed)
+	return report, nil
+}
+
+// Observe requests the endpoint impersonating target and returns the
+// fingerprint it reports
+func (v *FingerprintVerifier) Observe(ctx context.Context, target string) (*ClientFingerprint, error) {
+	endpoint := v.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultFingerprintEndpoint
+	}
+	transport := v.Transport
+	if transport == nil {
+		transport = NewTransport()
+	}
+	transport = transport.Clone()
+	transport.ImpersonateTarget = target
+	defer transport.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fingerprint request: %w", err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fingerprint endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fingerprint endpoint returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fingerprint response: %w", err)
+	}
+	return parseFingerprintEcho(data)
+}
+
+// fingerprintEcho is the subset of the tls.peet.ws response that is used
+type fingerprintEcho struct {
+	HTTPVersion string `json:"http_version"`
+	UserAgent   string `json:"user_agent"`
+	TLS         struct {
+		JA3     string `json:"ja3"`
+		JA3Hash string `json:"ja3_hash"`
+		JA4     string `json:"ja4"`
+	} `json:"tls"`
+	HTTP2 *struct {
+		Akamai     string `json:"akamai_fingerprint"`
+		AkamaiHash string `json:"akamai_fingerprint_hash"`
+		SentFrames []struct {
+			FrameType string   `json:"frame_type"`
+			Headers   []string `json:"headers"`
+		} `json:"sent_frames"`
+	} `json:"http2"`
+	HTTP1 *struct {
+		Headers []string `json:"headers"`
+	} `json:"http1"`
+}
+
+// parseFingerprintEcho decodes an echo service response
+func parseFingerprintEcho(data []byte) (*ClientFingerprint, error) {
+	var echo fingerprintEcho
+	if err := json.Unmarshal(data, &echo); err != nil {
+		return nil, fmt.Errorf("failed to decode fingerprint response: %w", err)
+	}
+
+	fp := &ClientFingerprint{
+		HTTPVersion: echo.HTTPVersion,
+		UserAgent:   echo.UserAgent,
+		JA3:         echo.TLS.JA3,
+		JA3Hash:     echo.TLS.JA3Hash,
+		JA4:         echo.TLS.JA4,
+	}
+	var headers []string
+	if echo.HTTP2 != nil {
+		fp.Akamai, fp.AkamaiHash = echo.HTTP2.Akamai, echo.HTTP2.AkamaiHash
+		for _, frame := range echo.HTTP2.SentFrames {
+			if frame.FrameType == "HEADERS" {
+				headers = frame.Headers
+				break
+			}
+		}
+	} else if echo.HTTP1 != nil {
+		headers = echo.HTTP1.Headers
+	}
+	for _, line := range headers {
+		// Lines look like "name: value"; pseudo-headers start with ':'
+		name, _, ok := strings.Cut(strings.TrimPrefix(line, ":"), ":")
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			name = ":" + name
+		}
+		fp.HeaderOrder = append(fp.HeaderOrder, strings.ToLower(strings.TrimSpace(name)))
+	}
+	return fp, nil
+}
+
+// compareFingerprint lists where observed differs from expected
+func compareFingerprint(expected ExpectedFingerprint, observed ClientFingerprint) []FingerprintDrift {
+	var drift []FingerprintDrift
+	check := func(field, want, got string) {
+		if want != "" && want != got {
+			drift = append(drift, FingerprintDrift{Field: field, Expected: want, Observed: got})
+		}
+	}
+	check("ja3_hash", expected.JA3Hash, observed.JA3Hash)
+	check("ja4", expected.JA4, observed.JA4)
+	check("akamai_hash", expected.AkamaiHash, observed.AkamaiHash)
+
+	if len(expected.HeaderOrder) > 0 {
+		want := make(map[string]bool, len(expected.HeaderOrder))
+		for _, name := range expected.HeaderOrder {
+			want[strings.ToLower(name)] = true
+		}
+		sent := make(map[string]bool, len(observed.HeaderOrder))
+		var got []string
+		for _, name := range observed.HeaderOrder {
+			sent[name] = true
+			if want[name] {
+				got = append(got, name)
+			}
+		}
+		var wantSent []string
+		for _, name := range expected.HeaderOrder {
+			if sent[strings.ToLower(name)] {
+				wantSent = append(wantSent, strings.ToLower(name))
+			}
+		}
+		if strings.Join(wantSent, ",") != strings.Join(got, ",") {
+			check("header_order", strings.Join(wantSent, ","), strings.Join(got, ","))
+		}
+	}
+	return drift
+}
@@ -0,0 +1,87 @@
+package curlhttp
+
+import (
+	"context"
+	"testing"
+)
+
+// fingerprintEchoBody is a trimmed tls.peet.ws response for an HTTP/2 request
+const fingerprintEchoBody = `{
+	"http_version": "h2",
+	"user_agent": "Mozilla/5.0",
+	"tls": {"ja3": "771,4865-4866,0-23,29,0", "ja3_hash": "aaa", "ja4": "t13d1516h2_8daaf6152771_02713d6af862"},
+	"http2": {
+		"akamai_fingerprint": "1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p",
+		"akamai_fingerprint_hash": "bbb",
+		"sent_frames": [
+			{"frame_type": "SETTINGS"},
+			{"frame_type": "HEADERS", "headers": [":method: GET", ":authority: tls.peet.ws", "sec-ch-ua: \"Chromium\"", "user-agent: Mozilla/5.0", "accept: */*"]}
+		]
+	}
+}`
+
+// TestParseFingerprintEcho tests decoding of an echo service response
+func TestParseFingerprintEcho(t *testing.T) {
+	fp, err := parseFingerprintEcho([]byte(fingerprintEchoBody))
+	if err != nil {
+		t.Fatalf("parseFingerprintEcho failed: %v", err)
+	}
+	if fp.JA4 != "t13d1516h2_8daaf6152771_02713d6af862" || fp.AkamaiHash != "bbb" || fp.HTTPVersion != "h2" {
+		t.Errorf("Unexpected fingerprint: %+v", fp)
+	}
+	want := []string{":method", ":authority", "sec-ch-ua", "user-agent", "accept"}
+	if len(fp.HeaderOrder) != len(want) {
+		t.Fatalf("Expected header order %v, got %v", want, fp.HeaderOrder)
+	}
+	for i := range want {
+		if fp.HeaderOrder[i] != want[i] {
+			t.Errorf("Expected header %d to be %s, got %s", i, want[i], fp.HeaderOrder[i])
+		}
+	}
+}
+
+// TestVerifyFingerprintDrift tests drift reporting against expected values
+func TestVerifyFingerprintDrift(t *testing.T) {
+	transport := newFakeTransport(newFakeEngine(fingerprintEchoBody))
+	verifier := &FingerprintVerifier{
+		Endpoint:  "https://echo.example/api/all",
+		Transport: transport,
+		Expected: &ExpectedFingerprint{
+			JA4:         "t13d1516h2_8daaf6152771_02713d6af862",
+			AkamaiHash:  "ccc",
+			HeaderOrder: []string{"user-agent", "sec-ch-ua", "accept", "accept-language"},
+		},
+	}
+
+	report, err := verifier.Verify(context.Background(), "chrome136")
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.OK() {
+		t.Fatal("Expected drift to be reported")
+	}
+	fields := map[string]bool{}
+	for _, d := range report.Drift {
+		fields[d.Field] = true
+	}
+	if !fields["akamai_hash"] || !fields["header_order"] || fields["ja4"] {
+		t.Errorf("Expected akamai_hash and header_order drift only, got %v", report.Drift)
+	}
+}
+
+// TestVerifyFingerprintRegistered tests that registered expectations are used
+func TestVerifyFingerprintRegistered(t *testing.T) {
+	RegisterExpectedFingerprint("test_target", ExpectedFingerprint{
+		AkamaiHash:  "bbb",
+		HeaderOrder: []string{":method", ":authority", "user-agent"},
+	})
+	verifier := &FingerprintVerifier{Transport: newFakeTransport(newFakeEngine(fingerprintEchoBody))}
+
+	report, err := verifier.Verify(context.Background(), "test_target")
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("Expected registered fingerprint to match, got %v", report.Drift)
+	}
+}
@@ -0,0 +1,53 @@
+package curlhttp
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// FormField is one name and value of an OrderedForm
+type FormField struct {
+	Name, Value string
+}
+
+// OrderedForm is a URL-encoded form whose fields are sent in slice order.
+// url.Values is a map, so PostForm sends its fields sorted by name, which
+// form handlers checking field order against the page's markup can tell
+// apart from a browser submission.
+type OrderedForm []FormField
+
+// Add appends a field
+func (f *OrderedForm) Add(name, value string) {
+	*f = append(*f, FormField{name, value})
+}
+
+// Encode returns the form as an application/x-www-form-urlencoded body,
+// escaped as browsers escape form submissions
+func (f OrderedForm) Encode() string {
+	parts := make([]string, len(f))
+	for i, field := range f {
+		parts[i] = escapeQuery(field.Name, QueryForm) + "=" + escapeQuery(field.Value, QueryForm)
+	}
+	return strings.Join(parts, "&")
+}
+
+// reader returns the encoded form as a request body
+func (f OrderedForm) reader() io.Reader {
+	return strings.NewReader(f.Encode())
+}
+
+// PostFormOrdered posts form to url with its fields in order. Ensures the
+// client is initialized if needed for zero-value compatibility.
+func (c *Client) PostFormOrdered(url string, form OrderedForm) (*Response, error) {
+	c.ensureInitialized()
+	if c.scope != nil {
+		return c.scopedRequest(http.MethodPost, url, "application/x-www-form-urlencoded", form.reader())
+	}
+	return c.Client.Post(url, "application/x-www-form-urlencoded", form.reader())
+}
+
+// PostFormOrdered posts an ordered form using the default client
+func PostFormOrdered(url string, form OrderedForm) (*Response, error) {
+	return DefaultClient.PostFormOrdered(url, form)
+}
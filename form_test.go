@@ -0,0 +1,46 @@
+package curlhttp
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+// TestOrderedFormEncode tests that fields keep their order and browser escaping
+func TestOrderedFormEncode(t *testing.T) {
+	var form OrderedForm
+	form.Add("username", "jo doe")
+	form.Add("csrf", "a~b*c")
+	form.Add("action", "log in")
+
+	want := "username=jo+doe&csrf=a%7Eb*c&action=log+in"
+	if got := form.Encode(); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+// TestClientPostFormOrdered tests the body and Content-Type of ordered form posts
+func TestClientPostFormOrdered(t *testing.T) {
+	var contentType, body string
+	client := &Client{Client: http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		contentType = req.Header.Get("Content-Type")
+		data, _ := io.ReadAll(req.Body)
+		body = string(data)
+		return jsonResponse(200, "application/json", `{}`), nil
+	})}}
+
+	form := OrderedForm{{"z", "1"}, {"a", "2"}, {"m", "3"}}
+	for _, c := range []*Client{client, client.WithHeader("X-Test", "1")} {
+		resp, err := c.PostFormOrdered("https://example.com/login", form)
+		if err != nil {
+			t.Fatalf("PostFormOrdered failed: %v", err)
+		}
+		resp.Body.Close()
+		if body != "z=1&a=2&m=3" {
+			t.Errorf("Expected fields in order, got %s", body)
+		}
+		if contentType != "application/x-www-form-urlencoded" {
+			t.Errorf("Expected form Content-Type, got %s", contentType)
+		}
+	}
+}
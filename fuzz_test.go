@@ -0,0 +1,122 @@
+package curlhttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Fuzz targets for the response parsing path. Run one with e.g.
+//
+//	go test -run=^$ -fuzz=FuzzResponseAssembly -fuzztime=60s
+//
+// Every target exercises only code that runs on data already copied out of
+// curl, so malformed server output can at worst produce notes or an error,
+// never a panic or a change to pooled handle configuration.
+
+// feedHeaderLines splits data into lines the way curl's header callback delivers them
+func feedHeaderLines(p *headerParser, data string) {
+	for _, line := range strings.SplitAfter(data, "\n") {
+		if line != "" {
+			writeHeaderToParser([]byte(line), p)
+		}
+	}
+}
+
+// FuzzResponseAssembly fuzzes header parsing plus response construction
+func FuzzResponseAssembly(f *testing.F) {
+	f.Add(200, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\n", []byte("hello"))
+	f.Add(302, "HTTP/1.1 302 Found\r\n\r\n", []byte{})
+	f.Add(0, "garbage\r\n \r\n\tfold\r\n", []byte{0xff, 0x00})
+	f.Add(999, "HTTP/2 999\r\nLocation:\r\nSet-Cookie: =;;\r\n", []byte(nil))
+
+	f.Fuzz(func(t *testing.T, code int, headerData string, body []byte) {
+		p := newHeaderParser()
+		feedHeaderLines(p, headerData)
+
+		resp := buildResponse(code, p, newResponseBody(body), int64(len(body)))
+		if resp.StatusCode != code {
+			t.Fatalf("status code changed from %d to %d", code, resp.StatusCode)
+		}
+		if resp.ContentLength != int64(len(body)) {
+			t.Fatalf("content length %d does not match body length %d", resp.ContentLength, len(body))
+		}
+
+		got, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read body: %v", err)
+		}
+		if !bytes.Equal(got, body) {
+			t.Fatal("body was modified during response assembly")
+		}
+		resp.Body.Close()
+
+		// Inspecting the response must be safe regardless of content
+		_ = Notes(resp)
+		_ = resp.Cookies()
+		_, _ = resp.Location()
+	})
+}
+
+// FuzzSetCookie fuzzes cookie extraction from parsed Set-Cookie headers
+func FuzzSetCookie(f *testing.F) {
+	f.Add("session=abc; Path=/; HttpOnly")
+	f.Add("a=b; Expires=Wed, 21 Oct 2015 07:28:00 GMT; Max-Age=-1")
+	f.Add("=; ; ;Domain=..; SameSite=\xff")
+
+	f.Fuzz(func(t *testing.T, value string) {
+		p := newHeaderParser()
+		writeHeaderToParser([]byte("HTTP/1.1 200 OK\r\n"), p)
+		writeHeaderToParser([]byte("Set-Cookie: "+value+"\r\n"), p)
+
+		resp := &http.Response{Header: p.header}
+		for _, cookie := range resp.Cookies() {
+			// Re-serializing a parsed cookie must not panic
+			_ = cookie.String()
+		}
+	})
+}
+
+// FuzzBodyFraming fuzzes body delivery split into arbitrary callback chunks
+func FuzzBodyFraming(f *testing.F) {
+	f.Add([]byte("hello world"), uint8(3))
+	f.Add([]byte{}, uint8(0))
+	f.Add(bytes.Repeat([]byte{0xff}, 100), uint8(7))
+
+	f.Fuzz(func(t *testing.T, data []byte, chunkSize uint8) {
+		buf := &responseBuffer{buffer: new(bytes.Buffer)}
+		size := int(chunkSize) + 1
+		for start := 0; start < len(data); start += size {
+			end := start + size
+			if end > len(data) {
+				end = len(data)
+			}
+			if !writeDataToBuffer(data[start:end], buf) {
+				t.Fatal("write callback rejected a chunk")
+			}
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Fatal("reassembled body does not match input")
+		}
+	})
+}
+
+// FuzzCallbackUserdata checks that callbacks reject mismatched userdata
+// instead of panicking inside the cgo trampoline
+func FuzzCallbackUserdata(f *testing.F) {
+	f.Add([]byte("X-Test: value\r\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if writeHeaderToParser(data, &responseBuffer{buffer: new(bytes.Buffer)}) {
+			t.Fatal("header callback accepted wrong userdata")
+		}
+		if writeDataToBuffer(data, newHeaderParser()) {
+			t.Fatal("write callback accepted wrong userdata")
+		}
+		if writeHeaderToParser(data, nil) || writeDataToBuffer(data, nil) {
+			t.Fatal("callbacks accepted nil userdata")
+		}
+	})
+}
@@ -4,6 +4,10 @@ go 1.24.2
 
 toolchain go1.24.4
 
-require github.com/BridgeSenseDev/go-curl-impersonate v0.0.0-20250711173909-b592b23236d1
-
-require golang.org/x/sys v0.33.0 // indirect
+require (
+	github.com/BridgeSenseDev/go-curl-impersonate v0.0.0-20250711173909-b592b23236d1
+	golang.org/x/net v0.40.0
+	golang.org/x/sys v0.33.0
+	golang.org/x/text v0.25.0
+	gopkg.in/yaml.v3 v3.0.1
+)
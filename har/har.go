@@ -0,0 +1,463 @@
+// Package har records traffic made through curlhttp in HTTP Archive (HAR)
+// 1.2 format, so it can be loaded into Chrome DevTools or other HAR viewers
+// and compared side by side with a capture from a real browser.
+//
+// The Recorder is installed as middleware on a curlhttp.Transport so every
+// hop of a redirect chain becomes its own entry:
+//
+//	rec := har.NewRecorder()
+//	transport := curlhttp.NewTransport()
+//	transport.Use(rec.Middleware())
+//
+//	// ... make requests ...
+//
+//	rec.WriteFile("session.har")
+package har
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	curlhttp "github.com/dstockton/go-curl-impersonate-net-http-wrapper"
+)
+
+// DefaultMaxBodySize is the default limit on body bytes stored per entry
+const DefaultMaxBodySize = 1 << 20
+
+// HAR is the top-level HAR document
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+// Log is the root HAR object
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Pages   []Page  `json:"pages,omitempty"`
+	Entries []Entry `json:"entries"`
+}
+
+// Creator identifies the application that produced the HAR
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Page groups entries; the Recorder does not create pages but keeps any
+// present in files loaded with Load
+type Page struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	ID              string    `json:"id"`
+	Title           string    `json:"title"`
+}
+
+// Entry is a single request/response exchange
+type Entry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Time            float64   `json:"time"`
+	Request         Request   `json:"request"`
+	Response        Response  `json:"response"`
+	Cache           struct{}  `json:"cache"`
+	Timings         Timings   `json:"timings"`
+	ServerIPAddress string    `json:"serverIPAddress,omitempty"`
+	Comment         string    `json:"comment,omitempty"`
+}
+
+// NameValue is a header, query parameter or cookie
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Request describes the request of an entry
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int64       `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+// PostData describes a request body
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	// Encoding is "base64" when Text holds a binary body. HAR 1.2 only
+	// defines it for response content, so it is stored as a custom field.
+	Encoding string `json:"_encoding,omitempty"`
+}
+
+// Body returns the request body, decoding Text according to Encoding
+func (p *PostData) Body() ([]byte, error) {
+	switch p.Encoding {
+	case "":
+		return []byte(p.Text), nil
+	case "base64":
+		body, err := base64.StdEncoding.DecodeString(p.Text)
+		if err != nil {
+			return nil, fmt.Errorf("har: failed to decode post data: %w", err)
+		}
+		return body, nil
+	}
+	return nil, fmt.Errorf("har: unsupported post data encoding %q", p.Encoding)
+}
+
+// Response describes the response of an entry
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int64       `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+// Content describes a response body
+type Content struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// Timings are the phases of an entry in milliseconds; -1 means not applicable
+type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// Recorder collects HAR entries from traffic passing through its middleware
+type Recorder struct {
+	// MaxBodySize limits how many response body bytes are stored per
+	// entry. Larger bodies are truncated and marked with a comment.
+	// Defaults to DefaultMaxBodySize; a negative value stores no bodies.
+	MaxBodySize int
+
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewRecorder creates an empty HAR recorder
+func NewRecorder() *Recorder {
+	return &Recorder{MaxBodySize: DefaultMaxBodySize}
+}
+
+// Middleware returns middleware that records every exchange
+func (r *Recorder) Middleware() curlhttp.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return curlhttp.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return r.roundTrip(next, req)
+		})
+	}
+}
+
+// Entries returns a copy of the recorded entries
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Entry(nil), r.entries...)
+}
+
+// Reset discards all recorded entries
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+}
+
+// HAR returns the recorded traffic as a HAR document
+func (r *Recorder) HAR() *HAR {
+	return &HAR{Log: Log{
+		Version: "1.2",
+		Creator: Creator{Name: "curlhttp", Version: "1.0"},
+		Entries: r.Entries(),
+	}}
+}
+
+// WriteTo writes the HAR document as JSON to w
+func (r *Recorder) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode HAR: %w", err)
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// WriteFile writes the HAR document to path
+func (r *Recorder) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := r.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads a HAR document from path
+func Load(path string) (*HAR, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var h HAR
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("failed to parse HAR %s: %w", path, err)
+	}
+	return &h, nil
+}
+
+// roundTrip records a single exchange
+func (r *Recorder) roundTrip(next http.RoundTripper, req *http.Request) (*http.Response, error) {
+	started := time.Now()
+
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("har: failed to read request body: %w", err)
+		}
+		reqBody = data
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(data))
+	}
+
+	resp, err := next.RoundTrip(req)
+	elapsed := time.Since(started)
+	if err != nil {
+		r.add(Entry{
+			StartedDateTime: started,
+			Time:            millis(elapsed),
+			Request:         r.request(req, reqBody),
+			Response:        Response{Status: 0, Headers: []NameValue{}, Cookies: []NameValue{}, HeadersSize: -1, BodySize: -1},
+			Timings:         Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Send: 0, Wait: millis(elapsed), Receive: 0},
+			Comment:         err.Error(),
+		})
+		return nil, err
+	}
+
+	content, err := r.content(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	entry := Entry{
+		StartedDateTime: started,
+		Time:            millis(elapsed),
+		Request:         r.request(req, reqBody),
+		Response: Response{
+			Status:      resp.StatusCode,
+			StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode))),
+			HTTPVersion: protoOf(resp.Proto),
+			Cookies:     responseCookies(resp),
+			Headers:     headerList(resp.Header),
+			Content:     content,
+			RedirectURL: resp.Header.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    content.Size,
+		},
+		Timings: Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Send: 0, Wait: millis(elapsed), Receive: 0},
+	}
+	if t, ok := curlhttp.ResponseTimings(resp); ok {
+		entry.Timings = harTimings(t)
+		entry.Time = millis(t.Total)
+	}
+	r.add(entry)
+	return resp, nil
+}
+
+// add appends an entry under the lock
+func (r *Recorder) add(entry Entry) {
+	r.mu.Lock()
+	r.entries = append(r.entries, entry)
+	r.mu.Unlock()
+}
+
+// request converts an outgoing request to its HAR form
+func (r *Recorder) request(req *http.Request, body []byte) Request {
+	out := Request{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: protoOf(req.Proto),
+		Cookies:     []NameValue{},
+		Headers:     headerList(req.Header),
+		QueryString: []NameValue{},
+		HeadersSize: -1,
+		BodySize:    int64(len(body)),
+	}
+	for _, c := range req.Cookies() {
+		out.Cookies = append(out.Cookies, NameValue{Name: c.Name, Value: c.Value})
+	}
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range query[k] {
+			out.QueryString = append(out.QueryString, NameValue{Name: k, Value: v})
+		}
+	}
+	if body != nil {
+		text, encoding := encode(body)
+		out.PostData = &PostData{MimeType: req.Header.Get("Content-Type"), Text: text, Encoding: encoding}
+	}
+	return out
+}
+
+// content captures the response body without consuming it for the caller
+func (r *Recorder) content(resp *http.Response) (Content, error) {
+	content := Content{
+		Size:     resp.ContentLength,
+		MimeType: resp.Header.Get("Content-Type"),
+	}
+	if r.MaxBodySize < 0 || resp.Body == nil {
+		return content, nil
+	}
+	limit := r.MaxBodySize
+	if limit == 0 {
+		limit = DefaultMaxBodySize
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	if err != nil {
+		return content, fmt.Errorf("har: failed to read response body: %w", err)
+	}
+
+	// Bodies from curlhttp are seekable, so rewinding keeps the original
+	// Body (and the metadata it carries) in place. Other bodies are rebuilt.
+	if seeker, ok := resp.Body.(io.Seeker); ok {
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return content, fmt.Errorf("har: failed to rewind response body: %w", err)
+		}
+	} else {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+	}
+
+	if len(data) > limit {
+		data = data[:limit]
+		content.Comment = fmt.Sprintf("truncated to %d bytes", limit)
+	}
+	if content.Size < 0 {
+		content.Size = int64(len(data))
+	}
+	content.Text, content.Encoding = encode(data)
+	return content, nil
+}
+
+// encode returns body as text, base64 encoding it when it is not valid UTF-8
+func encode(body []byte) (text, encoding string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// headerList flattens headers into sorted name/value pairs
+func headerList(h http.Header) []NameValue {
+	list := []NameValue{}
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			list = append(list, NameValue{Name: k, Value: v})
+		}
+	}
+	return list
+}
+
+// responseCookies lists cookies set by the response
+func responseCookies(resp *http.Response) []NameValue {
+	list := []NameValue{}
+	for _, c := range resp.Cookies() {
+		list = append(list, NameValue{Name: c.Name, Value: c.Value})
+	}
+	return list
+}
+
+// protoOf returns proto or HTTP/1.1 when unset
+func protoOf(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+	return proto
+}
+
+// harTimings converts cumulative curl timings into HAR phase durations
+func harTimings(t curlhttp.Timings) Timings {
+	out := Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	if t.NameLookup > 0 {
+		out.DNS = millis(t.NameLookup)
+	}
+	if t.Connect > 0 {
+		// HAR's connect includes the TLS handshake
+		end := t.Connect
+		if t.AppConnect > 0 {
+			end = t.AppConnect
+			out.SSL = millis(t.AppConnect - t.Connect)
+		}
+		out.Connect = millis(end - t.NameLookup)
+	}
+	ready := t.PreTransfer
+	if ready == 0 {
+		ready = maxDuration(t.AppConnect, t.Connect)
+	}
+	out.Send = 0
+	out.Wait = millis(t.StartTransfer - ready)
+	out.Receive = millis(t.Total - t.StartTransfer)
+	if out.Wait < 0 {
+		out.Wait = 0
+	}
+	if out.Receive < 0 {
+		out.Receive = 0
+	}
+	return out
+}
+
+// maxDuration returns the larger of a and b
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// millis converts a duration to fractional milliseconds
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,113 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	curlhttp "github.com/dstockton/go-curl-impersonate-net-http-wrapper"
+)
+
+// TestRecorderRedirectChain tests that each redirect hop becomes an entry
+func TestRecorderRedirectChain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/start" {
+			http.Redirect(w, r, "/end?x=1", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("arrived"))
+	}))
+	defer server.Close()
+
+	rec := NewRecorder()
+	client := &http.Client{Transport: curlhttp.Chain(http.DefaultTransport, rec.Middleware())}
+
+	resp, err := client.Get(server.URL + "/start")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "arrived" {
+		t.Errorf("Recorder must not consume the body, got %q", body)
+	}
+
+	entries := rec.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Response.Status != http.StatusFound || entries[0].Response.RedirectURL != "/end?x=1" {
+		t.Errorf("Unexpected first entry response: %+v", entries[0].Response)
+	}
+	if entries[1].Response.Content.Text != "arrived" {
+		t.Errorf("Expected captured content, got %q", entries[1].Response.Content.Text)
+	}
+	if len(entries[1].Request.QueryString) != 1 || entries[1].Request.QueryString[0].Name != "x" {
+		t.Errorf("Expected query string to be recorded, got %+v", entries[1].Request.QueryString)
+	}
+}
+
+// TestRecorderPostDataAndOutput tests request bodies and the JSON document
+func TestRecorderPostDataAndOutput(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = string(data)
+		w.Write([]byte{0xff, 0xfe})
+	}))
+	defer server.Close()
+
+	rec := NewRecorder()
+	client := &http.Client{Transport: curlhttp.Chain(http.DefaultTransport, rec.Middleware())}
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"a":1}`))
+	if err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if received != `{"a":1}` {
+		t.Errorf("Server should still receive the body, got %q", received)
+	}
+
+	var buf bytes.Buffer
+	if _, err := rec.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	var doc HAR
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+	if doc.Log.Version != "1.2" || len(doc.Log.Entries) != 1 {
+		t.Fatalf("Unexpected HAR document: %+v", doc.Log)
+	}
+	entry := doc.Log.Entries[0]
+	if entry.Request.PostData == nil || entry.Request.PostData.Text != `{"a":1}` {
+		t.Errorf("Expected post data to be recorded, got %+v", entry.Request.PostData)
+	}
+	if entry.Response.Content.Encoding != "base64" {
+		t.Errorf("Expected binary content to be base64 encoded, got %q", entry.Response.Content.Encoding)
+	}
+}
+
+// TestHarTimings tests conversion of cumulative curl timings
+func TestHarTimings(t *testing.T) {
+	got := harTimings(curlhttp.Timings{
+		NameLookup:    10 * time.Millisecond,
+		Connect:       30 * time.Millisecond,
+		AppConnect:    70 * time.Millisecond,
+		PreTransfer:   71 * time.Millisecond,
+		StartTransfer: 171 * time.Millisecond,
+		Total:         200 * time.Millisecond,
+	})
+
+	want := Timings{Blocked: -1, DNS: 10, Connect: 60, SSL: 40, Send: 0, Wait: 100, Receive: 29}
+	if got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,138 @@
+package har
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	curlhttp "github.com/dstockton/go-curl-impersonate-net-http-wrapper"
+)
+
+// skippedHeaders are never replayed: they describe the original connection
+// or are recomputed by the transport. Accept-Encoding is left to the
+// impersonation profile so bodies are decoded consistently.
+var skippedHeaders = map[string]bool{
+	"host":              true,
+	"content-length":    true,
+	"connection":        true,
+	"keep-alive":        true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"accept-encoding":   true,
+}
+
+// Result is the outcome of replaying one entry
+type Result struct {
+	Entry      *Entry
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Err        error
+}
+
+// Replayer re-issues the requests described in a HAR document
+type Replayer struct {
+	// Client performs the requests. Defaults to a curlhttp client that does
+	// not follow redirects, since a browser HAR already lists every hop.
+	Client *http.Client
+
+	// RewriteHosts maps recorded hosts (host or host:port) to the hosts
+	// requests should be sent to, e.g. {"www.example.com": "staging.example.com"}.
+	RewriteHosts map[string]string
+
+	// Filter, if set, selects which entries to replay.
+	Filter func(*Entry) bool
+
+	// StopOnError aborts the replay at the first failed request.
+	StopOnError bool
+}
+
+// Replay issues every selected entry in order and returns one Result per
+// replayed entry. It only returns an error if ctx is cancelled or, with
+// StopOnError set, a request fails.
+func (r *Replayer) Replay(ctx context.Context, h *HAR) ([]Result, error) {
+	client := r.Client
+	if client == nil {
+		client = &http.Client{
+			Transport: curlhttp.NewTransport(),
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+
+	var results []Result
+	for i := range h.Log.Entries {
+		entry := &h.Log.Entries[i]
+		if r.Filter != nil && !r.Filter(entry) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		result := Result{Entry: entry}
+		req, err := r.NewRequest(ctx, entry)
+		if err == nil {
+			var resp *http.Response
+			if resp, err = client.Do(req); err == nil {
+				result.StatusCode = resp.StatusCode
+				result.Header = resp.Header
+				result.Body, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+		}
+		result.Err = err
+		results = append(results, result)
+
+		if err != nil && r.StopOnError {
+			return results, fmt.Errorf("har: replaying %s %s: %w", entry.Request.Method, entry.Request.URL, err)
+		}
+	}
+	return results, nil
+}
+
+// NewRequest builds the request for an entry, applying host rewriting
+func (r *Replayer) NewRequest(ctx context.Context, entry *Entry) (*http.Request, error) {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("har: invalid URL %q: %w", entry.Request.URL, err)
+	}
+	if target, ok := r.RewriteHosts[u.Host]; ok {
+		u.Host = target
+	} else if target, ok := r.RewriteHosts[u.Hostname()]; ok {
+		if port := u.Port(); port != "" && !strings.Contains(target, ":") {
+			target += ":" + port
+		}
+		u.Host = target
+	}
+
+	var body io.Reader
+	if entry.Request.PostData != nil {
+		data, err := entry.Request.PostData.Body()
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, entry.Request.Method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range entry.Request.Headers {
+		name := strings.ToLower(h.Name)
+		if strings.HasPrefix(name, ":") || skippedHeaders[name] {
+			continue
+		}
+		req.Header.Add(h.Name, h.Value)
+	}
+	if entry.Request.PostData != nil && entry.Request.PostData.MimeType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", entry.Request.PostData.MimeType)
+	}
+	return req, nil
+}
@@ -0,0 +1,118 @@
+package har
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	curlhttp "github.com/dstockton/go-curl-impersonate-net-http-wrapper"
+)
+
+// TestReplayerRewritesHosts tests replaying entries against a rewritten host
+func TestReplayerRewritesHosts(t *testing.T) {
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		seen = append(seen, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("X-Session")+" "+string(body))
+		if r.URL.Path == "/login" {
+			http.Redirect(w, r, "/home", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	doc := &HAR{Log: Log{Entries: []Entry{
+		{Request: Request{
+			Method:   "POST",
+			URL:      "https://www.example.com/login",
+			Headers:  []NameValue{{Name: ":authority", Value: "www.example.com"}, {Name: "X-Session", Value: "s1"}, {Name: "Content-Length", Value: "3"}},
+			PostData: &PostData{MimeType: "text/plain", Text: "u=1"},
+		}},
+		{Request: Request{
+			Method:  "GET",
+			URL:     "https://www.example.com/home",
+			Headers: []NameValue{{Name: "X-Session", Value: "s1"}},
+		}},
+		{Request: Request{Method: "GET", URL: "https://cdn.example.com/app.js"}},
+	}}}
+
+	replayer := &Replayer{
+		Client: &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}},
+		RewriteHosts: map[string]string{"www.example.com": serverURL.Host},
+		Filter:       func(e *Entry) bool { return e.Request.URL != "https://cdn.example.com/app.js" },
+	}
+	// httptest serves plain HTTP; rewrite the scheme through a custom transport
+	replayer.Client.Transport = curlhttp.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme = "http"
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	results, err := replayer.Replay(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].StatusCode != http.StatusFound || results[1].StatusCode != http.StatusOK {
+		t.Errorf("Unexpected statuses %d, %d", results[0].StatusCode, results[1].StatusCode)
+	}
+
+	want := []string{"POST /login s1 u=1", "GET /home s1 "}
+	if len(seen) != len(want) {
+		t.Fatalf("Expected requests %v, got %v", want, seen)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Errorf("Request %d: expected %q, got %q", i, want[i], seen[i])
+		}
+	}
+}
+
+// TestReplayBinaryPostData tests that a recorded non-UTF-8 body is replayed
+// byte for byte
+func TestReplayBinaryPostData(t *testing.T) {
+	payload := []byte{0xff, 0x00, 0xfe, 'a', 0x80}
+	var received [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, body)
+	}))
+	defer server.Close()
+
+	rec := NewRecorder()
+	client := &http.Client{Transport: curlhttp.Chain(http.DefaultTransport, rec.Middleware())}
+	resp, err := client.Post(server.URL+"/upload", "application/octet-stream", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	resp.Body.Close()
+
+	var buf bytes.Buffer
+	if _, err := rec.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	var doc HAR
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+	if postData := doc.Log.Entries[0].Request.PostData; postData == nil || postData.Encoding != "base64" {
+		t.Fatalf("Expected base64 post data, got %+v", postData)
+	}
+
+	if _, err := (&Replayer{Client: &http.Client{}}).Replay(context.Background(), &doc); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(received) != 2 || !bytes.Equal(received[1], payload) {
+		t.Errorf("Expected replayed body %x, got %x", payload, received)
+	}
+}
@@ -0,0 +1,192 @@
+package curlhttp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// headerParser accumulates response header lines delivered one at a time by
+// curl's header callback. It handles RFC 7230 obsolete line folding and keeps
+// header bytes as received, including invalid UTF-8, rather than dropping them.
+type headerParser struct {
+	header  http.Header
+	trailer http.Header
+	lastKey string
+	notes   []ResponseNote
+	raw     []byte // the current header block as received
+
+	// status and proto come from the current block's status line; complete is
+	// set once the final (non-1xx) block has ended, after which header
+	// lines are trailers. onComplete, if set, is called at that point.
+	status     int
+	proto      string
+	complete   bool
+	onComplete func()
+
+	// onInformational, if set, is called at the end of each 1xx block,
+	// such as 103 Early Hints; an error from it aborts the transfer and
+	// is kept in err
+	onInformational func(code int, header http.Header) error
+
+	// onStart, if set, is called before the first line is parsed; an error
+	// from it aborts the transfer and is kept in err
+	onStart func() error
+	started bool
+	err     error
+}
+
+// newHeaderParser creates a parser that fills a fresh header map
+func newHeaderParser() *headerParser {
+	return &headerParser{header: make(http.Header)}
+}
+
+// parseLine consumes a single raw header line
+func (p *headerParser) parseLine(data []byte) {
+	line := strings.TrimRight(string(data), "\r\n")
+	if strings.HasPrefix(line, "HTTP/") {
+		p.raw = nil
+	}
+	if !p.complete || strings.HasPrefix(line, "HTTP/") {
+		p.raw = append(p.raw, data...)
+	}
+	if trimOWS(line) == "" {
+		// End of a header block
+		p.lastKey = ""
+		if !p.complete && p.status >= 100 && p.status < 200 && p.onInformational != nil && p.err == nil {
+			p.err = p.onInformational(p.status, p.header)
+		}
+		if !p.complete && (p.status < 100 || p.status >= 200) {
+			p.complete = true
+			if p.onComplete != nil {
+				p.onComplete()
+			}
+		}
+		return
+	}
+
+	// A new status line starts a new header block (e.g. after a
+	// 100 Continue or a proxy CONNECT response); only the final
+	// response's headers are kept.
+	if strings.HasPrefix(line, "HTTP/") {
+		p.header = make(http.Header)
+		p.trailer = nil
+		p.lastKey = ""
+		p.complete = false
+		p.status = 0
+		fields := strings.Fields(line)
+		p.proto = fields[0]
+		if len(fields) > 1 {
+			p.status, _ = strconv.Atoi(fields[1])
+		}
+		return
+	}
+
+	// Fields after the final header block are trailers
+	fields := p.header
+	if p.complete {
+		if p.trailer == nil {
+			p.trailer = make(http.Header)
+		}
+		fields = p.trailer
+	}
+
+	// obs-fold: a line starting with SP or HTAB continues the previous
+	// header value and is replaced by a single SP (RFC 7230 section 3.2.4)
+	if line[0] == ' ' || line[0] == '\t' {
+		continuation := trimOWS(line)
+		if values := fields[p.lastKey]; p.lastKey != "" && len(values) > 0 {
+			values[len(values)-1] += " " + continuation
+			return
+		}
+		p.addNote(NoteMalformedHeader, "continuation line without preceding header")
+		return
+	}
+
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		p.addNote(NoteMalformedHeader, "header line without colon")
+		return
+	}
+	key := trimOWS(parts[0])
+	if key == "" {
+		p.addNote(NoteMalformedHeader, "header line with empty name")
+		return
+	}
+	value := trimOWS(parts[1])
+	fields.Add(key, value)
+	p.lastKey = http.CanonicalHeaderKey(key)
+}
+
+// parseProtoVersion parses the version of a status line protocol such as
+// "HTTP/1.1" or "HTTP/2", where HTTP/2 and HTTP/3 omit the minor version
+func parseProtoVersion(proto string) (major, minor int, ok bool) {
+	version, found := strings.CutPrefix(proto, "HTTP/")
+	if !found {
+		return 0, 0, false
+	}
+	majorText, minorText, hasMinor := strings.Cut(version, ".")
+	major, err := strconv.Atoi(majorText)
+	if err != nil || major < 0 {
+		return 0, 0, false
+	}
+	if hasMinor {
+		if minor, err = strconv.Atoi(minorText); err != nil || minor < 0 {
+			return 0, 0, false
+		}
+	}
+	return major, minor, true
+}
+
+// trimOWS strips optional whitespace (SP and HTAB) without touching other
+// bytes, so values that are not valid UTF-8 are preserved exactly
+func trimOWS(s string) string {
+	return strings.Trim(s, " \t")
+}
+
+// addNote records a note about a header line that could not be used as-is
+func (p *headerParser) addNote(kind NoteKind, message string) {
+	p.notes = append(p.notes, ResponseNote{Kind: kind, Message: message})
+}
+
+// parseHeaders parses a block of raw header lines separated by LF or CRLF
+func parseHeaders(data string) http.Header {
+	p := newHeaderParser()
+	for _, line := range strings.SplitAfter(data, "\n") {
+		if line != "" {
+			p.parseLine([]byte(line))
+		}
+	}
+	return p.header
+}
+
+// hopHeaders are the hop-by-hop headers of RFC 9110 section 7.6.1 that
+// describe a single connection. curl manages connections itself, so they
+// are not forwarded from requests. Proxy-Authorization is kept since it is
+// meant for the configured proxy.
+var hopHeaders = map[string]bool{
+	"Connection":        true,
+	"Proxy-Connection":  true,
+	"Keep-Alive":        true,
+	"Te":                true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// connectionHeaders returns the header names listed in header's Connection
+// field, which are hop-by-hop as well
+func connectionHeaders(header http.Header) map[string]bool {
+	var names map[string]bool
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = trimOWS(name); name != "" {
+				if names == nil {
+					names = make(map[string]bool)
+				}
+				names[http.CanonicalHeaderKey(name)] = true
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,132 @@
+package curlhttp
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseHeadersObsFold tests RFC 7230 obsolete line folding
+func TestParseHeadersObsFold(t *testing.T) {
+	headerData := "HTTP/1.1 200 OK\r\nX-Folded: first\r\n  second\r\n\tthird\r\nX-Next: value\r\n"
+
+	headers := parseHeaders(headerData)
+
+	if got := headers.Get("X-Folded"); got != "first second third" {
+		t.Errorf("Expected folded value 'first second third', got %q", got)
+	}
+	if got := headers.Get("X-Next"); got != "value" {
+		t.Errorf("Expected X-Next: value, got %q", got)
+	}
+}
+
+// TestParseHeadersInvalidUTF8 tests that non-UTF-8 header bytes are passed through unchanged
+func TestParseHeadersInvalidUTF8(t *testing.T) {
+	value := "caf\xe9 \xff\xfe"
+	headers := parseHeaders("HTTP/1.1 200 OK\r\nX-Latin1: " + value + "\r\n")
+
+	if got := headers.Get("X-Latin1"); got != value {
+		t.Errorf("Expected raw bytes %q, got %q", value, got)
+	}
+}
+
+// TestParseHeadersMultipleBlocks tests that only the final response's headers are kept
+func TestParseHeadersMultipleBlocks(t *testing.T) {
+	headerData := "HTTP/1.1 100 Continue\r\nX-Interim: yes\r\n\r\nHTTP/1.1 200 OK\r\nX-Final: yes\r\n\r\n"
+
+	headers := parseHeaders(headerData)
+
+	if headers.Get("X-Interim") != "" {
+		t.Error("Interim response headers should be discarded")
+	}
+	if headers.Get("X-Final") != "yes" {
+		t.Error("Expected final response headers to be kept")
+	}
+}
+
+// TestHeaderParserMalformedLines tests that malformed lines are skipped with a note
+func TestHeaderParserMalformedLines(t *testing.T) {
+	p := newHeaderParser()
+	for _, line := range []string{"HTTP/1.1 200 OK\r\n", " orphan continuation\r\n", "no colon here\r\n", ": empty name\r\n", "X-Ok: fine\r\n"} {
+		p.parseLine([]byte(line))
+	}
+
+	if p.header.Get("X-Ok") != "fine" {
+		t.Error("Expected valid header to survive malformed neighbours")
+	}
+	if len(p.notes) != 3 {
+		t.Errorf("Expected 3 malformed-header notes, got %d", len(p.notes))
+	}
+	for _, note := range p.notes {
+		if note.Kind != NoteMalformedHeader {
+			t.Errorf("Expected NoteMalformedHeader, got %v", note.Kind)
+		}
+	}
+}
+
+// FuzzHeaderParser feeds arbitrary bytes to the header callback parser
+func FuzzHeaderParser(f *testing.F) {
+	f.Add("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n")
+	f.Add("HTTP/1.1 200 OK\r\nX-Folded: a\r\n b\r\n")
+	f.Add(" \t\r\n:\r\n\xff\xfe: \xe9\r\n")
+
+	f.Fuzz(func(t *testing.T, data string) {
+		p := newHeaderParser()
+		for _, line := range strings.SplitAfter(data, "\n") {
+			if ok := writeHeaderToParser([]byte(line), p); !ok {
+				t.Fatal("header callback must accept any input")
+			}
+		}
+		for key, values := range p.header {
+			if key == "" {
+				t.Error("parser produced an empty header name")
+			}
+			for _, v := range values {
+				if strings.Contains(v, "\n") {
+					t.Errorf("header %q value contains line feed: %q", key, v)
+				}
+			}
+		}
+	})
+}
+
+// TestParseHeadersTrailers tests that fields after the final header block are trailers
+func TestParseHeadersTrailers(t *testing.T) {
+	p := newHeaderParser()
+	completed := 0
+	p.onComplete = func() { completed++ }
+	for _, line := range []string{"HTTP/1.1 103 Early Hints\r\n", "Link: </a>\r\n", "\r\n", "HTTP/2 200\r\n", "X-Final: yes\r\n", "\r\n", "X-Trailer: done\r\n", "\r\n"} {
+		p.parseLine([]byte(line))
+	}
+
+	if p.status != 200 {
+		t.Errorf("Expected status 200, got %d", p.status)
+	}
+	if completed != 1 {
+		t.Errorf("Expected onComplete once, got %d", completed)
+	}
+	if p.header.Get("X-Trailer") != "" || p.trailer.Get("X-Trailer") != "done" {
+		t.Errorf("Expected X-Trailer as trailer, got header %v trailer %v", p.header, p.trailer)
+	}
+}
+
+// TestParseProtoVersion tests status line protocol parsing
+func TestParseProtoVersion(t *testing.T) {
+	tests := []struct {
+		proto        string
+		major, minor int
+		ok           bool
+	}{
+		{"HTTP/1.1", 1, 1, true},
+		{"HTTP/1.0", 1, 0, true},
+		{"HTTP/2", 2, 0, true},
+		{"HTTP/3", 3, 0, true},
+		{"", 0, 0, false},
+		{"HTTP/x.1", 0, 0, false},
+	}
+	for _, tt := range tests {
+		major, minor, ok := parseProtoVersion(tt.proto)
+		if major != tt.major || minor != tt.minor || ok != tt.ok {
+			t.Errorf("parseProtoVersion(%q): expected %d.%d %v, got %d.%d %v", tt.proto, tt.major, tt.minor, tt.ok, major, minor, ok)
+		}
+	}
+}
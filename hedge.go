@@ -0,0 +1,205 @@
+package curlhttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Hedger sends a second copy of a slow request and uses whichever copy
+// answers successfully first, canceling the other. It trades extra load for
+// lower tail latency against flaky servers or CDNs.
+//
+// Only idempotent requests whose body can be replayed (no body, or
+// Request.GetBody set) are hedged; others pass through unchanged.
+type Hedger struct {
+	// Delay is how long to wait for the first attempt before sending a
+	// hedge. A failed attempt sends the next hedge immediately.
+	Delay time.Duration
+
+	// MaxHedges is the number of extra attempts. Defaults to 1.
+	MaxHedges int
+
+	// Alternate, if set, rewrites each hedge request, for example to send
+	// it to a mirror host. It receives a clone and may modify it freely.
+	Alternate func(req *http.Request)
+
+	// Budget, if set, must fund each hedge; when it is spent the request
+	// waits for the attempts already sent
+	Budget *RetryBudget
+
+	// Clock schedules hedges. Defaults to SystemClock.
+	Clock Clock
+}
+
+// hedgeResult is the outcome of one attempt
+type hedgeResult struct {
+	resp    *http.Response
+	err     error
+	attempt int
+}
+
+// succeeded reports whether the attempt can be returned as the winner
+func (r hedgeResult) succeeded() bool {
+	return r.err == nil && r.resp.StatusCode < 500
+}
+
+// Middleware returns middleware that hedges requests
+func (h *Hedger) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if h.Delay <= 0 || !isIdempotent(req) || !canReplayBody(req) {
+				return next.RoundTrip(req)
+			}
+			return h.roundTrip(next, req)
+		})
+	}
+}
+
+// roundTrip runs the attempts and picks the winner
+func (h *Hedger) roundTrip(next http.RoundTripper, req *http.Request) (*http.Response, error) {
+	clock := clockOrSystem(h.Clock)
+	maxAttempts := 1 + h.MaxHedges
+	if h.MaxHedges <= 0 {
+		maxAttempts = 2
+	}
+
+	results := make(chan hedgeResult, maxAttempts)
+	cancels := make([]context.CancelFunc, 0, maxAttempts)
+	launch := func() {
+		attempt := len(cancels) + 1
+		ctx, cancel := context.WithCancel(WithAttempt(req.Context(), attempt))
+		cancels = append(cancels, cancel)
+
+		attemptReq := req.WithContext(ctx)
+		var bodyErr error
+		if attempt > 1 {
+			attemptReq = req.Clone(ctx)
+			if req.GetBody != nil {
+				attemptReq.Body, bodyErr = req.GetBody()
+			}
+			if h.Alternate != nil {
+				h.Alternate(attemptReq)
+			}
+		}
+		go func() {
+			if bodyErr != nil {
+				results <- hedgeResult{err: bodyErr, attempt: attempt}
+				return
+			}
+			resp, err := next.RoundTrip(attemptReq)
+			results <- hedgeResult{resp: resp, err: err, attempt: attempt}
+		}()
+	}
+
+	launch()
+	pending := 1
+	timer := clock.After(h.Delay)
+	var last hedgeResult
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			if res.succeeded() {
+				return h.finish(res, cancels, results, pending), nil
+			}
+			if last.resp != nil {
+				last.resp.Body.Close()
+			}
+			last = res
+			if len(cancels) < maxAttempts && allowRetry(h.Budget, req) {
+				launch()
+				pending++
+				timer = clock.After(h.Delay)
+			}
+		case <-timer:
+			timer = nil
+			if len(cancels) < maxAttempts && allowRetry(h.Budget, req) {
+				launch()
+				pending++
+				if len(cancels) < maxAttempts {
+					timer = clock.After(h.Delay)
+				}
+			}
+		case <-req.Context().Done():
+			go drainHedges(results, pending)
+			if last.resp != nil {
+				last.resp.Body.Close()
+			}
+			return nil, req.Context().Err()
+		}
+	}
+
+	// Every attempt failed; return the last outcome
+	if last.err != nil {
+		for _, cancel := range cancels {
+			cancel()
+		}
+		return nil, last.err
+	}
+	return h.finish(last, cancels, results, 0), nil
+}
+
+// finish cancels the losing attempts and returns the winner's response
+func (h *Hedger) finish(winner hedgeResult, cancels []context.CancelFunc, results <-chan hedgeResult, pending int) *http.Response {
+	for i, cancel := range cancels {
+		if i+1 != winner.attempt {
+			cancel()
+		}
+	}
+	go drainHedges(results, pending)
+
+	cancel := cancels[winner.attempt-1]
+	_, streamed := winner.resp.Body.(*streamBody)
+	if _, buffered := winner.resp.Body.(metaBody); buffered && !streamed {
+		// curl bodies are fully received, so the context is no longer needed
+		cancel()
+	} else {
+		winner.resp.Body = &cancelOnClose{ReadCloser: winner.resp.Body, cancel: cancel}
+	}
+	return winner.resp
+}
+
+// drainHedges closes the responses of attempts that lost
+func drainHedges(results <-chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		if res := <-results; res.resp != nil {
+			res.resp.Body.Close()
+		}
+	}
+}
+
+// cancelOnClose releases an attempt's context when its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// meta keeps the metadata of Transport responses reachable
+func (b *cancelOnClose) meta() *responseMeta {
+	return innerMeta(b.ReadCloser)
+}
+
+// isIdempotent reports whether req may safely be sent more than once,
+// following the rules net/http uses for retries
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, hasKey := req.Header["Idempotency-Key"]
+	_, hasXKey := req.Header["X-Idempotency-Key"]
+	return hasKey || hasXKey
+}
+
+// canReplayBody reports whether req's body can be sent again
+func canReplayBody(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
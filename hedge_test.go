@@ -0,0 +1,120 @@
+package curlhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestHedgerSlowPrimary tests that a hedge answers when the first attempt is slow
+func TestHedgerSlowPrimary(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC))
+	hedger := &Hedger{Delay: 100 * time.Millisecond, Clock: clock}
+
+	primaryCanceled := make(chan struct{})
+	var hosts []string
+	hostSeen := make(chan string, 2)
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		hostSeen <- req.URL.Host
+		if Attempt(req.Context()) == 1 {
+			<-req.Context().Done()
+			close(primaryCanceled)
+			return nil, req.Context().Err()
+		}
+		return stubResponse("ok").RoundTrip(req)
+	})
+	hedger.Alternate = func(req *http.Request) { req.URL.Host = "mirror.example.com" }
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	done := make(chan *http.Response)
+	go func() {
+		resp, err := Chain(next, hedger.Middleware()).RoundTrip(req)
+		if err != nil {
+			t.Errorf("RoundTrip failed: %v", err)
+		}
+		done <- resp
+	}()
+
+	hosts = append(hosts, <-hostSeen)
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(100 * time.Millisecond)
+	hosts = append(hosts, <-hostSeen)
+
+	resp := <-done
+	if resp == nil || resp.StatusCode != 200 {
+		t.Fatalf("Expected the hedge's 200 response, got %+v", resp)
+	}
+	if hosts[0] != "example.com" || hosts[1] != "mirror.example.com" {
+		t.Errorf("Expected primary then mirror, got %v", hosts)
+	}
+	<-primaryCanceled
+}
+
+// TestHedgerFastPrimary tests that no hedge is sent when the first attempt is fast
+func TestHedgerFastPrimary(t *testing.T) {
+	var calls atomic.Int32
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return stubResponse("ok").RoundTrip(req)
+	})
+	hedger := &Hedger{Delay: time.Hour}
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if _, err := Chain(next, hedger.Middleware()).RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected 1 attempt, got %d", n)
+	}
+}
+
+// TestHedgerFailedPrimary tests that a failure sends the hedge right away
+func TestHedgerFailedPrimary(t *testing.T) {
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if Attempt(req.Context()) == 1 {
+			return nil, errors.New("connection reset")
+		}
+		return stubResponse("ok").RoundTrip(req)
+	})
+	hedger := &Hedger{Delay: time.Hour}
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	resp, err := Chain(next, hedger.Middleware()).RoundTrip(req)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Expected hedge to succeed, got %v, %v", resp, err)
+	}
+}
+
+// TestHedgerAllFail tests that the last error is returned when every attempt fails
+func TestHedgerAllFail(t *testing.T) {
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("unreachable")
+	})
+	hedger := &Hedger{Delay: time.Hour, MaxHedges: 2}
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if _, err := Chain(next, hedger.Middleware()).RoundTrip(req); err == nil {
+		t.Error("Expected an error when every attempt fails")
+	}
+}
+
+// TestHedgerSkipsUnsafeRequests tests that POST requests are not hedged
+func TestHedgerSkipsUnsafeRequests(t *testing.T) {
+	var calls atomic.Int32
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return nil, errors.New("failed")
+	})
+	hedger := &Hedger{Delay: time.Millisecond}
+
+	req, _ := http.NewRequestWithContext(context.Background(), "POST", "http://example.com", nil)
+	Chain(next, hedger.Middleware()).RoundTrip(req)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected POST to be sent once, got %d", n)
+	}
+}
@@ -0,0 +1,72 @@
+package curlhttp
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// HookEvent describes a request at one point of its lifecycle. It is passed
+// to the Transport's OnRequest, OnResponse and OnError hooks.
+type HookEvent struct {
+	Request *http.Request
+
+	// Attempt is 1 for the first try of a request and increases with each
+	// retry made by middleware that records attempts with WithAttempt.
+	Attempt int
+
+	// Key names the requested resource for grouping metrics, as returned
+	// by the Transport's KeyFunc
+	Key string
+
+	// Response and Timings are set for OnResponse
+	Response *http.Response
+	Timings  Timings
+
+	// Err is set for OnError
+	Err error
+
+	// Duration is the time spent in curl; zero for OnRequest
+	Duration time.Duration
+}
+
+type attemptKey struct{}
+
+// WithAttempt returns a context recording that requests made with it are
+// the given attempt (starting at 1) of a logical request. Retry middleware
+// uses it so hooks can tell retries apart.
+func WithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// Attempt returns the attempt number recorded in ctx, or 1 if none is
+func Attempt(ctx context.Context) int {
+	if n, ok := ctx.Value(attemptKey{}).(int); ok && n > 0 {
+		return n
+	}
+	return 1
+}
+
+// runRequestHook calls OnRequest if set
+func (t *Transport) runRequestHook(req *http.Request) {
+	if t.OnRequest != nil {
+		t.OnRequest(HookEvent{Request: req, Attempt: Attempt(req.Context()), Key: keyOrDefault(t.KeyFunc, req)})
+	}
+}
+
+// runResultHook calls OnResponse or OnError for the outcome of a request
+func (t *Transport) runResultHook(req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+	event := HookEvent{Request: req, Attempt: Attempt(req.Context()), Key: keyOrDefault(t.KeyFunc, req), Duration: elapsed}
+	if err != nil {
+		if t.OnError != nil {
+			event.Err = err
+			t.OnError(event)
+		}
+		return
+	}
+	if t.OnResponse != nil {
+		event.Response = resp
+		event.Timings, _ = ResponseTimings(resp)
+		t.OnResponse(event)
+	}
+}
@@ -0,0 +1,74 @@
+package curlhttp
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// TestLifecycleHooks tests that OnRequest and OnResponse fire for a successful request
+func TestLifecycleHooks(t *testing.T) {
+	fake := newFakeEngine("hello")
+	transport := newFakeTransport(fake)
+
+	var events []string
+	var response HookEvent
+	transport.OnRequest = func(e HookEvent) { events = append(events, "request") }
+	transport.OnResponse = func(e HookEvent) {
+		events = append(events, "response")
+		response = e
+	}
+	transport.OnError = func(e HookEvent) { events = append(events, "error") }
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	req = req.WithContext(WithAttempt(req.Context(), 2))
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+
+	if len(events) != 2 || events[0] != "request" || events[1] != "response" {
+		t.Fatalf("Expected request and response hooks, got %v", events)
+	}
+	if response.Attempt != 2 {
+		t.Errorf("Expected attempt 2, got %d", response.Attempt)
+	}
+	if response.Response == nil || response.Response.StatusCode != 200 {
+		t.Errorf("Expected response with status 200, got %+v", response.Response)
+	}
+	if response.Response.Request != req {
+		t.Error("Expected response to reference the request")
+	}
+}
+
+// TestLifecycleHooksError tests that OnError fires when curl fails
+func TestLifecycleHooksError(t *testing.T) {
+	fake := newFakeEngine("")
+	fake.performErr = curl.CurlError(28)
+	transport := newFakeTransport(fake)
+
+	var got HookEvent
+	transport.OnError = func(e HookEvent) { got = e }
+	transport.OnResponse = func(e HookEvent) { t.Error("Expected OnResponse not to be called") }
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	transport.RoundTrip(req)
+
+	if got.Err == nil {
+		t.Fatal("Expected OnError to receive the error")
+	}
+	if got.Attempt != 1 {
+		t.Errorf("Expected default attempt 1, got %d", got.Attempt)
+	}
+}
+
+// TestAttempt tests the attempt context helpers
+func TestAttempt(t *testing.T) {
+	if n := Attempt(context.Background()); n != 1 {
+		t.Errorf("Expected 1 without attempt, got %d", n)
+	}
+	if n := Attempt(WithAttempt(context.Background(), 3)); n != 3 {
+		t.Errorf("Expected 3, got %d", n)
+	}
+}
@@ -0,0 +1,178 @@
+package curlhttp
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Integrity verifies response bodies as they are read: the body must have
+// the size its Content-Length declared, and must match the Content-Digest,
+// Repr-Digest, Digest and Content-MD5 headers the server sent. A body that
+// fails returns an error from the Read that reaches its end: a
+// *ContentLengthError, which matches ErrTruncatedBody when the body is
+// short, or a *DigestError. Install it with Transport.Use(v.Middleware()).
+//
+// curl hands over bodies with their content coding removed, so digest
+// headers of compressed responses, which cover the compressed bytes, are
+// not checked. Unknown digest algorithms are ignored.
+type Integrity struct {
+	// SkipLength disables the Content-Length check.
+	SkipLength bool
+
+	// SkipDigests disables the digest header checks.
+	SkipDigests bool
+
+	// Checksum, if set, supplies an extra check for a response: the hash
+	// to compute over its body and the sum it must produce, such as one
+	// taken from a vendor header or a manifest. Returning a nil hash skips
+	// the check.
+	Checksum func(resp *http.Response) (h hash.Hash, want []byte)
+}
+
+// DigestError is returned when a response body does not match a digest
+type DigestError struct {
+	Source    string // header the digest came from, or "checksum"
+	Algorithm string
+}
+
+func (e *DigestError) Error() string {
+	return fmt.Sprintf("response body does not match its %s %s digest", e.Source, e.Algorithm)
+}
+
+// digestAlgorithms maps digest algorithm names to hash constructors
+var digestAlgorithms = map[string]func() hash.Hash{
+	"md5":     md5.New,
+	"sha":     sha1.New,
+	"sha-1":   sha1.New,
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// expectedDigest is a sum a body must produce
+type expectedDigest struct {
+	source    string
+	algorithm string
+	hash      hash.Hash
+	want      []byte
+}
+
+// Middleware returns middleware that verifies response bodies
+func (v *Integrity) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return resp, err
+			}
+			body := &verifyingBody{ReadCloser: resp.Body, declared: -1}
+			if !v.SkipLength {
+				if declared, ok := declaredContentLength(req.Method, resp.StatusCode, resp.Header); ok {
+					body.declared = declared
+				}
+			}
+			if !v.SkipDigests && req.Method != http.MethodHead {
+				body.digests = headerDigests(resp.Header)
+			}
+			if v.Checksum != nil {
+				if h, want := v.Checksum(resp); h != nil {
+					body.digests = append(body.digests, expectedDigest{source: "checksum", algorithm: "custom", hash: h, want: want})
+				}
+			}
+			if body.declared < 0 && len(body.digests) == 0 {
+				return resp, nil
+			}
+			resp.Body = body
+			return resp, nil
+		})
+	}
+}
+
+// headerDigests returns the digests the response headers declare for an
+// unencoded body
+func headerDigests(header http.Header) []expectedDigest {
+	if enc := header.Get("Content-Encoding"); enc != "" && !strings.EqualFold(enc, "identity") {
+		return nil
+	}
+	var digests []expectedDigest
+	for _, name := range []string{"Content-Digest", "Repr-Digest", "Digest"} {
+		for _, value := range header.Values(name) {
+			for _, item := range strings.Split(value, ",") {
+				algorithm, encoded, ok := strings.Cut(strings.TrimSpace(item), "=")
+				if !ok {
+					continue
+				}
+				algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+				newHash, known := digestAlgorithms[algorithm]
+				if !known {
+					continue
+				}
+				// Structured fields wrap byte sequences in colons
+				want, err := base64.StdEncoding.DecodeString(strings.Trim(strings.TrimSpace(encoded), ":"))
+				if err != nil {
+					continue
+				}
+				digests = append(digests, expectedDigest{source: name, algorithm: algorithm, hash: newHash(), want: want})
+			}
+		}
+	}
+	if value := header.Get("Content-Md5"); value != "" {
+		if want, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value)); err == nil {
+			digests = append(digests, expectedDigest{source: "Content-MD5", algorithm: "md5", hash: md5.New(), want: want})
+		}
+	}
+	return digests
+}
+
+// verifyingBody checks the size and digests of a body when it reaches EOF
+type verifyingBody struct {
+	io.ReadCloser
+	declared int64 // -1 if unknown
+	digests  []expectedDigest
+	received int64
+	err      error
+}
+
+func (b *verifyingBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.received += int64(n)
+	for _, d := range b.digests {
+		d.hash.Write(p[:n])
+	}
+	if err == io.EOF {
+		if verifyErr := b.verify(); verifyErr != nil {
+			b.err = verifyErr
+			return n, verifyErr
+		}
+	}
+	return n, err
+}
+
+// verify compares what was read with what the response declared
+func (b *verifyingBody) verify() error {
+	if b.declared >= 0 && b.received != b.declared {
+		return &ContentLengthError{Declared: b.declared, Received: b.received}
+	}
+	for _, d := range b.digests {
+		if !bytes.Equal(d.hash.Sum(nil), d.want) {
+			return &DigestError{Source: d.source, Algorithm: d.algorithm}
+		}
+	}
+	return nil
+}
+
+// meta keeps the metadata of Transport responses reachable
+func (b *verifyingBody) meta() *responseMeta {
+	return innerMeta(b.ReadCloser)
+}
@@ -0,0 +1,96 @@
+package curlhttp
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// integrityResponse answers with body and the given headers
+func integrityResponse(body string, header map[string]string) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(body)), Request: req}
+		for k, v := range header {
+			resp.Header.Set(k, v)
+		}
+		return resp, nil
+	})
+}
+
+// TestIntegrity tests the length and digest checks
+func TestIntegrity(t *testing.T) {
+	sha := sha256.Sum256([]byte("hello"))
+	md := md5.Sum([]byte("hello"))
+	shaB64 := base64.StdEncoding.EncodeToString(sha[:])
+	mdB64 := base64.StdEncoding.EncodeToString(md[:])
+
+	tests := []struct {
+		name   string
+		body   string
+		header map[string]string
+		check  func(error) bool
+	}{
+		{"intact", "hello", map[string]string{"Content-Length": "5", "Content-Digest": "sha-256=:" + shaB64 + ":"}, func(err error) bool { return err == nil }},
+		{"truncated", "hel", map[string]string{"Content-Length": "5"}, func(err error) bool { return errors.Is(err, ErrTruncatedBody) }},
+		{"too long", "hello!", map[string]string{"Content-Length": "5"}, func(err error) bool {
+			var cl *ContentLengthError
+			return errors.As(err, &cl) && !errors.Is(err, ErrTruncatedBody)
+		}},
+		{"content digest", "jello", map[string]string{"Content-Digest": "sha-256=:" + shaB64 + ":"}, isDigestError("Content-Digest")},
+		{"legacy digest", "jello", map[string]string{"Digest": "SHA-256=" + shaB64}, isDigestError("Digest")},
+		{"content md5", "jello", map[string]string{"Content-MD5": mdB64}, isDigestError("Content-MD5")},
+		{"unknown algorithm", "jello", map[string]string{"Digest": "crc32c=AAAAAA=="}, func(err error) bool { return err == nil }},
+		{"encoded", "jello", map[string]string{"Content-Encoding": "gzip", "Content-MD5": mdB64}, func(err error) bool { return err == nil }},
+	}
+	for _, tt := range tests {
+		rt := Chain(integrityResponse(tt.body, tt.header), (&Integrity{}).Middleware())
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: RoundTrip failed: %v", tt.name, err)
+		}
+		_, err = io.ReadAll(resp.Body)
+		if !tt.check(err) {
+			t.Errorf("%s: Unexpected read error %v", tt.name, err)
+		}
+	}
+}
+
+// isDigestError returns a check for a DigestError from source
+func isDigestError(source string) func(error) bool {
+	return func(err error) bool {
+		var de *DigestError
+		return errors.As(err, &de) && de.Source == source
+	}
+}
+
+// TestIntegrityChecksum tests the user-supplied checksum hook
+func TestIntegrityChecksum(t *testing.T) {
+	verifier := &Integrity{Checksum: func(resp *http.Response) (hash.Hash, []byte) {
+		want, err := base64.StdEncoding.DecodeString(resp.Header.Get("X-Checksum-Crc32"))
+		if err != nil {
+			return nil, nil
+		}
+		return crc32.NewIEEE(), want
+	}}
+	sum := crc32.NewIEEE()
+	sum.Write([]byte("payload"))
+	good := base64.StdEncoding.EncodeToString(sum.Sum(nil))
+
+	for body, wantErr := range map[string]bool{"payload": false, "paylaod": true} {
+		rt := Chain(integrityResponse(body, map[string]string{"X-Checksum-Crc32": good}), verifier.Middleware())
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		resp, _ := rt.RoundTrip(req)
+		_, err := io.ReadAll(resp.Body)
+		if (err != nil) != wantErr {
+			t.Errorf("Expected error %v for body %q, got %v", wantErr, body, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package curlhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxJSONSize is the largest response body DecodeJSON accepts
+const DefaultMaxJSONSize = 10 << 20
+
+// maxErrorBodySize is how much of an unsuccessful response HTTPError keeps
+const maxErrorBodySize = 4 << 10
+
+var (
+	// ErrNotJSON is returned for responses whose Content-Type is not JSON
+	ErrNotJSON = errors.New("response is not JSON")
+
+	// ErrJSONTooLarge is returned for bodies over the size limit
+	ErrJSONTooLarge = errors.New("JSON response too large")
+)
+
+// HTTPError reports a response with a status code outside 2xx. Body holds
+// the start of the response body, which usually explains the failure.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *HTTPError) Error() string {
+	if len(e.Body) == 0 {
+		return fmt.Sprintf("unexpected status %s", e.Status)
+	}
+	return fmt.Sprintf("unexpected status %s: %s", e.Status, e.Body)
+}
+
+// httpError reads the start of an unsuccessful response into an
+// *HTTPError and closes the body
+func httpError(resp *http.Response) *HTTPError {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+}
+
+// DecodeJSON decodes resp's body into v, reading at most
+// DefaultMaxJSONSize bytes, and closes the body. See DecodeJSONLimit.
+func DecodeJSON(resp *http.Response, v any) error {
+	return DecodeJSONLimit(resp, v, DefaultMaxJSONSize)
+}
+
+// DecodeJSONLimit decodes resp's body into v and closes the body. It
+// returns an *HTTPError for statuses outside 2xx, ErrNotJSON when the
+// Content-Type is neither application/json nor a +json type, and a
+// *BodyTooLargeError matching ErrJSONTooLarge when the body exceeds limit
+// bytes. Responses without content and a nil v only have their status
+// checked.
+func DecodeJSONLimit(resp *http.Response, v any, limit int64) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return httpError(resp)
+	}
+	if v == nil || resp.StatusCode == http.StatusNoContent || resp.ContentLength == 0 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, limit))
+		return nil
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || !isJSONMediaType(mediaType) {
+		return fmt.Errorf("%w: Content-Type %q", ErrNotJSON, contentType)
+	}
+
+	data, err := readLimited(resp.Body, limit, ErrJSONTooLarge)
+	var tooLarge *BodyTooLargeError
+	if errors.As(err, &tooLarge) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read JSON response: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode JSON response: %w", err)
+	}
+	return nil
+}
+
+// isJSONMediaType reports whether mediaType is a JSON type
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// GetJSON requests url and decodes the JSON response into out
+func (c *Client) GetJSON(ctx context.Context, url string, out any) error {
+	req, err := NewRequestBuilder(http.MethodGet, url).
+		Context(ctx).
+		Header("Accept", "application/json").
+		Build()
+	if err != nil {
+		return err
+	}
+	return c.doJSON(req, out)
+}
+
+// PostJSON posts in encoded as JSON to url and decodes the JSON response
+// into out. A nil out only checks the status.
+func (c *Client) PostJSON(ctx context.Context, url string, in, out any) error {
+	req, err := NewRequestBuilder(http.MethodPost, url).
+		Context(ctx).
+		Header("Accept", "application/json").
+		JSON(in).
+		Build()
+	if err != nil {
+		return err
+	}
+	return c.doJSON(req, out)
+}
+
+// doJSON sends req and decodes the response into out
+func (c *Client) doJSON(req *http.Request, out any) error {
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	return DecodeJSON(resp, out)
+}
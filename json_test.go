@@ -0,0 +1,93 @@
+package curlhttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// jsonResponse builds a response with the given status, content type and body
+func jsonResponse(status int, contentType, body string) *http.Response {
+	header := make(http.Header)
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return &http.Response{
+		StatusCode:    status,
+		Status:        http.StatusText(status),
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+// TestDecodeJSON tests decoding, status, content type and size checks
+func TestDecodeJSON(t *testing.T) {
+	var out struct{ Name string }
+	if err := DecodeJSON(jsonResponse(200, "application/json; charset=utf-8", `{"name":"go"}`), &out); err != nil {
+		t.Fatalf("DecodeJSON failed: %v", err)
+	}
+	if out.Name != "go" {
+		t.Errorf("Expected name go, got %q", out.Name)
+	}
+	if err := DecodeJSON(jsonResponse(200, "application/problem+json", `{}`), &out); err != nil {
+		t.Errorf("Expected +json types to be accepted, got %v", err)
+	}
+
+	err := DecodeJSON(jsonResponse(200, "text/html", `<html>`), &out)
+	if !errors.Is(err, ErrNotJSON) {
+		t.Errorf("Expected ErrNotJSON, got %v", err)
+	}
+
+	err = DecodeJSONLimit(jsonResponse(200, "application/json", `{"name":"too long"}`), &out, 8)
+	if !errors.Is(err, ErrJSONTooLarge) {
+		t.Errorf("Expected ErrJSONTooLarge, got %v", err)
+	}
+
+	var httpErr *HTTPError
+	err = DecodeJSON(jsonResponse(404, "application/json", `{"error":"missing"}`), &out)
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 404 || string(httpErr.Body) != `{"error":"missing"}` {
+		t.Errorf("Expected HTTPError with body, got %v", err)
+	}
+
+	if err := DecodeJSON(jsonResponse(204, "", ""), &out); err != nil {
+		t.Errorf("Expected no error for 204, got %v", err)
+	}
+	if err := DecodeJSON(jsonResponse(200, "application/json", `{bad`), &out); err == nil {
+		t.Error("Expected an error for malformed JSON")
+	}
+}
+
+// TestClientJSON tests GetJSON and PostJSON
+func TestClientJSON(t *testing.T) {
+	var gotBody, gotAccept, gotType string
+	client := &Client{Client: http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		gotAccept, gotType = req.Header.Get("Accept"), req.Header.Get("Content-Type")
+		if req.Body != nil {
+			data, _ := io.ReadAll(req.Body)
+			gotBody = string(data)
+		}
+		resp := jsonResponse(200, "application/json", `{"id":7}`)
+		resp.Request = req
+		return resp, nil
+	})}}
+
+	var out struct{ ID int }
+	if err := client.GetJSON(context.Background(), "https://example.com/item", &out); err != nil {
+		t.Fatalf("GetJSON failed: %v", err)
+	}
+	if out.ID != 7 || gotAccept != "application/json" {
+		t.Errorf("Expected id 7 with JSON Accept, got %d and %q", out.ID, gotAccept)
+	}
+
+	out.ID = 0
+	if err := client.PostJSON(context.Background(), "https://example.com/item", map[string]string{"a": "b"}, &out); err != nil {
+		t.Fatalf("PostJSON failed: %v", err)
+	}
+	if gotBody != `{"a":"b"}` || gotType != "application/json" || out.ID != 7 {
+		t.Errorf("Expected JSON request body, got %q (%q)", gotBody, gotType)
+	}
+}
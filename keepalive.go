@@ -0,0 +1,41 @@
+package curlhttp
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// closesConnection reports whether req gets a connection of its own that
+// is closed after it: under DisableKeepAlives, and for requests setting
+// Close or sending Connection: close
+func (t *Transport) closesConnection(req *http.Request) bool {
+	t.configMu.RLock()
+	disabled := t.DisableKeepAlives
+	t.configMu.RUnlock()
+	if disabled || req.Close {
+		return true
+	}
+	for _, value := range req.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(trimOWS(token), "close") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setConnectionClose makes curl open a new connection for the transfer
+// and close it afterwards
+func setConnectionClose(easy curlEngine) error {
+	if err := easy.Setopt(curl.OPT_FRESH_CONNECT, true); err != nil {
+		return fmt.Errorf("failed to set fresh connect: %w", err)
+	}
+	if err := easy.Setopt(curl.OPT_FORBID_REUSE, true); err != nil {
+		return fmt.Errorf("failed to set forbid reuse: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package curlhttp
+
+import (
+	"net/http"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// TestConnectionClose tests that non-keep-alive requests get their own connection
+func TestConnectionClose(t *testing.T) {
+	tests := []struct {
+		name    string
+		disable bool
+		prepare func(req *http.Request)
+		closes  bool
+	}{
+		{"keep-alive", false, func(req *http.Request) {}, false},
+		{"DisableKeepAlives", true, func(req *http.Request) {}, true},
+		{"Request.Close", false, func(req *http.Request) { req.Close = true }, true},
+		{"Connection header", false, func(req *http.Request) { req.Header.Set("Connection", "X-Trace, Close") }, true},
+	}
+	for _, tt := range tests {
+		fake := newFakeEngine("ok")
+		transport := newFakeTransport(fake)
+		transport.DisableKeepAlives = tt.disable
+
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		tt.prepare(req)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: RoundTrip failed: %v", tt.name, err)
+		}
+		resp.Body.Close()
+
+		fresh, _ := fake.performed[curl.OPT_FRESH_CONNECT].(bool)
+		forbid, _ := fake.performed[curl.OPT_FORBID_REUSE].(bool)
+		if fresh != tt.closes || forbid != tt.closes {
+			t.Errorf("%s: Expected fresh connect and forbid reuse %v, got %v and %v", tt.name, tt.closes, fresh, forbid)
+		}
+		if got := sentHeaders(fake)["Connection"]; (got == "close") != tt.closes {
+			t.Errorf("%s: Expected Connection: close only when closing, got %q", tt.name, got)
+		}
+		if fake.opts[curl.OPT_FORBID_REUSE] != false {
+			t.Errorf("%s: Expected the handle to allow reuse again once returned", tt.name)
+		}
+	}
+}
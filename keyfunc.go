@@ -0,0 +1,76 @@
+package curlhttp
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// KeyFunc returns the canonical name of the resource a request asks for.
+// Requests with equal keys are treated as the same resource by
+// Singleflight, ResponseCache and the Transport's hooks, so a KeyFunc that
+// strips tracking parameters or session IDs lets equivalent URLs share
+// one cache entry, one in-flight request and one metrics series. Set the
+// same KeyFunc on each of them to keep them consistent. An empty key falls
+// back to NormalizeURL.
+type KeyFunc func(req *http.Request) string
+
+// NormalizedURLKey is the default KeyFunc: the request URL as returned by
+// NormalizeURL
+func NormalizedURLKey(req *http.Request) string {
+	return NormalizeURL(req.URL)
+}
+
+// TrackingParams are the query parameters StripTrackingParams removes.
+// A trailing "*" matches any parameter with that prefix.
+var TrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid",
+	"mc_cid", "mc_eid", "_ga", "_gl", "igshid", "yclid", "twclid",
+}
+
+// StripTrackingParams is a KeyFunc that drops TrackingParams from the
+// normalized URL
+func StripTrackingParams(req *http.Request) string {
+	return stripQueryParams(req.URL, TrackingParams)
+}
+
+// StripQueryParams returns a KeyFunc that drops the named query parameters
+// from the normalized URL. A name ending in "*" matches any parameter with
+// that prefix; names are case-sensitive, like query parameters.
+func StripQueryParams(names ...string) KeyFunc {
+	names = append([]string(nil), names...)
+	return func(req *http.Request) string {
+		return stripQueryParams(req.URL, names)
+	}
+}
+
+// stripQueryParams returns the normalized form of u without the named
+// query parameters
+func stripQueryParams(u *url.URL, names []string) string {
+	if u.RawQuery == "" {
+		return NormalizeURL(u)
+	}
+	query := u.Query()
+	for param := range query {
+		for _, name := range names {
+			if prefix, ok := strings.CutSuffix(name, "*"); (ok && strings.HasPrefix(param, prefix)) || param == name {
+				delete(query, param)
+				break
+			}
+		}
+	}
+	stripped := *u
+	stripped.RawQuery = query.Encode()
+	return NormalizeURL(&stripped)
+}
+
+// keyOrDefault returns the key of req under key, or its normalized URL if
+// key is nil or returns an empty key
+func keyOrDefault(key KeyFunc, req *http.Request) string {
+	if key != nil {
+		if k := key(req); k != "" {
+			return k
+		}
+	}
+	return NormalizeURL(req.URL)
+}
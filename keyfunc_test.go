@@ -0,0 +1,81 @@
+package curlhttp
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// TestStripTrackingParams tests that tracking parameters are dropped from the key
+func TestStripTrackingParams(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://Example.com/a?utm_source=x&id=1&utm_medium=y", "http://example.com/a?id=1"},
+		{"https://example.com:443/a?fbclid=abc", "https://example.com/a"},
+		{"http://example.com/a?gclid=1&b=2&a=1#frag", "http://example.com/a?a=1&b=2"},
+		{"http://example.com", "http://example.com/"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		if got := StripTrackingParams(req); got != tt.want {
+			t.Errorf("StripTrackingParams(%q): expected %q, got %q", tt.url, tt.want, got)
+		}
+	}
+}
+
+// TestStripQueryParams tests custom parameter lists and prefixes
+func TestStripQueryParams(t *testing.T) {
+	key := StripQueryParams("session", "cb_*")
+	req, _ := http.NewRequest("GET", "http://example.com/feed?cb_1=x&cb_2=y&session=s&page=2&Session=keep", nil)
+	if got, want := key(req), "http://example.com/feed?Session=keep&page=2"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+// TestKeyFuncSharedByLayers tests that one KeyFunc merges equivalent URLs in the cache and hooks
+func TestKeyFuncSharedByLayers(t *testing.T) {
+	calls := 0
+	cache := &ResponseCache{KeyFunc: StripTrackingParams}
+	rt := Chain(countingResponses(&calls), cache.Middleware())
+
+	cacheGet(t, rt, "http://example.com/post?id=1&utm_source=mail")
+	if body, _ := cacheGet(t, rt, "http://example.com/post?utm_campaign=x&id=1"); body != "1" {
+		t.Errorf("Expected equivalent URL to hit the cache, got %q", body)
+	}
+	cache.Invalidate("http://example.com/post?id=1&fbclid=z")
+	if n := cache.Len(); n != 0 {
+		t.Errorf("Expected Invalidate to match by key, got %d entries", n)
+	}
+
+	sf := &Singleflight{KeyFunc: StripTrackingParams}
+	a, _ := http.NewRequest("GET", "http://example.com/post?id=1&utm_source=a", nil)
+	b, _ := http.NewRequest("GET", "http://example.com/post?id=1&gclid=b", nil)
+	if sf.key(a) != sf.key(b) {
+		t.Errorf("Expected equal singleflight keys, got %q and %q", sf.key(a), sf.key(b))
+	}
+
+	fake := newFakeEngine("ok")
+	transport := newFakeTransport(fake)
+	transport.KeyFunc = StripTrackingParams
+	var keys []string
+	transport.OnRequest = func(e HookEvent) { keys = append(keys, e.Key) }
+	transport.OnResponse = func(e HookEvent) { keys = append(keys, e.Key) }
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "http://example.com/post?id=1&utm_source="+strconv.Itoa(i), nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+	for _, key := range keys {
+		if key != "http://example.com/post?id=1" {
+			t.Errorf("Expected hook key without tracking params, got %q", key)
+		}
+	}
+	if len(keys) != 4 {
+		t.Errorf("Expected 4 hook events, got %d", len(keys))
+	}
+}
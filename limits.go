@@ -0,0 +1,164 @@
+package curlhttp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// semaphore is a counting semaphore whose acquisition respects context
+// cancellation
+type semaphore chan struct{}
+
+// acquire takes a slot, waiting until one is free or ctx is done
+func (s semaphore) acquire(ctx context.Context) error {
+	return s.acquireBefore(ctx, nil)
+}
+
+// acquireBefore is acquire giving up with ErrPoolExhausted when timeout
+// fires. A nil timeout never fires.
+func (s semaphore) acquireBefore(ctx context.Context, timeout <-chan time.Time) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	default:
+	}
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-timeout:
+		return ErrPoolExhausted
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release gives a slot back
+func (s semaphore) release() {
+	<-s
+}
+
+// requestLimits holds the semaphores behind MaxConcurrentRequests and the
+// per-host limits. They are created on first use from the settings of the
+// time; ApplyConfig drops them when the limits change, and requests already
+// holding or waiting for a slot of the old ones keep using them. A host's
+// semaphore is dropped once no request holds or waits for it, so crawling
+// many hosts does not grow the map.
+type requestLimits struct {
+	mu     sync.Mutex
+	ready  bool
+	global semaphore
+	hosts  map[string]*hostSlots
+}
+
+// hostSlots is the semaphore of one host
+type hostSlots struct {
+	sem   semaphore
+	users int // requests holding or waiting for sem
+}
+
+// done records that a request no longer holds or waits for slots of host
+func (l *requestLimits) done(host string, slots *hostSlots) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots.users--
+	if slots.users == 0 && l.hosts[host] == slots {
+		delete(l.hosts, host)
+	}
+}
+
+// reset drops the semaphores so they are created again with new limits
+func (l *requestLimits) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ready = false
+	l.global = nil
+	l.hosts = nil
+}
+
+// hostLimit returns the concurrency limit for host, or 0 if unlimited
+func (t *Transport) hostLimit(host string) int {
+	if limit, ok := t.HostConcurrencyLimits[host]; ok {
+		return limit
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		if limit, ok := t.HostConcurrencyLimits[hostname]; ok {
+			return limit
+		}
+	}
+	return t.MaxConcurrentRequestsPerHost
+}
+
+// requestSemaphores returns the global semaphore and the slots for host,
+// creating them on first use; either is nil when unlimited. The caller must
+// pass non-nil host slots to limits.done when finished with them. Call with
+// configMu held.
+func (t *Transport) requestSemaphores(host string) (global semaphore, perHost *hostSlots) {
+	t.limits.mu.Lock()
+	defer t.limits.mu.Unlock()
+	if !t.limits.ready {
+		t.limits.ready = true
+		if t.MaxConcurrentRequests > 0 {
+			t.limits.global = make(semaphore, t.MaxConcurrentRequests)
+		}
+	}
+	limit := t.hostLimit(host)
+	if limit <= 0 {
+		return t.limits.global, nil
+	}
+	if t.limits.hosts == nil {
+		t.limits.hosts = make(map[string]*hostSlots)
+	}
+	slots, ok := t.limits.hosts[host]
+	if !ok {
+		slots = &hostSlots{sem: make(semaphore, limit)}
+		t.limits.hosts[host] = slots
+	}
+	slots.users++
+	return t.limits.global, slots
+}
+
+// acquireRequestSlot waits for both the global and the per-host limit to
+// admit req. The returned function releases whatever was acquired.
+func (t *Transport) acquireRequestSlot(req *http.Request) (func(), error) {
+	key := strings.ToLower(req.URL.Host)
+	t.configMu.RLock()
+	global, host := t.requestSemaphores(key)
+	t.configMu.RUnlock()
+
+	ctx := req.Context()
+	timeout, _ := t.acquireTimeout()
+	// Take the host slot first so requests queued for a busy host do not
+	// hold global slots other hosts could use
+	if host != nil {
+		if err := host.sem.acquireBefore(ctx, timeout); err != nil {
+			t.limits.done(key, host)
+			t.countExhausted(err)
+			return nil, fmt.Errorf("failed to acquire request slot for %s: %w", req.URL.Host, err)
+		}
+	}
+	if global != nil {
+		if err := global.acquireBefore(ctx, timeout); err != nil {
+			if host != nil {
+				host.sem.release()
+				t.limits.done(key, host)
+			}
+			t.countExhausted(err)
+			return nil, fmt.Errorf("failed to acquire request slot: %w", err)
+		}
+	}
+
+	return func() {
+		if global != nil {
+			global.release()
+		}
+		if host != nil {
+			host.sem.release()
+			t.limits.done(key, host)
+		}
+	}, nil
+}
@@ -0,0 +1,152 @@
+package curlhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// limitRequest builds a request for url bounded by timeout
+func limitRequest(t *testing.T, url string, timeout time.Duration) *http.Request {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	return req
+}
+
+// TestMaxConcurrentRequests tests that the global limit waits and respects context cancellation
+func TestMaxConcurrentRequests(t *testing.T) {
+	transport := NewTransport()
+	transport.MaxConcurrentRequests = 2
+
+	var releases []func()
+	for _, url := range []string{"http://a.example/", "http://b.example/"} {
+		release, err := transport.acquireRequestSlot(limitRequest(t, url, time.Second))
+		if err != nil {
+			t.Fatalf("Expected slot for %s, got %v", url, err)
+		}
+		releases = append(releases, release)
+	}
+
+	_, err := transport.acquireRequestSlot(limitRequest(t, "http://c.example/", 20*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	releases[0]()
+	release, err := transport.acquireRequestSlot(limitRequest(t, "http://c.example/", time.Second))
+	if err != nil {
+		t.Fatalf("Expected slot after release, got %v", err)
+	}
+	release()
+	releases[1]()
+}
+
+// TestPerHostConcurrencyLimit tests that a busy host does not block others
+func TestPerHostConcurrencyLimit(t *testing.T) {
+	transport := NewTransport()
+	transport.MaxConcurrentRequestsPerHost = 1
+	transport.HostConcurrencyLimits = map[string]int{"wide.example": 2}
+
+	release, err := transport.acquireRequestSlot(limitRequest(t, "http://a.example/", time.Second))
+	if err != nil {
+		t.Fatalf("Expected slot, got %v", err)
+	}
+	defer release()
+
+	if _, err := transport.acquireRequestSlot(limitRequest(t, "http://a.example/x", 20*time.Millisecond)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected second request to a.example to wait, got %v", err)
+	}
+	other, err := transport.acquireRequestSlot(limitRequest(t, "http://b.example/", 20*time.Millisecond))
+	if err != nil {
+		t.Errorf("Expected b.example to be admitted, got %v", err)
+	} else {
+		other()
+	}
+
+	for i := 0; i < 2; i++ {
+		wide, err := transport.acquireRequestSlot(limitRequest(t, "http://wide.example:8080/", 20*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Expected override to admit request %d, got %v", i+1, err)
+		}
+		defer wide()
+	}
+}
+
+// TestConcurrencyLimitReleasedOnError tests that a failed request gives its slots back
+func TestConcurrencyLimitReleasedOnError(t *testing.T) {
+	fake := newFakeEngine("")
+	fake.performErr = errors.New("boom")
+	transport := newFakeTransport(fake)
+	transport.MaxConcurrentRequests = 1
+	transport.MaxConcurrentRequestsPerHost = 1
+
+	for i := 0; i < 3; i++ {
+		if _, err := transport.RoundTrip(limitRequest(t, "http://a.example/", 100*time.Millisecond)); err == nil {
+			t.Fatal("Expected request to fail")
+		} else if errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expected slot to be released after failure, got %v", err)
+		}
+	}
+}
+
+// TestConcurrencyLimitAcquireTimeout tests that slot waits end with ErrPoolExhausted
+func TestConcurrencyLimitAcquireTimeout(t *testing.T) {
+	transport := NewTransport()
+	transport.MaxConcurrentRequests = 1
+	transport.AcquireTimeout = 10 * time.Millisecond
+
+	release, err := transport.acquireRequestSlot(limitRequest(t, "http://a.example/", time.Second))
+	if err != nil {
+		t.Fatalf("Expected slot, got %v", err)
+	}
+	defer release()
+
+	if _, err := transport.acquireRequestSlot(limitRequest(t, "http://b.example/", time.Second)); !errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("Expected ErrPoolExhausted, got %v", err)
+	}
+	if n := transport.Stats().PoolExhausted; n != 1 {
+		t.Errorf("Expected 1 exhausted acquisition, got %d", n)
+	}
+}
+
+// TestHostSlotsEvicted tests that a host's semaphore is dropped once no
+// request holds or waits for it, and kept while one does
+func TestHostSlotsEvicted(t *testing.T) {
+	transport := NewTransport()
+	transport.MaxConcurrentRequestsPerHost = 1
+
+	hosts := func() int {
+		transport.limits.mu.Lock()
+		defer transport.limits.mu.Unlock()
+		return len(transport.limits.hosts)
+	}
+
+	release, err := transport.acquireRequestSlot(limitRequest(t, "http://a.example/", time.Second))
+	if err != nil {
+		t.Fatalf("Expected slot, got %v", err)
+	}
+	if _, err := transport.acquireRequestSlot(limitRequest(t, "http://a.example/", 20*time.Millisecond)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected second request to a.example to wait, got %v", err)
+	}
+	if n := hosts(); n != 1 {
+		t.Errorf("Expected the busy host to keep its semaphore, got %d hosts", n)
+	}
+	release()
+
+	for _, url := range []string{"http://b.example/", "http://c.example/", "http://a.example/"} {
+		release, err := transport.acquireRequestSlot(limitRequest(t, url, time.Second))
+		if err != nil {
+			t.Fatalf("Expected slot for %s, got %v", url, err)
+		}
+		release()
+	}
+	if n := hosts(); n != 0 {
+		t.Errorf("Expected idle hosts to be evicted, got %d hosts", n)
+	}
+}
@@ -0,0 +1,151 @@
+package curlhttp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// sensitiveHeaders are logged as "<redacted>" instead of their values
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"X-Auth-Token":        true,
+}
+
+// IsSensitiveHeader reports whether the header name carries credentials or
+// session state, which the Logger and other recorders of traffic redact
+func IsSensitiveHeader(name string) bool {
+	return sensitiveHeaders[http.CanonicalHeaderKey(name)]
+}
+
+type debugKey struct{}
+
+type unsafeVerboseKey struct{}
+
+// WithDebug returns a context that traces requests carrying it: their
+// debug events, with sensitive headers redacted, are logged at info level
+// so they pass a production Logger's threshold
+func WithDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugKey{}, true)
+}
+
+// WithUnsafeVerbose returns a context that enables curl's verbose output
+// for requests carrying it. The binding does not expose curl's debug
+// callback, so that output goes to the process's standard error and is not
+// redacted: it includes credentials and cookies. Use it only for local
+// debugging.
+func WithUnsafeVerbose(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unsafeVerboseKey{}, true)
+}
+
+// debugging reports whether ctx asks for a traced request
+func debugging(ctx context.Context) bool {
+	debug, _ := ctx.Value(debugKey{}).(bool)
+	return debug
+}
+
+// unsafeVerbose reports whether ctx asks for curl's verbose output
+func unsafeVerbose(ctx context.Context) bool {
+	verbose, _ := ctx.Value(unsafeVerboseKey{}).(bool)
+	return verbose
+}
+
+// logLevel raises debug events of traced requests to info
+func logLevel(ctx context.Context, level slog.Level) slog.Level {
+	if level < slog.LevelInfo && debugging(ctx) {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// log emits a structured event if a Logger is configured
+func (t *Transport) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	level = logLevel(ctx, level)
+	if t.Logger == nil || !t.Logger.Enabled(ctx, level) {
+		return
+	}
+	t.Logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// logEnabled reports whether events at level would be emitted
+func (t *Transport) logEnabled(ctx context.Context, level slog.Level) bool {
+	return t.Logger != nil && t.Logger.Enabled(ctx, logLevel(ctx, level))
+}
+
+// logRequestStart logs an outgoing request; headers are only included at
+// debug level
+func (t *Transport) logRequestStart(req *http.Request, headers map[string]string) {
+	ctx := req.Context()
+	if !t.logEnabled(ctx, slog.LevelDebug) {
+		return
+	}
+	target, _ := t.requestTarget(req)
+	t.log(ctx, slog.LevelDebug, "curl request started",
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.Redacted()),
+		slog.String("target", target),
+		headerAttr("headers", headers),
+	)
+}
+
+// logRequestDone logs the outcome of a request
+func (t *Transport) logRequestDone(req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+	ctx := req.Context()
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.Redacted()),
+		slog.Duration("duration", elapsed),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		var curlErr curl.CurlError
+		if errors.As(err, &curlErr) {
+			attrs = append(attrs, slog.Int("curl_code", int(curlErr)))
+		}
+		t.log(ctx, slog.LevelWarn, "curl request failed", attrs...)
+		return
+	}
+
+	attrs = append(attrs,
+		slog.Int("status", resp.StatusCode),
+		slog.Int64("bytes", resp.ContentLength),
+	)
+	if t.logEnabled(ctx, slog.LevelDebug) {
+		response := make(map[string]string, len(resp.Header))
+		for name, values := range resp.Header {
+			if len(values) > 0 {
+				response[name] = values[0]
+			}
+		}
+		attrs = append(attrs, headerAttr("headers", response))
+	}
+	t.log(ctx, slog.LevelInfo, "curl request finished", attrs...)
+}
+
+// headerAttr groups headers into a sorted, redacted slog attribute
+func headerAttr(key string, headers map[string]string) slog.Attr {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attrs := make([]any, 0, len(names))
+	for _, name := range names {
+		value := headers[name]
+		if IsSensitiveHeader(name) {
+			value = "<redacted>"
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return slog.Group(key, attrs...)
+}
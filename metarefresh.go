@@ -0,0 +1,239 @@
+package curlhttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetaRefresh detects redirects that pages perform on the client side: a
+// <meta http-equiv="refresh"> tag, a Refresh header, or a script that only
+// assigns location. Responses carrying one get a NoteClientRedirect note
+// and, with Follow set, are followed like an HTTP redirect. Followed
+// responses link to the one that triggered them through Request.Response,
+// as http.Client does for 3xx redirects, so RedirectChain lists every hop.
+// Install it with Transport.Use(m.Middleware()).
+//
+// Only successful HTML responses up to MaxBodySize are inspected.
+type MetaRefresh struct {
+	// Follow requests the target of a detected redirect with GET, sending
+	// the page as Referer, instead of only recording a note.
+	Follow bool
+
+	// MaxHops limits how many client-side redirects are followed for one
+	// request. Defaults to 5; the last response is returned once reached.
+	MaxHops int
+
+	// MaxDelay ignores refreshes that wait longer, which usually reload a
+	// page rather than redirect. Defaults to 10 seconds.
+	MaxDelay time.Duration
+
+	// MaxBodySize is the largest body that is inspected. Defaults to 256 KiB.
+	MaxBodySize int64
+}
+
+// ClientRedirect is a redirect found in a page
+type ClientRedirect struct {
+	URL    *url.URL
+	Delay  time.Duration
+	Source string // "meta", "header" or "script"
+}
+
+var (
+	metaRefreshPattern = regexp.MustCompile(`(?is)<meta\s[^>]*http-equiv\s*=\s*["']?refresh\b[^>]*>`)
+	metaContentPattern = regexp.MustCompile(`(?is)\scontent\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	scriptPattern      = regexp.MustCompile(`(?is)<script[^>]*>(.*?)</script>`)
+
+	// locationPattern matches scripts that consist of a single location
+	// assignment or replace/assign call
+	locationPattern = regexp.MustCompile(`^(?:(?:window|document|self|top)\.)?location(?:\.href)?\s*=\s*["']([^"']+)["']\s*;?$|` +
+		`^(?:(?:window|document|self|top)\.)?location(?:\.href)?\.(?:replace|assign)\(\s*["']([^"']+)["']\s*\)\s*;?$`)
+)
+
+// ParseRefresh parses a Refresh header or meta content value such as
+// "0; url=/next". It reports false when the value has no URL.
+func ParseRefresh(value string) (delay time.Duration, target string, ok bool) {
+	value = strings.TrimSpace(value)
+	end := strings.IndexAny(value, ";, ")
+	if end < 0 {
+		return 0, "", false
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(value[:end]), 64)
+	if err != nil || seconds < 0 {
+		return 0, "", false
+	}
+	rest := strings.TrimLeft(value[end:], ";, \t")
+	if len(rest) >= 3 && strings.EqualFold(rest[:3], "url") {
+		if after := strings.TrimLeft(rest[3:], " \t"); strings.HasPrefix(after, "=") {
+			rest = strings.TrimLeft(after[1:], " \t")
+		}
+	}
+	rest = strings.Trim(rest, `"' `)
+	if rest == "" {
+		return 0, "", false
+	}
+	return time.Duration(seconds * float64(time.Second)), rest, true
+}
+
+// FindClientRedirect looks for a client-side redirect in a response from
+// base and its body. The Refresh header takes precedence over the page.
+func FindClientRedirect(base *url.URL, header http.Header, body []byte) (*ClientRedirect, bool) {
+	resolve := func(delay time.Duration, target, source string) (*ClientRedirect, bool) {
+		u, err := base.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, false
+		}
+		return &ClientRedirect{URL: u, Delay: delay, Source: source}, true
+	}
+
+	if delay, target, ok := ParseRefresh(header.Get("Refresh")); ok {
+		return resolve(delay, target, "header")
+	}
+	if tag := metaRefreshPattern.Find(body); tag != nil {
+		if m := metaContentPattern.FindSubmatch(tag); m != nil {
+			content := string(m[1]) + string(m[2]) + string(m[3])
+			if delay, target, ok := ParseRefresh(htmlUnescape(content)); ok {
+				return resolve(delay, target, "meta")
+			}
+		}
+	}
+	for _, m := range scriptPattern.FindAllSubmatch(body, -1) {
+		if lm := locationPattern.FindSubmatch(bytes.TrimSpace(m[1])); lm != nil {
+			return resolve(0, string(lm[1])+string(lm[2]), "script")
+		}
+	}
+	return nil, false
+}
+
+// htmlUnescape decodes the entities that commonly appear in attribute URLs
+var htmlUnescape = strings.NewReplacer("&amp;", "&", "&quot;", `"`, "&#39;", "'", "&#x27;", "'").Replace
+
+// RedirectChain returns the URLs that led to resp, oldest first and ending
+// with resp's own, following Request.Response links left by http.Client
+// redirects and MetaRefresh
+func RedirectChain(resp *http.Response) []*url.URL {
+	var chain []*url.URL
+	for r := resp; r != nil && r.Request != nil; r = r.Request.Response {
+		chain = append(chain, r.Request.URL)
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+// Middleware returns middleware that detects and optionally follows
+// client-side redirects
+func (m *MetaRefresh) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			maxHops := m.MaxHops
+			if maxHops <= 0 {
+				maxHops = 5
+			}
+			for hops := 0; err == nil; hops++ {
+				redirect, ok := m.inspect(resp)
+				if !ok || !m.Follow || hops >= maxHops || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+					return resp, nil
+				}
+
+				follow, followErr := m.followRequest(req, resp, redirect)
+				if followErr != nil {
+					return resp, nil
+				}
+				io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+				resp.Body.Close()
+				req = follow
+				resp, err = next.RoundTrip(req)
+			}
+			return resp, err
+		})
+	}
+}
+
+// followRequest builds the request that follows redirect from resp
+func (m *MetaRefresh) followRequest(req *http.Request, resp *http.Response, redirect *ClientRedirect) (*http.Request, error) {
+	follow, err := http.NewRequestWithContext(req.Context(), http.MethodGet, redirect.URL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range req.Header {
+		follow.Header[key] = append([]string(nil), values...)
+	}
+	// Credentials are not carried to other hosts, as for HTTP redirects
+	if !strings.EqualFold(redirect.URL.Host, req.URL.Host) {
+		follow.Header.Del("Authorization")
+		follow.Header.Del("Cookie")
+	}
+	if ref := referrerFor(req.URL, redirect.URL); ref != "" {
+		follow.Header.Set("Referer", ref)
+	} else {
+		follow.Header.Del("Referer")
+	}
+	follow.Response = resp
+	return follow, nil
+}
+
+// inspect reads resp's body if it may hold a client-side redirect, puts it
+// back and records a note for the redirect found
+func (m *MetaRefresh) inspect(resp *http.Response) (*ClientRedirect, bool) {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || resp.Request == nil {
+		return nil, false
+	}
+	maxDelay := m.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = 10 * time.Second
+	}
+	limit := m.MaxBodySize
+	if limit <= 0 {
+		limit = 256 << 10
+	}
+
+	var body []byte
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		if resp.ContentLength > limit {
+			return nil, false
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+		resp.Body = &prefixedBody{prefix: bytes.NewReader(data), ReadCloser: resp.Body}
+		if err != nil || int64(len(data)) > limit {
+			return nil, false
+		}
+		body = data
+	}
+
+	redirect, ok := FindClientRedirect(resp.Request.URL, resp.Header, body)
+	if !ok || redirect.Delay > maxDelay {
+		return nil, false
+	}
+	if meta := metaOf(resp); meta != nil {
+		meta.addNote(NoteClientRedirect, fmt.Sprintf("%s redirect to %s after %v", redirect.Source, redirect.URL, redirect.Delay))
+	}
+	return redirect, true
+}
+
+// prefixedBody replays data already read from a body before the rest of it
+type prefixedBody struct {
+	prefix *bytes.Reader
+	io.ReadCloser
+}
+
+func (b *prefixedBody) Read(p []byte) (int, error) {
+	if b.prefix.Len() > 0 {
+		return b.prefix.Read(p)
+	}
+	return b.ReadCloser.Read(p)
+}
+
+// meta keeps the metadata of Transport responses reachable
+func (b *prefixedBody) meta() *responseMeta {
+	return innerMeta(b.ReadCloser)
+}
@@ -0,0 +1,164 @@
+package curlhttp
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// refreshSite serves HTML pages keyed by path with Transport response bodies
+func refreshSite(pages map[string]string) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		header := make(http.Header)
+		header.Set("Content-Type", "text/html; charset=utf-8")
+		return &http.Response{
+			StatusCode: 200,
+			Header:     header,
+			Body:       newResponseBody([]byte(pages[req.URL.Path])),
+			Request:    req,
+		}, nil
+	})
+}
+
+// TestParseRefresh tests parsing of refresh values
+func TestParseRefresh(t *testing.T) {
+	tests := []struct {
+		value  string
+		delay  time.Duration
+		target string
+		ok     bool
+	}{
+		{"0; url=/next", 0, "/next", true},
+		{"5;URL='https://example.com/a?b=1'", 5 * time.Second, "https://example.com/a?b=1", true},
+		{"1, http://example.com/", time.Second, "http://example.com/", true},
+		{"30", 0, "", false},
+		{"soon; url=/x", 0, "", false},
+	}
+	for _, tt := range tests {
+		delay, target, ok := ParseRefresh(tt.value)
+		if delay != tt.delay || target != tt.target || ok != tt.ok {
+			t.Errorf("ParseRefresh(%q) = %v, %q, %v, expected %v, %q, %v", tt.value, delay, target, ok, tt.delay, tt.target, tt.ok)
+		}
+	}
+}
+
+// TestFindClientRedirect tests detection in meta tags, headers and scripts
+func TestFindClientRedirect(t *testing.T) {
+	base, _ := url.Parse("https://example.com/dir/page")
+	tests := []struct {
+		header string
+		body   string
+		want   string
+		source string
+	}{
+		{"", `<html><head><META HTTP-EQUIV="Refresh" CONTENT="0;URL=/landing?a=1&amp;b=2"></head></html>`, "https://example.com/landing?a=1&b=2", "meta"},
+		{"0; url=other", "", "https://example.com/dir/other", "header"},
+		{"", `<script>window.location.href = "https://www.example.com/";</script>`, "https://www.example.com/", "script"},
+		{"", `<script type="text/javascript"> location.replace('/x') </script>`, "https://example.com/x", "script"},
+		{"", `<script>if (x) { location.href = "/x"; }</script>`, "", ""},
+		{"", `<meta http-equiv="refresh" content="0; url=javascript:alert(1)">`, "", ""},
+	}
+	for _, tt := range tests {
+		header := make(http.Header)
+		if tt.header != "" {
+			header.Set("Refresh", tt.header)
+		}
+		redirect, ok := FindClientRedirect(base, header, []byte(tt.body))
+		if tt.want == "" {
+			if ok {
+				t.Errorf("Expected no redirect in %q, got %s", tt.body, redirect.URL)
+			}
+			continue
+		}
+		if !ok || redirect.URL.String() != tt.want || redirect.Source != tt.source {
+			t.Errorf("Expected %s redirect to %s in %q, got %+v", tt.source, tt.want, tt.body, redirect)
+		}
+	}
+}
+
+// TestMetaRefreshFollow tests following a chain of client-side redirects
+func TestMetaRefreshFollow(t *testing.T) {
+	var referers []string
+	site := refreshSite(map[string]string{
+		"/":      `<meta http-equiv="refresh" content="0; url=/step">`,
+		"/step":  `<script>location = "/final"</script>`,
+		"/final": `done`,
+	})
+	rt := Chain(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		referers = append(referers, req.Header.Get("Referer"))
+		return site.RoundTrip(req)
+	}), (&MetaRefresh{Follow: true}).Middleware())
+
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "done" {
+		t.Errorf("Expected final page, got %q", body)
+	}
+
+	var chain []string
+	for _, u := range RedirectChain(resp) {
+		chain = append(chain, u.Path)
+	}
+	if len(chain) != 3 || chain[0] != "/" || chain[1] != "/step" || chain[2] != "/final" {
+		t.Errorf("Expected chain [/ /step /final], got %v", chain)
+	}
+	if !HasNote(resp.Request.Response, NoteClientRedirect) {
+		t.Error("Expected a client-redirect note on the intermediate response")
+	}
+	if referers[1] != "https://example.com/" {
+		t.Errorf("Expected the page as Referer, got %q", referers[1])
+	}
+}
+
+// TestMetaRefreshDetectOnly tests that detection alone keeps the body intact
+func TestMetaRefreshDetectOnly(t *testing.T) {
+	page := `<meta http-equiv="refresh" content="0; url=/next">`
+	rt := Chain(refreshSite(map[string]string{"/": page}), (&MetaRefresh{}).Middleware())
+
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != page {
+		t.Errorf("Expected the original body, got %q", body)
+	}
+	if !HasNote(resp, NoteClientRedirect) {
+		t.Error("Expected a client-redirect note")
+	}
+}
+
+// TestMetaRefreshLimits tests the hop limit and slow refreshes
+func TestMetaRefreshLimits(t *testing.T) {
+	var requests int
+	site := refreshSite(map[string]string{
+		"/loop": `<meta http-equiv="refresh" content="0; url=/loop">`,
+		"/slow": `<meta http-equiv="refresh" content="60; url=/next">`,
+	})
+	rt := Chain(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		return site.RoundTrip(req)
+	}), (&MetaRefresh{Follow: true, MaxHops: 2}).Middleware())
+
+	req, _ := http.NewRequest("GET", "https://example.com/loop", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("Expected 1 request and 2 hops, got %d requests", requests)
+	}
+
+	requests = 0
+	req, _ = http.NewRequest("GET", "https://example.com/slow", nil)
+	resp, _ := rt.RoundTrip(req)
+	if requests != 1 || HasNote(resp, NoteClientRedirect) {
+		t.Errorf("Expected a slow refresh to be ignored, got %d requests", requests)
+	}
+}
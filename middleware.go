@@ -0,0 +1,56 @@
+package curlhttp
+
+import (
+	"net/http"
+)
+
+// RoundTripperFunc adapts an ordinary function to the http.RoundTripper interface.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a RoundTripper to intercept requests and responses.
+// Logging, authentication, retries and header mutation can all be written
+// as middleware and composed with Use.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// Chain composes middleware around rt. The first middleware is the
+// outermost, so it sees the request first and the response last.
+func Chain(rt http.RoundTripper, middleware ...Middleware) http.RoundTripper {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		rt = middleware[i](rt)
+	}
+	return rt
+}
+
+// Use appends middleware to the Transport. Transport middleware runs for
+// every request the Transport performs, including each hop of a redirect.
+// Use must not be called concurrently with RoundTrip.
+func (t *Transport) Use(middleware ...Middleware) {
+	t.middleware = append(t.middleware, middleware...)
+}
+
+// allMiddleware returns the middleware added with Use followed by the
+// middleware installed by a Config
+func (t *Transport) allMiddleware() []Middleware {
+	configured := t.configured.list()
+	if len(configured) == 0 {
+		return t.middleware
+	}
+	return append(t.middleware[:len(t.middleware):len(t.middleware)], configured...)
+}
+
+// Use wraps the Client's current Transport with middleware, leaving a
+// Transport shared with other clients untouched. Each call wraps whatever was
+// installed before, so middleware from a later call runs outside middleware
+// from earlier calls.
+// Because Client.Transport is replaced with the wrapped RoundTripper, prefer
+// Transport.Use when the concrete *Transport must stay reachable.
+// Use must not be called concurrently with requests on the Client.
+func (c *Client) Use(middleware ...Middleware) {
+	c.ensureInitialized()
+	c.Transport = Chain(c.Transport, middleware...)
+}
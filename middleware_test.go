@@ -0,0 +1,93 @@
+package curlhttp
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// stubResponse returns a RoundTripper that answers every request with body
+func stubResponse(body string) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+}
+
+// recordingMiddleware appends name to order before and after calling next
+func recordingMiddleware(name string, order *[]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			*order = append(*order, name+">")
+			resp, err := next.RoundTrip(req)
+			*order = append(*order, "<"+name)
+			return resp, err
+		})
+	}
+}
+
+// TestChainOrder tests that the first middleware is the outermost
+func TestChainOrder(t *testing.T) {
+	var order []string
+	rt := Chain(stubResponse("ok"), recordingMiddleware("a", &order), recordingMiddleware("b", &order))
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+
+	want := "a> b> <b <a"
+	if got := strings.Join(order, " "); got != want {
+		t.Errorf("Expected order %q, got %q", want, got)
+	}
+}
+
+// TestTransportUseShortCircuit tests that Transport middleware can answer without curl
+func TestTransportUseShortCircuit(t *testing.T) {
+	transport := NewTransport()
+	transport.Use(func(next http.RoundTripper) http.RoundTripper {
+		return stubResponse("from middleware")
+	})
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "from middleware" {
+		t.Errorf("Expected middleware response, got %q", body)
+	}
+}
+
+// TestClientUseHeaderMutation tests Client middleware mutating request headers
+func TestClientUseHeaderMutation(t *testing.T) {
+	var seen string
+	client := &Client{}
+	client.Transport = RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		seen = req.Header.Get("X-Trace")
+		return stubResponse("").RoundTrip(req)
+	})
+	client.Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("X-Trace", "abc")
+			return next.RoundTrip(req)
+		})
+	})
+
+	resp, err := client.Get("http://example.com")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if seen != "abc" {
+		t.Errorf("Expected X-Trace header from middleware, got %q", seen)
+	}
+}
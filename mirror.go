@@ -0,0 +1,221 @@
+package curlhttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Mirror sends a copy of requests to a secondary endpoint in the
+// background while returning only the primary response, for comparing a
+// new environment or migrating a scraper without affecting callers.
+// Install it with Transport.Use(m.Middleware()) and call Wait before
+// shutting down to let shadow requests finish. Target or Rewrite must be
+// set; otherwise, or if Target cannot be parsed, nothing is mirrored and
+// OnResult receives the error.
+type Mirror struct {
+	// Target replaces the scheme and host of mirrored requests, as in
+	// "https://staging.example.com". Path and query are kept.
+	Target string
+
+	// Rewrite, if set, adjusts each mirrored request after Target is
+	// applied. It receives a clone and may modify it freely.
+	Rewrite func(req *http.Request)
+
+	// MirrorNonIdempotent also mirrors requests that are not idempotent,
+	// such as POST without an Idempotency-Key. They are skipped by default
+	// because the target would repeat their side effects.
+	MirrorNonIdempotent bool
+
+	// Transport sends the mirrored requests. Defaults to the rest of the
+	// middleware chain.
+	Transport http.RoundTripper
+
+	// SampleRate is the fraction of requests to mirror, between 0 and 1.
+	// Defaults to 1, mirroring every request; a negative rate mirrors none.
+	SampleRate float64
+
+	// MaxBodySize is the largest request body that is copied for the
+	// mirror. Bodies that cannot be replayed are buffered up to this size;
+	// larger ones are not mirrored. Defaults to 1 MiB.
+	MaxBodySize int64
+
+	// MaxInFlight limits concurrent mirrored requests; requests arriving
+	// while the limit is reached are not mirrored. Defaults to 16.
+	MaxInFlight int
+
+	// Timeout bounds each mirrored request. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	// OnResult, if set, receives the outcome of every mirrored request.
+	// The response body is closed when it returns.
+	OnResult func(MirrorResult)
+
+	once   sync.Once
+	slots  semaphore
+	target *url.URL
+	err    error // configuration error reported instead of mirroring
+	wg     sync.WaitGroup
+}
+
+// MirrorResult is the outcome of a mirrored request
+type MirrorResult struct {
+	Request  *http.Request // the mirrored request
+	Response *http.Response
+	Err      error
+	Duration time.Duration
+}
+
+// Wait blocks until all mirrored requests have finished
+func (m *Mirror) Wait() {
+	m.wg.Wait()
+}
+
+// Middleware returns middleware that mirrors requests
+func (m *Mirror) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if m.sampled() && (m.MirrorNonIdempotent || isIdempotent(req)) {
+				req = m.mirror(req, next)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// sampled decides whether to mirror a request
+func (m *Mirror) sampled() bool {
+	return m.SampleRate == 0 || m.SampleRate >= 1 || rand.Float64() < m.SampleRate
+}
+
+// init sets up the in-flight limit and parses Target
+func (m *Mirror) init() {
+	limit := m.MaxInFlight
+	if limit <= 0 {
+		limit = 16
+	}
+	m.slots = make(semaphore, limit)
+
+	switch {
+	case m.Target != "":
+		target, err := url.Parse(m.Target)
+		if err == nil && (target.Scheme == "" || target.Host == "") {
+			err = errors.New("scheme and host are required")
+		}
+		if err != nil {
+			m.err = fmt.Errorf("mirror: invalid Target %q: %w", m.Target, err)
+			return
+		}
+		m.target = target
+	case m.Rewrite == nil:
+		m.err = errors.New("mirror: Target or Rewrite is required")
+	}
+}
+
+// mirror starts a mirrored copy of req and returns the request to send as
+// the primary, whose body may have been buffered
+func (m *Mirror) mirror(req *http.Request, next http.RoundTripper) *http.Request {
+	m.once.Do(m.init)
+	if m.err != nil {
+		if m.OnResult != nil {
+			m.OnResult(MirrorResult{Err: m.err})
+		}
+		return req
+	}
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		return req
+	}
+
+	primary, getBody, ok := m.duplicateBody(req)
+	if !ok {
+		m.slots.release()
+		return primary
+	}
+
+	timeout := m.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), timeout)
+	shadow := req.Clone(ctx)
+	shadow.Body, shadow.GetBody = nil, getBody
+	if getBody != nil {
+		shadow.Body, _ = getBody()
+	}
+	if m.target != nil {
+		shadow.URL.Scheme, shadow.URL.Host = m.target.Scheme, m.target.Host
+		shadow.Host = ""
+	}
+	if m.Rewrite != nil {
+		m.Rewrite(shadow)
+	}
+
+	transport := m.Transport
+	if transport == nil {
+		transport = next
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer m.slots.release()
+		defer cancel()
+
+		start := time.Now()
+		resp, err := transport.RoundTrip(shadow)
+		result := MirrorResult{Request: shadow, Response: resp, Err: err, Duration: time.Since(start)}
+		if m.OnResult != nil {
+			m.OnResult(result)
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+			resp.Body.Close()
+		}
+	}()
+	return primary
+}
+
+// duplicateBody returns the primary request and a function producing
+// copies of its body for the mirror. It reports false when the body is too
+// large to copy.
+func (m *Mirror) duplicateBody(req *http.Request) (*http.Request, func() (io.ReadCloser, error), bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil, true
+	}
+	if req.GetBody != nil {
+		return req, req.GetBody, true
+	}
+	if _, streamed := streamedUpload(req); streamed {
+		return req, nil, false
+	}
+
+	limit := m.MaxBodySize
+	if limit <= 0 {
+		limit = 1 << 20
+	}
+	if req.ContentLength > limit {
+		return req, nil, false
+	}
+	data, err := io.ReadAll(io.LimitReader(req.Body, limit+1))
+	primary := req.Clone(req.Context())
+	if err != nil || int64(len(data)) > limit {
+		// Hand the primary what was read followed by the rest
+		primary.Body = &prefixedBody{prefix: bytes.NewReader(data), ReadCloser: req.Body}
+		return primary, nil, false
+	}
+	req.Body.Close()
+	getBody := func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	primary.Body, _ = getBody()
+	primary.GetBody = getBody
+	return primary, getBody, true
+}
@@ -0,0 +1,133 @@
+package curlhttp
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestMirror tests that requests and bodies are copied to the target
+func TestMirror(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]string{}
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		seen[req.URL.Host] = req.URL.Path + " " + string(body)
+		mu.Unlock()
+		return stubResponse(req.URL.Host).RoundTrip(req)
+	})
+	var results []MirrorResult
+	mirror := &Mirror{
+		Target:              "https://staging.example.com",
+		MirrorNonIdempotent: true,
+		OnResult:            func(r MirrorResult) { results = append(results, r) },
+	}
+	rt := Chain(next, mirror.Middleware())
+
+	// A body without GetBody is buffered for both requests
+	req, _ := http.NewRequest("POST", "https://example.com/submit", nil)
+	req.Body = io.NopCloser(strings.NewReader("payload"))
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "example.com" {
+		t.Errorf("Expected the primary response, got %q", body)
+	}
+	mirror.Wait()
+
+	if seen["example.com"] != "/submit payload" || seen["staging.example.com"] != "/submit payload" {
+		t.Errorf("Expected both endpoints to get the body, got %v", seen)
+	}
+	if len(results) != 1 || results[0].Err != nil || results[0].Request.URL.Host != "staging.example.com" {
+		t.Errorf("Expected one successful mirror result, got %+v", results)
+	}
+}
+
+// TestMirrorSkips tests sampling and the body size limit
+func TestMirrorSkips(t *testing.T) {
+	var mu sync.Mutex
+	var hosts []string
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		var body []byte
+		if req.Body != nil {
+			body, _ = io.ReadAll(req.Body)
+		}
+		mu.Lock()
+		hosts = append(hosts, req.URL.Host+" "+string(body))
+		mu.Unlock()
+		return stubResponse("ok").RoundTrip(req)
+	})
+
+	mirror := &Mirror{Target: "https://shadow.example.com", MirrorNonIdempotent: true, MaxBodySize: 4}
+	rt := Chain(next, mirror.Middleware())
+	req, _ := http.NewRequest("POST", "https://example.com/", nil)
+	req.Body = io.NopCloser(strings.NewReader("too large"))
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	mirror.Wait()
+	if len(hosts) != 1 || hosts[0] != "example.com too large" {
+		t.Errorf("Expected only the intact primary request, got %v", hosts)
+	}
+
+	hosts = nil
+	mirror = &Mirror{Target: "https://shadow.example.com", SampleRate: 0.000001}
+	rt = Chain(next, mirror.Middleware())
+	for i := 0; i < 20; i++ {
+		req, _ := http.NewRequest("GET", "https://example.com/", nil)
+		rt.RoundTrip(req)
+	}
+	mirror.Wait()
+	if len(hosts) != 20 {
+		t.Errorf("Expected almost no requests to be sampled, got %d requests", len(hosts))
+	}
+}
+
+// TestMirrorConfiguration tests that misconfigured mirrors, disabled
+// sampling and non-idempotent requests send nothing to the target
+func TestMirrorConfiguration(t *testing.T) {
+	var mu sync.Mutex
+	var hosts []string
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		hosts = append(hosts, req.URL.Host)
+		mu.Unlock()
+		return stubResponse("ok").RoundTrip(req)
+	})
+
+	tests := []struct {
+		name    string
+		mirror  *Mirror
+		method  string
+		wantErr bool
+	}{
+		{"no target", &Mirror{}, "GET", true},
+		{"unparsable target", &Mirror{Target: "://bad"}, "GET", true},
+		{"target without host", &Mirror{Target: "staging.example.com"}, "GET", true},
+		{"sampling off", &Mirror{Target: "https://shadow.example.com", SampleRate: -1}, "GET", false},
+		{"post", &Mirror{Target: "https://shadow.example.com"}, "POST", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts = nil
+			var errs []error
+			tt.mirror.OnResult = func(r MirrorResult) { errs = append(errs, r.Err) }
+			req, _ := http.NewRequest(tt.method, "https://example.com/", nil)
+			if _, err := Chain(next, tt.mirror.Middleware()).RoundTrip(req); err != nil {
+				t.Fatalf("RoundTrip failed: %v", err)
+			}
+			tt.mirror.Wait()
+			if len(hosts) != 1 || hosts[0] != "example.com" {
+				t.Errorf("Expected only the primary request, got %v", hosts)
+			}
+			if tt.wantErr != (len(errs) == 1 && errs[0] != nil) {
+				t.Errorf("Expected configuration error %v, got %v", tt.wantErr, errs)
+			}
+		})
+	}
+}
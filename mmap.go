@@ -0,0 +1,154 @@
+package curlhttp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// errMmapUnsupported is returned by mapFile on platforms without mmap
+var errMmapUnsupported = errors.New("mmap not supported on this platform")
+
+// fileSink receives a response body into a temporary file
+type fileSink struct {
+	file   *os.File
+	writer *bufio.Writer
+	size   int64
+}
+
+// newFileSink creates a temporary file in dir (or the default temp dir)
+func newFileSink(dir string) (*fileSink, error) {
+	f, err := os.CreateTemp(dir, "curlhttp-body-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file for response body: %w", err)
+	}
+	return &fileSink{file: f, writer: bufio.NewWriterSize(f, 256*1024)}, nil
+}
+
+// Write implements io.Writer
+func (s *fileSink) Write(p []byte) (int, error) {
+	n, err := s.writer.Write(p)
+	s.size += int64(n)
+	return n, err
+}
+
+// discard closes and removes the temporary file
+func (s *fileSink) discard() {
+	s.file.Close()
+	os.Remove(s.file.Name())
+}
+
+// mappedBody flushes the sink and returns a Body backed by a memory
+// mapping of the file. The file is unlinked once mapped where the platform
+// allows it, so nothing is left behind if the Body is never closed.
+func (s *fileSink) mappedBody() (*mappedBody, error) {
+	if err := s.writer.Flush(); err != nil {
+		s.discard()
+		return nil, fmt.Errorf("failed to flush response body: %w", err)
+	}
+
+	data, unmap, err := mapFile(s.file, s.size)
+	if err == nil {
+		s.discard()
+		r := &mappedReaderAt{data: data, release: unmap}
+		return &mappedBody{SectionReader: io.NewSectionReader(r, 0, s.size), readerAt: r}, nil
+	}
+	if !errors.Is(err, errMmapUnsupported) {
+		s.discard()
+		return nil, fmt.Errorf("failed to map response body: %w", err)
+	}
+
+	// Fall back to reading the file directly
+	file := s.file
+	r := &mappedReaderAt{file: file, release: func() error {
+		err := file.Close()
+		os.Remove(file.Name())
+		return err
+	}}
+	return &mappedBody{SectionReader: io.NewSectionReader(r, 0, s.size), readerAt: r}, nil
+}
+
+// writeDataToFile is the callback function for writing response data to a fileSink
+func writeDataToFile(ptr []byte, userdata interface{}) bool {
+	sink, ok := userdata.(*fileSink)
+	if !ok {
+		return false
+	}
+	_, err := sink.Write(ptr)
+	return err == nil
+}
+
+// mappedReaderAt serves reads from a mapping (or file) and guards against
+// access after the mapping has been released
+type mappedReaderAt struct {
+	mu      sync.RWMutex
+	data    []byte
+	file    *os.File
+	release func() error
+	closed  bool
+}
+
+// ReadAt implements io.ReaderAt
+func (r *mappedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return 0, http.ErrBodyReadAfterClose
+	}
+	if r.file != nil {
+		return r.file.ReadAt(p, off)
+	}
+	if off >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// close releases the mapping exactly once
+func (r *mappedReaderAt) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.data = nil
+	return r.release()
+}
+
+// mappedBody is the Body of responses received with MmapResponses enabled.
+// Besides io.Reader it implements io.ReaderAt and io.Seeker, so large
+// payloads such as archives can be read randomly without loading them
+// onto the heap. Closing the body releases the mapping.
+type mappedBody struct {
+	*io.SectionReader
+	responseMeta
+	readerAt *mappedReaderAt
+}
+
+// Close releases the memory mapping and the backing temporary file
+func (b *mappedBody) Close() error {
+	return b.readerAt.close()
+}
+
+// BodyReaderAt returns random access to a response body received with
+// Transport.MmapResponses enabled, along with its size in bytes. It reports
+// false for any other response.
+func BodyReaderAt(resp *http.Response) (io.ReaderAt, int64, bool) {
+	if resp == nil {
+		return nil, 0, false
+	}
+	body, ok := resp.Body.(*mappedBody)
+	if !ok {
+		return nil, 0, false
+	}
+	return body, body.Size(), true
+}
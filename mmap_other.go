@@ -0,0 +1,12 @@
+//go:build !unix
+
+package curlhttp
+
+import (
+	"os"
+)
+
+// mapFile is not available on this platform; callers fall back to file reads
+func mapFile(f *os.File, size int64) ([]byte, func() error, error) {
+	return nil, nil, errMmapUnsupported
+}
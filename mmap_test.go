@@ -0,0 +1,98 @@
+package curlhttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// TestFileSinkMappedBody tests random access and release of a mapped body
+func TestFileSinkMappedBody(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := newFileSink(dir)
+	if err != nil {
+		t.Fatalf("newFileSink failed: %v", err)
+	}
+
+	payload := bytes.Repeat([]byte("0123456789"), 1000)
+	for i := 0; i < len(payload); i += 333 {
+		end := i + 333
+		if end > len(payload) {
+			end = len(payload)
+		}
+		if !writeDataToFile(payload[i:end], sink) {
+			t.Fatal("writeDataToFile rejected data")
+		}
+	}
+
+	body, err := sink.mappedBody()
+	if err != nil {
+		t.Fatalf("mappedBody failed: %v", err)
+	}
+	if body.Size() != int64(len(payload)) {
+		t.Errorf("Expected size %d, got %d", len(payload), body.Size())
+	}
+
+	buf := make([]byte, 10)
+	if _, err := body.ReadAt(buf, 5005); err != nil {
+		t.Fatalf("ReadAt failed: %v", err)
+	}
+	if string(buf) != "5678901234" {
+		t.Errorf("Unexpected ReadAt data %q", buf)
+	}
+
+	all, err := io.ReadAll(body)
+	if err != nil || !bytes.Equal(all, payload) {
+		t.Errorf("Sequential read mismatch (err=%v)", err)
+	}
+
+	if err := body.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := body.ReadAt(buf, 0); !errors.Is(err, http.ErrBodyReadAfterClose) {
+		t.Errorf("Expected ErrBodyReadAfterClose after Close, got %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Expected temp file to be removed, found %d entries", len(entries))
+	}
+}
+
+// TestMmapResponsesRoundTrip tests a mapped response through the fake engine
+func TestMmapResponsesRoundTrip(t *testing.T) {
+	fake := newFakeEngine("mapped payload")
+	transport := newFakeTransport(fake)
+	transport.MmapResponses = true
+	transport.MmapTempDir = t.TempDir()
+
+	req, _ := http.NewRequest("GET", "http://example.com/archive.zip", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	ra, size, ok := BodyReaderAt(resp)
+	if !ok {
+		t.Fatal("Expected BodyReaderAt to succeed for mapped response")
+	}
+	if size != int64(len("mapped payload")) || resp.ContentLength != size {
+		t.Errorf("Unexpected size %d / ContentLength %d", size, resp.ContentLength)
+	}
+	buf := make([]byte, 7)
+	if _, err := ra.ReadAt(buf, 7); err != nil || string(buf) != "payload" {
+		t.Errorf("Unexpected ReadAt result %q (err=%v)", buf, err)
+	}
+}
+
+// TestBodyReaderAtInMemory tests that regular responses are not reported as mapped
+func TestBodyReaderAtInMemory(t *testing.T) {
+	resp := &http.Response{Body: newResponseBody([]byte("x"))}
+	if _, _, ok := BodyReaderAt(resp); ok {
+		t.Error("Expected in-memory body not to be reported as mapped")
+	}
+}
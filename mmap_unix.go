@@ -0,0 +1,25 @@
+//go:build unix
+
+package curlhttp
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps size bytes of f read-only into memory
+func mapFile(f *os.File, size int64) ([]byte, func() error, error) {
+	if size == 0 {
+		// mmap rejects empty mappings
+		return []byte{}, func() error { return nil }, nil
+	}
+	if int64(int(size)) != size {
+		// Too large for the address space; read from the file instead
+		return nil, nil, errMmapUnsupported
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
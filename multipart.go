@@ -0,0 +1,401 @@
+package curlhttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// readFuncAbort is CURL_READFUNC_ABORT, which makes curl abort the transfer
+const readFuncAbort = 0x10000000
+
+// MultipartForm builds a multipart/form-data body the way the impersonated
+// browser does: its boundary style, parts in the order they were added and
+// browser escaping of names. Requests created with NewRequest stream the
+// form to curl through its read callback while it is sent, opening files
+// only when their part is reached, so large uploads are never held in
+// memory. The binding does not expose curl's mime API, so the body is
+// encoded here rather than by curl.
+type MultipartForm struct {
+	boundary string
+	parts    []formPart
+	err      error
+}
+
+// formPart is one field or file of a MultipartForm
+type formPart struct {
+	header string // boundary line and part headers
+	value  string // field value
+	path   string // file to read, opened lazily
+	reader io.Reader
+	size   int64 // -1 if unknown
+}
+
+// NewMultipartForm starts a form with a boundary in the style of target's
+// browser: WebKitFormBoundary for Chrome, Edge and Safari, and
+// geckoformboundary for Firefox, unless RegisterFormBoundary replaced it
+func NewMultipartForm(target string) *MultipartForm {
+	return &MultipartForm{boundary: formBoundary(target)}
+}
+
+var (
+	formBoundariesMu sync.RWMutex
+	formBoundaries   = map[string]func() string{}
+)
+
+// RegisterFormBoundary replaces the boundary generator used for target,
+// either a full target such as "chrome136" or a browser family such as
+// "firefox", by NewMultipartForm and the Transport's boundary rewriting.
+// Register one when a browser release changes its boundary format.
+func RegisterFormBoundary(target string, generate func() string) {
+	formBoundariesMu.Lock()
+	defer formBoundariesMu.Unlock()
+	formBoundaries[target] = generate
+}
+
+// formBoundary generates a boundary in the style of target's browser
+func formBoundary(target string) string {
+	family, _, _ := splitTarget(target)
+	formBoundariesMu.RLock()
+	generate, ok := formBoundaries[target]
+	if !ok {
+		generate, ok = formBoundaries[family]
+	}
+	if !ok {
+		generate, ok = formBoundaries[browserFamily(target)]
+	}
+	formBoundariesMu.RUnlock()
+	if ok {
+		return generate()
+	}
+
+	if browserFamily(target) == "firefox" {
+		b := make([]byte, 16)
+		rand.Read(b)
+		return "----geckoformboundary" + hex.EncodeToString(b)
+	}
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, 16)
+	rand.Read(b)
+	for i := range b {
+		b[i] = chars[int(b[i])%len(chars)]
+	}
+	return "----WebKitFormBoundary" + string(b)
+}
+
+// escapeFormName escapes a field name or filename as browsers do
+var escapeFormName = strings.NewReplacer("\r", "%0D", "\n", "%0A", `"`, "%22").Replace
+
+// Field adds a text field. Line breaks in value are normalized to CRLF.
+func (f *MultipartForm) Field(name, value string) *MultipartForm {
+	value = strings.ReplaceAll(strings.ReplaceAll(value, "\r\n", "\n"), "\n", "\r\n")
+	header := fmt.Sprintf("Content-Disposition: form-data; name=\"%s\"\r\n\r\n", escapeFormName(name))
+	f.parts = append(f.parts, formPart{header: header, value: value, size: int64(len(value))})
+	return f
+}
+
+// File adds the file at path as a file part named after its base name,
+// with a Content-Type guessed from its extension. Errors surface from
+// NewRequest.
+func (f *MultipartForm) File(name, path string) *MultipartForm {
+	info, err := os.Stat(path)
+	if err != nil {
+		if f.err == nil {
+			f.err = fmt.Errorf("failed to add file %s: %w", path, err)
+		}
+		return f
+	}
+	filename := filepath.Base(path)
+	f.parts = append(f.parts, formPart{
+		header: fileHeader(name, filename, ""),
+		path:   path,
+		size:   info.Size(),
+	})
+	return f
+}
+
+// Reader adds a file part read from r. A negative size means unknown,
+// which makes the form's size unknown and the upload chunked. An empty
+// contentType is guessed from filename. Forms with Reader parts cannot be
+// replayed, so their requests are not retried or redirected with a body.
+func (f *MultipartForm) Reader(name, filename, contentType string, r io.Reader, size int64) *MultipartForm {
+	if size < 0 {
+		size = -1
+	}
+	f.parts = append(f.parts, formPart{
+		header: fileHeader(name, filename, contentType),
+		reader: r,
+		size:   size,
+	})
+	return f
+}
+
+// fileHeader returns the headers of a file part
+func fileHeader(name, filename, contentType string) string {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return fmt.Sprintf("Content-Disposition: form-data; name=\"%s\"; filename=\"%s\"\r\nContent-Type: %s\r\n\r\n",
+		escapeFormName(name), escapeFormName(filename), contentType)
+}
+
+// Boundary returns the form's boundary
+func (f *MultipartForm) Boundary() string {
+	return f.boundary
+}
+
+// ContentType returns the Content-Type header value for the form
+func (f *MultipartForm) ContentType() string {
+	return "multipart/form-data; boundary=" + f.boundary
+}
+
+// Size returns the encoded size of the form, or -1 if a part's size is
+// unknown
+func (f *MultipartForm) Size() int64 {
+	size := int64(len(f.closing()))
+	for _, p := range f.parts {
+		if p.size < 0 {
+			return -1
+		}
+		size += int64(len(f.opening())+len(p.header)) + p.size + 2
+	}
+	return size
+}
+
+// opening is the boundary line that starts each part
+func (f *MultipartForm) opening() string {
+	return "--" + f.boundary + "\r\n"
+}
+
+// closing ends the form
+func (f *MultipartForm) closing() string {
+	return "--" + f.boundary + "--\r\n"
+}
+
+// replayable reports whether the form can be encoded more than once
+func (f *MultipartForm) replayable() bool {
+	for _, p := range f.parts {
+		if p.reader != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// NewRequest returns a request that uploads the form. Transport streams
+// its body to curl instead of buffering it.
+func (f *MultipartForm) NewRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Body = f.body()
+	req.ContentLength = f.Size()
+	if f.replayable() {
+		req.GetBody = func() (io.ReadCloser, error) {
+			return f.body(), nil
+		}
+	}
+	req.Header.Set("Content-Type", f.ContentType())
+	return req, nil
+}
+
+// body returns a reader that encodes the form
+func (f *MultipartForm) body() *multipartBody {
+	b := &multipartBody{}
+	readers := make([]io.Reader, 0, 3*len(f.parts)+1)
+	for _, p := range f.parts {
+		readers = append(readers, strings.NewReader(f.opening()+p.header))
+		switch {
+		case p.path != "":
+			file := &lazyFile{path: p.path}
+			b.files = append(b.files, file)
+			readers = append(readers, file)
+		case p.reader != nil:
+			readers = append(readers, p.reader)
+		default:
+			readers = append(readers, strings.NewReader(p.value))
+		}
+		readers = append(readers, strings.NewReader("\r\n"))
+	}
+	readers = append(readers, strings.NewReader(f.closing()))
+	b.r = io.MultiReader(readers...)
+	return b
+}
+
+// multipartBody is the Body of MultipartForm requests
+type multipartBody struct {
+	r     io.Reader
+	files []*lazyFile
+}
+
+func (b *multipartBody) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+// Close closes any file still open
+func (b *multipartBody) Close() error {
+	var errs []error
+	for _, file := range b.files {
+		errs = append(errs, file.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// lazyFile opens its file on the first Read and closes it at EOF
+type lazyFile struct {
+	path string
+	file *os.File
+	done bool
+}
+
+func (l *lazyFile) Read(p []byte) (int, error) {
+	if l.done {
+		return 0, io.EOF
+	}
+	if l.file == nil {
+		file, err := os.Open(l.path)
+		if err != nil {
+			return 0, err
+		}
+		l.file = file
+	}
+	n, err := l.file.Read(p)
+	if err == io.EOF {
+		l.Close()
+	}
+	return n, err
+}
+
+func (l *lazyFile) Close() error {
+	l.done = true
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// streamedBody is implemented by Body types that are produced while curl
+// sends them, such as multipart forms, and must not be buffered
+type streamedBody interface {
+	io.ReadCloser
+	streamed()
+}
+
+func (b *multipartBody) streamed() {}
+
+// streamedUpload returns the body of req if it is streamed to curl rather
+// than buffered
+func streamedUpload(req *http.Request) (io.ReadCloser, bool) {
+	b, ok := req.Body.(streamedBody)
+	return b, ok
+}
+
+// defaultStreamUploadThreshold is the default StreamUploadThreshold
+const defaultStreamUploadThreshold = 64 << 20
+
+// streamsUpload reports whether the body of req is streamed to curl:
+// multipart forms, bodies of unknown length, which are sent with chunked
+// transfer coding, and bodies whose known length exceeds
+// StreamUploadThreshold
+func (t *Transport) streamsUpload(req *http.Request) bool {
+	if _, ok := streamedUpload(req); ok {
+		return true
+	}
+	if req.Body == nil || req.Body == http.NoBody || t.StreamUploadThreshold < 0 {
+		return false
+	}
+	threshold := t.StreamUploadThreshold
+	if threshold == 0 {
+		threshold = defaultStreamUploadThreshold
+	}
+	return req.ContentLength < 0 || req.ContentLength > threshold
+}
+
+// uploadSource feeds a streamed request body to curl's read callback
+type uploadSource struct {
+	r   io.Reader
+	err error
+}
+
+// readUpload is the callback function for reading a streamed request body
+func readUpload(ptr []byte, userdata interface{}) int {
+	src, ok := userdata.(*uploadSource)
+	if !ok {
+		return readFuncAbort
+	}
+	n, err := io.ReadFull(src.r, ptr)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		src.err = err
+		return readFuncAbort
+	}
+	return n
+}
+
+// isGoBoundary reports whether boundary has the format of the random
+// boundaries of mime/multipart.Writer: 60 lowercase hex digits
+func isGoBoundary(boundary string) bool {
+	if len(boundary) != 60 {
+		return false
+	}
+	for i := 0; i < len(boundary); i++ {
+		if c := boundary[i]; !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// mimicFormBoundary rewrites multipart/form-data bodies built with
+// mime/multipart.Writer to use a boundary in the style of the target's
+// browser, since Go's random hex boundary gives the client away. Only
+// buffered bodies are rewritten.
+func (t *Transport) mimicFormBoundary(req *http.Request) (*http.Request, error) {
+	if t.KeepFormBoundary || req.Body == nil || req.Body == http.NoBody || t.streamsUpload(req) {
+		return req, nil
+	}
+	contentType := req.Header.Get("Content-Type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" || !isGoBoundary(params["boundary"]) {
+		return req, nil
+	}
+	target, err := t.requestTarget(req)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	old, boundary := params["boundary"], formBoundary(target)
+	data = bytes.ReplaceAll(data, []byte("--"+old), []byte("--"+boundary))
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Content-Type", strings.Replace(contentType, old, boundary, 1))
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return req, nil
+}
@@ -0,0 +1,257 @@
+package curlhttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// readForm encodes form and parses it back
+func readForm(t *testing.T, form *MultipartForm) (*multipart.Form, []byte) {
+	t.Helper()
+	data, err := io.ReadAll(form.body())
+	if err != nil {
+		t.Fatalf("Failed to encode form: %v", err)
+	}
+	_, params, _ := mime.ParseMediaType(form.ContentType())
+	parsed, err := multipart.NewReader(bytes.NewReader(data), params["boundary"]).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("Failed to parse form: %v", err)
+	}
+	return parsed, data
+}
+
+// TestMultipartFormEncoding tests fields, files and the reported size
+func TestMultipartFormEncoding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.txt")
+	os.WriteFile(path, []byte("file contents"), 0o644)
+
+	form := NewMultipartForm("chrome136").
+		Field("title", "line one\nline two").
+		File("upload", path).
+		Reader("blob", "data.bin", "", strings.NewReader("xyz"), 3)
+
+	parsed, data := readForm(t, form)
+	if got := parsed.Value["title"]; len(got) != 1 || got[0] != "line one\r\nline two" {
+		t.Errorf("Expected CRLF normalized field, got %q", got)
+	}
+	file := parsed.File["upload"][0]
+	if file.Filename != "report.txt" || !strings.HasPrefix(file.Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("Expected report.txt as text/plain, got %s as %s", file.Filename, file.Header.Get("Content-Type"))
+	}
+	if blob := parsed.File["blob"][0]; blob.Header.Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("Expected octet-stream for unknown extension, got %s", blob.Header.Get("Content-Type"))
+	}
+	if form.Size() != int64(len(data)) {
+		t.Errorf("Expected size %d, got %d", len(data), form.Size())
+	}
+
+	// Parts keep the order they were added in
+	if i, j := bytes.Index(data, []byte(`name="title"`)), bytes.Index(data, []byte(`name="upload"`)); i > j {
+		t.Error("Expected title before upload")
+	}
+}
+
+// TestMultipartFormBoundary tests browser boundary styles and name escaping
+func TestMultipartFormBoundary(t *testing.T) {
+	if b := NewMultipartForm("chrome136").Boundary(); !strings.HasPrefix(b, "----WebKitFormBoundary") || len(b) != 38 {
+		t.Errorf("Expected a WebKit boundary, got %s", b)
+	}
+	if b := NewMultipartForm("firefox135").Boundary(); !strings.HasPrefix(b, "----geckoformboundary") {
+		t.Errorf("Expected a gecko boundary, got %s", b)
+	}
+
+	form := NewMultipartForm("chrome136").Reader("a\"b", "x\ny.txt", "", strings.NewReader(""), 0)
+	data, _ := io.ReadAll(form.body())
+	if !bytes.Contains(data, []byte(`name="a%22b"; filename="x%0Ay.txt"`)) {
+		t.Errorf("Expected escaped names, got %q", data)
+	}
+
+	if _, err := NewMultipartForm("chrome136").File("f", "/nonexistent/file").NewRequest(context.Background(), "POST", "http://example.com"); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+// TestMultipartFormUpload tests that the Transport streams the form to curl
+func TestMultipartFormUpload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.bin")
+	os.WriteFile(path, bytes.Repeat([]byte("0123456789"), 1000), 0o644)
+
+	fake := newFakeEngine("ok")
+	transport := newFakeTransport(fake)
+	form := NewMultipartForm("chrome136").Field("name", "value").File("file", path)
+	req, err := form.NewRequest(context.Background(), "POST", "https://example.com/upload")
+	if err != nil {
+		t.Fatalf("NewRequest failed: %v", err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+
+	want, _ := io.ReadAll(form.body())
+	if !bytes.Equal(fake.uploaded, want) {
+		t.Errorf("Expected %d uploaded bytes matching the form, got %d", len(want), len(fake.uploaded))
+	}
+	if size := fake.performed[curl.OPT_POSTFIELDSIZE_LARGE]; size != form.Size() {
+		t.Errorf("Expected upload size %d, got %v", form.Size(), size)
+	}
+	if _, ok := fake.performed[curl.OPT_POSTFIELDS]; ok {
+		t.Error("Expected the body not to be buffered into POSTFIELDS")
+	}
+	if !strings.HasPrefix(sentHeaders(fake)["Content-Type"], "multipart/form-data; boundary=----WebKitFormBoundary") {
+		t.Errorf("Expected multipart Content-Type, got %q", sentHeaders(fake)["Content-Type"])
+	}
+	if fake.resets == 0 {
+		t.Error("Expected the handle to be reset after a streamed upload")
+	}
+
+	// Unknown sizes are sent chunked
+	form = NewMultipartForm("chrome136").Reader("r", "r.txt", "", strings.NewReader("abc"), -1)
+	req, _ = form.NewRequest(context.Background(), "POST", "https://example.com/upload")
+	resp, err = transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+	if sentHeaders(fake)["Transfer-Encoding"] != "chunked" {
+		t.Error("Expected a chunked upload for an unknown size")
+	}
+	if req.GetBody != nil {
+		t.Error("Expected forms with readers not to be replayable")
+	}
+}
+
+// zeroReader reads endless zero bytes without touching the buffer
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// TestLargeUpload tests that bodies over 4 GiB are streamed with 64-bit
+// sizes, or chunked when their length is unknown
+func TestLargeUpload(t *testing.T) {
+	const size = 5 << 30
+	for _, length := range []int64{size, -1} {
+		for _, method := range []string{"POST", "PUT", "PATCH"} {
+			fake := newFakeEngine("ok")
+			fake.countOnly = true
+			transport := newFakeTransport(fake)
+			req, _ := http.NewRequest(method, "https://example.com/upload", nil)
+			req.Body = io.NopCloser(io.LimitReader(zeroReader{}, size))
+			req.ContentLength = length
+
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("%s: RoundTrip failed: %v", method, err)
+			}
+			resp.Body.Close()
+			if fake.uploadSize != size {
+				t.Errorf("%s: Expected %d bytes to be uploaded, got %d", method, int64(size), fake.uploadSize)
+			}
+			if got := fake.performed[curl.OPT_POSTFIELDSIZE_LARGE]; got != length {
+				t.Errorf("%s: Expected upload size %d, got %v", method, length, got)
+			}
+			if _, ok := fake.performed[curl.OPT_POSTFIELDS]; ok {
+				t.Errorf("%s: Expected the body not to be buffered into POSTFIELDS", method)
+			}
+		}
+	}
+}
+
+// TestStreamUploadThreshold tests which bodies are buffered and which streamed
+func TestStreamUploadThreshold(t *testing.T) {
+	fake := newFakeEngine("ok")
+	transport := newFakeTransport(fake)
+	transport.StreamUploadThreshold = 8
+
+	for body, streamed := range map[string]bool{"small": false, "larger than eight": true} {
+		req, _ := http.NewRequest("POST", "https://example.com/", strings.NewReader(body))
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		resp.Body.Close()
+		buffered := fake.performed[curl.OPT_POSTFIELDS] != nil
+		if buffered == streamed {
+			t.Errorf("Expected %q to be streamed: %v", body, streamed)
+		}
+		if got := fake.performed[curl.OPT_POSTFIELDSIZE_LARGE]; got != int64(len(body)) {
+			t.Errorf("Expected size %d for %q, got %v", len(body), body, got)
+		}
+		if streamed && string(fake.uploaded) != body {
+			t.Errorf("Expected %q to be uploaded, got %q", body, fake.uploaded)
+		}
+	}
+}
+
+// TestMimicFormBoundary tests that mime/multipart boundaries are rewritten in the target's style
+func TestMimicFormBoundary(t *testing.T) {
+	build := func() *http.Request {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		w.WriteField("name", "value")
+		w.Close()
+		req, _ := http.NewRequest("POST", "https://example.com/upload", &buf)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		return req
+	}
+	fake := newFakeEngine("ok")
+	transport := newFakeTransport(fake)
+	resp, err := transport.RoundTrip(build())
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+
+	_, params, _ := mime.ParseMediaType(sentHeaders(fake)["Content-Type"])
+	boundary := params["boundary"]
+	if !strings.HasPrefix(boundary, "----WebKitFormBoundary") {
+		t.Fatalf("Expected a WebKit boundary, got %q", boundary)
+	}
+	body, _ := fake.performed[curl.OPT_POSTFIELDS].([]byte)
+	parsed, err := multipart.NewReader(bytes.NewReader(body), boundary).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("Failed to parse rewritten body: %v", err)
+	}
+	if got := parsed.Value["name"]; len(got) != 1 || got[0] != "value" {
+		t.Errorf("Expected field to survive the rewrite, got %v", got)
+	}
+	if size := fake.performed[curl.OPT_POSTFIELDSIZE_LARGE]; size != int64(len(body)) {
+		t.Errorf("Expected upload size %d, got %v", len(body), size)
+	}
+
+	// Registered generators apply to their family
+	RegisterFormBoundary("firefox", func() string { return "----custom" })
+	defer func() {
+		formBoundariesMu.Lock()
+		delete(formBoundaries, "firefox")
+		formBoundariesMu.Unlock()
+	}()
+	req := build()
+	resp, _ = transport.RoundTrip(req.WithContext(WithTarget(req.Context(), "firefox135")))
+	resp.Body.Close()
+	if got := sentHeaders(fake)["Content-Type"]; got != "multipart/form-data; boundary=----custom" {
+		t.Errorf("Expected the registered boundary, got %q", got)
+	}
+
+	transport.KeepFormBoundary = true
+	req = build()
+	want := req.Header.Get("Content-Type")
+	resp, _ = transport.RoundTrip(req)
+	resp.Body.Close()
+	if got := sentHeaders(fake)["Content-Type"]; got != want {
+		t.Errorf("Expected the boundary kept, got %q", got)
+	}
+}
@@ -0,0 +1,251 @@
+package curlhttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ResourceType is the kind of resource a browser is loading, which decides
+// the Sec-Fetch-* and Accept headers it sends
+type ResourceType int
+
+const (
+	// ResourceDocument is a top-level page navigation
+	ResourceDocument ResourceType = iota + 1
+
+	// ResourceXHR is an XMLHttpRequest made by a page
+	ResourceXHR
+
+	// ResourceFetch is a fetch() call made by a page
+	ResourceFetch
+
+	// ResourceImage is an image loaded by a page
+	ResourceImage
+)
+
+// String returns the Sec-Fetch-Dest style name of the resource type
+func (r ResourceType) String() string {
+	switch r {
+	case ResourceDocument:
+		return "document"
+	case ResourceXHR:
+		return "xhr"
+	case ResourceFetch:
+		return "fetch"
+	case ResourceImage:
+		return "image"
+	default:
+		return "unknown"
+	}
+}
+
+// Navigation describes how the browser being impersonated came to make a
+// request. Attach it with WithNavigation.
+type Navigation struct {
+	Type ResourceType
+
+	// Referrer is the URL of the page making the request, or of the page a
+	// link was followed from. Empty means the URL was typed or bookmarked.
+	Referrer string
+
+	// UserActivated marks document navigations triggered by a click or
+	// key press, which browsers flag with Sec-Fetch-User.
+	UserActivated bool
+}
+
+type navigationKey struct{}
+
+// WithNavigation returns a context that makes the Transport send the
+// headers the impersonated browser would for nav: Sec-Fetch-Site,
+// Sec-Fetch-Mode, Sec-Fetch-Dest, Sec-Fetch-User, Referer, Origin, Accept
+// and Upgrade-Insecure-Requests. Headers set on the request take
+// precedence.
+func WithNavigation(ctx context.Context, nav Navigation) context.Context {
+	return context.WithValue(ctx, navigationKey{}, nav)
+}
+
+// navigationFrom returns the Navigation attached to ctx
+func navigationFrom(ctx context.Context) (Navigation, bool) {
+	nav, ok := ctx.Value(navigationKey{}).(Navigation)
+	return nav, ok && nav.Type != 0
+}
+
+// browserFamily groups targets by the engine whose headers they copy
+func browserFamily(target string) string {
+	switch {
+	case strings.HasPrefix(target, "firefox"), strings.HasPrefix(target, "tor"):
+		return "firefox"
+	case strings.HasPrefix(target, "safari"):
+		return "safari"
+	default:
+		return "chrome"
+	}
+}
+
+// navigationAccept holds the Accept header per browser family and resource
+// type; XHR and fetch use */* everywhere
+var navigationAccept = map[string]map[ResourceType]string{
+	"chrome": {
+		ResourceDocument: "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+		ResourceImage:    "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8",
+	},
+	"firefox": {
+		ResourceDocument: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		ResourceImage:    "image/avif,image/webp,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5",
+	},
+	"safari": {
+		ResourceDocument: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		ResourceImage:    "image/webp,image/avif,image/jxl,image/heic,image/heic-sequence,video/*;q=0.8,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5",
+	},
+}
+
+// navigationHeaders computes the headers for a request made as nav by a
+// browser impersonated with target. An empty value removes a header the
+// impersonation profile would otherwise add.
+func navigationHeaders(req *http.Request, nav Navigation, target string) map[string]string {
+	headers := make(map[string]string)
+	referrer, _ := url.Parse(nav.Referrer)
+	if nav.Referrer == "" {
+		referrer = nil
+	}
+
+	site := "none"
+	if referrer != nil {
+		site = fetchSite(referrer, req.URL)
+	}
+	headers["Sec-Fetch-Site"] = site
+
+	accept := navigationAccept[browserFamily(target)][nav.Type]
+	if accept == "" {
+		accept = "*/*"
+	}
+	headers["Accept"] = accept
+
+	switch nav.Type {
+	case ResourceDocument:
+		headers["Sec-Fetch-Mode"] = "navigate"
+		headers["Sec-Fetch-Dest"] = "document"
+		headers["Upgrade-Insecure-Requests"] = "1"
+		if nav.UserActivated {
+			headers["Sec-Fetch-User"] = "?1"
+		} else {
+			headers["Sec-Fetch-User"] = ""
+		}
+	case ResourceImage:
+		headers["Sec-Fetch-Mode"] = "no-cors"
+		headers["Sec-Fetch-Dest"] = "image"
+	default:
+		headers["Sec-Fetch-Mode"] = "cors"
+		headers["Sec-Fetch-Dest"] = "empty"
+	}
+	if nav.Type != ResourceDocument {
+		headers["Sec-Fetch-User"] = ""
+		headers["Upgrade-Insecure-Requests"] = ""
+	}
+
+	if referrer != nil {
+		if ref := referrerFor(referrer, req.URL); ref != "" {
+			headers["Referer"] = ref
+		}
+		// CORS requests carry Origin when cross-origin, and every
+		// request that is not GET or HEAD carries it
+		unsafe := req.Method != "" && req.Method != http.MethodGet && req.Method != http.MethodHead
+		cors := nav.Type == ResourceXHR || nav.Type == ResourceFetch
+		if (cors && site != "same-origin") || unsafe {
+			headers["Origin"] = origin(referrer)
+		}
+	}
+	return headers
+}
+
+// applyNavigation adds the headers for the request's Navigation, if any, to
+// headers without overriding ones the request set
+func (t *Transport) applyNavigation(req *http.Request, headers map[string]string) {
+	nav, ok := navigationFrom(req.Context())
+	if !ok {
+		return
+	}
+	target, _ := t.requestTarget(req)
+	for name, value := range navigationHeaders(req, nav, target) {
+		if _, set := headers[name]; !set {
+			headers[name] = value
+		}
+	}
+}
+
+// origin serializes the origin of u
+func origin(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// sameOrigin reports whether a and b have the same scheme, host and port
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(hostPort(a), hostPort(b))
+}
+
+// hostPort returns u's host with the scheme's default port made explicit
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// fetchSite computes Sec-Fetch-Site for a request to target made from from
+func fetchSite(from, target *url.URL) string {
+	switch {
+	case sameOrigin(from, target):
+		return "same-origin"
+	case strings.EqualFold(from.Scheme, target.Scheme) && registrableDomain(from.Hostname()) == registrableDomain(target.Hostname()):
+		return "same-site"
+	default:
+		return "cross-site"
+	}
+}
+
+// secondLevelLabels are common second-level labels under country code TLDs,
+// such as the "co" in example.co.uk
+var secondLevelLabels = map[string]bool{
+	"ac": true, "co": true, "com": true, "edu": true, "gov": true, "net": true, "or": true, "org": true, "ne": true,
+}
+
+// registrableDomain approximates the site of host (its eTLD+1) without a
+// public suffix list: the last two labels, or three under a two-letter
+// country code TLD with a common second-level label
+func registrableDomain(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	labels := strings.Split(host, ".")
+	n := 2
+	if len(labels) >= 3 && len(labels[len(labels)-1]) == 2 && secondLevelLabels[labels[len(labels)-2]] {
+		n = 3
+	}
+	if len(labels) <= n {
+		return host
+	}
+	return strings.Join(labels[len(labels)-n:], ".")
+}
+
+// referrerFor applies the default strict-origin-when-cross-origin referrer
+// policy: the full URL for same-origin requests, the origin for
+// cross-origin ones and nothing when going from HTTPS to HTTP
+func referrerFor(from, target *url.URL) string {
+	if from.Scheme == "https" && target.Scheme != "https" {
+		return ""
+	}
+	if !sameOrigin(from, target) {
+		return origin(from) + "/"
+	}
+	ref := *from
+	ref.Fragment, ref.RawFragment, ref.User = "", "", nil
+	return ref.String()
+}
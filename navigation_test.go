@@ -0,0 +1,121 @@
+package curlhttp
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// navigationRequest builds a request carrying nav
+func navigationRequest(method, target string, nav Navigation) *http.Request {
+	req, _ := http.NewRequestWithContext(WithNavigation(context.Background(), nav), method, target, nil)
+	return req
+}
+
+// TestNavigationHeaders tests the headers derived for each resource type
+func TestNavigationHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    *http.Request
+		target string
+		want   map[string]string
+	}{
+		{
+			name:   "typed document",
+			req:    navigationRequest("GET", "https://shop.example.com/", Navigation{Type: ResourceDocument, UserActivated: true}),
+			target: "chrome136",
+			want: map[string]string{
+				"Sec-Fetch-Site": "none", "Sec-Fetch-Mode": "navigate", "Sec-Fetch-Dest": "document",
+				"Sec-Fetch-User": "?1", "Upgrade-Insecure-Requests": "1",
+			},
+		},
+		{
+			name:   "same-origin xhr",
+			req:    navigationRequest("GET", "https://shop.example.com/api/cart", Navigation{Type: ResourceXHR, Referrer: "https://shop.example.com/cart#top"}),
+			target: "chrome136",
+			want: map[string]string{
+				"Sec-Fetch-Site": "same-origin", "Sec-Fetch-Mode": "cors", "Sec-Fetch-Dest": "empty",
+				"Referer": "https://shop.example.com/cart", "Accept": "*/*", "Sec-Fetch-User": "",
+			},
+		},
+		{
+			name:   "same-site image",
+			req:    navigationRequest("GET", "https://img.example.co.uk/a.png", Navigation{Type: ResourceImage, Referrer: "https://www.example.co.uk/page"}),
+			target: "firefox133",
+			want: map[string]string{
+				"Sec-Fetch-Site": "same-site", "Sec-Fetch-Mode": "no-cors", "Sec-Fetch-Dest": "image",
+				"Referer": "https://www.example.co.uk/", "Accept": navigationAccept["firefox"][ResourceImage],
+				"Upgrade-Insecure-Requests": "",
+			},
+		},
+		{
+			name:   "cross-site fetch POST",
+			req:    navigationRequest("POST", "https://api.other.com/v1", Navigation{Type: ResourceFetch, Referrer: "https://shop.example.com/checkout"}),
+			target: "safari18_0",
+			want: map[string]string{
+				"Sec-Fetch-Site": "cross-site", "Origin": "https://shop.example.com", "Referer": "https://shop.example.com/",
+			},
+		},
+	}
+	for _, tt := range tests {
+		nav, _ := navigationFrom(tt.req.Context())
+		got := navigationHeaders(tt.req, nav, tt.target)
+		for name, value := range tt.want {
+			if got[name] != value {
+				t.Errorf("%s: expected %s %q, got %q", tt.name, name, value, got[name])
+			}
+		}
+	}
+}
+
+// TestNavigationDowngradeReferrer tests that HTTPS referrers are not sent to HTTP
+func TestNavigationDowngradeReferrer(t *testing.T) {
+	req := navigationRequest("GET", "http://plain.example.com/", Navigation{Type: ResourceDocument, Referrer: "https://secure.example.com/"})
+	nav, _ := navigationFrom(req.Context())
+	if ref, ok := navigationHeaders(req, nav, "chrome136")["Referer"]; ok {
+		t.Errorf("Expected no Referer on downgrade, got %q", ref)
+	}
+}
+
+// TestNavigationRequestHeadersWin tests that explicit request headers are kept
+func TestNavigationRequestHeadersWin(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+
+	req := navigationRequest("GET", "https://example.com/", Navigation{Type: ResourceImage})
+	req.Header.Set("Accept", "image/png")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+
+	sent := map[string]bool{}
+	for _, line := range fake.performed[curl.OPT_HTTPHEADER].([]string) {
+		sent[line] = true
+	}
+	for _, line := range []string{"Accept: image/png", "Sec-Fetch-Dest: image", "Sec-Fetch-User: "} {
+		if !sent[line] {
+			t.Errorf("Expected %q to be sent, got %v", line, sent)
+		}
+	}
+}
+
+// TestRegistrableDomain tests the site approximation
+func TestRegistrableDomain(t *testing.T) {
+	tests := map[string]string{
+		"www.example.com":    "example.com",
+		"a.b.example.co.uk":  "example.co.uk",
+		"example.com":        "example.com",
+		"192.168.1.1":        "192.168.1.1",
+		"cdn.example.de":     "example.de",
+		"Static.Example.COM": "example.com",
+	}
+	for host, want := range tests {
+		if got := registrableDomain(host); got != want {
+			t.Errorf("registrableDomain(%s): expected %s, got %s", host, want, got)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package curlhttp
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// requestError is the error of a failed transfer. Like the errors of
+// net/http it is a net.Error, so url.Error.Timeout and net.Error checks
+// written for net/http keep working.
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string {
+	return "request failed: " + e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// Timeout reports whether the transfer timed out
+func (e *requestError) Timeout() bool {
+	var netErr net.Error
+	return errors.As(e.err, &netErr) && netErr.Timeout()
+}
+
+// Temporary matches Timeout, as for net package errors
+func (e *requestError) Temporary() bool {
+	return e.Timeout()
+}
+
+// transferError pairs a curl error with the error the net package reports
+// for the same failure, so errors.As finds the curl.CurlError, such as
+// curl.CurlError(curl.E_COULDNT_CONNECT), and errors.Is(err,
+// syscall.ECONNREFUSED) holds
+type transferError struct {
+	curl error
+	net  error
+}
+
+func (e *transferError) Error() string {
+	return e.curl.Error()
+}
+
+func (e *transferError) Unwrap() []error {
+	return []error{e.net, e.curl}
+}
+
+// requestFailed returns the error of a transfer of req on easy, made
+// through proxy unless it is nil, that failed with performErr
+func (t *Transport) requestFailed(req *http.Request, easy curlEngine, proxy *url.URL, performErr error) error {
+	err := t.netError(proxy, req.URL.Hostname(), easy, checkDeadConn(easy, performErr))
+	return &requestError{err: t.checkProxyError(proxy, easy, err)}
+}
+
+// netError adds to err, from a transfer to host on easy through proxy
+// unless it is nil, the error the net package would have returned, as far
+// as curl tells what went wrong:
+//
+//   - resolution failures: a *net.OpError holding a *net.DNSError
+//   - refused or unreachable connections: a *net.OpError holding an
+//     *os.SyscallError with the errno curl saw, such as syscall.ECONNREFUSED
+//   - timeouts: a *net.OpError holding os.ErrDeadlineExceeded
+//   - TLS handshake failures: a *net.OpError with Op "remote error", and
+//     a *tls.CertificateVerificationError when the certificate was rejected
+//
+// Other errors are returned unchanged.
+func (t *Transport) netError(proxy *url.URL, host string, easy curlEngine, err error) error {
+	var curlErr curl.CurlError
+	if !errors.As(err, &curlErr) {
+		return err
+	}
+	op := &net.OpError{Op: "dial", Net: "tcp", Addr: primaryAddr(easy)}
+	switch curlErr {
+	case curl.CurlError(curl.E_COULDNT_RESOLVE_HOST), curl.CurlError(curl.E_COULDNT_RESOLVE_PROXY):
+		name := host
+		if proxy != nil && curlErr == curl.CurlError(curl.E_COULDNT_RESOLVE_PROXY) {
+			name = proxy.Hostname()
+		}
+		// curl does not tell a missing name from a failed lookup
+		op.Err = &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	case curl.CurlError(curl.E_COULDNT_CONNECT):
+		op.Err = err
+		if errno := getinfoInt(easy, infoOSErrno); errno != 0 {
+			op.Err = os.NewSyscallError("connect", syscall.Errno(errno))
+		}
+	case curl.CurlError(curl.E_OPERATION_TIMEDOUT):
+		if getinfoDuration(easy, infoConnectTime) > 0 {
+			op.Op = "read"
+		}
+		op.Err = os.ErrDeadlineExceeded
+	case curl.CurlError(curl.E_PEER_FAILED_VERIFICATION):
+		op.Op = "remote error"
+		op.Err = &tls.CertificateVerificationError{Err: err}
+	case curl.CurlError(curl.E_SSL_CONNECT_ERROR):
+		op.Op = "remote error"
+		op.Err = err
+	default:
+		return err
+	}
+	return &transferError{curl: err, net: op}
+}
+
+// primaryAddr returns the address of the transfer's connection, or nil if
+// it got none
+func primaryAddr(easy curlEngine) net.Addr {
+	ip := net.ParseIP(getinfoString(easy, infoPrimaryIP))
+	if ip == nil {
+		return nil
+	}
+	return &net.TCPAddr{IP: ip, Port: int(getinfoInt(easy, infoPrimaryPort))}
+}
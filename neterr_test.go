@@ -0,0 +1,77 @@
+package curlhttp
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// failingClient returns a Client whose transfers fail with performErr
+func failingClient(performErr error, info map[curl.CurlInfo]interface{}) *Client {
+	fake := newFakeEngine("")
+	fake.performErr = performErr
+	fake.info = info
+	return &Client{Client: http.Client{Transport: newFakeTransport(fake)}}
+}
+
+// TestNetErrors tests that curl failures match the errors of net/http
+func TestNetErrors(t *testing.T) {
+	// Resolution failures carry a *net.DNSError for the host
+	_, err := failingClient(curl.CurlError(curl.E_COULDNT_RESOLVE_HOST), nil).Get("http://missing.example/")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || dnsErr.Name != "missing.example" || !dnsErr.IsNotFound {
+		t.Errorf("Expected a not-found DNSError for missing.example, got %v", err)
+	}
+	var curlErr curl.CurlError
+	if !errors.As(err, &curlErr) || curlErr != curl.CurlError(curl.E_COULDNT_RESOLVE_HOST) {
+		t.Errorf("Expected the curl error to stay reachable, got %v", err)
+	}
+
+	// Refused connections carry the errno curl saw
+	info := map[curl.CurlInfo]interface{}{
+		infoOSErrno:     int64(syscall.ECONNREFUSED),
+		infoPrimaryIP:   "127.0.0.1",
+		infoPrimaryPort: int64(8080),
+	}
+	_, err = failingClient(curl.CurlError(curl.E_COULDNT_CONNECT), info).Get("http://127.0.0.1:8080/")
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("Expected ECONNREFUSED, got %v", err)
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "dial" || opErr.Addr.String() != "127.0.0.1:8080" {
+		t.Errorf("Expected a dial OpError to 127.0.0.1:8080, got %v", err)
+	}
+
+	// Timeouts are net.Error timeouts, also through url.Error
+	_, err = failingClient(curl.CurlError(curl.E_OPERATION_TIMEDOUT), nil).Get("http://slow.example/")
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) || !urlErr.Timeout() {
+		t.Errorf("Expected a timeout url.Error, got %v", err)
+	}
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected os.ErrDeadlineExceeded, got %v", err)
+	}
+
+	// Rejected certificates carry a *tls.CertificateVerificationError
+	_, err = failingClient(curl.CurlError(curl.E_PEER_FAILED_VERIFICATION), nil).Get("https://self-signed.example/")
+	var certErr *tls.CertificateVerificationError
+	if !errors.As(err, &certErr) {
+		t.Errorf("Expected a CertificateVerificationError, got %v", err)
+	}
+	if urlErr, ok := err.(*url.Error); !ok || urlErr.Timeout() {
+		t.Errorf("Expected a non-timeout url.Error, got %v", err)
+	}
+
+	// Errors without a net equivalent are unchanged
+	_, err = failingClient(curl.CurlError(curl.E_WRITE_ERROR), nil).Get("http://example.com/")
+	if errors.As(err, &opErr) || !errors.As(err, &curlErr) || curlErr != curl.CurlError(curl.E_WRITE_ERROR) {
+		t.Errorf("Expected only the curl error, got %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+//go:build linux
+
+package curlhttp
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+	"golang.org/x/sys/unix"
+)
+
+// applySocketBinding binds the handle's sockets to BindDevice. curl treats
+// an "if!" interface name as a device and uses SO_BINDTODEVICE for it.
+func (t *Transport) applySocketBinding(easy curlEngine) error {
+	if t.BindDevice == "" {
+		return nil
+	}
+	if err := easy.Setopt(curl.OPT_INTERFACE, "if!"+t.BindDevice); err != nil {
+		return fmt.Errorf("failed to bind to device %s: %w", t.BindDevice, err)
+	}
+	return nil
+}
+
+// performInNamespace runs perform with the calling OS thread switched into
+// NetworkNamespace, so every socket curl opens belongs to that namespace.
+// The thread is switched back before it is released to other goroutines.
+func (t *Transport) performInNamespace(perform func() error) error {
+	if t.NetworkNamespace == "" {
+		return perform()
+	}
+
+	target, err := os.Open(t.NetworkNamespace)
+	if err != nil {
+		return fmt.Errorf("failed to open network namespace: %w", err)
+	}
+	defer target.Close()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	original, err := os.Open(fmt.Sprintf("/proc/%d/task/%d/ns/net", os.Getpid(), unix.Gettid()))
+	if err != nil {
+		return fmt.Errorf("failed to open current network namespace: %w", err)
+	}
+	defer original.Close()
+
+	if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+		return fmt.Errorf("failed to enter network namespace %s: %w", t.NetworkNamespace, err)
+	}
+	performErr := perform()
+	if err := unix.Setns(int(original.Fd()), unix.CLONE_NEWNET); err != nil {
+		// The thread is stuck in the wrong namespace; keep it locked so the
+		// runtime discards it when this goroutine exits.
+		runtime.LockOSThread()
+		return fmt.Errorf("failed to restore network namespace: %w", err)
+	}
+	return performErr
+}
@@ -0,0 +1,40 @@
+//go:build linux
+
+package curlhttp
+
+import (
+	"net/http"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// TestBindDevice tests that BindDevice is passed to curl as an interface name
+func TestBindDevice(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+	transport.BindDevice = "wg0"
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if got := fake.performed[curl.OPT_INTERFACE]; got != "if!wg0" {
+		t.Errorf("Expected OPT_INTERFACE if!wg0, got %v", got)
+	}
+}
+
+// TestNetworkNamespaceMissing tests that an unknown namespace fails the request
+func TestNetworkNamespaceMissing(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+	transport.NetworkNamespace = "/nonexistent/netns"
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Error("Expected error for missing network namespace")
+	}
+	if fake.performed != nil {
+		t.Error("Expected request not to be performed")
+	}
+}
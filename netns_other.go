@@ -0,0 +1,23 @@
+//go:build !linux
+
+package curlhttp
+
+import (
+	"fmt"
+)
+
+// applySocketBinding reports that device binding is unavailable on this platform
+func (t *Transport) applySocketBinding(easy curlEngine) error {
+	if t.BindDevice != "" {
+		return fmt.Errorf("BindDevice is only supported on Linux")
+	}
+	return nil
+}
+
+// performInNamespace reports that network namespaces are unavailable on this platform
+func (t *Transport) performInNamespace(perform func() error) error {
+	if t.NetworkNamespace != "" {
+		return fmt.Errorf("NetworkNamespace is only supported on Linux")
+	}
+	return perform()
+}
@@ -0,0 +1,42 @@
+package curlhttp
+
+import (
+	"context"
+	"net/http"
+)
+
+type omitHeadersKey struct{}
+
+// WithoutHeaders returns a context under which the Transport sends requests
+// without the named headers. Unlike setting a header to an empty value on
+// the request, it also removes headers the request never set but that would
+// otherwise be added: the impersonated browser's defaults such as
+// Accept-Language and Accept-Encoding, navigation headers, client hints and
+// headers curl adds itself. The headers are absent on the wire, not sent
+// empty. Names accumulate over nested calls.
+//
+// Headers the Transport needs to frame the request, such as the
+// Transfer-Encoding of a chunked upload, and those added by a
+// RequestSigner are still sent.
+func WithoutHeaders(ctx context.Context, names ...string) context.Context {
+	omitted := append(omittedHeaders(ctx), names...)
+	return context.WithValue(ctx, omitHeadersKey{}, omitted[:len(omitted):len(omitted)])
+}
+
+// omittedHeaders returns the header names attached with WithoutHeaders
+func omittedHeaders(ctx context.Context) []string {
+	names, _ := ctx.Value(omitHeadersKey{}).([]string)
+	return names
+}
+
+// applyOmitHeaders marks the headers in Transport.OmitHeaders and those
+// attached with WithoutHeaders for removal. An empty value makes curl drop
+// the header, including one it or the impersonation would add.
+func (t *Transport) applyOmitHeaders(req *http.Request, headers map[string]string) {
+	for _, name := range t.OmitHeaders {
+		headers[http.CanonicalHeaderKey(name)] = ""
+	}
+	for _, name := range omittedHeaders(req.Context()) {
+		headers[http.CanonicalHeaderKey(name)] = ""
+	}
+}
@@ -0,0 +1,58 @@
+package curlhttp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestWithoutHeaders tests that omitted headers are sent as removals
+func TestWithoutHeaders(t *testing.T) {
+	fake := newFakeEngine("ok")
+	transport := newFakeTransport(fake)
+	transport.OmitHeaders = []string{"x-tracking"}
+
+	ctx := WithoutHeaders(context.Background(), "accept-language")
+	ctx = WithoutHeaders(ctx, "Accept-Encoding")
+	ctx = WithNavigation(ctx, Navigation{Type: ResourceDocument, UserActivated: true})
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://example.com/", nil)
+	req.Header.Set("X-Tracking", "1")
+	req.Header.Set("X-Kept", "yes")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+
+	sent := sentHeaders(fake)
+	for _, name := range []string{"Accept-Language", "Accept-Encoding", "X-Tracking"} {
+		if value, ok := sent[name]; !ok || value != "" {
+			t.Errorf("Expected %s to be removed, got %q (present %v)", name, value, ok)
+		}
+	}
+	if sent["X-Kept"] != "yes" || sent["Sec-Fetch-User"] != "?1" {
+		t.Errorf("Expected other headers to be sent, got %v", sent)
+	}
+	if got := omittedHeaders(ctx); len(got) != 2 {
+		t.Errorf("Expected names to accumulate, got %v", got)
+	}
+}
+
+// TestWithoutHeadersNavigation tests omitting headers navigation would add
+func TestWithoutHeadersNavigation(t *testing.T) {
+	ctx := WithNavigation(context.Background(), Navigation{Type: ResourceDocument, UserActivated: true})
+	req, _ := http.NewRequestWithContext(WithoutHeaders(ctx, "Sec-Fetch-User"), "GET", "https://example.com/", nil)
+	headers := NewTransport().requestHeaders(req)
+	if value, ok := headers["Sec-Fetch-User"]; !ok || value != "" {
+		t.Errorf("Expected Sec-Fetch-User to be removed, got %q", value)
+	}
+
+	cmd, err := AsCurlCommand(req, nil)
+	if err != nil {
+		t.Fatalf("AsCurlCommand failed: %v", err)
+	}
+	if !strings.Contains(cmd, "'Sec-Fetch-User: '") {
+		t.Errorf("Expected the command to remove Sec-Fetch-User, got %s", cmd)
+	}
+}
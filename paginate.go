@@ -0,0 +1,186 @@
+package curlhttp
+
+import (
+	"fmt"
+	"iter"
+	"net/http"
+	"strings"
+)
+
+// Link is one link of a Link header (RFC 8288)
+type Link struct {
+	URL    string
+	Rel    []string          // relation types, lower case
+	Params map[string]string // other parameters, keys in lower case
+}
+
+// ParseLinkHeader parses the values of Link headers, such as
+// `<https://api.example.com/items?page=2>; rel="next"`. Malformed links are
+// skipped.
+func ParseLinkHeader(values []string) []Link {
+	var links []Link
+	for _, value := range values {
+		for value = strings.TrimSpace(value); strings.HasPrefix(value, "<"); {
+			end := strings.IndexByte(value, '>')
+			if end < 0 {
+				break
+			}
+			link := Link{URL: value[1:end], Params: make(map[string]string)}
+			value = strings.TrimSpace(value[end+1:])
+
+			// Parameters run until the comma that starts the next link
+			for strings.HasPrefix(value, ";") {
+				value = strings.TrimSpace(value[1:])
+				var name, param string
+				name, param, value = cutLinkParam(value)
+				if name == "rel" {
+					link.Rel = strings.Fields(strings.ToLower(param))
+				} else if name != "" {
+					link.Params[name] = param
+				}
+			}
+			links = append(links, link)
+			value = strings.TrimSpace(strings.TrimPrefix(value, ","))
+		}
+	}
+	return links
+}
+
+// cutLinkParam splits a name=value parameter off the start of s, which
+// may be quoted, and returns the rest
+func cutLinkParam(s string) (name, value, rest string) {
+	end := strings.IndexAny(s, "=;,")
+	if end < 0 {
+		return strings.ToLower(strings.TrimSpace(s)), "", ""
+	}
+	name = strings.ToLower(strings.TrimSpace(s[:end]))
+	if s[end] != '=' {
+		return name, "", s[end:]
+	}
+	s = strings.TrimSpace(s[end+1:])
+	if strings.HasPrefix(s, `"`) {
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '\\' && i+1 < len(s):
+				i++
+				b.WriteByte(s[i])
+			case c == '"':
+				return name, b.String(), strings.TrimSpace(s[i+1:])
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return name, b.String(), ""
+	}
+	end = strings.IndexAny(s, ";,")
+	if end < 0 {
+		return name, strings.TrimSpace(s), ""
+	}
+	return name, strings.TrimSpace(s[:end]), s[end:]
+}
+
+// LinkWithRel returns the URL of the first link in resp's Link headers
+// with relation rel, resolved against the request URL
+func LinkWithRel(resp *http.Response, rel string) (string, bool) {
+	rel = strings.ToLower(rel)
+	for _, link := range ParseLinkHeader(resp.Header.Values("Link")) {
+		for _, r := range link.Rel {
+			if r != rel {
+				continue
+			}
+			if resp.Request == nil || resp.Request.URL == nil {
+				return link.URL, true
+			}
+			u, err := resp.Request.URL.Parse(link.URL)
+			if err != nil {
+				return "", false
+			}
+			return u.String(), true
+		}
+	}
+	return "", false
+}
+
+// NextLink is the default next-page function of Paginate: it follows the
+// Link header with rel="next"
+func NextLink(resp *http.Response) (string, error) {
+	next, _ := LinkWithRel(resp, "next")
+	return next, nil
+}
+
+// Paginate requests req and then each following page, yielding the
+// responses as the loop asks for them. next returns the URL of the page
+// after resp, or "" on the last page; nil uses NextLink. Following pages
+// are GET requests with req's headers, sent through c so they share its
+// cookies and scope. Relative URLs are resolved against the page's URL.
+//
+// Each response body is closed once the loop body returns, so it must be
+// read inside the loop. A next function that reads the body must put a
+// replacement in resp.Body. Iteration stops at the first error, at a
+// response outside 2xx, reported as an *HTTPError, and at a URL that was
+// already visited.
+func (c *Client) Paginate(req *http.Request, next func(resp *http.Response) (string, error)) iter.Seq2[*http.Response, error] {
+	if next == nil {
+		next = NextLink
+	}
+	return func(yield func(*http.Response, error) bool) {
+		visited := make(map[string]bool)
+		page := req
+		for {
+			visited[page.URL.String()] = true
+			resp, err := c.Do(page)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				yield(nil, httpError(resp))
+				return
+			}
+
+			nextURL, nextErr := next(resp)
+			more := yield(resp, nil)
+			resp.Body.Close()
+			if !more {
+				return
+			}
+			if nextErr != nil {
+				yield(nil, fmt.Errorf("failed to find next page: %w", nextErr))
+				return
+			}
+			if nextURL == "" {
+				return
+			}
+
+			page, err = nextPageRequest(req, resp, nextURL)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if visited[page.URL.String()] {
+				return
+			}
+		}
+	}
+}
+
+// nextPageRequest builds the request for the page at rawURL
+func nextPageRequest(req *http.Request, resp *http.Response, rawURL string) (*http.Request, error) {
+	base := req.URL
+	if resp.Request != nil && resp.Request.URL != nil {
+		base = resp.Request.URL
+	}
+	u, err := base.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse next page URL: %w", err)
+	}
+	page, err := http.NewRequestWithContext(req.Context(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	page.Header = req.Header.Clone()
+	page.Header.Del("Content-Type")
+	page.Header.Del("Content-Length")
+	return page, nil
+}
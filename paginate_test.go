@@ -0,0 +1,114 @@
+package curlhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestParseLinkHeader tests parsing of multiple links and parameters
+func TestParseLinkHeader(t *testing.T) {
+	links := ParseLinkHeader([]string{
+		`<https://api.example.com/items?page=2>; rel="next", <https://api.example.com/items?page=9>; rel="last"`,
+		`</help>; rel="help start"; title="Say \"hi\"", <bad`,
+	})
+	if len(links) != 3 {
+		t.Fatalf("Expected 3 links, got %d: %+v", len(links), links)
+	}
+	if links[0].URL != "https://api.example.com/items?page=2" || links[0].Rel[0] != "next" {
+		t.Errorf("Expected next link to page 2, got %+v", links[0])
+	}
+	if links[1].Rel[0] != "last" {
+		t.Errorf("Expected last link, got %+v", links[1])
+	}
+	if len(links[2].Rel) != 2 || links[2].Params["title"] != `Say "hi"` {
+		t.Errorf("Expected two rels and an unescaped title, got %+v", links[2])
+	}
+}
+
+// pagedClient serves three pages linked with Link headers
+func pagedClient(requests *[]*http.Request) *Client {
+	return &Client{Client: http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		*requests = append(*requests, req)
+		resp := jsonResponse(200, "text/plain", "page "+req.URL.Query().Get("page"))
+		resp.Request = req
+		switch req.URL.Query().Get("page") {
+		case "":
+			resp.Header.Set("Link", `</items?page=2>; rel="next"`)
+		case "2":
+			resp.Header.Set("Link", `<https://example.com/items?page=3>; rel=next`)
+		case "missing":
+			return jsonResponse(404, "", "gone"), nil
+		}
+		return resp, nil
+	})}}
+}
+
+// TestPaginate tests following Link headers across pages
+func TestPaginate(t *testing.T) {
+	var requests []*http.Request
+	client := pagedClient(&requests)
+
+	req, _ := http.NewRequest("GET", "https://example.com/items", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	var bodies []string
+	for resp, err := range client.Paginate(req, nil) {
+		if err != nil {
+			t.Fatalf("Paginate failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		bodies = append(bodies, string(body))
+	}
+	if strings.Join(bodies, ",") != "page ,page 2,page 3" {
+		t.Errorf("Expected three pages, got %q", bodies)
+	}
+	if got := requests[2].Header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Expected headers to carry over, got %q", got)
+	}
+}
+
+// TestPaginateStops tests early exit, custom extractors and errors
+func TestPaginateStops(t *testing.T) {
+	var requests []*http.Request
+	client := pagedClient(&requests)
+
+	req, _ := http.NewRequest("GET", "https://example.com/items", nil)
+	for range client.Paginate(req, nil) {
+		break
+	}
+	if len(requests) != 1 {
+		t.Errorf("Expected breaking out of the loop to stop fetching, got %d requests", len(requests))
+	}
+
+	requests = nil
+	custom := func(resp *http.Response) (string, error) {
+		if resp.Request.URL.Query().Get("page") == "" {
+			return "?page=missing", nil
+		}
+		return "", nil
+	}
+	var pages int
+	var lastErr error
+	for _, err := range client.Paginate(req, custom) {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		pages++
+	}
+	var httpErr *HTTPError
+	if pages != 1 || !errors.As(lastErr, &httpErr) || httpErr.StatusCode != 404 {
+		t.Errorf("Expected one page and a 404 *HTTPError, got %d pages and %v", pages, lastErr)
+	}
+
+	// A page linking to itself ends the iteration
+	requests = nil
+	self := func(*http.Response) (string, error) { return "/items", nil }
+	for range client.Paginate(req, self) {
+	}
+	if len(requests) != 1 {
+		t.Errorf("Expected a visited URL not to be fetched again, got %d requests", len(requests))
+	}
+}
@@ -0,0 +1,155 @@
+package curlhttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RequestPolicy is consulted before every request is handed to curl,
+// including each redirect hop and retry. It returns the request to send,
+// which may be req itself or a copy with a rewritten URL or headers, or
+// an error to refuse the request. Returning a nil request with a nil
+// error sends req unchanged.
+type RequestPolicy func(req *http.Request) (*http.Request, error)
+
+// ErrRequestDenied matches every error returned for a request refused by
+// the Transport's RequestPolicy
+var ErrRequestDenied = errors.New("request denied by policy")
+
+// PolicyError is returned when RequestPolicy refuses a request
+type PolicyError struct {
+	URL string
+	Err error
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("request to %s denied by policy: %v", e.URL, e.Err)
+}
+
+func (e *PolicyError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports every PolicyError as ErrRequestDenied
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrRequestDenied
+}
+
+// applyRequestPolicy runs RequestPolicy for req, if set
+func (t *Transport) applyRequestPolicy(req *http.Request) (*http.Request, error) {
+	if t.RequestPolicy == nil {
+		return req, nil
+	}
+	checked, err := t.RequestPolicy(req)
+	if err != nil {
+		return nil, &PolicyError{URL: req.URL.Redacted(), Err: err}
+	}
+	if checked == nil {
+		return req, nil
+	}
+	if checked.URL == nil {
+		return nil, &PolicyError{URL: req.URL.Redacted(), Err: errors.New("policy returned a request without URL")}
+	}
+	return checked, nil
+}
+
+// URLAllowlist is a RequestPolicy that only lets through URLs matching
+// all of its non-empty lists, for services that fetch URLs supplied by
+// users. Use its Check method as the policy:
+//
+//	transport.RequestPolicy = (&curlhttp.URLAllowlist{
+//		Hosts: []string{"*.example.com"},
+//		Ports: []int{443},
+//	}).Check
+type URLAllowlist struct {
+	// Schemes lists the allowed URL schemes. Defaults to http and https.
+	Schemes []string
+
+	// Hosts lists the allowed host names, compared case-insensitively.
+	// An entry "*.example.com" matches subdomains of example.com but not
+	// example.com itself. Empty allows any host.
+	Hosts []string
+
+	// Ports lists the allowed ports; URLs without a port use the default
+	// port of their scheme. Empty allows any port.
+	Ports []int
+
+	// PathPrefixes lists the allowed path prefixes, such as "/api/".
+	// Empty allows any path.
+	PathPrefixes []string
+}
+
+// Check returns req if its URL is allowed and an error naming the first
+// rule it breaks otherwise
+func (a *URLAllowlist) Check(req *http.Request) (*http.Request, error) {
+	u := req.URL
+	scheme := strings.ToLower(u.Scheme)
+	schemes := a.Schemes
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	if !slices.ContainsFunc(schemes, func(s string) bool { return strings.EqualFold(s, scheme) }) {
+		return nil, fmt.Errorf("scheme %q not allowed", u.Scheme)
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if len(a.Hosts) > 0 && !slices.ContainsFunc(a.Hosts, func(pattern string) bool { return hostMatches(pattern, host) }) {
+		return nil, fmt.Errorf("host %q not allowed", u.Hostname())
+	}
+
+	if len(a.Ports) > 0 {
+		port, err := urlPort(scheme, u.Port())
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(a.Ports, port) {
+			return nil, fmt.Errorf("port %d not allowed", port)
+		}
+	}
+
+	if len(a.PathPrefixes) > 0 {
+		path := u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		if !slices.ContainsFunc(a.PathPrefixes, func(prefix string) bool { return strings.HasPrefix(path, prefix) }) {
+			return nil, fmt.Errorf("path %q not allowed", path)
+		}
+	}
+	return req, nil
+}
+
+// hostMatches reports whether host matches an allowlist entry
+func hostMatches(pattern, host string) bool {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	if ip := net.ParseIP(strings.Trim(pattern, "[]")); ip != nil {
+		return ip.Equal(net.ParseIP(host))
+	}
+	return pattern == host
+}
+
+// urlPort returns the port a URL connects to
+func urlPort(scheme, port string) (int, error) {
+	if port == "" {
+		switch scheme {
+		case "http", "ws":
+			return 80, nil
+		case "https", "wss":
+			return 443, nil
+		}
+		return 0, fmt.Errorf("no default port for scheme %q", scheme)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return 0, fmt.Errorf("invalid port %q", port)
+	}
+	return n, nil
+}
@@ -0,0 +1,95 @@
+package curlhttp
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// TestURLAllowlist tests the scheme, host, port and path rules
+func TestURLAllowlist(t *testing.T) {
+	allow := &URLAllowlist{
+		Hosts:        []string{"api.example.com", "*.cdn.example.net", "192.0.2.10"},
+		Ports:        []int{443, 8443},
+		PathPrefixes: []string{"/v1/", "/static/"},
+	}
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://api.example.com/v1/items", true},
+		{"https://API.Example.com./v1/items", true},
+		{"https://api.example.com:8443/static/a.js", true},
+		{"https://img.cdn.example.net/static/a.png", true},
+		{"https://192.0.2.10/v1/", true},
+		{"http://api.example.com/v1/items", false},
+		{"ftp://api.example.com/v1/items", false},
+		{"file:///etc/passwd", false},
+		{"https://cdn.example.net/static/a.png", false},
+		{"https://api.example.com.evil.test/v1/", false},
+		{"https://api.example.com:22/v1/", false},
+		{"https://api.example.com/admin", false},
+		{"https://127.0.0.1/v1/", false},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		_, err := allow.Check(req)
+		if allowed := err == nil; allowed != tt.allowed {
+			t.Errorf("%s: Expected allowed=%v, got error %v", tt.url, tt.allowed, err)
+		}
+	}
+}
+
+// TestRequestPolicyDeny tests that refused requests never reach curl
+func TestRequestPolicyDeny(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+	transport.RequestPolicy = (&URLAllowlist{Ports: []int{443}}).Check
+
+	req, _ := http.NewRequest("GET", "https://example.com:6379/", nil)
+	_, err := transport.RoundTrip(req)
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || !errors.Is(err, ErrRequestDenied) {
+		t.Fatalf("Expected a PolicyError, got %v", err)
+	}
+	if fake.performed != nil {
+		t.Error("Expected the denied request not to be performed")
+	}
+
+	req, _ = http.NewRequest("GET", "https://example.com/", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Errorf("Expected the allowed request to succeed, got %v", err)
+	}
+}
+
+// TestRequestPolicyRewrite tests that a policy can replace the request
+func TestRequestPolicyRewrite(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+	transport.RequestPolicy = func(req *http.Request) (*http.Request, error) {
+		if req.URL.Scheme != "http" {
+			return nil, nil
+		}
+		upgraded := req.Clone(req.Context())
+		upgraded.URL.Scheme = "https"
+		upgraded.Header.Set("X-Policy", "upgraded")
+		return upgraded, nil
+	}
+
+	req, _ := http.NewRequest("GET", "http://example.com/page", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if got := fake.performed[curl.OPT_URL]; got != "https://example.com/page" {
+		t.Errorf("Expected the rewritten URL, got %v", got)
+	}
+	if got := sentHeaders(fake)["X-Policy"]; got != "upgraded" {
+		t.Errorf("Expected the rewritten headers, got %q", got)
+	}
+	if resp.Request.URL.Scheme != "https" {
+		t.Errorf("Expected the response to name the request sent, got %s", resp.Request.URL)
+	}
+}
@@ -0,0 +1,155 @@
+package curlhttp
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Politeness paces requests the way a well-behaved crawler does: requests
+// to the same domain start at least Delay apart, plus a random Jitter, and
+// at most MaxConcurrent of them run at once. Different domains do not wait
+// for each other.
+//
+// A request holds its domain's concurrency slot until its response body is
+// read to the end or closed.
+type Politeness struct {
+	// Delay is the minimum time between the starts of two requests to the
+	// same domain, as in a robots.txt Crawl-delay.
+	Delay time.Duration
+
+	// Jitter adds a random extra delay of up to Jitter to each gap, so the
+	// requests do not arrive at a fixed rhythm.
+	Jitter time.Duration
+
+	// MaxConcurrent limits the requests in flight per domain. Zero means
+	// no limit.
+	MaxConcurrent int
+
+	// Domain maps a request URL to the key requests are paced by. Defaults
+	// to the registrable domain of the host, so www.example.com and
+	// img.example.com share a budget.
+	Domain func(u *url.URL) string
+
+	// Clock schedules the delays. Defaults to SystemClock.
+	Clock Clock
+
+	mu      sync.Mutex
+	domains map[string]*politeDomain
+}
+
+// politeDomain is the pacing state of one domain
+type politeDomain struct {
+	slots semaphore
+	next  time.Time // earliest start of the next request
+}
+
+// domainKey returns the key u is paced by
+func (p *Politeness) domainKey(u *url.URL) string {
+	if p.Domain != nil {
+		return p.Domain(u)
+	}
+	return registrableDomain(u.Hostname())
+}
+
+// domain returns the state for key, creating it on first use
+func (p *Politeness) domain(key string) *politeDomain {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.domains == nil {
+		p.domains = make(map[string]*politeDomain)
+	}
+	d, ok := p.domains[key]
+	if !ok {
+		d = &politeDomain{}
+		if p.MaxConcurrent > 0 {
+			d.slots = make(semaphore, p.MaxConcurrent)
+		}
+		p.domains[key] = d
+	}
+	return d
+}
+
+// reserve books the next start time for d and returns how long to wait
+// for it
+func (p *Politeness) reserve(d *politeDomain, now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	start := now
+	if d.next.After(now) {
+		start = d.next
+	}
+	gap := p.Delay
+	if p.Jitter > 0 {
+		gap += rand.N(p.Jitter)
+	}
+	d.next = start.Add(gap)
+	return start.Sub(now)
+}
+
+// Middleware returns middleware that paces requests per domain
+func (p *Politeness) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			key := p.domainKey(req.URL)
+			d := p.domain(key)
+			ctx := req.Context()
+
+			release := func() {}
+			if d.slots != nil {
+				if err := d.slots.acquire(ctx); err != nil {
+					return nil, fmt.Errorf("failed to acquire request slot for %s: %w", key, err)
+				}
+				release = d.slots.release
+			}
+
+			clock := clockOrSystem(p.Clock)
+			if wait := p.reserve(d, clock.Now()); wait > 0 {
+				select {
+				case <-clock.After(wait):
+				case <-ctx.Done():
+					release()
+					return nil, ctx.Err()
+				}
+			}
+
+			resp, err := next.RoundTrip(req)
+			if err != nil || d.slots == nil {
+				release()
+				return resp, err
+			}
+			resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+			return resp, nil
+		})
+	}
+}
+
+// releasingBody calls release once the body is read to the end or closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// meta keeps the metadata of Transport responses reachable
+func (b *releasingBody) meta() *responseMeta {
+	return innerMeta(b.ReadCloser)
+}
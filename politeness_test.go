@@ -0,0 +1,121 @@
+package curlhttp
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestPolitenessDelay tests that requests to one domain are spaced by Delay
+func TestPolitenessDelay(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	polite := &Politeness{Delay: time.Second, Clock: clock}
+	rt := Chain(stubResponse("ok"), polite.Middleware())
+
+	get := func(rawURL string) <-chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			req, _ := http.NewRequest("GET", rawURL, nil)
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Errorf("RoundTrip failed: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+		return done
+	}
+
+	<-get("http://www.example.com/a")
+	// A different domain is not delayed
+	<-get("http://other.org/")
+
+	second := get("http://img.example.com/b")
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-second:
+		t.Fatal("Expected the second request to the domain to wait")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	<-second
+}
+
+// TestPolitenessJitter tests that jitter stays within its bound
+func TestPolitenessJitter(t *testing.T) {
+	polite := &Politeness{Delay: time.Second, Jitter: 500 * time.Millisecond}
+	d := polite.domain("example.com")
+	now := time.Unix(0, 0)
+	polite.reserve(d, now)
+	for i := 0; i < 50; i++ {
+		start := d.next
+		wait := polite.reserve(d, start)
+		gap := d.next.Sub(start)
+		if wait != 0 || gap < time.Second || gap >= 1500*time.Millisecond {
+			t.Fatalf("Expected a gap in [1s, 1.5s), got %v (wait %v)", gap, wait)
+		}
+	}
+}
+
+// TestPolitenessConcurrency tests the per-domain concurrency limit
+func TestPolitenessConcurrency(t *testing.T) {
+	polite := &Politeness{MaxConcurrent: 1}
+	var inFlight, peak atomic.Int32
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		if n > peak.Load() {
+			peak.Store(n)
+		}
+		time.Sleep(5 * time.Millisecond)
+		return stubResponse("ok").RoundTrip(req)
+	})
+	rt := Chain(next, polite.Middleware())
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	held, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+
+	// The slot is held until the body is read
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Errorf("RoundTrip failed: %v", err)
+			return
+		}
+		resp.Body.Close()
+	}()
+	select {
+	case <-done:
+		t.Fatal("Expected the second request to wait for the first body")
+	case <-time.After(20 * time.Millisecond):
+	}
+	io.ReadAll(held.Body)
+	<-done
+	if peak.Load() != 1 {
+		t.Errorf("Expected at most 1 request in flight, got %d", peak.Load())
+	}
+}
+
+// TestPolitenessDomain tests custom domain normalization
+func TestPolitenessDomain(t *testing.T) {
+	polite := &Politeness{Domain: func(u *url.URL) string { return u.Host }}
+	a, _ := url.Parse("http://www.example.com/")
+	b, _ := url.Parse("http://img.example.com/")
+	if polite.domainKey(a) == polite.domainKey(b) {
+		t.Error("Expected custom Domain to separate subdomains")
+	}
+	if (&Politeness{}).domainKey(a) != "example.com" {
+		t.Errorf("Expected default key example.com, got %q", (&Politeness{}).domainKey(a))
+	}
+}
@@ -0,0 +1,272 @@
+package curlhttp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// PoolOverflowPolicy controls what happens when a request needs a curl
+// handle and every handle in the Transport's pool is in use.
+type PoolOverflowPolicy int
+
+const (
+	// PoolOverflowBlock waits until a handle is returned or the request's
+	// context is done. The pool never holds more handles than its size.
+	PoolOverflowBlock PoolOverflowPolicy = iota
+
+	// PoolOverflowCreate creates a temporary handle that is destroyed when
+	// the request finishes. Concurrency is unbounded, at the cost of handle
+	// churn under bursts.
+	PoolOverflowCreate
+
+	// PoolOverflowFail returns ErrPoolExhausted immediately.
+	PoolOverflowFail
+)
+
+// ErrPoolExhausted is returned when a request finds no curl handle or
+// request slot in time: immediately under PoolOverflowFail, or once
+// Transport.AcquireTimeout has passed
+var ErrPoolExhausted = errors.New("curl handle pool exhausted")
+
+// acquireTimeout returns a channel that fires once AcquireTimeout has
+// passed, or nil if there is no timeout, along with the timeout
+func (t *Transport) acquireTimeout() (<-chan time.Time, time.Duration) {
+	t.configMu.RLock()
+	wait := t.AcquireTimeout
+	t.configMu.RUnlock()
+	if wait <= 0 {
+		return nil, 0
+	}
+	return t.clock().After(wait), wait
+}
+
+// countExhausted counts err in TransportStats.PoolExhausted if it is
+// ErrPoolExhausted
+func (t *Transport) countExhausted(err error) {
+	if errors.Is(err, ErrPoolExhausted) {
+		t.stats.poolExhausted.Add(1)
+	}
+}
+
+// handlePool holds the idle handles of a Transport and one slot per pooled
+// handle, idle or in use. ApplyConfig replaces it when the size changes.
+type handlePool struct {
+	idle  chan *pooledHandle
+	slots chan struct{}
+}
+
+func newHandlePool(size int) *handlePool {
+	return &handlePool{idle: make(chan *pooledHandle, size), slots: make(chan struct{}, size)}
+}
+
+// pooledHandle is a curl handle with the bookkeeping the pool needs
+type pooledHandle struct {
+	curlEngine
+	pool       *handlePool // pool whose slot it holds; nil for overflow handles
+	target     string      // impersonation target the handle was configured with
+	settings   handleSettings
+	generation uint64 // Transport.generation when settings were copied
+	created    time.Time
+	idleSince  time.Time
+	requests   int
+	needsReset bool   // set an option clearRequestOptions does not clear
+	route      string // key of the proxy route its connections were made on
+}
+
+// handleExpired returns why handle must not be reused, or "" if it can be
+func (t *Transport) handleExpired(handle *pooledHandle, now time.Time) string {
+	switch {
+	case handle.generation != t.generation.Load():
+		return "config changed"
+	case handle.settings.idleTimeout > 0 && now.Sub(handle.idleSince) >= handle.settings.idleTimeout:
+		return "idle timeout"
+	case handle.settings.maxAge > 0 && now.Sub(handle.created) >= handle.settings.maxAge:
+		return "max age"
+	case t.MaxHandleRequests > 0 && handle.requests >= t.MaxHandleRequests:
+		return "max requests"
+	}
+	return ""
+}
+
+// takeIdleHandle returns a usable idle handle of pool for route, destroying
+// expired ones on the way, or nil if none is idle. With anyRoute, a handle
+// of any route is returned.
+func (t *Transport) takeIdleHandle(pool *handlePool, route string, anyRoute bool) *pooledHandle {
+	var skipped []*pooledHandle
+	defer func() {
+		for _, handle := range skipped {
+			t.putIdleHandle(handle)
+		}
+	}()
+	for n := len(pool.idle); n > 0; n-- {
+		var handle *pooledHandle
+		select {
+		case handle = <-pool.idle:
+		default:
+			return nil
+		}
+		if reason := t.handleExpired(handle, t.clock().Now()); reason != "" {
+			t.destroyHandle(handle, reason)
+			continue
+		}
+		if anyRoute || handle.route == route {
+			return handle
+		}
+		skipped = append(skipped, handle)
+	}
+	return nil
+}
+
+// putIdleHandle returns handle to the idle pool, destroying it if the pool
+// is full
+func (t *Transport) putIdleHandle(handle *pooledHandle) {
+	select {
+	case handle.pool.idle <- handle:
+	default:
+		t.destroyHandle(handle, "pool full")
+	}
+}
+
+// reroute prepares handle for a request on route. A handle that has made
+// connections on another route is replaced by a new one in the same pool
+// slot, closing those connections.
+func (t *Transport) reroute(handle *pooledHandle, route string) (curlEngine, error) {
+	if handle.route == route || handle.requests == 0 {
+		handle.route = route
+		return handle, nil
+	}
+	handle.Cleanup()
+	t.stats.handlesDestroyed.Add(1)
+	t.log(context.Background(), slog.LevelDebug, "curl handle destroyed", slog.String("reason", "proxy route changed"))
+
+	fresh, err := t.createCurlHandle()
+	if err != nil {
+		if handle.pool != nil {
+			<-handle.pool.slots
+		}
+		return nil, err
+	}
+	fresh.pool = handle.pool
+	fresh.route = route
+	return fresh, nil
+}
+
+// destroyHandle cleans up a handle and frees its pool slot
+func (t *Transport) destroyHandle(handle *pooledHandle, reason string) {
+	handle.Cleanup()
+	if handle.pool != nil {
+		<-handle.pool.slots
+	}
+	t.stats.handlesDestroyed.Add(1)
+	t.log(context.Background(), slog.LevelDebug, "curl handle destroyed", slog.String("reason", reason))
+}
+
+// evictIdleHandles destroys idle handles of pool that have expired
+func (t *Transport) evictIdleHandles(pool *handlePool) {
+	now := t.clock().Now()
+	for n := len(pool.idle); n > 0; n-- {
+		var handle *pooledHandle
+		select {
+		case handle = <-pool.idle:
+		default:
+			return
+		}
+		if reason := t.handleExpired(handle, now); reason != "" {
+			t.destroyHandle(handle, reason)
+			continue
+		}
+		t.putIdleHandle(handle)
+	}
+}
+
+// CloseIdleConnections destroys every idle handle in the pool, closing the
+// connections they keep open. In-use handles are not affected.
+func (t *Transport) CloseIdleConnections() {
+	pool := t.handles()
+	for {
+		select {
+		case handle := <-pool.idle:
+			t.destroyHandle(handle, "closed")
+		default:
+			return
+		}
+	}
+}
+
+// reapInterval returns how often the reaper checks idle handles with
+// settings, or 0 if no time limit is configured
+func reapInterval(settings handleSettings) time.Duration {
+	interval := settings.idleTimeout
+	if settings.maxAge > 0 && (interval == 0 || settings.maxAge < interval) {
+		interval = settings.maxAge
+	}
+	if interval > 0 {
+		interval /= 2
+		if interval < time.Second {
+			interval = time.Second
+		}
+	}
+	return interval
+}
+
+// startReaper starts the background reaper if settings have a time limit
+// and it is not already running. The reaper stops once the pool is empty.
+func (t *Transport) startReaper(settings handleSettings) {
+	interval := reapInterval(settings)
+	if interval == 0 || !t.reaping.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		for {
+			<-t.clock().After(interval)
+			pool := t.handles()
+			t.evictIdleHandles(pool)
+			if len(pool.idle) > 0 {
+				continue
+			}
+			t.reaping.Store(false)
+			// A handle returned after the check would otherwise be missed
+			if len(t.handles().idle) == 0 || !t.reaping.CompareAndSwap(false, true) {
+				return
+			}
+		}
+	}()
+}
+
+// clearRequestOptions restores the options performOptimizedRequest sets per
+// request to their defaults, so the handle can be reused without a full
+// Reset and reconfiguration. Any option set per request must be cleared
+// here. It reports false if an option could not be cleared.
+func (t *Transport) clearRequestOptions(handle *pooledHandle) bool {
+	// HTTPGET also clears NOBODY, POST and UPLOAD
+	options := []struct {
+		opt   curl.EasyOpt
+		value interface{}
+	}{
+		{curl.OPT_HTTPGET, true},
+		{curl.OPT_CUSTOMREQUEST, nil},
+		{curl.OPT_POSTFIELDS, nil},
+		{curl.OPT_POSTFIELDSIZE_LARGE, int64(-1)},
+		{curl.OPT_HTTPHEADER, nil},
+		{curl.OPT_PROXY, nil},
+		{curl.OPT_PROXYHEADER, nil},
+		{curl.OPT_CERTINFO, false},
+		{curl.OPT_INTERFACE, nil},
+		{curl.OPT_TIMEOUT_MS, handle.settings.timeoutMs},
+		{curl.OPT_VERBOSE, false},
+		{curl.OPT_FRESH_CONNECT, false},
+		{curl.OPT_FORBID_REUSE, false},
+		{curl.OPT_XFERINFOFUNCTION, nil},
+		{curl.OPT_NOPROGRESS, true},
+	}
+	for _, o := range options {
+		if err := handle.Setopt(o.opt, o.value); err != nil {
+			return false
+		}
+	}
+	return true
+}
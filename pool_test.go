@@ -0,0 +1,244 @@
+package curlhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// newFakePool returns a Transport with a pool of size handing out fresh fakes
+func newFakePool(size int, policy PoolOverflowPolicy) (*Transport, *int) {
+	created := 0
+	transport := NewTransportWithPoolSize(size)
+	transport.PoolOverflow = policy
+	transport.newEngine = func() curlEngine {
+		created++
+		return newFakeEngine("")
+	}
+	return transport, &created
+}
+
+// TestPoolBlocksWhenExhausted tests that acquisition waits for a returned handle
+func TestPoolBlocksWhenExhausted(t *testing.T) {
+	transport, created := newFakePool(1, PoolOverflowBlock)
+
+	first, err := transport.getCurlHandle(context.Background())
+	if err != nil {
+		t.Fatalf("getCurlHandle failed: %v", err)
+	}
+
+	got := make(chan curlEngine)
+	go func() {
+		handle, _ := transport.getCurlHandle(context.Background())
+		got <- handle
+	}()
+
+	select {
+	case <-got:
+		t.Fatal("Expected acquisition to block while the pool is exhausted")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	transport.returnCurlHandle(first)
+	if second := <-got; second != first {
+		t.Error("Expected the returned handle to be handed to the waiter")
+	}
+	if *created != 1 {
+		t.Errorf("Expected 1 handle to be created, got %d", *created)
+	}
+	if n := transport.Stats().AcquisitionsBlocked; n != 1 {
+		t.Errorf("Expected 1 blocked acquisition, got %d", n)
+	}
+}
+
+// TestPoolBlockRespectsContext tests that a waiting acquisition gives up with its context
+func TestPoolBlockRespectsContext(t *testing.T) {
+	transport, _ := newFakePool(1, PoolOverflowBlock)
+	if _, err := transport.getCurlHandle(context.Background()); err != nil {
+		t.Fatalf("getCurlHandle failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := transport.getCurlHandle(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+// TestPoolOverflowFail tests that PoolOverflowFail returns ErrPoolExhausted
+func TestPoolOverflowFail(t *testing.T) {
+	transport, _ := newFakePool(1, PoolOverflowFail)
+	if _, err := transport.getCurlHandle(context.Background()); err != nil {
+		t.Fatalf("getCurlHandle failed: %v", err)
+	}
+	if _, err := transport.getCurlHandle(context.Background()); !errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("Expected ErrPoolExhausted, got %v", err)
+	}
+}
+
+// TestPoolCreateFailureKeepsSlot tests that a failed allocation does not shrink the pool
+func TestPoolCreateFailureKeepsSlot(t *testing.T) {
+	transport := NewTransportWithPoolSize(1)
+	transport.PoolOverflow = PoolOverflowFail
+	transport.newEngine = func() curlEngine { return nil }
+	if _, err := transport.getCurlHandle(context.Background()); !errors.Is(err, ErrBackendUnavailable) {
+		t.Fatalf("Expected ErrBackendUnavailable, got %v", err)
+	}
+
+	transport.newEngine = func() curlEngine { return newFakeEngine("") }
+	if _, err := transport.getCurlHandle(context.Background()); err != nil {
+		t.Errorf("Expected the slot to be usable after a failed allocation, got %v", err)
+	}
+}
+
+// TestPoolHandleRecycling tests idle, age and request-count limits
+func TestPoolHandleRecycling(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC))
+	transport, created := newFakePool(2, PoolOverflowBlock)
+	transport.Clock = clock
+	transport.IdleHandleTimeout = time.Minute
+	transport.MaxHandleRequests = 2
+
+	handle, _ := transport.getCurlHandle(context.Background())
+	transport.returnCurlHandle(handle)
+	if reused, _ := transport.getCurlHandle(context.Background()); reused != handle {
+		t.Fatal("Expected the idle handle to be reused")
+	}
+	// Second request reaches MaxHandleRequests
+	transport.returnCurlHandle(handle)
+	if n := transport.Stats().HandlesDestroyed; n != 1 {
+		t.Errorf("Expected handle to be recycled after 2 requests, got %d destroyed", n)
+	}
+
+	handle, _ = transport.getCurlHandle(context.Background())
+	transport.returnCurlHandle(handle)
+	clock.Advance(2 * time.Minute)
+	if fresh, _ := transport.getCurlHandle(context.Background()); fresh == handle {
+		t.Error("Expected the idle-expired handle to be replaced")
+	}
+	if *created != 3 {
+		t.Errorf("Expected 3 handles to be created, got %d", *created)
+	}
+	if n := transport.Stats().OpenHandles; n != 1 {
+		t.Errorf("Expected 1 open handle, got %d", n)
+	}
+}
+
+// TestPoolReaper tests that the background reaper evicts idle handles
+func TestPoolReaper(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC))
+	transport, _ := newFakePool(1, PoolOverflowBlock)
+	transport.Clock = clock
+	transport.IdleHandleTimeout = 10 * time.Second
+
+	handle, _ := transport.getCurlHandle(context.Background())
+	transport.returnCurlHandle(handle)
+
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(10 * time.Second)
+	for transport.Stats().HandlesDestroyed == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if n := transport.Stats().IdleHandles; n != 0 {
+		t.Errorf("Expected no idle handles, got %d", n)
+	}
+}
+
+// TestCloseIdleConnections tests that idle handles are destroyed and slots freed
+func TestCloseIdleConnections(t *testing.T) {
+	transport, _ := newFakePool(1, PoolOverflowFail)
+	handle, _ := transport.getCurlHandle(context.Background())
+	transport.returnCurlHandle(handle)
+
+	transport.CloseIdleConnections()
+	if n := transport.Stats().OpenHandles; n != 0 {
+		t.Errorf("Expected no open handles, got %d", n)
+	}
+	if _, err := transport.getCurlHandle(context.Background()); err != nil {
+		t.Errorf("Expected a new handle after closing idle ones, got %v", err)
+	}
+}
+
+// TestPoolProxyRoutes tests that handles are kept apart by proxy route
+func TestPoolProxyRoutes(t *testing.T) {
+	var fakes []*fakeEngine
+	transport := NewTransportWithPoolSize(2)
+	transport.newEngine = func() curlEngine {
+		fake := newFakeEngine("")
+		fakes = append(fakes, fake)
+		return fake
+	}
+	send := func(proxy string) *fakeEngine {
+		t.Helper()
+		u, _ := url.Parse(proxy)
+		req, _ := http.NewRequestWithContext(WithProxy(context.Background(), u), "GET", "https://example.com/", nil)
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		for _, fake := range fakes {
+			if fake.performed != nil && fake.performed[curl.OPT_PROXY] == proxy {
+				fake.performed[curl.OPT_PROXY] = "seen"
+				return fake
+			}
+		}
+		t.Fatalf("No handle performed the request via %s", proxy)
+		return nil
+	}
+
+	// Sticky sessions on the same gateway differ only in credentials
+	first := send("http://user-session-1:pw@gate.example:7777")
+	second := send("http://user-session-2:pw@gate.example:7777")
+	if first == second || len(fakes) != 2 {
+		t.Fatalf("Expected each session to get its own handle, got %d handles", len(fakes))
+	}
+	if again := send("http://user-session-1:pw@gate.example:7777"); again != first {
+		t.Error("Expected the session's own handle to be reused")
+	}
+
+	// A full pool recycles a handle of another route instead of reusing its connections
+	third := send("http://user-session-3:pw@gate.example:7777")
+	if third == first || third == second || len(fakes) != 3 {
+		t.Errorf("Expected a new handle for a new route, got %d handles", len(fakes))
+	}
+	if fakes[0].cleanups+fakes[1].cleanups != 1 {
+		t.Errorf("Expected one handle of another route to be destroyed, got %d and %d", fakes[0].cleanups, fakes[1].cleanups)
+	}
+	if n := transport.Stats().OpenHandles; n != 2 {
+		t.Errorf("Expected the pool to stay at 2 handles, got %d", n)
+	}
+}
+
+// TestPoolAcquireTimeout tests that waits for a handle end with ErrPoolExhausted
+func TestPoolAcquireTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC))
+	transport, _ := newFakePool(1, PoolOverflowBlock)
+	transport.Clock = clock
+	transport.AcquireTimeout = time.Second
+	if _, err := transport.getCurlHandle(context.Background()); err != nil {
+		t.Fatalf("getCurlHandle failed: %v", err)
+	}
+
+	errs := make(chan error)
+	go func() {
+		_, err := transport.getCurlHandle(context.Background())
+		errs <- err
+	}()
+	for transport.Stats().AcquisitionsBlocked == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Second)
+
+	if err := <-errs; !errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("Expected ErrPoolExhausted, got %v", err)
+	}
+	if n := transport.Stats().PoolExhausted; n != 1 {
+		t.Errorf("Expected 1 exhausted acquisition, got %d", n)
+	}
+}
@@ -0,0 +1,188 @@
+package curlhttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Prefetcher warms the resources a page announces before they are asked
+// for, the way browsers act on 103 Early Hints and Link headers. Links
+// with rel=preload or modulepreload are fetched into Cache, and links with
+// rel=preconnect get a HEAD request that leaves a pooled connection open.
+// Work runs in the background and never delays the response.
+// Install it with Transport.Use(p.Middleware()).
+//
+// Without a Cache, preloads are fetched and discarded, which still warms
+// the connection and any server-side cache.
+type Prefetcher struct {
+	// Cache receives preloaded responses. Use the same ResponseCache, and
+	// install its middleware, for the requests that should find them.
+	Cache *ResponseCache
+
+	// Filter, if set, decides whether a link target may be warmed. By
+	// default only http and https URLs are.
+	Filter func(u *url.URL) bool
+
+	// MaxConcurrent caps the warm-up requests in flight. Defaults to 4.
+	MaxConcurrent int
+
+	// Timeout bounds each warm-up request. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	inFlight map[string]bool // keyed by rel and URL
+	sem      chan struct{}
+	wg       sync.WaitGroup
+}
+
+// prefetchHeaders are copied from the page request to preloads of the
+// same origin, as browsers send credentials with same-origin subresources
+var prefetchHeaders = []string{"Accept-Language", "Authorization", "Cookie"}
+
+// Middleware returns middleware that starts warm-up requests for the
+// links announced in 103 responses and in the final response headers
+func (p *Prefetcher) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		warm := next
+		if p.Cache != nil {
+			warm = p.Cache.Middleware()(next)
+		}
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			hinted := make(map[string]bool)
+			var mu sync.Mutex
+			announce := func(links []string) {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, link := range links {
+					if !hinted[link] {
+						hinted[link] = true
+						p.start(warm, req, link)
+					}
+				}
+			}
+
+			ctx := httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+				Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+					if code == http.StatusEarlyHints {
+						announce(header.Values("Link"))
+					}
+					return nil
+				},
+			})
+			resp, err := next.RoundTrip(req.WithContext(ctx))
+			if err == nil {
+				announce(resp.Header.Values("Link"))
+			}
+			return resp, err
+		})
+	}
+}
+
+// start warms the targets of one Link header value announced for req
+func (p *Prefetcher) start(warm http.RoundTripper, req *http.Request, value string) {
+	for _, link := range ParseLinkHeader([]string{value}) {
+		method := ""
+		switch {
+		case slices.Contains(link.Rel, "preload"), slices.Contains(link.Rel, "modulepreload"):
+			method = http.MethodGet
+		case slices.Contains(link.Rel, "preconnect"):
+			method = http.MethodHead
+		default:
+			continue
+		}
+		target, err := req.URL.Parse(link.URL)
+		if err != nil || !p.allowed(target) {
+			continue
+		}
+		if method == http.MethodHead {
+			target = &url.URL{Scheme: target.Scheme, Host: target.Host, Path: "/"}
+		}
+
+		key := method + " " + target.String()
+		p.mu.Lock()
+		if p.inFlight == nil {
+			p.inFlight = make(map[string]bool)
+			maxConcurrent := p.MaxConcurrent
+			if maxConcurrent <= 0 {
+				maxConcurrent = 4
+			}
+			p.sem = make(chan struct{}, maxConcurrent)
+		}
+		if p.inFlight[key] {
+			p.mu.Unlock()
+			continue
+		}
+		p.inFlight[key] = true
+		p.mu.Unlock()
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			defer func() {
+				p.mu.Lock()
+				delete(p.inFlight, key)
+				p.mu.Unlock()
+			}()
+			p.sem <- struct{}{}
+			defer func() { <-p.sem }()
+			p.fetch(warm, req, method, target)
+		}()
+	}
+}
+
+// fetch performs one warm-up request and drains its response
+func (p *Prefetcher) fetch(warm http.RoundTripper, page *http.Request, method string, target *url.URL) {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	// Start afresh so the page's trace hooks do not see the warm-up, but
+	// keep the exit the page was fetched through
+	ctx := context.Background()
+	if proxy, ok := page.Context().Value(proxyKey{}).(*url.URL); ok {
+		ctx = WithProxy(ctx, proxy)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), nil)
+	if err != nil {
+		return
+	}
+	if referrer := referrerFor(page.URL, target); referrer != "" {
+		req.Header.Set("Referer", referrer)
+	}
+	if sameOrigin(page.URL, target) {
+		for _, name := range prefetchHeaders {
+			for _, value := range page.Header.Values(name) {
+				req.Header.Add(name, value)
+			}
+		}
+	}
+	resp, err := warm.RoundTrip(req)
+	if err != nil {
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// allowed reports whether u may be warmed
+func (p *Prefetcher) allowed(u *url.URL) bool {
+	if p.Filter != nil {
+		return p.Filter(u)
+	}
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// Wait blocks until every warm-up request started so far has finished
+func (p *Prefetcher) Wait() {
+	p.wg.Wait()
+}
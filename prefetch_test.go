@@ -0,0 +1,162 @@
+package curlhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// TestGot1xxResponse tests that 1xx header blocks reach the request's ClientTrace
+func TestGot1xxResponse(t *testing.T) {
+	fake := newFakeEngine("page")
+	fake.headers = []string{
+		"HTTP/1.1 103 Early Hints\r\n", "Link: </app.css>; rel=preload\r\n", "\r\n",
+		"HTTP/1.1 200 OK\r\n", "Content-Type: text/html\r\n", "\r\n",
+	}
+	transport := newFakeTransport(fake)
+
+	var codes []int
+	var links []string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			codes = append(codes, code)
+			links = append(links, header.Get("Link"))
+			return nil
+		},
+	}
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	resp, err := transport.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(codes) != 1 || codes[0] != 103 || links[0] != "</app.css>; rel=preload" {
+		t.Errorf("Expected one 103 with the Link header, got %v %v", codes, links)
+	}
+	if resp.StatusCode != 200 || resp.Header.Get("Link") != "" {
+		t.Errorf("Expected final 200 without interim headers, got %d %v", resp.StatusCode, resp.Header)
+	}
+}
+
+// TestGot1xxResponseError tests that an error from Got1xxResponse aborts the request
+func TestGot1xxResponseError(t *testing.T) {
+	fake := newFakeEngine("page")
+	fake.headers = []string{"HTTP/1.1 103 Early Hints\r\n", "\r\n", "HTTP/1.1 200 OK\r\n", "\r\n"}
+	transport := newFakeTransport(fake)
+
+	stop := io.ErrUnexpectedEOF
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(int, textproto.MIMEHeader) error { return stop },
+	}
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	_, err := transport.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != stop {
+		t.Errorf("Expected the hook's error, got %v", err)
+	}
+}
+
+// TestPrefetcherWarmsLinks tests that preload and preconnect links are warmed in the background
+func TestPrefetcherWarmsLinks(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	var cookies []string
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		requests = append(requests, req.Method+" "+req.URL.String())
+		cookies = append(cookies, req.Header.Get("Cookie"))
+		mu.Unlock()
+		if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && req.URL.Path == "/page" {
+			trace.Got1xxResponse(103, textproto.MIMEHeader{"Link": {"</app.css>; rel=preload; as=style, <https://cdn.example.net>; rel=preconnect"}})
+		}
+		resp, err := stubResponse("body of " + req.URL.Path).RoundTrip(req)
+		if req.URL.Path == "/page" {
+			resp.Header.Set("Link", `</app.css>; rel=preload, </app.js>; rel="modulepreload", </next>; rel=next`)
+		}
+		return resp, err
+	})
+	cache := &ResponseCache{}
+	prefetcher := &Prefetcher{Cache: cache}
+	rt := Chain(next, cache.Middleware(), prefetcher.Middleware())
+
+	req, _ := http.NewRequest("GET", "http://example.com/page", nil)
+	req.Header.Set("Cookie", "session=1")
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+	prefetcher.Wait()
+
+	for _, want := range []string{"GET http://example.com/app.css", "GET http://example.com/app.js", "HEAD https://cdn.example.net/"} {
+		if !slices.Contains(requests, want) {
+			t.Errorf("Expected warm-up %q in %v", want, requests)
+		}
+	}
+	if slices.Contains(requests, "GET http://example.com/next") {
+		t.Errorf("Expected rel=next not to be warmed, got %v", requests)
+	}
+	for i, r := range requests {
+		if r == "HEAD https://cdn.example.net/" && cookies[i] != "" {
+			t.Errorf("Expected no cookie sent to another host, got %q", cookies[i])
+		}
+	}
+
+	// The preloaded stylesheet is now served from the cache
+	cssReq, _ := http.NewRequest("GET", "http://example.com/app.css", nil)
+	cssReq.Header.Set("Cookie", "session=1")
+	before := len(requests)
+	css, err := rt.RoundTrip(cssReq)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	data, _ := io.ReadAll(css.Body)
+	css.Body.Close()
+	if string(data) != "body of /app.css" || !HasNote(css, NoteCached) || len(requests) != before {
+		t.Errorf("Expected preloaded stylesheet from the cache, got %q (cached %v)", data, HasNote(css, NoteCached))
+	}
+}
+
+// TestPrefetcherCrossOrigin tests that credentials are only sent to the
+// page's origin and that other origins get a trimmed referrer
+func TestPrefetcherCrossOrigin(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]string{}
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		seen[req.URL.String()] = req.Header.Get("Authorization") + "|" + req.Header.Get("Cookie") + "|" + req.Header.Get("Referer")
+		mu.Unlock()
+		resp, err := stubResponse("ok").RoundTrip(req)
+		if req.URL.Path == "/page" {
+			resp.Header.Set("Link", `</same.css>; rel=preload, <http://example.com/plain.css>; rel=preload, <https://example.com:8443/port.css>; rel=preload`)
+		}
+		return resp, err
+	})
+	prefetcher := &Prefetcher{}
+	rt := Chain(next, prefetcher.Middleware())
+
+	req, _ := http.NewRequest("GET", "https://example.com/page?q=1", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Cookie", "session=1")
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+	prefetcher.Wait()
+
+	want := map[string]string{
+		"https://example.com/same.css":      "Bearer token|session=1|https://example.com/page?q=1",
+		"http://example.com/plain.css":      "||",
+		"https://example.com:8443/port.css": "||https://example.com/",
+	}
+	for u, headers := range want {
+		if seen[u] != headers {
+			t.Errorf("Expected %s to get %q, got %q", u, headers, seen[u])
+		}
+	}
+}
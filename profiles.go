@@ -0,0 +1,324 @@
+package curlhttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// Version is a semantic version such as 8.7.1
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// ParseVersion parses "major.minor[.patch]", ignoring a leading "v" and any
+// pre-release or build suffix
+func ParseVersion(s string) (Version, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, "-+ "); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version %q", s)
+		}
+		nums[i] = n
+	}
+	return Version{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+// Compare returns -1, 0 or 1 as v is older than, equal to or newer than other
+func (v Version) Compare(other Version) int {
+	for _, d := range [][2]int{{v.Major, other.Major}, {v.Minor, other.Minor}, {v.Patch, other.Patch}} {
+		if d[0] < d[1] {
+			return -1
+		}
+		if d[0] > d[1] {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// targetRequirements maps the targets this package knows about to the
+// libcurl version of the first curl-impersonate release that shipped them.
+// Targets missing from the table are not checked.
+var targetRequirements = map[string]Version{
+	"chrome99":         {8, 1, 1},
+	"chrome100":        {8, 1, 1},
+	"chrome101":        {8, 1, 1},
+	"chrome104":        {8, 1, 1},
+	"chrome107":        {8, 1, 1},
+	"chrome110":        {8, 1, 1},
+	"chrome116":        {8, 1, 1},
+	"chrome99_android": {8, 1, 1},
+	"edge99":           {8, 1, 1},
+	"edge101":          {8, 1, 1},
+	"safari15_3":       {8, 1, 1},
+	"safari15_5":       {8, 1, 1},
+
+	"chrome119":      {8, 5, 0},
+	"chrome120":      {8, 5, 0},
+	"safari17_0":     {8, 5, 0},
+	"safari17_2_ios": {8, 5, 0},
+
+	"chrome123": {8, 7, 1},
+	"chrome124": {8, 7, 1},
+
+	"chrome131":         {8, 10, 1},
+	"chrome131_android": {8, 10, 1},
+	"safari18_0":        {8, 10, 1},
+	"safari18_0_ios":    {8, 10, 1},
+	"firefox133":        {8, 10, 1},
+
+	"chrome133a":     {8, 13, 0},
+	"chrome136":      {8, 13, 0},
+	"firefox135":     {8, 13, 0},
+	"safari18_4":     {8, 13, 0},
+	"safari18_4_ios": {8, 13, 0},
+}
+
+// TargetRequirement returns the minimum libcurl version needed for target,
+// if the target is known
+func TargetRequirement(target string) (Version, bool) {
+	v, ok := targetRequirements[target]
+	return v, ok
+}
+
+var libcurlVersionPattern = regexp.MustCompile(`libcurl/(\d+\.\d+(?:\.\d+)?)`)
+
+// LibraryVersion returns the version of the loaded libcurl
+func LibraryVersion() (Version, error) {
+	return parseLibraryVersion(curl.Version())
+}
+
+// parseLibraryVersion extracts the libcurl version from a curl_version string
+func parseLibraryVersion(info string) (Version, error) {
+	m := libcurlVersionPattern.FindStringSubmatch(info)
+	if m == nil {
+		return Version{}, fmt.Errorf("no libcurl version in %q", info)
+	}
+	return ParseVersion(m[1])
+}
+
+// TargetPolicy decides what happens when the installed library is older than
+// the impersonation target requires.
+type TargetPolicy int
+
+const (
+	// TargetPolicyNone does not check targets. This is the default.
+	TargetPolicyNone TargetPolicy = iota
+
+	// TargetPolicyFail fails every request with an *UnsupportedTargetError.
+	TargetPolicyFail
+
+	// TargetPolicyDowngrade uses the newest target of the same browser that
+	// the library supports and reports the substitution to OnTargetDowngrade.
+	TargetPolicyDowngrade
+)
+
+// UnsupportedTargetError reports a target the installed library is too old for
+type UnsupportedTargetError struct {
+	Target    string
+	Required  Version
+	Installed Version
+}
+
+func (e *UnsupportedTargetError) Error() string {
+	return fmt.Sprintf("impersonation target %s requires libcurl %s, installed %s", e.Target, e.Required, e.Installed)
+}
+
+// CheckTarget reports whether target is usable with the given library version
+func CheckTarget(target string, installed Version) error {
+	required, ok := targetRequirements[target]
+	if !ok || installed.Compare(required) >= 0 {
+		return nil
+	}
+	return &UnsupportedTargetError{Target: target, Required: required, Installed: installed}
+}
+
+// NearestSupportedTarget returns the newest known target of the same browser
+// family and platform as target, no newer than target, that the installed
+// library supports
+func NearestSupportedTarget(target string, installed Version) (string, bool) {
+	family, variant, version := splitTarget(target)
+
+	best, bestVersion := "", -1
+	for name, required := range targetRequirements {
+		f, v, n := splitTarget(name)
+		if f != family || v != variant || n > version || installed.Compare(required) < 0 {
+			continue
+		}
+		if n > bestVersion {
+			best, bestVersion = name, n
+		}
+	}
+	return best, best != ""
+}
+
+// IsTargetAlias reports whether target is a browser name without a
+// version, such as "chrome", "firefox" or "safari_ios", which is resolved to
+// the newest matching target at runtime
+func IsTargetAlias(target string) bool {
+	return target != "" && !strings.ContainsAny(target, "0123456789")
+}
+
+// LatestTarget returns the newest known target for alias, a browser name
+// optionally followed by a platform as in "chrome_android", that the
+// installed library supports. A zero installed Version ignores library
+// requirements.
+func LatestTarget(alias string, installed Version) (string, bool) {
+	family, variant, _ := strings.Cut(alias, "_")
+
+	best, bestVersion := "", -1
+	for name, required := range targetRequirements {
+		f, v, n := splitTarget(name)
+		if f != family || v != variant {
+			continue
+		}
+		if installed != (Version{}) && installed.Compare(required) < 0 {
+			continue
+		}
+		if n > bestVersion {
+			best, bestVersion = name, n
+		}
+	}
+	return best, best != ""
+}
+
+// splitTarget splits a target such as "safari17_2_ios" into its browser
+// family ("safari"), platform variant ("ios") and a comparable version
+func splitTarget(target string) (family, variant string, version int) {
+	i := strings.IndexFunc(target, unicode.IsDigit)
+	if i < 0 {
+		return target, "", 0
+	}
+	family = target[:i]
+
+	var numbers []int
+	for _, part := range strings.Split(target[i:], "_") {
+		digits := part[:len(part)-len(strings.TrimLeftFunc(part, unicode.IsDigit))]
+		if n, err := strconv.Atoi(digits); err == nil {
+			// Revision letters, as in "chrome133a", are ignored
+			numbers = append(numbers, n)
+		} else {
+			variant = part
+		}
+	}
+	for i := 0; i < 3; i++ {
+		version *= 1000
+		if i < len(numbers) {
+			version += numbers[i]
+		}
+	}
+	return family, variant, version
+}
+
+// targetResolver caches the outcome of checking targets against the library
+type targetResolver struct {
+	mu       sync.Mutex
+	resolved map[string]resolvedTarget
+}
+
+type resolvedTarget struct {
+	target string
+	err    error
+}
+
+type targetKey struct{}
+
+// WithTarget returns a context that makes the Transport impersonate target,
+// instead of its ImpersonateTarget, for requests carrying it. Aliases and
+// TargetPolicy apply as they do to ImpersonateTarget.
+func WithTarget(ctx context.Context, target string) context.Context {
+	return context.WithValue(ctx, targetKey{}, target)
+}
+
+// requestTarget returns the target req should impersonate
+func (t *Transport) requestTarget(req *http.Request) (string, error) {
+	if target, ok := req.Context().Value(targetKey{}).(string); ok && target != "" {
+		return t.resolveTargetName(target)
+	}
+	return t.resolveTarget()
+}
+
+// resolveTarget resolves the configured target
+func (t *Transport) resolveTarget() (string, error) {
+	t.configMu.RLock()
+	target := t.ImpersonateTarget
+	t.configMu.RUnlock()
+	return t.resolveTargetName(target)
+}
+
+// resolveTargetName resolves an alias in target, applies TargetPolicy and
+// returns the target handles should impersonate
+func (t *Transport) resolveTargetName(target string) (string, error) {
+	if target == "" {
+		target = "chrome136"
+	}
+	alias := IsTargetAlias(target)
+	if t.TargetPolicy == TargetPolicyNone && !alias {
+		return target, nil
+	}
+
+	t.targets.mu.Lock()
+	defer t.targets.mu.Unlock()
+	if r, ok := t.targets.resolved[target]; ok {
+		return r.target, r.err
+	}
+
+	// Without a recognizable library version there is nothing to check,
+	// and aliases resolve to the newest known target
+	r := resolvedTarget{target: target}
+	installed, versionErr := t.libraryVersion()
+	if versionErr != nil {
+		installed = Version{}
+	}
+	// Names no known target matches are passed to curl unchanged
+	if alias {
+		if latest, ok := LatestTarget(target, installed); ok {
+			r.target = latest
+		}
+	}
+	if versionErr == nil && t.TargetPolicy != TargetPolicyNone {
+		r.err = CheckTarget(r.target, installed)
+		if r.err != nil && t.TargetPolicy == TargetPolicyDowngrade {
+			if nearest, ok := NearestSupportedTarget(target, installed); ok {
+				r.target, r.err = nearest, nil
+				if t.OnTargetDowngrade != nil {
+					t.OnTargetDowngrade(target, nearest)
+				}
+			}
+		}
+	}
+	if t.targets.resolved == nil {
+		t.targets.resolved = make(map[string]resolvedTarget)
+	}
+	t.targets.resolved[target] = r
+	return r.target, r.err
+}
+
+// libraryVersion returns the libcurl version targets are checked against
+func (t *Transport) libraryVersion() (Version, error) {
+	if t.libVersion != nil {
+		return t.libVersion()
+	}
+	return LibraryVersion()
+}
@@ -0,0 +1,150 @@
+package curlhttp
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// TestParseVersion tests semantic version parsing and comparison
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Version
+	}{
+		{"8.7.1", Version{8, 7, 1}},
+		{"v8.13", Version{8, 13, 0}},
+		{"8.13.0-DEV", Version{8, 13, 0}},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.input)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseVersion(%q): expected %v, got %v (%v)", tt.input, tt.want, got, err)
+		}
+	}
+	if _, err := ParseVersion("eight"); err == nil {
+		t.Error("Expected error for invalid version")
+	}
+
+	if (Version{8, 10, 1}).Compare(Version{8, 9, 9}) != 1 || (Version{8, 1, 1}).Compare(Version{8, 1, 1}) != 0 {
+		t.Error("Expected versions to compare numerically")
+	}
+}
+
+// TestParseLibraryVersion tests extracting the libcurl version from curl_version output
+func TestParseLibraryVersion(t *testing.T) {
+	got, err := parseLibraryVersion("libcurl/8.7.1 BoringSSL zlib/1.3 brotli/1.1.0 nghttp2/1.61.0")
+	if err != nil || got != (Version{8, 7, 1}) {
+		t.Errorf("Expected 8.7.1, got %v (%v)", got, err)
+	}
+}
+
+// TestNearestSupportedTarget tests downgrades within a browser family and platform
+func TestNearestSupportedTarget(t *testing.T) {
+	tests := []struct {
+		target    string
+		installed Version
+		want      string
+	}{
+		{"chrome136", Version{8, 7, 1}, "chrome124"},
+		{"chrome136", Version{8, 10, 1}, "chrome131"},
+		{"safari18_4_ios", Version{8, 10, 1}, "safari18_0_ios"},
+		{"chrome131_android", Version{8, 7, 1}, "chrome99_android"},
+	}
+	for _, tt := range tests {
+		got, ok := NearestSupportedTarget(tt.target, tt.installed)
+		if !ok || got != tt.want {
+			t.Errorf("NearestSupportedTarget(%s, %v): expected %s, got %q", tt.target, tt.installed, tt.want, got)
+		}
+	}
+	if _, ok := NearestSupportedTarget("firefox135", Version{8, 1, 1}); ok {
+		t.Error("Expected no supported firefox target on an old library")
+	}
+}
+
+// TestTargetPolicy tests failing and downgrading unsupported targets
+func TestTargetPolicy(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+	transport.libVersion = func() (Version, error) { return Version{8, 7, 1}, nil }
+	transport.TargetPolicy = TargetPolicyFail
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	var targetErr *UnsupportedTargetError
+	if _, err := transport.RoundTrip(req); !errors.As(err, &targetErr) {
+		t.Fatalf("Expected UnsupportedTargetError, got %v", err)
+	}
+	if targetErr.Target != "chrome136" || targetErr.Installed != (Version{8, 7, 1}) {
+		t.Errorf("Unexpected error details: %+v", targetErr)
+	}
+
+	transport = newFakeTransport(fake)
+	transport.libVersion = func() (Version, error) { return Version{8, 7, 1}, nil }
+	transport.TargetPolicy = TargetPolicyDowngrade
+	var downgraded string
+	transport.OnTargetDowngrade = func(requested, used string) { downgraded = requested + "->" + used }
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if fake.target != "chrome124" {
+		t.Errorf("Expected handle to impersonate chrome124, got %s", fake.target)
+	}
+	if downgraded != "chrome136->chrome124" {
+		t.Errorf("Expected downgrade callback, got %q", downgraded)
+	}
+}
+
+// TestLatestTarget tests resolving browser aliases to the newest supported target
+func TestLatestTarget(t *testing.T) {
+	tests := []struct {
+		alias     string
+		installed Version
+		want      string
+	}{
+		{"chrome", Version{}, "chrome136"},
+		{"chrome", Version{8, 7, 1}, "chrome124"},
+		{"firefox", Version{8, 10, 1}, "firefox133"},
+		{"safari", Version{8, 13, 0}, "safari18_4"},
+		{"safari_ios", Version{8, 5, 0}, "safari17_2_ios"},
+		{"chrome_android", Version{8, 13, 0}, "chrome131_android"},
+		{"edge", Version{}, "edge101"},
+	}
+	for _, tt := range tests {
+		got, ok := LatestTarget(tt.alias, tt.installed)
+		if !ok || got != tt.want {
+			t.Errorf("LatestTarget(%s, %v): expected %s, got %q", tt.alias, tt.installed, tt.want, got)
+		}
+	}
+	if _, ok := LatestTarget("firefox", Version{8, 5, 0}); ok {
+		t.Error("Expected no firefox target on an old library")
+	}
+	if IsTargetAlias("chrome136") || !IsTargetAlias("chrome") {
+		t.Error("Expected only unversioned names to be aliases")
+	}
+}
+
+// TestTargetAliasResolution tests that the Transport impersonates the resolved alias
+func TestTargetAliasResolution(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+	transport.ImpersonateTarget = "chrome"
+	transport.libVersion = func() (Version, error) { return Version{8, 10, 1}, nil }
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if fake.target != "chrome131" {
+		t.Errorf("Expected handle to impersonate chrome131, got %s", fake.target)
+	}
+
+	transport = newFakeTransport(fake)
+	transport.ImpersonateTarget = "netscape"
+	transport.libVersion = func() (Version, error) { return Version{8, 10, 1}, nil }
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if fake.target != "netscape" {
+		t.Errorf("Expected an unknown name to be used unchanged, got %s", fake.target)
+	}
+}
@@ -0,0 +1,269 @@
+package curlhttp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+type proxyKey struct{}
+
+// WithProxy returns a context that sends requests carrying it through
+// proxy instead of the Transport's Proxy, so one client can route some
+// requests through a different exit. A nil proxy connects directly, even
+// when the Transport has a Proxy or one is set in the environment.
+// Redirects made with the same context use the same proxy.
+func WithProxy(ctx context.Context, proxy *url.URL) context.Context {
+	return context.WithValue(ctx, proxyKey{}, proxy)
+}
+
+// proxyFor returns the proxy for requests made with ctx, or nil for a
+// direct connection. override reports whether it came from WithProxy.
+func (t *Transport) proxyFor(ctx context.Context) (proxy *url.URL, override bool) {
+	if proxy, ok := ctx.Value(proxyKey{}).(*url.URL); ok {
+		return proxy, true
+	}
+	t.configMu.RLock()
+	defer t.configMu.RUnlock()
+	return t.Proxy, false
+}
+
+// proxyRoute is the proxy configuration of a request: the proxy, if any,
+// and the headers sent to it
+type proxyRoute struct {
+	proxy    *url.URL
+	override bool // chosen with WithProxy
+	header   []string
+	tls      *ProxyTLSConfig // Transport.ProxyTLS when the route was chosen
+}
+
+// key identifies the route in the handle pool. curl only matches pooled
+// connections on the proxy's host and port, so the key also covers the
+// proxy credentials and headers, which commercial proxies use to select
+// sticky exits: connections are never reused across routes.
+func (r proxyRoute) key() string {
+	switch {
+	case r.proxy != nil:
+		return strings.Join(append([]string{r.proxy.String()}, r.header...), "\n")
+	case r.override:
+		return "direct"
+	}
+	return "" // the Transport's default: no proxy, or one from the environment
+}
+
+// route returns the proxy route of a request to target made with ctx
+func (t *Transport) route(ctx context.Context, target *url.URL) (proxyRoute, error) {
+	proxy, override := t.proxyFor(ctx)
+	r := proxyRoute{proxy: proxy, override: override}
+	if proxy != nil {
+		t.configMu.RLock()
+		r.tls = t.ProxyTLS
+		t.configMu.RUnlock()
+		var err error
+		if r.header, err = t.proxyConnectHeader(ctx, proxy, target); err != nil {
+			return proxyRoute{}, err
+		}
+	}
+	return r, nil
+}
+
+// setProxy configures easy to use the proxy of route
+func (t *Transport) setProxy(easy curlEngine, r proxyRoute) error {
+	proxy, override := r.proxy, r.override
+	switch {
+	case proxy != nil:
+		if r.tls != nil {
+			// Handles are set up ignoring errors; report a bad version here
+			if _, err := r.tls.curlVersion(); err != nil {
+				return err
+			}
+		}
+		if err := easy.Setopt(curl.OPT_PROXY, proxy.String()); err != nil {
+			return fmt.Errorf("failed to set proxy: %w", err)
+		}
+		if override {
+			// Handles are only set up for the Transport's Proxy
+			cfg := r.tls
+			if cfg == nil {
+				cfg = &defaultProxyTLS
+			}
+			if err := t.applyProxyTLS(easy, cfg); err != nil {
+				return err
+			}
+		}
+		if len(r.header) > 0 {
+			if err := easy.Setopt(curl.OPT_PROXYHEADER, r.header); err != nil {
+				return fmt.Errorf("failed to set proxy headers: %w", err)
+			}
+		}
+	case override:
+		// An empty proxy also overrides the environment
+		if err := easy.Setopt(curl.OPT_PROXY, ""); err != nil {
+			return fmt.Errorf("failed to disable proxy: %w", err)
+		}
+	}
+	return nil
+}
+
+// proxyConnectHeader returns the header lines sent to proxy for target,
+// sorted by name
+func (t *Transport) proxyConnectHeader(ctx context.Context, proxy, target *url.URL) ([]string, error) {
+	header := t.ProxyConnectHeader
+	if t.GetProxyConnectHeader != nil {
+		port := target.Port()
+		if port == "" {
+			if n, err := urlPort(target.Scheme, ""); err == nil {
+				port = strconv.Itoa(n)
+			}
+		}
+		var err error
+		if header, err = t.GetProxyConnectHeader(ctx, proxy, net.JoinHostPort(target.Hostname(), port)); err != nil {
+			return nil, err
+		}
+	}
+	var lines []string
+	for _, name := range slices.Sorted(maps.Keys(header)) {
+		for _, value := range header[name] {
+			lines = append(lines, name+": "+value)
+		}
+	}
+	return lines, nil
+}
+
+// ProxyTLSConfig configures TLS to https:// proxies. Certificates are
+// verified against the system roots unless InsecureSkipVerify is set.
+type ProxyTLSConfig struct {
+	// InsecureSkipVerify accepts any certificate from the proxy
+	InsecureSkipVerify bool
+
+	// CAFile is a PEM bundle of CA certificates to verify the proxy with
+	// instead of the system roots
+	CAFile string
+
+	// CertFile and KeyFile are a PEM client certificate and its key,
+	// presented to proxies that require mutual TLS
+	CertFile string
+	KeyFile  string
+
+	// PinnedPublicKey pins the proxy's public key, in curl's format: the
+	// path of a PEM or DER public key, or "sha256//" base64 hashes
+	// separated by ";"
+	PinnedPublicKey string
+
+	// MinVersion is the lowest TLS version accepted, such as
+	// tls.VersionTLS12. Zero leaves curl's default.
+	MinVersion uint16
+}
+
+// curlSSLVersions maps TLS versions to curl's CURL_SSLVERSION values
+var curlSSLVersions = map[uint16]int{
+	tls.VersionTLS10: 4,
+	tls.VersionTLS11: 5,
+	tls.VersionTLS12: 6,
+	tls.VersionTLS13: 7,
+}
+
+// curlVersion returns the CURL_SSLVERSION value for MinVersion
+func (c *ProxyTLSConfig) curlVersion() (int, error) {
+	if c.MinVersion == 0 {
+		return 0, nil
+	}
+	version, ok := curlSSLVersions[c.MinVersion]
+	if !ok {
+		return 0, fmt.Errorf("unsupported proxy TLS version %#x", c.MinVersion)
+	}
+	return version, nil
+}
+
+// defaultProxyTLS is used when Transport.ProxyTLS is nil
+var defaultProxyTLS = ProxyTLSConfig{InsecureSkipVerify: true}
+
+// applyProxyTLS sets the proxy TLS options of cfg on easy
+func (t *Transport) applyProxyTLS(easy curlEngine, cfg *ProxyTLSConfig) error {
+	version, err := cfg.curlVersion()
+	if err != nil {
+		return err
+	}
+	verifyHost := 0
+	if !cfg.InsecureSkipVerify {
+		verifyHost = 2
+	}
+	options := []struct {
+		opt   curl.EasyOpt
+		value interface{}
+		name  string
+	}{
+		{curl.OPT_PROXY_SSL_VERIFYPEER, !cfg.InsecureSkipVerify, "verification"},
+		{curl.OPT_PROXY_SSL_VERIFYHOST, verifyHost, "host verification"},
+		{curl.OPT_PROXY_CAINFO, cfg.CAFile, "CA file"},
+		{curl.OPT_PROXY_SSLCERT, cfg.CertFile, "client certificate"},
+		{curl.OPT_PROXY_SSLKEY, cfg.KeyFile, "client key"},
+		{curl.OPT_PROXY_PINNEDPUBLICKEY, cfg.PinnedPublicKey, "pinned public key"},
+		{curl.OPT_PROXY_SSLVERSION, version, "TLS version"},
+	}
+	for _, o := range options {
+		if s, ok := o.value.(string); ok && s == "" {
+			// Only set what is configured, leaving curl's defaults
+			continue
+		}
+		if err := easy.Setopt(o.opt, o.value); err != nil {
+			return fmt.Errorf("failed to set proxy TLS %s: %w", o.name, err)
+		}
+	}
+	return nil
+}
+
+// ProxyError is returned (wrapped) when a request fails at its proxy
+// rather than at the origin: the proxy could not be resolved or reached,
+// refused the CONNECT tunnel, for example with 407 Proxy Authentication
+// Required, or timed out before the request got through it. Check for it
+// with errors.As.
+type ProxyError struct {
+	Proxy      string // proxy URL, without password
+	StatusCode int    // status of a refused CONNECT; 0 if there was none
+	Err        error  // the curl error
+}
+
+func (e *ProxyError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("proxy %s refused CONNECT with status %d: %v", e.Proxy, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("proxy %s failed: %v", e.Proxy, e.Err)
+}
+
+func (e *ProxyError) Unwrap() error {
+	return e.Err
+}
+
+// checkProxyError wraps err in a ProxyError if the transfer made through
+// proxy on easy failed at the proxy. A nil proxy means a direct transfer.
+func (t *Transport) checkProxyError(proxy *url.URL, easy curlEngine, err error) error {
+	var dead *deadConnError
+	if proxy == nil || errors.As(err, &dead) {
+		return err
+	}
+	proxyErr := &ProxyError{Proxy: proxy.Redacted(), Err: err}
+	connectCode := getinfoInt(easy, infoHTTPConnectCode)
+	var curlErr curl.CurlError
+	errors.As(err, &curlErr) // stays 0, CURLE_OK, for other errors
+	switch {
+	case connectCode >= 300:
+		proxyErr.StatusCode = int(connectCode)
+	case curlErr == curl.CurlError(curl.E_COULDNT_RESOLVE_PROXY), curlErr == curl.CurlError(curl.E_PROXY), curlErr == curl.CurlError(curl.E_COULDNT_CONNECT):
+		// With a proxy, curl only ever connects to the proxy
+	case curlErr == curl.CurlError(curl.E_OPERATION_TIMEDOUT) && connectCode == 0 && getinfoDuration(easy, infoPretransferTime) == 0:
+		// The request was never sent: stuck connecting to or through the proxy
+	default:
+		return err
+	}
+	return proxyErr
+}
@@ -0,0 +1,197 @@
+package curlhttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// QueryEncoding selects how a QueryBuilder escapes names and values
+type QueryEncoding int
+
+const (
+	// QueryForm escapes as browsers do for form submissions and
+	// URLSearchParams: spaces become "+" and everything except letters,
+	// digits and "*-._" is percent-encoded. This is the default.
+	QueryForm QueryEncoding = iota
+
+	// QueryComponent escapes as JavaScript's encodeURIComponent, which
+	// pages building URLs by hand use: spaces become "%20" and letters,
+	// digits and "-_.!~*'()" are kept
+	QueryComponent
+)
+
+// queryPair is one name and value of a query string
+type queryPair struct {
+	key, value string
+}
+
+// QueryBuilder builds query strings the way browsers do. Unlike
+// url.Values.Encode it keeps parameters in the order they were added, and
+// when applied to a URL it keeps the URL's own query as it is instead of
+// decoding, sorting and re-escaping it, so the request matches what a
+// browser would send.
+//
+//	u, err := curlhttp.NewQueryBuilder().
+//		Add("q", "go curl").
+//		Add("page", "2").
+//		Apply("https://example.com/search?lang=en")
+//	// https://example.com/search?lang=en&q=go+curl&page=2
+type QueryBuilder struct {
+	pairs    []queryPair
+	replace  map[string]bool // names whose parameters in the URL are dropped
+	encoding QueryEncoding
+}
+
+// NewQueryBuilder returns an empty QueryBuilder using QueryForm
+func NewQueryBuilder() *QueryBuilder {
+	return &QueryBuilder{replace: make(map[string]bool)}
+}
+
+// Encoding sets how names and values are escaped
+func (q *QueryBuilder) Encoding(e QueryEncoding) *QueryBuilder {
+	q.encoding = e
+	return q
+}
+
+// Add appends a parameter
+func (q *QueryBuilder) Add(key, value string) *QueryBuilder {
+	q.pairs = append(q.pairs, queryPair{key, value})
+	return q
+}
+
+// AddValues appends the parameters of v, in key order since url.Values
+// has none of its own
+func (q *QueryBuilder) AddValues(v url.Values) *QueryBuilder {
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		for _, value := range v[key] {
+			q.Add(key, value)
+		}
+	}
+	return q
+}
+
+// Set replaces every parameter named key, including those in the URL it
+// is applied to, with a single one. A parameter added earlier keeps its
+// position.
+func (q *QueryBuilder) Set(key, value string) *QueryBuilder {
+	q.replace[key] = true
+	i := slices.IndexFunc(q.pairs, func(p queryPair) bool { return p.key == key })
+	if i < 0 {
+		return q.Add(key, value)
+	}
+	q.pairs[i].value = value
+	rest := slices.DeleteFunc(q.pairs[i+1:], func(p queryPair) bool { return p.key == key })
+	q.pairs = q.pairs[:i+1+len(rest)]
+	return q
+}
+
+// Del removes every parameter named key, including those in the URL it is
+// applied to
+func (q *QueryBuilder) Del(key string) *QueryBuilder {
+	q.replace[key] = true
+	q.pairs = slices.DeleteFunc(q.pairs, func(p queryPair) bool { return p.key == key })
+	return q
+}
+
+// Encode returns the query string, without a leading "?"
+func (q *QueryBuilder) Encode() string {
+	parts := make([]string, len(q.pairs))
+	for i, p := range q.pairs {
+		parts[i] = q.escape(p.key) + "=" + q.escape(p.value)
+	}
+	return strings.Join(parts, "&")
+}
+
+// Apply returns rawURL with the parameters added to its query. Parameters
+// already in the URL keep their order and escaping unless Set or Del named
+// them; the fragment is kept.
+func (q *QueryBuilder) Apply(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse URL: %w", err)
+	}
+	u.RawQuery = q.merge(u.RawQuery)
+	u.ForceQuery = false
+	return u.String(), nil
+}
+
+// merge appends the parameters to rawQuery, dropping the parameters of
+// rawQuery that Set or Del replaced
+func (q *QueryBuilder) merge(rawQuery string) string {
+	var parts []string
+	for _, part := range strings.Split(rawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(part, "=")
+		if key, err := url.QueryUnescape(name); err == nil && q.replace[key] {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if encoded := q.Encode(); encoded != "" {
+		parts = append(parts, encoded)
+	}
+	return strings.Join(parts, "&")
+}
+
+// escape escapes s for a query string under q's encoding
+func (q *QueryBuilder) escape(s string) string {
+	return escapeQuery(s, q.encoding)
+}
+
+// escapeQuery escapes s for a query string or form body under encoding
+func escapeQuery(s string, encoding QueryEncoding) string {
+	keep := formUnreserved
+	if encoding == QueryComponent {
+		keep = componentUnreserved
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' && encoding == QueryForm:
+			b.WriteByte('+')
+		case c < 0x80 && (isASCIIAlnum(c) || strings.IndexByte(keep, c) >= 0):
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// Characters besides letters and digits left unescaped by each encoding
+const (
+	formUnreserved      = "*-._"
+	componentUnreserved = "-_.!~*'()"
+)
+
+func isASCIIAlnum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// GetWithParams requests rawURL with params added to its query as a
+// browser form submission would encode them, keeping any query rawURL
+// already has unchanged. Use a QueryBuilder for control over order,
+// replacement and escaping.
+func (c *Client) GetWithParams(ctx context.Context, rawURL string, params url.Values) (*Response, error) {
+	target, err := NewQueryBuilder().AddValues(params).Apply(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
@@ -0,0 +1,97 @@
+package curlhttp
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// TestQueryBuilderEncoding tests form and component escaping
+func TestQueryBuilderEncoding(t *testing.T) {
+	tests := []struct {
+		encoding QueryEncoding
+		want     string
+	}{
+		{QueryForm, "q=go+curl%2Fhttp&x=a*b%7Ec%27%28%29&%C3%A9=%26"},
+		{QueryComponent, "q=go%20curl%2Fhttp&x=a*b~c'()&%C3%A9=%26"},
+	}
+	for _, tt := range tests {
+		got := NewQueryBuilder().
+			Encoding(tt.encoding).
+			Add("q", "go curl/http").
+			Add("x", "a*b~c'()").
+			Add("é", "&").
+			Encode()
+		if got != tt.want {
+			t.Errorf("Expected %s, got %s", tt.want, got)
+		}
+	}
+}
+
+// TestQueryBuilderApply tests merging with an existing query string
+func TestQueryBuilderApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(q *QueryBuilder)
+		url   string
+		want  string
+	}{
+		{
+			name:  "keeps order and existing escaping",
+			build: func(q *QueryBuilder) { q.Add("z", "1").Add("a", "2") },
+			url:   "https://example.com/s?q=a%20b&lang=en#top",
+			want:  "https://example.com/s?q=a%20b&lang=en&z=1&a=2#top",
+		},
+		{
+			name:  "set replaces URL parameters",
+			build: func(q *QueryBuilder) { q.Add("page", "1").Add("q", "x").Set("page", "3") },
+			url:   "https://example.com/s?page=2&sort=new&page=9",
+			want:  "https://example.com/s?sort=new&page=3&q=x",
+		},
+		{
+			name:  "del removes parameters",
+			build: func(q *QueryBuilder) { q.Del("token") },
+			url:   "https://example.com/s?token=abc&id=1",
+			want:  "https://example.com/s?id=1",
+		},
+		{
+			name:  "no query",
+			build: func(q *QueryBuilder) { q.Add("id", "1") },
+			url:   "https://example.com/s",
+			want:  "https://example.com/s?id=1",
+		},
+	}
+	for _, tt := range tests {
+		q := NewQueryBuilder()
+		tt.build(q)
+		got, err := q.Apply(tt.url)
+		if err != nil {
+			t.Fatalf("%s: Apply failed: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: Expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestClientGetWithParams tests that params are added to the request URL
+func TestClientGetWithParams(t *testing.T) {
+	var seen []*http.Request
+	client := recordingClient(&seen)
+
+	params := url.Values{"q": {"go curl"}, "page": {"2"}}
+	resp, err := client.GetWithParams(context.Background(), "https://example.com/search?lang=en", params)
+	if err != nil {
+		t.Fatalf("GetWithParams failed: %v", err)
+	}
+	resp.Body.Close()
+
+	want := "https://example.com/search?lang=en&page=2&q=go+curl"
+	if got := seen[0].URL.String(); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if seen[0].Method != http.MethodGet {
+		t.Errorf("Expected GET, got %s", seen[0].Method)
+	}
+}
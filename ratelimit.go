@@ -0,0 +1,177 @@
+package curlhttp
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit is the rate limit state a server advertised in a response,
+// from Retry-After and the RateLimit-* or X-RateLimit-* header families.
+// Clients can consult it to slow down before they are throttled.
+type RateLimit struct {
+	// Limit is the number of requests allowed per window, or -1 if the
+	// server did not say.
+	Limit int
+
+	// Remaining is the number of requests left in the current window, or
+	// -1 if the server did not say.
+	Remaining int
+
+	// Reset is when the current window ends, or zero if unknown.
+	Reset time.Time
+
+	// RetryAt is when Retry-After allows the next request, or zero if the
+	// response had no Retry-After header.
+	RetryAt time.Time
+}
+
+// unixThreshold separates reset values given as Unix timestamps from those
+// given as seconds to wait; no window lasts longer than about 30 years
+const unixThreshold = 1e9
+
+// Delay returns how long to wait after now before sending the next request:
+// until RetryAt, or until Reset if no requests remain in the window
+func (r *RateLimit) Delay(now time.Time) time.Duration {
+	until := r.RetryAt
+	if r.Remaining == 0 && r.Reset.After(until) {
+		until = r.Reset
+	}
+	if d := until.Sub(now); d > 0 && !until.IsZero() {
+		return d
+	}
+	return 0
+}
+
+// ParseRetryAfter parses a Retry-After value, either delay seconds or an
+// HTTP date, into the time it refers to
+func ParseRetryAfter(value string, now time.Time) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return time.Time{}, false
+		}
+		return now.Add(secondsDuration(float64(seconds))), true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// ParseRateLimit extracts the rate limit state from response headers,
+// resolving relative values against now. It understands Retry-After, the
+// IETF RateLimit-Limit, -Remaining and -Reset fields and their combined
+// RateLimit form, and the common X-RateLimit-* and X-Rate-Limit-*
+// variants, whose reset may be a Unix timestamp or seconds to wait. It
+// reports false if none of them are present.
+func ParseRateLimit(h http.Header, now time.Time) (*RateLimit, bool) {
+	r := &RateLimit{Limit: -1, Remaining: -1}
+	found := false
+
+	if at, ok := ParseRetryAfter(h.Get("Retry-After"), now); ok {
+		r.RetryAt = at
+		found = true
+	}
+	if v := h.Get("RateLimit"); v != "" {
+		found = parseCombinedRateLimit(r, v, now) || found
+	}
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-", "X-Rate-Limit-"} {
+		if n, ok := rateLimitInt(h.Get(prefix + "Limit")); ok && r.Limit < 0 {
+			r.Limit, found = n, true
+		}
+		if n, ok := rateLimitInt(h.Get(prefix + "Remaining")); ok && r.Remaining < 0 {
+			r.Remaining, found = n, true
+		}
+		if at, ok := rateLimitReset(h.Get(prefix+"Reset"), now); ok && r.Reset.IsZero() {
+			r.Reset, found = at, true
+		}
+		if at, ok := rateLimitReset(h.Get(prefix+"Reset-After"), now); ok && r.Reset.IsZero() {
+			r.Reset, found = at, true
+		}
+	}
+	if !found {
+		return nil, false
+	}
+	return r, true
+}
+
+// ResponseRateLimit returns the rate limit state advertised by resp,
+// resolving relative values against the current time
+func ResponseRateLimit(resp *http.Response) (*RateLimit, bool) {
+	return ParseRateLimit(resp.Header, time.Now())
+}
+
+// parseCombinedRateLimit parses the single RateLimit field, both the
+// "limit=100, remaining=50, reset=30" form and the later
+// `"policy";r=50;t=30` form
+func parseCombinedRateLimit(r *RateLimit, value string, now time.Time) bool {
+	found := false
+	for _, item := range strings.FieldsFunc(value, func(c rune) bool { return c == ',' || c == ';' }) {
+		key, val, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		n, err := strconv.Atoi(strings.Trim(strings.TrimSpace(val), `"`))
+		if err != nil || n < 0 {
+			continue
+		}
+		switch key {
+		case "limit", "q":
+			r.Limit, found = n, true
+		case "remaining", "r":
+			r.Remaining, found = n, true
+		case "reset", "t":
+			r.Reset, found = now.Add(secondsDuration(float64(n))), true
+		}
+	}
+	return found
+}
+
+// rateLimitInt parses a count, taking the first item of a list such as
+// "100, 100;w=60"
+func rateLimitInt(value string) (int, bool) {
+	value, _, _ = strings.Cut(value, ",")
+	value, _, _ = strings.Cut(value, ";")
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// rateLimitReset parses a reset value: seconds to wait, possibly
+// fractional, or a Unix timestamp in seconds or milliseconds
+func rateLimitReset(value string, now time.Time) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return time.Time{}, false
+	}
+	switch {
+	case n >= unixThreshold*1000:
+		return time.UnixMilli(int64(n)), true
+	case n >= unixThreshold:
+		return time.Unix(int64(n), 0), true
+	default:
+		return now.Add(secondsDuration(n)), true
+	}
+}
+
+// secondsDuration converts seconds to a Duration, saturating on overflow
+func secondsDuration(seconds float64) time.Duration {
+	if seconds >= float64(math.MaxInt64)/float64(time.Second) {
+		return math.MaxInt64
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
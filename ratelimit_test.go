@@ -0,0 +1,108 @@
+package curlhttp
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestParseRetryAfter tests delay seconds, HTTP dates and invalid values
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+		ok    bool
+	}{
+		{"120", now.Add(2 * time.Minute), true},
+		{" 0 ", now, true},
+		{"Tue, 02 Jan 2024 03:10:00 GMT", time.Date(2024, 1, 2, 3, 10, 0, 0, time.UTC), true},
+		{"-5", time.Time{}, false},
+		{"soon", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseRetryAfter(tt.value, now)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("ParseRetryAfter(%q): Expected %v %v, got %v %v", tt.value, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+// TestParseRateLimit tests the supported header families
+func TestParseRateLimit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name      string
+		header    map[string]string
+		limit     int
+		remaining int
+		reset     time.Time
+	}{
+		{"ietf", map[string]string{"RateLimit-Limit": "100, 100;w=60", "RateLimit-Remaining": "7", "RateLimit-Reset": "30"}, 100, 7, now.Add(30 * time.Second)},
+		{"combined", map[string]string{"RateLimit": "limit=10, remaining=0, reset=5"}, 10, 0, now.Add(5 * time.Second)},
+		{"structured", map[string]string{"RateLimit": `"default";r=3;t=9`}, -1, 3, now.Add(9 * time.Second)},
+		{"github", map[string]string{"X-RateLimit-Limit": "5000", "X-RateLimit-Remaining": "4999", "X-RateLimit-Reset": "1700000600"}, 5000, 4999, time.Unix(1700000600, 0)},
+		{"milliseconds", map[string]string{"X-RateLimit-Reset": "1700000600500"}, -1, -1, time.UnixMilli(1700000600500)},
+		{"reset after", map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset-After": "1.5"}, -1, 0, now.Add(1500 * time.Millisecond)},
+		{"dashed", map[string]string{"X-Rate-Limit-Limit": "15", "X-Rate-Limit-Remaining": "14"}, 15, 14, time.Time{}},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		for k, v := range tt.header {
+			h.Set(k, v)
+		}
+		rl, ok := ParseRateLimit(h, now)
+		if !ok {
+			t.Errorf("%s: Expected rate limit headers to be found", tt.name)
+			continue
+		}
+		if rl.Limit != tt.limit || rl.Remaining != tt.remaining || !rl.Reset.Equal(tt.reset) {
+			t.Errorf("%s: Expected %d %d %v, got %+v", tt.name, tt.limit, tt.remaining, tt.reset, rl)
+		}
+	}
+
+	if _, ok := ParseRateLimit(http.Header{"Content-Type": {"text/plain"}}, now); ok {
+		t.Error("Expected no rate limit without the headers")
+	}
+}
+
+// TestRateLimitDelay tests the wait derived from Retry-After and exhausted windows
+func TestRateLimitDelay(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	h := http.Header{}
+	h.Set("Retry-After", "10")
+	h.Set("X-RateLimit-Remaining", "0")
+	h.Set("X-RateLimit-Reset", "60")
+	rl, _ := ParseRateLimit(h, now)
+	if d := rl.Delay(now); d != time.Minute {
+		t.Errorf("Expected to wait for the window reset, got %v", d)
+	}
+
+	h.Set("X-RateLimit-Remaining", "5")
+	rl, _ = ParseRateLimit(h, now)
+	if d := rl.Delay(now); d != 10*time.Second {
+		t.Errorf("Expected to wait for Retry-After, got %v", d)
+	}
+	if d := rl.Delay(now.Add(time.Hour)); d != 0 {
+		t.Errorf("Expected no wait once Retry-After passed, got %v", d)
+	}
+}
+
+// TestResponseExtraRateLimit tests that the rate limit is reachable from the response extension
+func TestResponseExtraRateLimit(t *testing.T) {
+	fake := newFakeEngine("hello")
+	fake.headers = []string{"HTTP/1.1 200 OK\r\n", "X-RateLimit-Limit: 60\r\n", "X-RateLimit-Remaining: 59\r\n", "\r\n"}
+	transport := newFakeTransport(fake)
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	defer resp.Body.Close()
+	extra, _ := ResponseExtra(resp)
+	if extra == nil || extra.RateLimit == nil || extra.RateLimit.Limit != 60 || extra.RateLimit.Remaining != 59 {
+		t.Errorf("Expected the rate limit in the response extension, got %+v", extra)
+	}
+}
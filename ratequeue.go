@@ -0,0 +1,125 @@
+package curlhttp
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RateLimitQueue makes 429 Too Many Requests responses transparent: when
+// one carries Retry-After, the request waits as long as the server asked
+// and is sent again, and the caller only sees the 429 if the retries run
+// out, the wait is too long or it would outlast the request's context
+// deadline. Install it with Transport.Use(q.Middleware()).
+//
+// Requests with a body are only retried if it can be replayed through
+// GetBody.
+type RateLimitQueue struct {
+	// MaxRetries bounds the retries of one request. Defaults to 3.
+	MaxRetries int
+
+	// MaxWait is the longest wait honored; a 429 asking for more is
+	// returned to the caller. Defaults to one minute.
+	MaxWait time.Duration
+
+	// Hosts, if set, limits queueing to the listed hostnames and can
+	// override the limits per host. Zero fields inherit the values above.
+	Hosts map[string]QueueLimits
+
+	// Budget, if set, must fund each retry; when it is spent the 429 is
+	// returned to the caller
+	Budget *RetryBudget
+
+	// Clock schedules the waits. Defaults to SystemClock.
+	Clock Clock
+}
+
+// QueueLimits are the RateLimitQueue limits for one host
+type QueueLimits struct {
+	MaxRetries int
+	MaxWait    time.Duration
+}
+
+// limits returns the limits for host and whether it is queued at all
+func (q *RateLimitQueue) limits(host string) (QueueLimits, bool) {
+	limits := QueueLimits{MaxRetries: q.MaxRetries, MaxWait: q.MaxWait}
+	if q.Hosts != nil {
+		override, ok := q.Hosts[strings.ToLower(host)]
+		if !ok {
+			return limits, false
+		}
+		if override.MaxRetries > 0 {
+			limits.MaxRetries = override.MaxRetries
+		}
+		if override.MaxWait > 0 {
+			limits.MaxWait = override.MaxWait
+		}
+	}
+	if limits.MaxRetries <= 0 {
+		limits.MaxRetries = 3
+	}
+	if limits.MaxWait <= 0 {
+		limits.MaxWait = time.Minute
+	}
+	return limits, true
+}
+
+// Middleware returns middleware that waits out and retries 429 responses
+func (q *RateLimitQueue) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			limits, ok := q.limits(req.URL.Hostname())
+			if !ok || !canReplayBody(req) {
+				return next.RoundTrip(req)
+			}
+			clock := clockOrSystem(q.Clock)
+			ctx := req.Context()
+
+			attempt := req
+			for retries := 0; ; retries++ {
+				resp, err := next.RoundTrip(attempt)
+				if err != nil || resp.StatusCode != http.StatusTooManyRequests || retries >= limits.MaxRetries {
+					return resp, err
+				}
+				wait, ok := q.retryDelay(req, resp, clock.Now(), limits)
+				if !ok || !allowRetry(q.Budget, req) {
+					return resp, nil
+				}
+
+				io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+				resp.Body.Close()
+				select {
+				case <-clock.After(wait):
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+
+				attempt = req.Clone(WithAttempt(ctx, Attempt(ctx)+retries+1))
+				if req.GetBody != nil {
+					if attempt.Body, err = req.GetBody(); err != nil {
+						return nil, err
+					}
+				}
+			}
+		})
+	}
+}
+
+// retryDelay returns how long to wait before retrying after the 429 resp.
+// It reports false if the response has no Retry-After or the wait exceeds
+// MaxWait or the request's deadline.
+func (q *RateLimitQueue) retryDelay(req *http.Request, resp *http.Response, now time.Time, limits QueueLimits) (time.Duration, bool) {
+	rl, ok := ParseRateLimit(resp.Header, now)
+	if !ok || rl.RetryAt.IsZero() {
+		return 0, false
+	}
+	wait := rl.Delay(now)
+	if wait > limits.MaxWait {
+		return 0, false
+	}
+	if deadline, ok := req.Context().Deadline(); ok && now.Add(wait).After(deadline) {
+		return 0, false
+	}
+	return wait, true
+}
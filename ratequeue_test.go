@@ -0,0 +1,121 @@
+package curlhttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// throttlingServer answers 429 with retryAfter until limited requests have
+// been made, then 200, recording the attempt of each request
+func throttlingServer(limited int, retryAfter string) (http.RoundTripper, func() []int) {
+	var mu sync.Mutex
+	var attempts []int
+	rt := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		attempts = append(attempts, Attempt(req.Context()))
+		n := len(attempts)
+		mu.Unlock()
+		resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader("ok")), Request: req}
+		if n <= limited {
+			resp.StatusCode = http.StatusTooManyRequests
+			if retryAfter != "" {
+				resp.Header.Set("Retry-After", retryAfter)
+			}
+		}
+		return resp, nil
+	})
+	return rt, func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), attempts...)
+	}
+}
+
+// TestRateLimitQueue tests that 429s are waited out and retried transparently
+func TestRateLimitQueue(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	next, attempts := throttlingServer(2, "2")
+	rt := Chain(next, (&RateLimitQueue{Clock: clock}).Middleware())
+
+	done := make(chan *http.Response)
+	go func() {
+		req, _ := http.NewRequest("POST", "https://api.example.com/", strings.NewReader("data"))
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Errorf("RoundTrip failed: %v", err)
+		}
+		done <- resp
+	}()
+	for i := 0; i < 2; i++ {
+		for clock.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(2 * time.Second)
+	}
+	resp := <-done
+	if resp == nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the 429s to be hidden, got %v", resp)
+	}
+	if got := attempts(); len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("Expected attempts 1, 2 and 3, got %v", got)
+	}
+}
+
+// TestRateLimitQueueSurfaces tests the cases where the 429 is returned
+func TestRateLimitQueueSurfaces(t *testing.T) {
+	deadline, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	tests := []struct {
+		name       string
+		queue      *RateLimitQueue
+		retryAfter string
+		ctx        context.Context
+		calls      int
+	}{
+		{"no retry-after", &RateLimitQueue{}, "", context.Background(), 1},
+		{"wait too long", &RateLimitQueue{}, "120", context.Background(), 1},
+		{"past deadline", &RateLimitQueue{MaxWait: 24 * time.Hour}, "7200", deadline, 1},
+		{"host not listed", &RateLimitQueue{Hosts: map[string]QueueLimits{"other.com": {}}}, "0", context.Background(), 1},
+		{"retries exhausted", &RateLimitQueue{MaxRetries: 1}, "0", context.Background(), 2},
+		{"host override", &RateLimitQueue{Hosts: map[string]QueueLimits{"api.example.com": {MaxRetries: 2}}}, "0", context.Background(), 3},
+	}
+	for _, tt := range tests {
+		next, attempts := throttlingServer(10, tt.retryAfter)
+		rt := Chain(next, tt.queue.Middleware())
+		req, _ := http.NewRequestWithContext(tt.ctx, "GET", "https://API.example.com/", nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: RoundTrip failed: %v", tt.name, err)
+		}
+		if resp.StatusCode != http.StatusTooManyRequests || len(attempts()) != tt.calls {
+			t.Errorf("%s: Expected a 429 after %d calls, got %d after %d", tt.name, tt.calls, resp.StatusCode, len(attempts()))
+		}
+	}
+}
+
+// TestRateLimitQueueCanceled tests that a canceled context ends the wait
+func TestRateLimitQueueCanceled(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	next, _ := throttlingServer(1, "5")
+	rt := Chain(next, (&RateLimitQueue{Clock: clock}).Middleware())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() {
+		req, _ := http.NewRequestWithContext(ctx, "GET", "https://api.example.com/", nil)
+		_, err := rt.RoundTrip(req)
+		errc <- err
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package curlhttp
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// defaultMaxTrackedLinks bounds the crawl graph a RefererTracker keeps
+const defaultMaxTrackedLinks = 100000
+
+// maxLinkScanBytes is how much of an HTML page is scanned for links
+const maxLinkScanBytes = 2 << 20
+
+// RefererTracker sets the Referer of a crawl's requests the way a browser
+// following links would, instead of sending every request without one.
+// Install it with Transport.Use(rt.Middleware()) or Client.Use.
+//
+// A request for a URL that was linked from an HTML page fetched earlier is
+// sent with that page as its referrer; other requests use the most recently
+// fetched page. Links are found in the href and src attributes of HTML
+// responses as their bodies are read, and can also be recorded with
+// Discovered. Redirects keep the referrer of the request that started them,
+// as browsers do, rather than naming the redirecting URL.
+//
+// The referrer is reduced to its origin for cross-origin requests and
+// dropped from HTTPS to HTTP, following the default
+// strict-origin-when-cross-origin policy. Requests that already have a
+// Referer header are left alone. When a request carries a Navigation
+// without a Referrer, the referrer is set on the Navigation instead so
+// Sec-Fetch-Site agrees with it.
+type RefererTracker struct {
+	// MaxLinks bounds the number of discovered links remembered. When it
+	// is reached the crawl graph is cleared. Defaults to 100000.
+	MaxLinks int
+
+	mu    sync.Mutex
+	last  string            // most recently fetched page
+	links map[string]string // normalized URL -> page it was linked from
+}
+
+// Discovered records that page links to each of links. Relative links are
+// resolved against page.
+func (r *RefererTracker) Discovered(page string, links ...string) {
+	base, err := url.Parse(page)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, link := range links {
+		if u, err := base.Parse(link); err == nil {
+			r.addLink(base, u)
+		}
+	}
+}
+
+// addLink records that page links to u; r.mu must be held
+func (r *RefererTracker) addLink(page, u *url.URL) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	limit := r.MaxLinks
+	if limit <= 0 {
+		limit = defaultMaxTrackedLinks
+	}
+	key := NormalizeURL(u)
+	if _, ok := r.links[key]; ok {
+		// Keep the first page a URL was found on
+		return
+	}
+	if r.links == nil || len(r.links) >= limit {
+		r.links = make(map[string]string)
+	}
+	pageURL := *page
+	pageURL.Fragment, pageURL.RawFragment = "", ""
+	r.links[key] = pageURL.String()
+}
+
+// Referrer returns the page a request for u would be sent from, or "" for
+// none
+func (r *RefererTracker) Referrer(u *url.URL) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if page, ok := r.links[NormalizeURL(u)]; ok {
+		return page
+	}
+	return r.last
+}
+
+// Middleware returns middleware that manages the Referer header
+func (r *RefererTracker) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = r.prepare(req)
+			resp, err := next.RoundTrip(req)
+			if err != nil || resp == nil {
+				return resp, err
+			}
+			if isHTMLPage(resp) {
+				r.mu.Lock()
+				page := *req.URL
+				page.Fragment, page.RawFragment = "", ""
+				r.last = page.String()
+				r.mu.Unlock()
+				resp.Body = &linkScanner{ReadCloser: resp.Body, tracker: r, page: req.URL}
+			}
+			return resp, nil
+		})
+	}
+}
+
+// prepare returns req with its referrer set, cloning it if it changes
+func (r *RefererTracker) prepare(req *http.Request) *http.Request {
+	// Redirect hops reuse the referrer of the original request
+	if req.Response != nil {
+		first := req
+		for first.Response != nil && first.Response.Request != nil {
+			first = first.Response.Request
+		}
+		ref := first.Header.Get("Referer")
+		if req.Header.Get("Referer") == ref {
+			return req
+		}
+		req = req.Clone(req.Context())
+		if ref == "" || strings.EqualFold(first.URL.Scheme, "https") && !strings.EqualFold(req.URL.Scheme, "https") {
+			req.Header.Del("Referer")
+		} else {
+			req.Header.Set("Referer", ref)
+		}
+		return req
+	}
+
+	if req.Header.Get("Referer") != "" {
+		return req
+	}
+	page := r.Referrer(req.URL)
+	if page == "" {
+		return req
+	}
+	from, err := url.Parse(page)
+	if err != nil {
+		return req
+	}
+
+	if nav, ok := navigationFrom(req.Context()); ok {
+		if nav.Referrer != "" {
+			return req
+		}
+		nav.Referrer = page
+		return req.Clone(WithNavigation(req.Context(), nav))
+	}
+	ref := referrerFor(from, req.URL)
+	if ref == "" {
+		return req
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Referer", ref)
+	return req
+}
+
+// isHTMLPage reports whether resp is a successful HTML page that links can
+// be followed from
+func isHTMLPage(resp *http.Response) bool {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || resp.Request == nil || resp.Request.Method == http.MethodHead {
+		return false
+	}
+	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
+	return strings.HasPrefix(contentType, "text/html") || strings.HasPrefix(contentType, "application/xhtml+xml")
+}
+
+var linkAttrPattern = regexp.MustCompile(`(?i)\b(?:href|src)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+// linkScanner collects the start of an HTML body as it is read and records
+// its links once the body has been read to the end
+type linkScanner struct {
+	io.ReadCloser
+	tracker *RefererTracker
+	page    *url.URL
+	buf     bytes.Buffer
+	done    bool
+}
+
+func (s *linkScanner) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+	if room := maxLinkScanBytes - s.buf.Len(); room > 0 {
+		s.buf.Write(p[:min(n, room)])
+	}
+	if err == io.EOF && !s.done {
+		s.done = true
+		s.scan()
+	}
+	return n, err
+}
+
+// meta keeps the metadata of Transport responses reachable
+func (s *linkScanner) meta() *responseMeta {
+	return innerMeta(s.ReadCloser)
+}
+
+// scan records the links found in the collected body
+func (s *linkScanner) scan() {
+	matches := linkAttrPattern.FindAllSubmatch(s.buf.Bytes(), -1)
+	s.buf = bytes.Buffer{}
+	if len(matches) == 0 {
+		return
+	}
+	s.tracker.mu.Lock()
+	defer s.tracker.mu.Unlock()
+	for _, m := range matches {
+		link := string(m[1]) + string(m[2]) + string(m[3])
+		if u, err := s.page.Parse(html.UnescapeString(strings.TrimSpace(link))); err == nil {
+			s.tracker.addLink(s.page, u)
+		}
+	}
+}
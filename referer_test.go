@@ -0,0 +1,148 @@
+package curlhttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// htmlSite serves pages from a map and records the Referer of each request
+func htmlSite(pages map[string]string, referers map[string]string) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		referers[req.URL.String()] = req.Header.Get("Referer")
+		header := make(http.Header)
+		header.Set("Content-Type", "text/html; charset=utf-8")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     header,
+			Body:       io.NopCloser(strings.NewReader(pages[req.URL.String()])),
+			Request:    req,
+		}, nil
+	})
+}
+
+// TestRefererTrackerFollowsLinks tests that linked pages get their linking page as Referer
+func TestRefererTrackerFollowsLinks(t *testing.T) {
+	pages := map[string]string{
+		"https://example.com/":      `<a href="/products?id=2&amp;x=1">p</a><img src='https://cdn.example.net/logo.png'>`,
+		"https://example.com/about": `<a href=/team>team</a>`,
+		"https://example.com/team":  ``,
+		"http://plain.example.org/": ``,
+	}
+	referers := map[string]string{}
+	tracker := &RefererTracker{}
+	rt := Chain(htmlSite(pages, referers), tracker.Middleware())
+
+	get := func(target string) {
+		req, _ := http.NewRequest("GET", target, nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	get("https://example.com/")
+	get("https://example.com/about")
+	get("https://example.com/products?x=1&id=2")
+	get("https://cdn.example.net/logo.png")
+	get("https://example.com/team")
+	get("http://plain.example.org/")
+
+	tests := map[string]string{
+		"https://example.com/":                  "",
+		"https://example.com/about":             "https://example.com/",
+		"https://example.com/products?x=1&id=2": "https://example.com/",
+		"https://cdn.example.net/logo.png":      "https://example.com/",
+		"https://example.com/team":              "https://example.com/about",
+		"http://plain.example.org/":             "",
+	}
+	for target, want := range tests {
+		if got := referers[target]; got != want {
+			t.Errorf("%s: expected Referer %q, got %q", target, want, got)
+		}
+	}
+}
+
+// TestRefererTrackerKeepsExplicitReferer tests that a set Referer is not replaced
+func TestRefererTrackerKeepsExplicitReferer(t *testing.T) {
+	tracker := &RefererTracker{}
+	tracker.Discovered("https://example.com/list", "item/1")
+
+	var got string
+	rt := Chain(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		got = req.Header.Get("Referer")
+		return stubResponse("").RoundTrip(req)
+	}), tracker.Middleware())
+
+	req, _ := http.NewRequest("GET", "https://example.com/item/1", nil)
+	rt.RoundTrip(req)
+	if got != "https://example.com/list" {
+		t.Errorf("Expected Referer from Discovered, got %q", got)
+	}
+
+	req, _ = http.NewRequest("GET", "https://example.com/item/1", nil)
+	req.Header.Set("Referer", "https://search.example/")
+	rt.RoundTrip(req)
+	if got != "https://search.example/" {
+		t.Errorf("Expected explicit Referer, got %q", got)
+	}
+}
+
+// TestRefererTrackerNavigation tests that the referrer is passed to a Navigation
+func TestRefererTrackerNavigation(t *testing.T) {
+	tracker := &RefererTracker{}
+	tracker.Discovered("https://example.com/", "https://api.example.com/data")
+
+	var nav Navigation
+	rt := Chain(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		nav, _ = navigationFrom(req.Context())
+		return stubResponse("").RoundTrip(req)
+	}), tracker.Middleware())
+
+	ctx := WithNavigation(context.Background(), Navigation{Type: ResourceFetch})
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://api.example.com/data", nil)
+	rt.RoundTrip(req)
+	if nav.Referrer != "https://example.com/" {
+		t.Errorf("Expected navigation referrer https://example.com/, got %q", nav.Referrer)
+	}
+}
+
+// TestRefererTrackerRedirect tests that redirects keep the original referrer
+func TestRefererTrackerRedirect(t *testing.T) {
+	tracker := &RefererTracker{}
+	tracker.Discovered("https://example.com/page", "/old")
+
+	var finalReferer string
+	site := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/old" {
+			header := make(http.Header)
+			header.Set("Location", "/new")
+			return &http.Response{StatusCode: http.StatusFound, Header: header, Body: http.NoBody, Request: req}, nil
+		}
+		finalReferer = req.Header.Get("Referer")
+		return stubResponse("").RoundTrip(req)
+	})
+	client := &http.Client{Transport: Chain(site, tracker.Middleware())}
+
+	resp, err := client.Get("https://example.com/old")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	resp.Body.Close()
+	if finalReferer != "https://example.com/page" {
+		t.Errorf("Expected the original Referer after the redirect, got %q", finalReferer)
+	}
+}
+
+// TestRefererTrackerMaxLinks tests that the crawl graph is bounded
+func TestRefererTrackerMaxLinks(t *testing.T) {
+	tracker := &RefererTracker{MaxLinks: 2}
+	tracker.Discovered("https://example.com/", "/a", "/b", "/c")
+	if len(tracker.links) > 2 {
+		t.Errorf("Expected at most 2 links, got %d", len(tracker.links))
+	}
+}
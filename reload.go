@@ -0,0 +1,115 @@
+package curlhttp
+
+import (
+	"strings"
+	"time"
+)
+
+// configuredMiddleware is the middleware a Config installs, kept apart
+// from Use so that applying another Config replaces it
+type configuredMiddleware struct {
+	failover Middleware
+	retry    []Middleware
+}
+
+// list returns the middleware in the order it runs, retries outermost
+func (m configuredMiddleware) list() []Middleware {
+	if m.failover == nil {
+		return m.retry
+	}
+	return append(m.retry[:len(m.retry):len(m.retry)], m.failover)
+}
+
+func (m configuredMiddleware) clone() configuredMiddleware {
+	return configuredMiddleware{failover: m.failover, retry: append([]Middleware(nil), m.retry...)}
+}
+
+// handleSettings are the settings a handle is configured and recycled
+// with. Each handle keeps the ones it was created with, so it can be reset
+// and returned to the pool without configMu; changing them retires pooled
+// handles. Call with configMu held.
+type handleSettings struct {
+	target           string
+	maxConnects      int
+	connectTimeoutMs int
+	timeoutMs        int
+	httpVersion      int
+	proxied          bool
+	proxyTLS         ProxyTLSConfig
+	resolve          string
+	idleTimeout      time.Duration
+	maxAge           time.Duration
+}
+
+func (t *Transport) handleSettings() handleSettings {
+	s := handleSettings{
+		target:           t.ImpersonateTarget,
+		maxConnects:      t.MaxConnects,
+		connectTimeoutMs: t.ConnectTimeoutMs,
+		timeoutMs:        t.TimeoutMs,
+		httpVersion:      t.HttpVersion,
+		proxied:          t.Proxy != nil,
+		proxyTLS:         defaultProxyTLS,
+		resolve:          strings.Join(t.Resolve, "\n"),
+		idleTimeout:      t.IdleHandleTimeout,
+		maxAge:           t.MaxHandleAge,
+	}
+	if t.ProxyTLS != nil {
+		s.proxyTLS = *t.ProxyTLS
+	}
+	// Conservative defaults for fields left unset
+	if s.maxConnects == 0 {
+		s.maxConnects = 50
+	}
+	if s.connectTimeoutMs == 0 {
+		s.connectTimeoutMs = 5000
+	}
+	if s.timeoutMs == 0 {
+		s.timeoutMs = 30000
+	}
+	return s
+}
+
+// ApplyConfig changes the settings of a Transport in use, so long-running
+// services can rotate proxies or targets and tighten timeouts without a
+// restart. Fields set in cfg replace the current settings and zero fields
+// keep them; the proxy failover and retry middleware of a previous Config
+// are replaced when cfg sets Proxies or Retry. Middleware added with Use is
+// kept.
+//
+// Requests started after ApplyConfig returns see only the new settings;
+// requests already waiting for a handle or in curl finish under the
+// settings they started with, and ApplyConfig does not wait for them. When
+// the target, timeouts, connection or handle limits, HTTP version, proxy,
+// proxy TLS or pinned addresses change, idle pooled handles are closed and
+// handles in use are closed when they come back, so no connection made
+// under the old settings is reused. A new PoolSize replaces the pool, and
+// new concurrency limits apply to requests that have not yet queued for a
+// slot.
+func (t *Transport) ApplyConfig(cfg *Config) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	t.configMu.Lock()
+	defer t.configMu.Unlock()
+
+	before := t.handleSettings()
+	limits, hostLimit := t.MaxConcurrentRequests, t.MaxConcurrentRequestsPerHost
+	cfg.applyTo(t)
+	if t.MaxConcurrentRequests != limits || t.MaxConcurrentRequestsPerHost != hostLimit {
+		t.limits.reset()
+	}
+
+	pool := t.pool.Load()
+	if pool != nil && cap(pool.idle) != t.maxPoolSize {
+		t.pool.Store(newHandlePool(t.maxPoolSize))
+		t.generation.Add(1)
+	} else if t.handleSettings() != before {
+		t.generation.Add(1)
+	}
+	if pool != nil {
+		t.evictIdleHandles(pool)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package curlhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// fetch performs a GET through transport and drains the response
+func fetch(t *testing.T, transport *Transport) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Errorf("RoundTrip failed: %v", err)
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// TestApplyConfigRetiresHandles tests that handle settings changes close pooled handles
+func TestApplyConfigRetiresHandles(t *testing.T) {
+	fake := newFakeEngine("ok")
+	transport := newFakeTransport(fake)
+	fetch(t, transport)
+
+	if err := transport.ApplyConfig(&Config{Target: "firefox", Timeout: Duration(5 * time.Second)}); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if transport.ImpersonateTarget != "firefox" || transport.TimeoutMs != 5000 {
+		t.Errorf("Expected the new target and timeout, got %s, %d", transport.ImpersonateTarget, transport.TimeoutMs)
+	}
+	if stats := transport.Stats(); stats.HandlesDestroyed != 1 || stats.IdleHandles != 0 {
+		t.Errorf("Expected the idle handle to be closed, got %+v", stats)
+	}
+
+	fetch(t, transport)
+	if stats := transport.Stats(); stats.HandlesCreated != 2 {
+		t.Errorf("Expected a new handle, got %d created", stats.HandlesCreated)
+	}
+	if fake.opts[curl.OPT_TIMEOUT_MS] != 5000 {
+		t.Errorf("Expected the new handle to use the new timeout, got %v", fake.opts[curl.OPT_TIMEOUT_MS])
+	}
+
+	// Concurrency limits keep pooled handles
+	if err := transport.ApplyConfig(&Config{MaxConcurrentRequests: 4}); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if stats := transport.Stats(); stats.HandlesDestroyed != 1 || stats.IdleHandles != 1 {
+		t.Errorf("Expected the idle handle to be kept, got %+v", stats)
+	}
+}
+
+// TestApplyConfigConcurrencyLimits tests that changed limits are enforced
+func TestApplyConfigConcurrencyLimits(t *testing.T) {
+	transport := newFakeTransport(newFakeEngine("ok"))
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+
+	// slots takes request slots until one is refused, up to max
+	slots := func(max int) int {
+		var releases []func()
+		defer func() {
+			for _, release := range releases {
+				release()
+			}
+		}()
+		for len(releases) < max {
+			release, err := transport.acquireRequestSlot(req)
+			if err != nil {
+				if !errors.Is(err, ErrPoolExhausted) {
+					t.Errorf("Expected ErrPoolExhausted, got %v", err)
+				}
+				break
+			}
+			releases = append(releases, release)
+		}
+		return len(releases)
+	}
+
+	if err := transport.ApplyConfig(&Config{MaxConcurrentRequests: 3, AcquireTimeout: Duration(10 * time.Millisecond)}); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if n := slots(5); n != 3 {
+		t.Errorf("Expected 3 slots, got %d", n)
+	}
+	if err := transport.ApplyConfig(&Config{MaxConcurrentRequests: 1}); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if n := slots(5); n != 1 {
+		t.Errorf("Expected the new global limit of 1, got %d slots", n)
+	}
+	if err := transport.ApplyConfig(&Config{MaxConcurrentRequests: 4, MaxConcurrentRequestsPerHost: 2}); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if n := slots(5); n != 2 {
+		t.Errorf("Expected the new per-host limit of 2, got %d slots", n)
+	}
+	if err := transport.ApplyConfig(&Config{MaxConcurrentRequestsPerHost: 1}); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if n := slots(5); n != 1 {
+		t.Errorf("Expected the new per-host limit of 1, got %d slots", n)
+	}
+	fetch(t, transport)
+}
+
+// TestApplyConfigMiddleware tests that config middleware is replaced, not stacked
+func TestApplyConfigMiddleware(t *testing.T) {
+	transport := newFakeTransport(newFakeEngine("ok"))
+	transport.Use(func(next http.RoundTripper) http.RoundTripper { return next })
+
+	for i := 0; i < 2; i++ {
+		if err := transport.ApplyConfig(&Config{Retry: &RetryConfig{MaxRetries: 2, BudgetRatio: 0.5}}); err != nil {
+			t.Fatalf("ApplyConfig failed: %v", err)
+		}
+	}
+	if n := len(transport.allMiddleware()); n != 3 {
+		t.Errorf("Expected user, budget and queue middleware, got %d", n)
+	}
+
+	transport.ApplyConfig(&Config{Proxies: []string{"http://a:3128", "http://b:3128"}})
+	if transport.configured.failover == nil || transport.Proxy.Host != "a:3128" {
+		t.Errorf("Expected proxy failover, got %v", transport.Proxy)
+	}
+	transport.ApplyConfig(&Config{Proxies: []string{"http://c:3128"}})
+	if transport.configured.failover != nil || transport.Proxy.Host != "c:3128" {
+		t.Errorf("Expected a single proxy without failover, got %v", transport.Proxy)
+	}
+	fetch(t, transport)
+}
+
+// TestApplyConfigPoolSize tests resizing the pool and rejecting invalid configs
+func TestApplyConfigPoolSize(t *testing.T) {
+	transport := newFakeTransport(newFakeEngine("ok"))
+	fetch(t, transport)
+
+	if err := transport.ApplyConfig(&Config{PoolSize: 3}); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if stats := transport.Stats(); stats.MaxPoolSize != 3 || stats.HandlesDestroyed != 1 {
+		t.Errorf("Expected a pool of 3 and the old handle closed, got %+v", stats)
+	}
+	fetch(t, transport)
+
+	if err := transport.ApplyConfig(&Config{Target: "safari", HTTPVersion: "9"}); err == nil {
+		t.Errorf("Expected an error for an unknown HTTP version")
+	}
+	if transport.ImpersonateTarget == "safari" {
+		t.Errorf("Expected an invalid config to change nothing")
+	}
+}
+
+// TestApplyConfigConcurrent tests applying configs while requests run
+func TestApplyConfigConcurrent(t *testing.T) {
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return newFakeEngine("ok") }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				fetch(t, transport)
+			}
+		}()
+	}
+	for _, target := range []string{"firefox", "chrome", "safari", "chrome136"} {
+		if err := transport.ApplyConfig(&Config{Target: target, PoolSize: 2 + len(target)%3}); err != nil {
+			t.Errorf("ApplyConfig failed: %v", err)
+		}
+	}
+	wg.Wait()
+
+	stats := transport.Stats()
+	if stats.OpenHandles != int64(stats.IdleHandles) {
+		t.Errorf("Expected only idle handles to stay open, got %+v", stats)
+	}
+}
+
+// TestApplyConfigWhileWaitingForHandle tests that ApplyConfig does not
+// deadlock with a request waiting for the handle an open stream holds
+func TestApplyConfigWhileWaitingForHandle(t *testing.T) {
+	transport := NewTransportWithPoolSize(1)
+	transport.StreamResponses = true
+	transport.newEngine = func() curlEngine { return newChunkedEngine("first ", "second") }
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	stream, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+
+	waiting := make(chan struct{})
+	go func() {
+		defer close(waiting)
+		fetch(t, transport)
+	}()
+	for transport.Stats().AcquisitionsBlocked == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	applied := make(chan error, 1)
+	go func() {
+		applied <- transport.ApplyConfig(&Config{Target: "firefox", Timeout: Duration(5 * time.Second)})
+	}()
+	select {
+	case err := <-applied:
+		if err != nil {
+			t.Fatalf("ApplyConfig failed: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("ApplyConfig blocked behind a request waiting for a handle")
+	}
+
+	io.Copy(io.Discard, stream.Body)
+	stream.Body.Close()
+	select {
+	case <-waiting:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Waiting request never got the handle back")
+	}
+	if stats := transport.Stats(); stats.HandlesCreated != 2 {
+		t.Errorf("Expected the handle from before the change to be replaced, got %+v", stats)
+	}
+}
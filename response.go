@@ -0,0 +1,339 @@
+package curlhttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NoteKind identifies the kind of a ResponseNote.
+type NoteKind int
+
+const (
+	// NoteRedirectWithoutLocation is recorded when a 3xx response that would
+	// normally redirect carries no Location header. The response is returned
+	// as-is instead of being followed or turned into an error.
+	NoteRedirectWithoutLocation NoteKind = iota + 1
+
+	// NoteMalformedHeader is recorded when a response header line could not
+	// be parsed, such as a line without a colon. The line is skipped and the
+	// rest of the response is processed normally.
+	NoteMalformedHeader
+
+	// NoteContentLengthMismatch is recorded when the body size differs from
+	// the declared Content-Length. See Transport.ContentLengthPolicy.
+	NoteContentLengthMismatch
+
+	// NoteCoalesced is recorded on responses that were shared from another
+	// caller's identical in-flight request by Singleflight.
+	NoteCoalesced
+
+	// NoteRobotsDisallowed is recorded on responses to requests the site's
+	// robots.txt disallows, when RobotsPolicy.Annotate lets them through.
+	NoteRobotsDisallowed
+
+	// NoteClientRedirect is recorded by MetaRefresh on pages that redirect
+	// with a meta refresh, a Refresh header or a location script.
+	NoteClientRedirect
+
+	// NoteCached is recorded on responses served from a ResponseCache.
+	NoteCached
+)
+
+// String returns a short name for the note kind
+func (k NoteKind) String() string {
+	switch k {
+	case NoteRedirectWithoutLocation:
+		return "redirect-without-location"
+	case NoteMalformedHeader:
+		return "malformed-header"
+	case NoteContentLengthMismatch:
+		return "content-length-mismatch"
+	case NoteCoalesced:
+		return "coalesced"
+	case NoteRobotsDisallowed:
+		return "robots-disallowed"
+	case NoteClientRedirect:
+		return "client-redirect"
+	case NoteCached:
+		return "cached"
+	default:
+		return "unknown"
+	}
+}
+
+// ResponseNote is a non-fatal observation the Transport made about a
+// response, such as a protocol violation that was tolerated.
+type ResponseNote struct {
+	Kind    NoteKind
+	Message string
+}
+
+// responseMeta holds metadata about an exchange. It is embedded in every
+// Body type produced by Transport so it travels with the response.
+type responseMeta struct {
+	notes     []ResponseNote
+	timings   *Timings
+	conn      *ConnectionInfo
+	sizes     *TransferSizes
+	target    string
+	attempts  int
+	rawHeader []byte
+}
+
+// meta returns the metadata; it lets Body implementations be recognized
+func (m *responseMeta) meta() *responseMeta {
+	return m
+}
+
+// addNote records a note about the response
+func (m *responseMeta) addNote(kind NoteKind, message string) {
+	m.notes = append(m.notes, ResponseNote{Kind: kind, Message: message})
+}
+
+// metaBody is implemented by all Body types produced by Transport
+type metaBody interface {
+	io.ReadCloser
+	meta() *responseMeta
+}
+
+// responseBody is the Body of responses produced by Transport. Besides
+// serving the buffered payload it carries metadata about the exchange.
+type responseBody struct {
+	*bytes.Reader
+	responseMeta
+	buf    *bytes.Buffer // pooled storage released on Close, if any
+	closed bool
+}
+
+// newResponseBody wraps the buffered response payload
+func newResponseBody(data []byte) *responseBody {
+	return &responseBody{Reader: bytes.NewReader(data)}
+}
+
+// Read implements io.Reader, failing once the body has been closed
+func (b *responseBody) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, http.ErrBodyReadAfterClose
+	}
+	return b.Reader.Read(p)
+}
+
+// Close implements io.Closer, returning pooled storage for reuse
+func (b *responseBody) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.Reader.Reset(nil)
+	if b.buf != nil {
+		putBuffer(b.buf)
+		b.buf = nil
+	}
+	return nil
+}
+
+// innerMeta returns the metadata of a body wrapped by middleware, or nil.
+// Middleware wrapping response bodies implements meta with it so the
+// metadata stays reachable.
+func innerMeta(body io.ReadCloser) *responseMeta {
+	if b, ok := body.(metaBody); ok {
+		return b.meta()
+	}
+	return nil
+}
+
+// metaOf returns the metadata of a response produced by Transport, or nil
+func metaOf(resp *http.Response) *responseMeta {
+	if resp == nil {
+		return nil
+	}
+	body, ok := resp.Body.(metaBody)
+	if !ok {
+		return nil
+	}
+	return body.meta()
+}
+
+// Notes returns the notes recorded by the Transport for resp, or nil if there
+// are none or the response was not produced by this package.
+func Notes(resp *http.Response) []ResponseNote {
+	if m := metaOf(resp); m != nil {
+		return m.notes
+	}
+	return nil
+}
+
+// Timings holds the phases of a transfer as measured by curl. Each value
+// is the time elapsed from the start of the transfer until that phase
+// completed, so later phases include earlier ones. Phases that did not
+// happen, such as connecting on a reused connection, are zero.
+type Timings struct {
+	NameLookup    time.Duration // DNS resolution done
+	Connect       time.Duration // TCP connection established
+	AppConnect    time.Duration // TLS handshake done
+	PreTransfer   time.Duration // ready to send the request
+	StartTransfer time.Duration // first response byte received
+	Total         time.Duration // transfer complete
+}
+
+// ResponseTimings returns the transfer timings of a response produced by
+// Transport. It reports false for responses from other RoundTrippers.
+func ResponseTimings(resp *http.Response) (Timings, bool) {
+	if m := metaOf(resp); m != nil && m.timings != nil {
+		return *m.timings, true
+	}
+	return Timings{}, false
+}
+
+// ConnectionInfo describes the connection a response was received on
+type ConnectionInfo struct {
+	// PrimaryIP and PrimaryPort are the remote end of the connection: the
+	// server, or the proxy when one was used.
+	PrimaryIP   string
+	PrimaryPort int
+	LocalIP     string
+	LocalPort   int
+
+	// Reused is true when the transfer ran on a pooled connection instead
+	// of opening a new one.
+	Reused bool
+
+	// ViaProxy is true when the request was sent through a proxy.
+	ViaProxy bool
+
+	// Proxy is the URL of that proxy, with any password redacted, so
+	// systems spreading requests over several exits can attribute
+	// blocks, latency and cost to each. ProxyConnectStatus is the status
+	// the proxy answered CONNECT with, or 0 if this transfer sent no
+	// CONNECT, as for http:// URLs or a reused tunnel.
+	Proxy              string
+	ProxyConnectStatus int
+}
+
+// ConnInfo returns the connection details of a response produced by
+// Transport. It reports false for responses from other RoundTrippers.
+func ConnInfo(resp *http.Response) (ConnectionInfo, bool) {
+	if m := metaOf(resp); m != nil && m.conn != nil {
+		return *m.conn, true
+	}
+	return ConnectionInfo{}, false
+}
+
+// Extra is the curl-specific information attached to a response produced
+// by Transport. Responses keep the plain http.Response type; the extra
+// data travels with the body and survives the middleware in this package.
+type Extra struct {
+	// Target is the impersonation target the request was sent with.
+	Target string
+
+	// Attempts is the number of transfers the response took, counting
+	// retries on dead connections and Critical-CH retries.
+	Attempts int
+
+	Timings    *Timings
+	Connection *ConnectionInfo
+	Sizes      *TransferSizes
+	Notes      []ResponseNote
+
+	// RateLimit is the rate limit state the response advertised, or nil.
+	RateLimit *RateLimit
+
+	// RawHeader is the final header block exactly as received, from the
+	// status line to the blank line that ends it, with the original name
+	// casing, order and duplicates. HTTP/2 and HTTP/3 headers arrive in
+	// the textual form curl reconstructs from the binary frames.
+	RawHeader []byte
+}
+
+// HeaderField is a header line as the server sent it
+type HeaderField struct {
+	Name  string
+	Value string
+}
+
+// HeaderFields parses the fields of RawHeader in order, keeping the name
+// casing and duplicates that http.Header loses. Folded continuation lines
+// are joined to their field.
+func (e *Extra) HeaderFields() []HeaderField {
+	var fields []HeaderField
+	for _, line := range strings.SplitAfter(string(e.RawHeader), "\n") {
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "", strings.HasPrefix(line, "HTTP/"):
+		case line[0] == ' ' || line[0] == '\t':
+			if len(fields) > 0 {
+				fields[len(fields)-1].Value += " " + trimOWS(line)
+			}
+		default:
+			if name, value, ok := strings.Cut(line, ":"); ok {
+				fields = append(fields, HeaderField{Name: trimOWS(name), Value: trimOWS(value)})
+			}
+		}
+	}
+	return fields
+}
+
+// ResponseExtra returns the extra information of a response produced by
+// Transport. It reports false for responses from other RoundTrippers. The
+// result is a copy.
+func ResponseExtra(resp *http.Response) (*Extra, bool) {
+	m := metaOf(resp)
+	if m == nil {
+		return nil, false
+	}
+	extra := &Extra{
+		Target:    m.target,
+		Attempts:  m.attempts,
+		Notes:     append([]ResponseNote(nil), m.notes...),
+		RawHeader: append([]byte(nil), m.rawHeader...),
+	}
+	extra.RateLimit, _ = ResponseRateLimit(resp)
+	if m.timings != nil {
+		timings := *m.timings
+		extra.Timings = &timings
+	}
+	if m.conn != nil {
+		conn := *m.conn
+		extra.Connection = &conn
+	}
+	if m.sizes != nil {
+		sizes := *m.sizes
+		extra.Sizes = &sizes
+	}
+	return extra, true
+}
+
+// HasNote reports whether resp carries a note of the given kind
+func HasNote(resp *http.Response, kind NoteKind) bool {
+	for _, note := range Notes(resp) {
+		if note.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// isRedirectStatus reports whether code is a 3xx status that is normally
+// followed using the Location header
+func isRedirectStatus(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// checkRedirectLocation records a note on body when a redirect status comes
+// without a usable Location header
+func checkRedirectLocation(code int, headers http.Header, body metaBody) {
+	if !isRedirectStatus(code) {
+		return
+	}
+	if headers.Get("Location") == "" {
+		body.meta().addNote(NoteRedirectWithoutLocation, http.StatusText(code)+" response has no Location header")
+	}
+}
@@ -0,0 +1,142 @@
+package curlhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestCheckRedirectLocation tests detection of redirects without Location
+func TestCheckRedirectLocation(t *testing.T) {
+	tests := []struct {
+		code     int
+		location string
+		wantNote bool
+	}{
+		{http.StatusFound, "", true},
+		{http.StatusMovedPermanently, "", true},
+		{http.StatusPermanentRedirect, "", true},
+		{http.StatusFound, "/next", false},
+		{http.StatusNotModified, "", false},
+		{http.StatusMultipleChoices, "", false},
+		{http.StatusOK, "", false},
+	}
+
+	for _, tt := range tests {
+		headers := make(http.Header)
+		if tt.location != "" {
+			headers.Set("Location", tt.location)
+		}
+		body := newResponseBody(nil)
+		checkRedirectLocation(tt.code, headers, body)
+
+		resp := &http.Response{StatusCode: tt.code, Header: headers, Body: body}
+		if got := HasNote(resp, NoteRedirectWithoutLocation); got != tt.wantNote {
+			t.Errorf("status %d location %q: expected note=%v, got %v", tt.code, tt.location, tt.wantNote, got)
+		}
+	}
+}
+
+// TestNotesForeignResponse tests that Notes tolerates responses from other transports
+func TestNotesForeignResponse(t *testing.T) {
+	if Notes(nil) != nil {
+		t.Error("Expected nil notes for nil response")
+	}
+	resp := &http.Response{Body: http.NoBody}
+	if Notes(resp) != nil {
+		t.Error("Expected nil notes for foreign response body")
+	}
+}
+
+// TestRedirectWithoutLocation tests that the client returns a 3xx without Location as-is
+func TestRedirectWithoutLocation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusFound)
+		w.Write([]byte("moved somewhere"))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error for redirect without Location, got: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("Expected status 302, got %d", resp.StatusCode)
+	}
+	if !HasNote(resp, NoteRedirectWithoutLocation) {
+		t.Error("Expected redirect-without-location note on response")
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	if string(body) != "moved somewhere" {
+		t.Errorf("Expected original body, got %q", body)
+	}
+}
+
+// TestResponseExtra tests the extra data of Transport responses, through middleware
+func TestResponseExtra(t *testing.T) {
+	fake := newFakeEngine("hello")
+	transport := newFakeTransport(fake)
+	transport.ImpersonateTarget = "firefox135"
+	transport.Use((&Politeness{MaxConcurrent: 1}).Middleware())
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	extra, ok := ResponseExtra(resp)
+	if !ok {
+		t.Fatal("Expected extra data on a Transport response")
+	}
+	if extra.Target != "firefox135" || extra.Attempts != 1 {
+		t.Errorf("Expected target firefox135 and 1 attempt, got %q and %d", extra.Target, extra.Attempts)
+	}
+	if extra.Timings == nil || extra.Connection == nil {
+		t.Errorf("Expected timings and connection info, got %+v", extra)
+	}
+
+	if _, ok := ResponseExtra(&http.Response{Body: http.NoBody}); ok {
+		t.Error("Expected no extra data for a foreign response")
+	}
+}
+
+// TestResponseRawHeader tests the raw header block and its ordered fields
+func TestResponseRawHeader(t *testing.T) {
+	fake := newFakeEngine("hello")
+	fake.headers = []string{
+		"HTTP/1.1 100 Continue\r\n", "\r\n",
+		"HTTP/1.1 200 OK\r\n", "server: nginx\r\n", "Set-Cookie: a=1\r\n", "set-cookie: b=2\r\n",
+		"X-Folded: one\r\n", "\ttwo\r\n", "\r\n",
+	}
+	transport := newFakeTransport(fake)
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	extra, _ := ResponseExtra(resp)
+	want := strings.Join(fake.headers[2:], "")
+	if string(extra.RawHeader) != want {
+		t.Errorf("Expected the final header block %q, got %q", want, extra.RawHeader)
+	}
+	fields := extra.HeaderFields()
+	wantFields := []HeaderField{{"server", "nginx"}, {"Set-Cookie", "a=1"}, {"set-cookie", "b=2"}, {"X-Folded", "one two"}}
+	if !slices.Equal(fields, wantFields) {
+		t.Errorf("Expected fields %v, got %v", wantFields, fields)
+	}
+}
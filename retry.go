@@ -0,0 +1,63 @@
+package curlhttp
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// maxDeadConnRetries bounds how often a request is resent after failing on
+// reused connections
+const maxDeadConnRetries = 3
+
+// deadConnError marks a transfer that failed on a reused connection before
+// any response arrived, meaning the server had already closed it
+type deadConnError struct {
+	err error
+}
+
+func (e *deadConnError) Error() string {
+	return e.err.Error()
+}
+
+func (e *deadConnError) Unwrap() error {
+	return e.err
+}
+
+// checkDeadConn wraps performErr in a deadConnError if the transfer went
+// over a reused connection that turned out to be dead
+func checkDeadConn(easy curlEngine, performErr error) error {
+	var curlErr curl.CurlError
+	if !errors.As(performErr, &curlErr) {
+		return performErr
+	}
+	switch curlErr {
+	case curl.CurlError(curl.E_SEND_ERROR), curl.CurlError(curl.E_RECV_ERROR), curl.CurlError(curl.E_GOT_NOTHING):
+	default:
+		return performErr
+	}
+	if getinfoInt(easy, infoNumConnects) != 0 || getinfoInt(easy, infoResponseCode) != 0 {
+		// A fresh connection, or the server answered: not a stale reuse
+		return performErr
+	}
+	return &deadConnError{err: performErr}
+}
+
+// shouldRetryDeadConn reports whether req can be sent again after err, as
+// net/http does for idempotent requests on dead keep-alive connections.
+// The request body is already buffered, so it can always be replayed.
+func shouldRetryDeadConn(req *http.Request, err error) bool {
+	var dead *deadConnError
+	return errors.As(err, &dead) && isIdempotent(req) && req.Context().Err() == nil
+}
+
+// noteDeadConnRetry records that req is being resent after err
+func (t *Transport) noteDeadConnRetry(req *http.Request, err error) {
+	t.stats.deadConnRetries.Add(1)
+	t.log(req.Context(), slog.LevelDebug, "retrying request after dead reused connection",
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.String()),
+		slog.String("error", err.Error()))
+}
@@ -0,0 +1,101 @@
+package curlhttp
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// deadConnEngine fails its first transfers as if a reused connection had
+// been closed by the server
+type deadConnEngine struct {
+	*fakeEngine
+	failures int
+	performs int
+	newConns int64
+}
+
+func (e *deadConnEngine) Perform() error {
+	e.performs++
+	e.info = map[curl.CurlInfo]interface{}{infoNumConnects: e.newConns}
+	if e.failures > 0 {
+		e.failures--
+		e.status = 0
+		return curl.CurlError(curl.E_RECV_ERROR)
+	}
+	e.status = 200
+	return e.fakeEngine.Perform()
+}
+
+// newDeadConnTransport returns a Transport whose handle fails failures times
+func newDeadConnTransport(failures int) (*Transport, *deadConnEngine) {
+	engine := &deadConnEngine{fakeEngine: newFakeEngine("ok"), failures: failures}
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return engine }
+	return transport, engine
+}
+
+// TestRetryOnDeadReusedConnection tests that idempotent requests are resent
+func TestRetryOnDeadReusedConnection(t *testing.T) {
+	transport, engine := newDeadConnTransport(1)
+
+	req, _ := http.NewRequest("PUT", "http://example.com/", strings.NewReader("payload"))
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Expected retry to succeed, got %v", err)
+	}
+	resp.Body.Close()
+	if engine.performs != 2 {
+		t.Errorf("Expected 2 transfers, got %d", engine.performs)
+	}
+	if got, _ := engine.performed[curl.OPT_POSTFIELDS].([]byte); string(got) != "payload" {
+		t.Errorf("Expected body to be replayed, got %v", got)
+	}
+	if n := transport.Stats().DeadConnectionRetries; n != 1 {
+		t.Errorf("Expected 1 retry in stats, got %d", n)
+	}
+}
+
+// TestNoRetryForNonIdempotent tests that POSTs are not resent
+func TestNoRetryForNonIdempotent(t *testing.T) {
+	transport, engine := newDeadConnTransport(1)
+
+	req, _ := http.NewRequest("POST", "http://example.com/", strings.NewReader("payload"))
+	var curlErr curl.CurlError
+	if _, err := transport.RoundTrip(req); !errors.As(err, &curlErr) || curlErr != curl.CurlError(curl.E_RECV_ERROR) {
+		t.Errorf("Expected E_RECV_ERROR, got %v", err)
+	}
+	if engine.performs != 1 {
+		t.Errorf("Expected 1 transfer, got %d", engine.performs)
+	}
+}
+
+// TestNoRetryOnFreshConnection tests that failures on new connections are returned
+func TestNoRetryOnFreshConnection(t *testing.T) {
+	transport, engine := newDeadConnTransport(1)
+	engine.newConns = 1
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Error("Expected error for a failed fresh connection")
+	}
+	if engine.performs != 1 {
+		t.Errorf("Expected 1 transfer, got %d", engine.performs)
+	}
+}
+
+// TestDeadConnRetriesBounded tests that retries stop after maxDeadConnRetries
+func TestDeadConnRetriesBounded(t *testing.T) {
+	transport, engine := newDeadConnTransport(100)
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Error("Expected error after exhausting retries")
+	}
+	if engine.performs != maxDeadConnRetries+1 {
+		t.Errorf("Expected %d transfers, got %d", maxDeadConnRetries+1, engine.performs)
+	}
+}
@@ -0,0 +1,155 @@
+package curlhttp
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryBudget caps retries at a share of recent requests to each host, so
+// retries of isolated failures go through but a struggling origin is not
+// hit by a retry storm. It counts requests through its middleware, which
+// must be installed for the ratio to grow past MinRetries:
+//
+//	budget := &curlhttp.RetryBudget{Ratio: 0.1}
+//	transport.Use(budget.Middleware())
+//	transport.RetryBudget = budget
+//
+// The same budget can be shared by RateLimitQueue, Hedger and the
+// Transport's dead connection retries; a retry they cannot fund is not
+// made and the failure is returned as it is.
+type RetryBudget struct {
+	// Ratio is the share of requests per Window that may be retried.
+	// Defaults to 0.1.
+	Ratio float64
+
+	// MinRetries are allowed per Window and host whatever the ratio, so
+	// hosts with little traffic can still retry. Defaults to 3.
+	MinRetries int
+
+	// Window is the sliding period requests and retries are counted over.
+	// Defaults to 10 seconds.
+	Window time.Duration
+
+	// Clock times the window. Defaults to SystemClock.
+	Clock Clock
+
+	mu    sync.Mutex
+	hosts map[string]*budgetWindow
+	swept int64 // slot of the last sweep for quiet hosts
+}
+
+// budgetSlots is the number of slots a window is divided into
+const budgetSlots = 10
+
+// budgetWindow counts the requests and retries to one host per slot
+type budgetWindow struct {
+	slots [budgetSlots]budgetSlot
+}
+
+// budgetSlot holds the counts of one slot, numbered from the Unix epoch
+type budgetSlot struct {
+	index    int64
+	requests int
+	retries  int
+}
+
+// window returns the counts for host and the slot of now; b.mu must be held
+func (b *RetryBudget) window(host string, now time.Time) (*budgetWindow, int64) {
+	window := b.Window
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+	index := now.UnixNano() / int64(window/budgetSlots)
+
+	if b.hosts == nil {
+		b.hosts = make(map[string]*budgetWindow)
+		b.swept = index
+	}
+	if index-b.swept >= budgetSlots {
+		// Forget hosts that have been quiet for a whole window, at most
+		// once per window so new hosts do not each pay for a scan
+		for name, other := range b.hosts {
+			if requests, retries := other.totals(index); requests == 0 && retries == 0 {
+				delete(b.hosts, name)
+			}
+		}
+		b.swept = index
+	}
+	w := b.hosts[host]
+	if w == nil {
+		w = &budgetWindow{}
+		b.hosts[host] = w
+	}
+	return w, index
+}
+
+// current returns the slot for index, clearing it if it is stale
+func (w *budgetWindow) current(index int64) *budgetSlot {
+	slot := &w.slots[index%budgetSlots]
+	if slot.index != index {
+		*slot = budgetSlot{index: index}
+	}
+	return slot
+}
+
+// totals sums the slots that are within the window ending at index
+func (w *budgetWindow) totals(index int64) (requests, retries int) {
+	for _, slot := range w.slots {
+		if slot.index > index-budgetSlots && slot.index <= index {
+			requests += slot.requests
+			retries += slot.retries
+		}
+	}
+	return requests, retries
+}
+
+// Middleware returns middleware that counts first attempts of requests
+// towards the budget of their host
+func (b *RetryBudget) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if Attempt(req.Context()) == 1 {
+				b.mu.Lock()
+				w, index := b.window(budgetHost(req), clockOrSystem(b.Clock).Now())
+				w.current(index).requests++
+				b.mu.Unlock()
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Allow reports whether req may be retried and, if so, spends one retry
+// from the budget of its host
+func (b *RetryBudget) Allow(req *http.Request) bool {
+	ratio := b.Ratio
+	if ratio <= 0 {
+		ratio = 0.1
+	}
+	minRetries := b.MinRetries
+	if minRetries <= 0 {
+		minRetries = 3
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	w, index := b.window(budgetHost(req), clockOrSystem(b.Clock).Now())
+	requests, retries := w.totals(index)
+	if retries >= minRetries && float64(retries+1) > ratio*float64(requests) {
+		return false
+	}
+	w.current(index).retries++
+	return true
+}
+
+// allowRetry reports whether budget, if set, lets req be retried
+func allowRetry(budget *RetryBudget, req *http.Request) bool {
+	return budget == nil || budget.Allow(req)
+}
+
+// budgetHost is the key of req's budget
+func budgetHost(req *http.Request) string {
+	return strings.ToLower(req.URL.Hostname())
+}
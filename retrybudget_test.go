@@ -0,0 +1,112 @@
+package curlhttp
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// budgetRequests sends n first attempts to rawURL through the budget's middleware
+func budgetRequests(t *testing.T, budget *RetryBudget, rawURL string, n int) {
+	t.Helper()
+	rt := Chain(stubResponse("ok"), budget.Middleware())
+	for i := 0; i < n; i++ {
+		req, _ := http.NewRequest("GET", rawURL, nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+}
+
+// allowed counts how many of n retries of rawURL the budget funds
+func allowed(budget *RetryBudget, rawURL string, n int) int {
+	req, _ := http.NewRequest("GET", rawURL, nil)
+	granted := 0
+	for i := 0; i < n; i++ {
+		if budget.Allow(req) {
+			granted++
+		}
+	}
+	return granted
+}
+
+// TestRetryBudgetRatio tests that retries are capped at a share of requests
+func TestRetryBudgetRatio(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	budget := &RetryBudget{Ratio: 0.1, MinRetries: 2, Clock: clock}
+
+	if n := allowed(budget, "http://quiet.example.com/", 5); n != 2 {
+		t.Errorf("Expected MinRetries isolated retries without traffic, got %d", n)
+	}
+
+	budgetRequests(t, budget, "http://busy.example.com/", 100)
+	if n := allowed(budget, "http://busy.example.com/", 50); n != 10 {
+		t.Errorf("Expected 10%% of 100 requests as retries, got %d", n)
+	}
+	if n := allowed(budget, "http://quiet.example.com/", 1); n != 0 {
+		t.Errorf("Expected another host's traffic not to fund retries, got %d", n)
+	}
+}
+
+// TestRetryBudgetWindow tests that the budget recovers as the window slides
+func TestRetryBudgetWindow(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	budget := &RetryBudget{Ratio: 0.5, MinRetries: 1, Window: 10 * time.Second, Clock: clock}
+
+	budgetRequests(t, budget, "http://example.com/", 10)
+	if n := allowed(budget, "http://example.com/", 10); n != 5 {
+		t.Errorf("Expected 5 retries, got %d", n)
+	}
+	clock.Advance(5 * time.Second)
+	if n := allowed(budget, "http://example.com/", 1); n != 0 {
+		t.Errorf("Expected the budget to stay spent within the window, got %d", n)
+	}
+	clock.Advance(6 * time.Second)
+	if n := allowed(budget, "http://example.com/", 3); n != 1 {
+		t.Errorf("Expected only MinRetries once the window passed, got %d", n)
+	}
+}
+
+// TestRetryBudgetSweep tests that quiet hosts are forgotten once per window
+func TestRetryBudgetSweep(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	budget := &RetryBudget{Window: 10 * time.Second, Clock: clock}
+
+	for i := 0; i < 100; i++ {
+		budgetRequests(t, budget, fmt.Sprintf("http://host%d.example.com/", i), 1)
+	}
+	clock.Advance(5 * time.Second)
+	budgetRequests(t, budget, "http://new.example.com/", 1)
+	if n := len(budget.hosts); n != 101 {
+		t.Errorf("Expected no sweep within the window, got %d hosts", n)
+	}
+
+	clock.Advance(6 * time.Second)
+	budgetRequests(t, budget, "http://later.example.com/", 1)
+	if n := len(budget.hosts); n != 2 {
+		t.Errorf("Expected quiet hosts to be swept after a window, got %d hosts", n)
+	}
+}
+
+// TestRetryBudgetRateLimitQueue tests that RateLimitQueue returns the 429 once the budget is spent
+func TestRetryBudgetRateLimitQueue(t *testing.T) {
+	budget := &RetryBudget{MinRetries: 1}
+	next, attempts := throttlingServer(10, "0")
+	rt := Chain(next, budget.Middleware(), (&RateLimitQueue{Budget: budget}).Middleware())
+
+	req, _ := http.NewRequest("GET", "https://api.example.com/", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected the 429 once the budget ran out, got %d", resp.StatusCode)
+	}
+	if n := len(attempts()); n != 2 {
+		t.Errorf("Expected 1 request and 1 funded retry, got %d attempts", n)
+	}
+}
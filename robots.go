@@ -0,0 +1,373 @@
+package curlhttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDisallowedByRobots is returned for requests a site's robots.txt
+// disallows
+var ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+const (
+	// maxRobotsSize is how much of a robots.txt is parsed, as RFC 9309
+	// allows crawlers to ignore anything past 500 KiB
+	maxRobotsSize = 500 << 10
+
+	// maxRobotsRedirects is how many redirects are followed to a robots.txt
+	maxRobotsRedirects = 5
+
+	// robotsRetryInterval is how long an unreachable robots.txt is cached
+	robotsRetryInterval = time.Minute
+)
+
+// RobotsPolicy checks requests against each site's robots.txt before they
+// are sent. The file is fetched through the rest of the middleware chain,
+// and so with the same impersonation, the first time a site is requested,
+// then cached per origin. Install it with Transport.Use(p.Middleware()).
+//
+// Following RFC 9309, a robots.txt answered with a 4xx status allows
+// everything, and an unreachable one (5xx or a network error) disallows
+// everything until it is fetched again a minute later.
+type RobotsPolicy struct {
+	// Agent is the product token matched against User-agent lines, such as
+	// "MyCrawler". Groups for "*" apply when no group names it.
+	Agent string
+
+	// Annotate sends disallowed requests anyway and records a
+	// NoteRobotsDisallowed note on their responses, instead of failing them
+	// with ErrDisallowedByRobots.
+	Annotate bool
+
+	// TTL is how long a fetched robots.txt is used. Defaults to 24 hours.
+	TTL time.Duration
+
+	// Clock expires cached files. Defaults to SystemClock.
+	Clock Clock
+
+	mu    sync.Mutex
+	sites map[string]*robotsEntry
+}
+
+// robotsEntry is the cached robots.txt of one origin
+type robotsEntry struct {
+	done    chan struct{} // closed once the fetch finished
+	rules   *RobotsRules
+	err     error
+	expires time.Time
+}
+
+// Middleware returns middleware that enforces or annotates robots.txt rules
+func (p *RobotsPolicy) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path == "/robots.txt" {
+				return next.RoundTrip(req)
+			}
+			rules, err := p.rules(req, next)
+			if err != nil {
+				return nil, err
+			}
+			if rules.Allowed(req.URL.RequestURI()) {
+				return next.RoundTrip(req)
+			}
+			if !p.Annotate {
+				return nil, fmt.Errorf("%w: %s", ErrDisallowedByRobots, req.URL)
+			}
+			resp, err := next.RoundTrip(req)
+			if m := metaOf(resp); m != nil {
+				m.addNote(NoteRobotsDisallowed, fmt.Sprintf("robots.txt disallows %s for %s", req.URL.RequestURI(), p.agent()))
+			}
+			return resp, err
+		})
+	}
+}
+
+// agent returns the product token rules are selected for
+func (p *RobotsPolicy) agent() string {
+	if p.Agent == "" {
+		return "*"
+	}
+	return p.Agent
+}
+
+// rules returns the rules for req's origin, fetching them if they are not
+// cached. Concurrent requests to an origin share one fetch.
+func (p *RobotsPolicy) rules(req *http.Request, next http.RoundTripper) (*RobotsRules, error) {
+	key := origin(req.URL)
+	clock := clockOrSystem(p.Clock)
+	for {
+		p.mu.Lock()
+		if p.sites == nil {
+			p.sites = make(map[string]*robotsEntry)
+		}
+		entry, ok := p.sites[key]
+		if ok {
+			select {
+			case <-entry.done:
+				if clock.Now().Before(entry.expires) {
+					p.mu.Unlock()
+					return entry.rules, nil
+				}
+				ok = false
+			default:
+			}
+		}
+		if !ok {
+			entry = &robotsEntry{done: make(chan struct{})}
+			p.sites[key] = entry
+			p.mu.Unlock()
+			p.fetch(req, next, key, entry)
+			if entry.err != nil {
+				return nil, entry.err
+			}
+			return entry.rules, nil
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-entry.done:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		// A fetch abandoned with its request's context is retried with ours
+		if entry.err == nil {
+			return entry.rules, nil
+		}
+	}
+}
+
+// fetch downloads and parses the robots.txt of origin into entry
+func (p *RobotsPolicy) fetch(req *http.Request, next http.RoundTripper, origin string, entry *robotsEntry) {
+	defer close(entry.done)
+
+	ttl := p.TTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	rules, err := p.download(req, next, origin)
+	switch {
+	case err != nil:
+		entry.err = fmt.Errorf("failed to fetch robots.txt for %s: %w", origin, err)
+		p.mu.Lock()
+		if p.sites[origin] == entry {
+			delete(p.sites, origin)
+		}
+		p.mu.Unlock()
+		return
+	case rules == nil:
+		rules, ttl = disallowAllRules, robotsRetryInterval
+	}
+	entry.rules = rules
+	entry.expires = clockOrSystem(p.Clock).Now().Add(ttl)
+}
+
+// disallowAllRules are used while a robots.txt is unreachable
+var disallowAllRules = &RobotsRules{rules: []robotsRule{{pattern: "/"}}}
+
+// download requests origin's robots.txt, following redirects. It returns
+// nil rules when the file is unreachable and an error only when req's
+// context ended.
+func (p *RobotsPolicy) download(req *http.Request, next http.RoundTripper, origin string) (*RobotsRules, error) {
+	ctx := req.Context()
+	target := origin + "/robots.txt"
+	for hops := 0; hops <= maxRobotsRedirects; hops++ {
+		robotsReq, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if ua := req.Header.Get("User-Agent"); ua != "" {
+			robotsReq.Header.Set("User-Agent", ua)
+		}
+		resp, err := next.RoundTrip(robotsReq)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, nil
+		}
+
+		switch code := resp.StatusCode; {
+		case code >= 200 && code <= 299:
+			data, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+			resp.Body.Close()
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				return nil, nil
+			}
+			return ParseRobots(data, p.agent()), nil
+		case isRedirectStatus(code) && resp.Header.Get("Location") != "":
+			resp.Body.Close()
+			u, err := robotsReq.URL.Parse(resp.Header.Get("Location"))
+			if err != nil {
+				return &RobotsRules{}, nil
+			}
+			target = u.String()
+		case code >= 500:
+			resp.Body.Close()
+			return nil, nil
+		default:
+			// Missing or forbidden files allow everything
+			resp.Body.Close()
+			return &RobotsRules{}, nil
+		}
+	}
+	return &RobotsRules{}, nil
+}
+
+// Allowed reports whether p allows rawURL, using the cached robots.txt of
+// its origin. It reports true for origins that have not been fetched yet.
+func (p *RobotsPolicy) Allowed(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.sites[origin(u)]
+	if !ok {
+		return true
+	}
+	select {
+	case <-entry.done:
+		return entry.rules == nil || entry.rules.Allowed(u.RequestURI())
+	default:
+		return true
+	}
+}
+
+// RobotsRules are the robots.txt rules that apply to one agent
+type RobotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// robotsRule is an Allow or Disallow line
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// ParseRobots parses a robots.txt and returns the rules for agent: those of
+// every group naming agent, or of the "*" groups if none does. Agents are
+// compared case-insensitively.
+func ParseRobots(data []byte, agent string) *RobotsRules {
+	agent = strings.ToLower(strings.TrimSpace(agent))
+
+	var specific, wildcard RobotsRules
+	foundSpecific := false
+	groupSpecific, groupWildcard, inRules := false, false, false
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			// A user-agent line after rules starts a new group
+			if inRules {
+				groupSpecific, groupWildcard, inRules = false, false, false
+			}
+			name := strings.ToLower(value)
+			if name == "*" {
+				groupWildcard = true
+			} else if name == agent {
+				groupSpecific, foundSpecific = true, true
+			}
+			continue
+		}
+
+		var rule *robotsRule
+		switch key {
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue
+			}
+			rule = &robotsRule{pattern: value, allow: key == "allow"}
+		case "crawl-delay":
+			inRules = true
+		default:
+			continue
+		}
+		for _, target := range []struct {
+			match bool
+			rules *RobotsRules
+		}{{groupSpecific, &specific}, {groupWildcard, &wildcard}} {
+			if !target.match {
+				continue
+			}
+			if rule != nil {
+				target.rules.rules = append(target.rules.rules, *rule)
+			} else if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+				target.rules.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+	if foundSpecific {
+		return &specific
+	}
+	return &wildcard
+}
+
+// Allowed reports whether the rules allow path, which may include a query.
+// The longest matching rule wins, and Allow wins a tie.
+func (r *RobotsRules) Allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > longest || (n == longest && rule.allow) {
+			allowed, longest = rule.allow, n
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay returns the Crawl-delay of the rules, or 0 if there is none.
+// It can be used as a Politeness Delay.
+func (r *RobotsRules) CrawlDelay() time.Duration {
+	return r.crawlDelay
+}
+
+// robotsMatch reports whether path matches pattern, where * matches any
+// sequence of characters and a trailing $ anchors the end of the path
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		j := strings.Index(rest, part)
+		if j < 0 {
+			return false
+		}
+		rest = rest[j+len(part):]
+	}
+	return !anchored || rest == ""
+}
@@ -0,0 +1,156 @@
+package curlhttp
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testRobots = `# example
+User-agent: *
+Disallow: /private
+Allow: /private/open
+Crawl-delay: 2
+
+User-agent: MyCrawler
+User-agent: OtherBot
+Disallow: /*.pdf$
+Disallow: /search?
+Crawl-delay: 0.5
+`
+
+// robotsSite serves robots with the given status and counts its fetches
+func robotsSite(status int, robots string, fetches *atomic.Int32) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body := "page"
+		code := 200
+		if req.URL.Path == "/robots.txt" {
+			fetches.Add(1)
+			body, code = robots, status
+		}
+		return &http.Response{
+			StatusCode: code,
+			Header:     make(http.Header),
+			Body:       newResponseBody([]byte(body)),
+			Request:    req,
+		}, nil
+	})
+}
+
+// TestParseRobots tests group selection, precedence and wildcards
+func TestParseRobots(t *testing.T) {
+	wildcard := ParseRobots([]byte(testRobots), "SomeBot")
+	specific := ParseRobots([]byte(testRobots), "mycrawler")
+
+	tests := []struct {
+		rules   *RobotsRules
+		path    string
+		allowed bool
+	}{
+		{wildcard, "/", true},
+		{wildcard, "/private/data", false},
+		{wildcard, "/private/open/x", true},
+		{wildcard, "/robots.txt", true},
+		{specific, "/private/data", true},
+		{specific, "/docs/a.pdf", false},
+		{specific, "/docs/a.pdf?x=1", true},
+		{specific, "/search?q=go", false},
+		{specific, "/search", true},
+	}
+	for _, tt := range tests {
+		if got := tt.rules.Allowed(tt.path); got != tt.allowed {
+			t.Errorf("Allowed(%q) = %v, expected %v", tt.path, got, tt.allowed)
+		}
+	}
+	if wildcard.CrawlDelay() != 2*time.Second || specific.CrawlDelay() != 500*time.Millisecond {
+		t.Errorf("Expected crawl delays 2s and 500ms, got %v and %v", wildcard.CrawlDelay(), specific.CrawlDelay())
+	}
+}
+
+// TestRobotsPolicyBlocks tests that disallowed requests fail and the file is cached
+func TestRobotsPolicyBlocks(t *testing.T) {
+	var fetches atomic.Int32
+	policy := &RobotsPolicy{Agent: "MyCrawler"}
+	rt := Chain(robotsSite(200, testRobots, &fetches), policy.Middleware())
+
+	req, _ := http.NewRequest("GET", "http://example.com/search?q=go", nil)
+	if _, err := rt.RoundTrip(req); !errors.Is(err, ErrDisallowedByRobots) {
+		t.Errorf("Expected ErrDisallowedByRobots, got %v", err)
+	}
+	req, _ = http.NewRequest("GET", "http://example.com/about", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Expected allowed request to succeed, got %v", err)
+	}
+	resp.Body.Close()
+	if fetches.Load() != 1 {
+		t.Errorf("Expected robots.txt to be fetched once, got %d", fetches.Load())
+	}
+	if policy.Allowed("http://example.com/search?q=x") {
+		t.Error("Expected Allowed to use the cached rules")
+	}
+}
+
+// TestRobotsPolicyAnnotate tests that Annotate lets requests through with a note
+func TestRobotsPolicyAnnotate(t *testing.T) {
+	var fetches atomic.Int32
+	policy := &RobotsPolicy{Annotate: true}
+	rt := Chain(robotsSite(200, testRobots, &fetches), policy.Middleware())
+
+	req, _ := http.NewRequest("GET", "http://example.com/private/x", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if !HasNote(resp, NoteRobotsDisallowed) {
+		t.Error("Expected a robots-disallowed note")
+	}
+}
+
+// TestRobotsPolicyStatus tests the handling of missing and unreachable files
+func TestRobotsPolicyStatus(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var fetches atomic.Int32
+	policy := &RobotsPolicy{Clock: clock}
+	rt := Chain(robotsSite(503, "", &fetches), policy.Middleware())
+
+	req, _ := http.NewRequest("GET", "http://down.example.com/", nil)
+	if _, err := rt.RoundTrip(req); !errors.Is(err, ErrDisallowedByRobots) {
+		t.Errorf("Expected an unreachable robots.txt to disallow, got %v", err)
+	}
+	clock.Advance(2 * time.Minute)
+	rt.RoundTrip(req)
+	if fetches.Load() != 2 {
+		t.Errorf("Expected an unreachable robots.txt to be retried, got %d fetches", fetches.Load())
+	}
+
+	rt = Chain(robotsSite(404, "", &fetches), (&RobotsPolicy{}).Middleware())
+	req, _ = http.NewRequest("GET", "http://missing.example.com/anything", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Errorf("Expected a missing robots.txt to allow, got %v", err)
+	}
+}
+
+// TestRobotsMatch tests wildcard and anchor matching
+func TestRobotsMatch(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		match         bool
+	}{
+		{"/a", "/abc", true},
+		{"/a$", "/abc", false},
+		{"/a$", "/a", true},
+		{"/*.gif$", "/x/y.gif", true},
+		{"/*.gif$", "/x/y.gif?z", false},
+		{"/a*c*e", "/abcde", true},
+		{"/a*c*e", "/abde", false},
+		{"*", "/", true},
+	}
+	for _, tt := range tests {
+		if got := robotsMatch(tt.pattern, tt.path); got != tt.match {
+			t.Errorf("robotsMatch(%q, %q) = %v, expected %v", tt.pattern, tt.path, got, tt.match)
+		}
+	}
+}
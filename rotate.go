@@ -0,0 +1,200 @@
+package curlhttp
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Identity is an impersonation target and proxy combination a Rotator
+// sends requests with. An empty Proxy means a direct connection.
+type Identity struct {
+	Target string
+	Proxy  string
+}
+
+// IsBlocked reports whether resp looks like the server refused the client:
+// 403 and 429 responses and Cloudflare-style challenges
+func IsBlocked(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		return true
+	}
+	return resp.Header.Get("Cf-Mitigated") == "challenge"
+}
+
+// Rotator is an http.RoundTripper that retries blocked requests with a
+// different impersonation target and/or proxy. It remembers per host which
+// identity last got through and starts there next time.
+//
+// Requests with a body are only retried if it can be replayed through
+// GetBody.
+type Rotator struct {
+	// Base is cloned for every identity. Defaults to NewTransport().
+	Base *Transport
+
+	// Targets and Proxies are combined into the identities to try.
+	// Targets defaults to Base's target and Proxies to Base's proxy.
+	Targets []string
+	Proxies []*url.URL
+
+	// Blocked decides whether a response should be retried with another
+	// identity. Defaults to IsBlocked.
+	Blocked func(*http.Response) bool
+
+	// MaxAttempts bounds the identities tried per request. Defaults to
+	// trying each identity once.
+	MaxAttempts int
+
+	// OnRotate, if set, is called when a request to host is retried with
+	// another identity.
+	OnRotate func(host string, from, to Identity)
+
+	// Budget, if set, must fund each rotation; when it is spent the
+	// blocked response is returned to the caller
+	Budget *RetryBudget
+
+	mu         sync.Mutex
+	transports map[Identity]*Transport
+	working    map[string]Identity
+}
+
+// Working returns the identity that last got through to host
+func (r *Rotator) Working(host string) (Identity, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, ok := r.working[strings.ToLower(host)]
+	return id, ok
+}
+
+// identities returns every identity in order, starting with the one that
+// last worked for host
+func (r *Rotator) identities(host string) []Identity {
+	base := r.Base
+	targets := r.Targets
+	if len(targets) == 0 {
+		target := "chrome136"
+		if base != nil && base.ImpersonateTarget != "" {
+			target = base.ImpersonateTarget
+		}
+		targets = []string{target}
+	}
+	proxies := []string{""}
+	if len(r.Proxies) > 0 {
+		proxies = proxies[:0]
+		for _, proxy := range r.Proxies {
+			if proxy == nil {
+				proxies = append(proxies, "")
+			} else {
+				proxies = append(proxies, proxy.String())
+			}
+		}
+	} else if base != nil && base.Proxy != nil {
+		proxies[0] = base.Proxy.String()
+	}
+
+	ids := make([]Identity, 0, len(targets)*len(proxies))
+	for _, proxy := range proxies {
+		for _, target := range targets {
+			ids = append(ids, Identity{Target: target, Proxy: proxy})
+		}
+	}
+
+	r.mu.Lock()
+	last, ok := r.working[host]
+	r.mu.Unlock()
+	if ok {
+		for i, id := range ids {
+			if id == last {
+				ids = append(append([]Identity{}, ids[i:]...), ids[:i]...)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// transport returns the Transport for id, creating it on first use
+func (r *Rotator) transport(id Identity) (*Transport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.transports[id]; ok {
+		return t, nil
+	}
+
+	base := r.Base
+	if base == nil {
+		base = NewTransport()
+	}
+	t := base.Clone()
+	t.ImpersonateTarget = id.Target
+	t.Proxy = nil
+	if id.Proxy != "" {
+		proxy, err := url.Parse(id.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = proxy
+	}
+	if r.transports == nil {
+		r.transports = make(map[Identity]*Transport)
+	}
+	r.transports[id] = t
+	return t, nil
+}
+
+// RoundTrip sends req, switching identity while the response is blocked
+func (r *Rotator) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Host)
+	ids := r.identities(host)
+	attempts := len(ids)
+	if r.MaxAttempts > 0 && r.MaxAttempts < attempts {
+		attempts = r.MaxAttempts
+	}
+	if !canReplayBody(req) {
+		attempts = 1
+	}
+	blocked := r.Blocked
+	if blocked == nil {
+		blocked = IsBlocked
+	}
+
+	for i := 0; ; i++ {
+		id := ids[i]
+		t, err := r.transport(id)
+		if err != nil {
+			return nil, err
+		}
+
+		attempt := req.Clone(WithAttempt(req.Context(), i+1))
+		if i > 0 && req.GetBody != nil {
+			if attempt.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := t.RoundTrip(attempt)
+		if err != nil {
+			return nil, err
+		}
+		if !blocked(resp) {
+			r.mu.Lock()
+			if r.working == nil {
+				r.working = make(map[string]Identity)
+			}
+			r.working[host] = id
+			r.mu.Unlock()
+			return resp, nil
+		}
+		if i+1 >= attempts || !allowRetry(r.Budget, req) {
+			return resp, nil
+		}
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if r.OnRotate != nil {
+			r.OnRotate(host, id, ids[i+1])
+		}
+	}
+}
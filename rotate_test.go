@@ -0,0 +1,159 @@
+package curlhttp
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// targetEngine answers 403 unless impersonating an allowed target
+type targetEngine struct {
+	*fakeEngine
+	allowed string
+	seen    *[]string
+}
+
+func (e *targetEngine) Perform() error {
+	*e.seen = append(*e.seen, e.target)
+	if e.target == e.allowed {
+		e.status = 200
+		e.headers = []string{"HTTP/1.1 200 OK\r\n", "\r\n"}
+	} else {
+		e.status = 403
+		e.headers = []string{"HTTP/1.1 403 Forbidden\r\n", "\r\n"}
+	}
+	return e.fakeEngine.Perform()
+}
+
+// newRotatorBase returns a Transport whose handles only succeed for allowed
+func newRotatorBase(allowed string, seen *[]string) *Transport {
+	base := NewTransport()
+	base.newEngine = func() curlEngine {
+		return &targetEngine{fakeEngine: newFakeEngine("ok"), allowed: allowed, seen: seen}
+	}
+	return base
+}
+
+// TestRotatorSwitchesTarget tests that a blocked request is retried with the next target
+func TestRotatorSwitchesTarget(t *testing.T) {
+	var seen []string
+	var rotations []string
+	rotator := &Rotator{
+		Base:    newRotatorBase("firefox133", &seen),
+		Targets: []string{"chrome136", "firefox133"},
+		OnRotate: func(host string, from, to Identity) {
+			rotations = append(rotations, from.Target+"->"+to.Target)
+		},
+	}
+
+	req, _ := http.NewRequest("POST", "http://Example.com/", strings.NewReader("body"))
+	resp, err := rotator.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected 200 after rotation, got %d", resp.StatusCode)
+	}
+	if len(rotations) != 1 || rotations[0] != "chrome136->firefox133" {
+		t.Errorf("Expected one rotation to firefox133, got %v", rotations)
+	}
+	if id, ok := rotator.Working("example.com"); !ok || id.Target != "firefox133" {
+		t.Errorf("Expected firefox133 to be remembered, got %v", id)
+	}
+
+	// The remembered identity is tried first next time
+	seen = nil
+	req, _ = http.NewRequest("GET", "http://example.com/again", nil)
+	resp, err = rotator.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+	if len(seen) != 1 || seen[0] != "firefox133" {
+		t.Errorf("Expected only firefox133 to be tried, got %v", seen)
+	}
+}
+
+// TestRotatorGivesUp tests that the last blocked response is returned
+func TestRotatorGivesUp(t *testing.T) {
+	var seen []string
+	proxy, _ := url.Parse("http://proxy.example:8080")
+	rotator := &Rotator{
+		Base:        newRotatorBase("none", &seen),
+		Targets:     []string{"chrome136", "safari18_0"},
+		Proxies:     []*url.URL{nil, proxy},
+		MaxAttempts: 3,
+	}
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	resp, err := rotator.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 403 {
+		t.Errorf("Expected final 403, got %d", resp.StatusCode)
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected 3 attempts, got %v", seen)
+	}
+	if _, ok := rotator.Working("example.com"); ok {
+		t.Error("Expected no working identity to be recorded")
+	}
+}
+
+// TestRotatorNoReplay tests that bodies without GetBody are sent only once
+func TestRotatorNoReplay(t *testing.T) {
+	var seen []string
+	rotator := &Rotator{
+		Base:    newRotatorBase("firefox133", &seen),
+		Targets: []string{"chrome136", "firefox133"},
+	}
+
+	req, _ := http.NewRequest("POST", "http://example.com/", strings.NewReader("body"))
+	req.GetBody = nil
+	resp, err := rotator.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+	if len(seen) != 1 {
+		t.Errorf("Expected a single attempt, got %v", seen)
+	}
+}
+
+// TestRotatorBudget tests that rotations stop once the retry budget is spent
+func TestRotatorBudget(t *testing.T) {
+	var seen []string
+	rotator := &Rotator{
+		Base:    newRotatorBase("none", &seen),
+		Targets: []string{"chrome136", "firefox133", "safari18_0"},
+		Budget:  &RetryBudget{MinRetries: 1},
+	}
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	resp, err := rotator.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 403 {
+		t.Errorf("Expected the blocked response, got %d", resp.StatusCode)
+	}
+	if len(seen) != 2 {
+		t.Errorf("Expected a single funded rotation, got %v", seen)
+	}
+}
+
+// TestIsBlocked tests block signal detection
+func TestIsBlocked(t *testing.T) {
+	challenge := &http.Response{StatusCode: 200, Header: http.Header{"Cf-Mitigated": {"challenge"}}}
+	if !IsBlocked(&http.Response{StatusCode: 429}) || !IsBlocked(challenge) {
+		t.Error("Expected 429 and challenges to count as blocked")
+	}
+	if IsBlocked(&http.Response{StatusCode: 404, Header: http.Header{}}) {
+		t.Error("Expected 404 not to count as blocked")
+	}
+}
@@ -0,0 +1,125 @@
+package curlhttp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// clientScope holds the base URL and defaults of a derived Client
+type clientScope struct {
+	base   *url.URL
+	err    error
+	header http.Header
+	query  url.Values
+}
+
+// clone returns a copy of s that can be changed independently
+func (s *clientScope) clone() *clientScope {
+	if s == nil {
+		return &clientScope{header: make(http.Header), query: make(url.Values)}
+	}
+	return &clientScope{base: s.base, err: s.err, header: s.header.Clone(), query: cloneValues(s.query)}
+}
+
+// cloneValues returns a deep copy of v
+func cloneValues(v url.Values) url.Values {
+	out := make(url.Values, len(v))
+	for key, values := range v {
+		out[key] = append([]string(nil), values...)
+	}
+	return out
+}
+
+// derive returns a copy of c sharing its Transport, with an independent
+// copy of its scope
+func (c *Client) derive() *Client {
+	c.ensureInitialized()
+	return &Client{Client: c.Client, initialized: true, scope: c.scope.clone()}
+}
+
+// WithBase returns a Client that resolves relative request URLs against
+// base, such as "https://api.example.com/v1". Relative paths are appended
+// to the base path, so "users" and "/users" both become /v1/users. Absolute
+// URLs are used as they are. The new Client shares c's Transport, Jar and
+// Timeout; c is not changed.
+func (c *Client) WithBase(base string) *Client {
+	d := c.derive()
+	u, err := url.Parse(base)
+	if err == nil && (u.Scheme == "" || u.Host == "") {
+		err = fmt.Errorf("base URL %q is not absolute", base)
+	}
+	if err != nil {
+		d.scope.err = fmt.Errorf("invalid base URL: %w", err)
+	} else {
+		d.scope.base, d.scope.err = u, nil
+	}
+	return d
+}
+
+// WithHeader returns a Client that adds the header to requests that do
+// not set it themselves
+func (c *Client) WithHeader(key, value string) *Client {
+	d := c.derive()
+	d.scope.header.Add(key, value)
+	return d
+}
+
+// WithQuery returns a Client that adds the query parameter to requests
+// whose URL does not have it
+func (c *Client) WithQuery(key, value string) *Client {
+	d := c.derive()
+	d.scope.query.Add(key, value)
+	return d
+}
+
+// apply returns a copy of req with the scope's base URL and defaults
+// applied
+func (s *clientScope) apply(req *http.Request) (*http.Request, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	req = req.Clone(req.Context())
+	if s.base != nil && !req.URL.IsAbs() {
+		req.URL = joinURL(s.base, req.URL)
+		req.Host = ""
+	}
+	if len(s.query) > 0 {
+		query := req.URL.Query()
+		for key, values := range s.query {
+			if _, ok := query[key]; !ok {
+				query[key] = append([]string(nil), values...)
+			}
+		}
+		req.URL.RawQuery = query.Encode()
+	}
+	for key, values := range s.header {
+		if _, ok := req.Header[key]; !ok {
+			req.Header[key] = append([]string(nil), values...)
+		}
+	}
+	return req, nil
+}
+
+// joinURL appends the path of ref to base, keeping ref's query and fragment
+func joinURL(base, ref *url.URL) *url.URL {
+	u := *base
+	u.Path = strings.TrimSuffix(base.Path, "/") + "/" + strings.TrimPrefix(ref.Path, "/")
+	u.RawPath = ""
+	u.RawQuery, u.Fragment, u.RawFragment = ref.RawQuery, ref.Fragment, ref.RawFragment
+	return &u
+}
+
+// scopedRequest builds a request for the scoped convenience methods
+func (c *Client) scopedRequest(method, rawURL, contentType string, body io.Reader) (*Response, error) {
+	req, err := http.NewRequest(method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return c.Do(req)
+}
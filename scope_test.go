@@ -0,0 +1,93 @@
+package curlhttp
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// recordingClient returns a Client whose transport records each request
+func recordingClient(seen *[]*http.Request) *Client {
+	return &Client{Client: http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		*seen = append(*seen, req)
+		resp := jsonResponse(200, "application/json", `{}`)
+		resp.Request = req
+		return resp, nil
+	})}}
+}
+
+// TestClientWithBase tests URL resolution against a base URL
+func TestClientWithBase(t *testing.T) {
+	var seen []*http.Request
+	api := recordingClient(&seen).WithBase("https://api.example.com/v1/")
+
+	for _, path := range []string{"users", "/users?page=2", "https://other.example.com/x"} {
+		resp, err := api.Get(path)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+	want := []string{
+		"https://api.example.com/v1/users",
+		"https://api.example.com/v1/users?page=2",
+		"https://other.example.com/x",
+	}
+	for i, w := range want {
+		if got := seen[i].URL.String(); got != w {
+			t.Errorf("Expected %s, got %s", w, got)
+		}
+	}
+
+	if _, err := recordingClient(&seen).WithBase("/relative").Get("x"); err == nil {
+		t.Error("Expected an error for a relative base URL")
+	}
+}
+
+// TestClientDefaults tests default headers and query parameters
+func TestClientDefaults(t *testing.T) {
+	var seen []*http.Request
+	base := recordingClient(&seen)
+	api := base.WithBase("https://api.example.com").
+		WithHeader("X-Api-Key", "secret").
+		WithQuery("format", "json")
+
+	req, _ := http.NewRequest("GET", "/items?format=xml", nil)
+	req.Header.Set("X-Api-Key", "override")
+	resp, err := api.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := seen[0]; got.Header.Get("X-Api-Key") != "override" || got.URL.Query().Get("format") != "xml" {
+		t.Errorf("Expected request values to win, got %v and %s", got.Header, got.URL.RawQuery)
+	}
+	if req.URL.IsAbs() {
+		t.Error("Expected the caller's request to be left unchanged")
+	}
+
+	var out struct{}
+	if err := api.PostJSON(context.Background(), "items", map[string]int{}, &out); err != nil {
+		t.Fatalf("PostJSON failed: %v", err)
+	}
+	if got := seen[1]; got.Header.Get("X-Api-Key") != "secret" || got.URL.String() != "https://api.example.com/items?format=json" {
+		t.Errorf("Expected defaults to be applied, got %v and %s", got.Header, got.URL)
+	}
+
+	resp, err = api.PostForm("form", url.Values{"a": {"1"}})
+	if err != nil {
+		t.Fatalf("PostForm failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := seen[2]; got.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		t.Errorf("Expected form content type, got %q", got.Header.Get("Content-Type"))
+	}
+
+	// The parent client is not changed
+	resp, _ = base.Get("https://example.com/")
+	resp.Body.Close()
+	if seen[3].Header.Get("X-Api-Key") != "" {
+		t.Error("Expected the parent client to have no default headers")
+	}
+}
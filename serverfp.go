@@ -0,0 +1,363 @@
+package curlhttp
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerFingerprint is the TLS fingerprint of a server, derived from its
+// ServerHello. JA3S and JA4S identify the TLS stack in front of a host, so a
+// change usually means the host moved behind a different CDN or anti-bot
+// vendor.
+//
+// libcurl does not expose the raw ServerHello, so fingerprints are taken
+// with a separate probe handshake. Because a ServerHello depends on the
+// ClientHello it answers, values are comparable between probes but not with
+// fingerprints captured from other clients.
+type ServerFingerprint struct {
+	Host        string
+	Version     uint16   // negotiated version (from supported_versions if present)
+	CipherSuite uint16   // selected cipher suite
+	Extensions  []uint16 // ServerHello extensions in the order sent
+	ALPN        string   // negotiated application protocol
+	JA3S        string   // MD5 of JA3SString
+	JA3SString  string   // "version,cipher,ext-ext-..."
+	JA4S        string
+	ProbedAt    time.Time
+}
+
+// Equal reports whether two fingerprints identify the same TLS stack
+func (f *ServerFingerprint) Equal(other *ServerFingerprint) bool {
+	if f == nil || other == nil {
+		return f == other
+	}
+	return f.JA3S == other.JA3S && f.JA4S == other.JA4S
+}
+
+// errNoServerHello is returned when the probe did not see a ServerHello
+var errNoServerHello = errors.New("no ServerHello received")
+
+// ProbeServerFingerprint performs a TLS handshake with address (host:port,
+// port 443 if omitted) and returns the server's fingerprint.
+func ProbeServerFingerprint(ctx context.Context, address string) (*ServerFingerprint, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = address, "443"
+	}
+
+	var dialer net.Dialer
+	raw, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect for fingerprint probe: %w", err)
+	}
+	defer raw.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		raw.SetDeadline(deadline)
+	}
+
+	recorder := &handshakeRecorder{Conn: raw}
+	conn := tls.Client(recorder, &tls.Config{
+		ServerName:         host,
+		NextProtos:         []string{"h2", "http/1.1"},
+		InsecureSkipVerify: true, // fingerprinting only; matches the transport's defaults
+	})
+	handshakeErr := conn.HandshakeContext(ctx)
+
+	hello, err := parseServerHello(recorder.bytes())
+	if err != nil {
+		if handshakeErr != nil {
+			return nil, fmt.Errorf("fingerprint probe handshake failed: %w", handshakeErr)
+		}
+		return nil, err
+	}
+	if handshakeErr == nil {
+		hello.alpn = conn.ConnectionState().NegotiatedProtocol
+	}
+
+	fp := hello.fingerprint()
+	fp.Host = strings.ToLower(host)
+	fp.ProbedAt = time.Now()
+	return fp, nil
+}
+
+// handshakeRecorder keeps the first bytes read from the server
+type handshakeRecorder struct {
+	net.Conn
+	mu  sync.Mutex
+	buf []byte
+}
+
+// maxRecordedHandshake bounds how much server data the recorder keeps
+const maxRecordedHandshake = 64 * 1024
+
+// Read implements net.Conn, recording what it returns
+func (r *handshakeRecorder) Read(p []byte) (int, error) {
+	n, err := r.Conn.Read(p)
+	r.mu.Lock()
+	if room := maxRecordedHandshake - len(r.buf); room > 0 {
+		if n < room {
+			room = n
+		}
+		r.buf = append(r.buf, p[:room]...)
+	}
+	r.mu.Unlock()
+	return n, err
+}
+
+// bytes returns the recorded data
+func (r *handshakeRecorder) bytes() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf
+}
+
+// serverHello holds the fields of a ServerHello used for fingerprinting
+type serverHello struct {
+	legacyVersion uint16
+	version       uint16
+	cipherSuite   uint16
+	extensions    []uint16
+	alpn          string
+}
+
+// parseServerHello extracts the ServerHello from raw TLS records
+func parseServerHello(data []byte) (*serverHello, error) {
+	// Reassemble handshake messages from consecutive handshake records
+	var handshake []byte
+	for len(data) >= 5 {
+		contentType := data[0]
+		length := int(binary.BigEndian.Uint16(data[3:5]))
+		if len(data) < 5+length {
+			break
+		}
+		if contentType != 22 { // handshake
+			break
+		}
+		handshake = append(handshake, data[5:5+length]...)
+		data = data[5+length:]
+		if len(handshake) >= 4 {
+			msgLen := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+			if len(handshake) >= 4+msgLen {
+				break
+			}
+		}
+	}
+
+	if len(handshake) < 4 || handshake[0] != 2 { // server_hello
+		return nil, errNoServerHello
+	}
+	msgLen := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+	if len(handshake) < 4+msgLen {
+		return nil, errNoServerHello
+	}
+	msg := handshake[4 : 4+msgLen]
+
+	malformed := errors.New("malformed ServerHello")
+	if len(msg) < 2+32+1 {
+		return nil, malformed
+	}
+	hello := &serverHello{legacyVersion: binary.BigEndian.Uint16(msg[0:2])}
+	hello.version = hello.legacyVersion
+	pos := 2 + 32
+	sessionIDLen := int(msg[pos])
+	pos += 1 + sessionIDLen
+	if len(msg) < pos+3 {
+		return nil, malformed
+	}
+	hello.cipherSuite = binary.BigEndian.Uint16(msg[pos : pos+2])
+	pos += 3 // cipher suite + compression method
+
+	if len(msg) < pos+2 {
+		// No extensions
+		return hello, nil
+	}
+	extLen := int(binary.BigEndian.Uint16(msg[pos : pos+2]))
+	pos += 2
+	if len(msg) < pos+extLen {
+		return nil, malformed
+	}
+	exts := msg[pos : pos+extLen]
+	for len(exts) >= 4 {
+		extType := binary.BigEndian.Uint16(exts[0:2])
+		length := int(binary.BigEndian.Uint16(exts[2:4]))
+		if len(exts) < 4+length {
+			return nil, malformed
+		}
+		body := exts[4 : 4+length]
+		hello.extensions = append(hello.extensions, extType)
+		switch extType {
+		case 43: // supported_versions
+			if len(body) == 2 {
+				hello.version = binary.BigEndian.Uint16(body)
+			}
+		case 16: // application_layer_protocol_negotiation
+			if len(body) >= 3 && int(body[2]) <= len(body)-3 {
+				hello.alpn = string(body[3 : 3+int(body[2])])
+			}
+		}
+		exts = exts[4+length:]
+	}
+	return hello, nil
+}
+
+// fingerprint computes JA3S and JA4S for the ServerHello
+func (h *serverHello) fingerprint() *ServerFingerprint {
+	decimal := make([]string, len(h.extensions))
+	hexExts := make([]string, len(h.extensions))
+	for i, ext := range h.extensions {
+		decimal[i] = strconv.Itoa(int(ext))
+		hexExts[i] = fmt.Sprintf("%04x", ext)
+	}
+
+	ja3s := fmt.Sprintf("%d,%d,%s", h.legacyVersion, h.cipherSuite, strings.Join(decimal, "-"))
+	ja3sHash := md5.Sum([]byte(ja3s))
+
+	alpn := "00"
+	if h.alpn != "" {
+		alpn = string(h.alpn[0]) + string(h.alpn[len(h.alpn)-1])
+	}
+	extCount := len(h.extensions)
+	if extCount > 99 {
+		extCount = 99
+	}
+	extHash := sha256.Sum256([]byte(strings.Join(hexExts, ",")))
+	ja4s := fmt.Sprintf("t%s%02d%s_%04x_%s", ja4Version(h.version), extCount, alpn, h.cipherSuite, hex.EncodeToString(extHash[:])[:12])
+
+	return &ServerFingerprint{
+		Version:     h.version,
+		CipherSuite: h.cipherSuite,
+		Extensions:  h.extensions,
+		ALPN:        h.alpn,
+		JA3S:        hex.EncodeToString(ja3sHash[:]),
+		JA3SString:  ja3s,
+		JA4S:        ja4s,
+	}
+}
+
+// ja4Version maps a TLS version to its two-character JA4 code
+func ja4Version(v uint16) string {
+	switch v {
+	case tls.VersionTLS13:
+		return "13"
+	case tls.VersionTLS12:
+		return "12"
+	case tls.VersionTLS11:
+		return "11"
+	case tls.VersionTLS10:
+		return "10"
+	case 0x0300:
+		return "s3"
+	default:
+		return "00"
+	}
+}
+
+// FingerprintTracker keeps the last known server fingerprint per host and
+// reports changes. Assign it to Transport.ServerFingerprints to probe hosts
+// automatically when the transport opens new connections to them.
+type FingerprintTracker struct {
+	// Interval is the minimum time between probes of the same host.
+	// Defaults to one hour.
+	Interval time.Duration
+
+	// Timeout bounds each probe. Defaults to 10 seconds.
+	Timeout time.Duration
+
+	// OnChange is called when a host's fingerprint differs from the
+	// previous probe. It is not called for the first probe of a host.
+	OnChange func(host string, previous, current *ServerFingerprint)
+
+	// Clock decides when a host is due for another probe. Defaults to
+	// SystemClock.
+	Clock Clock
+
+	mu      sync.Mutex
+	hosts   map[string]*ServerFingerprint
+	probing map[string]bool
+	probe   func(ctx context.Context, address string) (*ServerFingerprint, error)
+}
+
+// Fingerprint returns the last fingerprint recorded for host
+func (t *FingerprintTracker) Fingerprint(host string) (*ServerFingerprint, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fp, ok := t.hosts[strings.ToLower(host)]
+	return fp, ok
+}
+
+// Check probes address (host:port) now and records the result
+func (t *FingerprintTracker) Check(ctx context.Context, address string) (*ServerFingerprint, error) {
+	probe := t.probe
+	if probe == nil {
+		probe = ProbeServerFingerprint
+	}
+	fp, err := probe(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	fp.ProbedAt = clockOrSystem(t.Clock).Now()
+
+	t.mu.Lock()
+	if t.hosts == nil {
+		t.hosts = make(map[string]*ServerFingerprint)
+	}
+	previous := t.hosts[fp.Host]
+	t.hosts[fp.Host] = fp
+	t.mu.Unlock()
+
+	if previous != nil && !previous.Equal(fp) && t.OnChange != nil {
+		t.OnChange(fp.Host, previous, fp)
+	}
+	return fp, nil
+}
+
+// maybeProbe starts a background probe of address unless one is running or
+// the host was probed within Interval
+func (t *FingerprintTracker) maybeProbe(address string) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	host = strings.ToLower(host)
+	interval := t.Interval
+	if interval == 0 {
+		interval = time.Hour
+	}
+
+	t.mu.Lock()
+	if t.probing == nil {
+		t.probing = make(map[string]bool)
+	}
+	if fp := t.hosts[host]; t.probing[host] || (fp != nil && clockOrSystem(t.Clock).Now().Sub(fp.ProbedAt) < interval) {
+		t.mu.Unlock()
+		return
+	}
+	t.probing[host] = true
+	t.mu.Unlock()
+
+	go func() {
+		defer func() {
+			t.mu.Lock()
+			delete(t.probing, host)
+			t.mu.Unlock()
+		}()
+		timeout := t.Timeout
+		if timeout == 0 {
+			timeout = 10 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		t.Check(ctx, address)
+	}()
+}
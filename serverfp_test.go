@@ -0,0 +1,169 @@
+package curlhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// buildServerHello assembles a TLS record containing a ServerHello
+func buildServerHello(version, cipher uint16, exts [][]byte) []byte {
+	var extData []byte
+	for _, e := range exts {
+		extData = append(extData, e...)
+	}
+	msg := []byte{byte(version >> 8), byte(version)}
+	msg = append(msg, make([]byte, 32)...) // random
+	msg = append(msg, 0)                   // empty session id
+	msg = append(msg, byte(cipher>>8), byte(cipher), 0)
+	msg = append(msg, byte(len(extData)>>8), byte(len(extData)))
+	msg = append(msg, extData...)
+
+	handshake := append([]byte{2, 0, byte(len(msg) >> 8), byte(len(msg))}, msg...)
+	return append([]byte{22, 3, 3, byte(len(handshake) >> 8), byte(len(handshake))}, handshake...)
+}
+
+// TestParseServerHelloFingerprint tests JA3S/JA4S computation
+func TestParseServerHelloFingerprint(t *testing.T) {
+	record := buildServerHello(0x0303, 0x1301, [][]byte{
+		{0x00, 0x2b, 0x00, 0x02, 0x03, 0x04}, // supported_versions: TLS 1.3
+		{0x00, 0x33, 0x00, 0x00},             // key_share (empty for test)
+	})
+
+	hello, err := parseServerHello(record)
+	if err != nil {
+		t.Fatalf("parseServerHello failed: %v", err)
+	}
+	fp := hello.fingerprint()
+
+	if fp.JA3SString != "771,4865,43-51" {
+		t.Errorf("Unexpected JA3S string %q", fp.JA3SString)
+	}
+	if fp.JA3S != "f4febc55ea12b31ae17cfb7e614afda8" {
+		t.Errorf("Unexpected JA3S hash %q", fp.JA3S)
+	}
+	if !strings.HasPrefix(fp.JA4S, "t130200_1301_") || len(fp.JA4S) != len("t130200_1301_")+12 {
+		t.Errorf("Unexpected JA4S %q", fp.JA4S)
+	}
+}
+
+// TestParseServerHelloRejectsGarbage tests malformed input handling
+func TestParseServerHelloRejectsGarbage(t *testing.T) {
+	for _, data := range [][]byte{nil, {22, 3, 3, 0, 1, 1}, {21, 3, 3, 0, 2, 2, 40}} {
+		if _, err := parseServerHello(data); err == nil {
+			t.Errorf("Expected error for %v", data)
+		}
+	}
+}
+
+// TestProbeServerFingerprint tests a probe against a local TLS server
+func TestProbeServerFingerprint(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	fp, err := ProbeServerFingerprint(ctx, strings.TrimPrefix(server.URL, "https://"))
+	if err != nil {
+		t.Fatalf("ProbeServerFingerprint failed: %v", err)
+	}
+	if fp.JA3S == "" || fp.JA4S == "" {
+		t.Errorf("Expected fingerprints to be set, got %+v", fp)
+	}
+	if fp.Host != "127.0.0.1" {
+		t.Errorf("Expected host 127.0.0.1, got %q", fp.Host)
+	}
+}
+
+// TestFingerprintTrackerOnChange tests change notification between probes
+func TestFingerprintTrackerOnChange(t *testing.T) {
+	results := []*ServerFingerprint{
+		{Host: "example.com", JA3S: "a", JA4S: "x"},
+		{Host: "example.com", JA3S: "a", JA4S: "x"},
+		{Host: "example.com", JA3S: "b", JA4S: "y"},
+	}
+	var changes int
+	tracker := &FingerprintTracker{
+		OnChange: func(host string, previous, current *ServerFingerprint) {
+			changes++
+			if previous.JA3S != "a" || current.JA3S != "b" {
+				t.Errorf("Unexpected change %s -> %s", previous.JA3S, current.JA3S)
+			}
+		},
+		probe: func(ctx context.Context, address string) (*ServerFingerprint, error) {
+			fp := results[0]
+			results = results[1:]
+			return fp, nil
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := tracker.Check(context.Background(), "example.com:443"); err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+	}
+	if changes != 1 {
+		t.Errorf("Expected 1 change notification, got %d", changes)
+	}
+	if fp, ok := tracker.Fingerprint("EXAMPLE.com"); !ok || fp.JA3S != "b" {
+		t.Errorf("Expected latest fingerprint to be stored, got %+v", fp)
+	}
+}
+
+// TestFingerprintProbeFollowsTransferPath tests that background probes are
+// skipped when dialing directly would bypass the transfer's proxy or binding
+func TestFingerprintProbeFollowsTransferPath(t *testing.T) {
+	if proxy, _ := http.ProxyFromEnvironment(httptest.NewRequest("GET", "https://example.com/", nil)); proxy != nil {
+		t.Skip("a proxy is set in the environment")
+	}
+	proxy, _ := url.Parse("http://proxy.example:3128")
+	tests := []struct {
+		name      string
+		configure func(*Transport)
+		probed    bool
+	}{
+		{"direct", func(*Transport) {}, true},
+		{"proxy", func(tr *Transport) { tr.Proxy = proxy }, false},
+		{"bind device", func(tr *Transport) { tr.BindDevice = "eth1" }, false},
+		{"resolve", func(tr *Transport) { tr.Resolve = []string{"example.com:443:127.0.0.1"} }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probes := make(chan string, 1)
+			fake := newFakeEngine("ok")
+			fake.info = map[curl.CurlInfo]interface{}{infoNumConnects: int64(1)}
+			transport := newFakeTransport(fake)
+			transport.ServerFingerprints = &FingerprintTracker{
+				probe: func(ctx context.Context, address string) (*ServerFingerprint, error) {
+					probes <- address
+					return &ServerFingerprint{Host: "example.com"}, nil
+				},
+			}
+			tt.configure(transport)
+
+			req, _ := http.NewRequest("GET", "https://example.com/", nil)
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip failed: %v", err)
+			}
+			resp.Body.Close()
+
+			select {
+			case address := <-probes:
+				if !tt.probed {
+					t.Errorf("Expected no probe, got probe of %s", address)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.probed {
+					t.Error("Expected a probe of example.com:443")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package curlhttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hmacHex returns the hex HMAC-SHA256 of message under key
+func hmacHex(key, message string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// TestRequestSigner tests the default canonical string and signature header
+func TestRequestSigner(t *testing.T) {
+	fake := newFakeEngine("ok")
+	transport := newFakeTransport(fake)
+	transport.Signer = &RequestSigner{
+		Key:             []byte("secret"),
+		SignedHeaders:   []string{"Host", "content-type"},
+		TimestampHeader: "X-Timestamp",
+		BodyHashHeader:  "X-Content-SHA256",
+		Clock:           NewFakeClock(time.Unix(1700000000, 0)),
+	}
+	// Middleware changes come before signing
+	transport.Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("Content-Type", "application/json")
+			return next.RoundTrip(req)
+		})
+	})
+
+	req, _ := http.NewRequest("POST", "https://api.example.com/v1/orders?b=2&a=1", strings.NewReader(`{"id":1}`))
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+
+	sum := sha256.Sum256([]byte(`{"id":1}`))
+	bodyHash := hex.EncodeToString(sum[:])
+	toSign := "POST\n/v1/orders\na=1&b=2\n" +
+		"content-type:application/json\nhost:api.example.com\nx-timestamp:1700000000\n" +
+		"\n" + bodyHash
+	sent := sentHeaders(fake)
+	if got, want := sent["X-Signature"], hmacHex("secret", toSign); got != want {
+		t.Errorf("Expected signature %s, got %s", want, got)
+	}
+	if sent["X-Timestamp"] != "1700000000" || sent["X-Content-Sha256"] != bodyHash {
+		t.Errorf("Expected timestamp and body hash headers, got %v", sent)
+	}
+}
+
+// TestRequestSignerCustom tests a custom header, prefix, encoding and canonical form
+func TestRequestSignerCustom(t *testing.T) {
+	signer := &RequestSigner{
+		Key:    []byte("k"),
+		Header: "Authorization",
+		Prefix: "HMAC ",
+		Base64: true,
+		Canonicalize: func(in SigningInput) string {
+			return in.Method + " " + in.Path
+		},
+	}
+	headers := map[string]string{}
+	req, _ := http.NewRequest("DELETE", "http://example.com/items/7", nil)
+	if err := signer.sign(req, "http://example.com/items/7", headers, nil, false); err != nil {
+		t.Fatalf("sign failed: %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte("k"))
+	mac.Write([]byte("DELETE /items/7"))
+	if want := "HMAC " + base64.StdEncoding.EncodeToString(mac.Sum(nil)); headers["Authorization"] != want {
+		t.Errorf("Expected %q, got %q", want, headers["Authorization"])
+	}
+}
+
+// TestRequestSignerStreamedBody tests hashing streamed bodies through GetBody
+func TestRequestSignerStreamedBody(t *testing.T) {
+	signer := &RequestSigner{Key: []byte("k"), BodyHashHeader: "X-Hash"}
+	req, _ := http.NewRequest("PUT", "http://example.com/", strings.NewReader("streamed"))
+	headers := map[string]string{}
+	if err := signer.sign(req, "http://example.com/", headers, nil, true); err != nil {
+		t.Fatalf("sign failed: %v", err)
+	}
+	sum := sha256.Sum256([]byte("streamed"))
+	if headers["X-Hash"] != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the hash of the streamed body, got %q", headers["X-Hash"])
+	}
+
+	req.GetBody = nil
+	req.Body = io.NopCloser(strings.NewReader("streamed"))
+	if err := signer.sign(req, "http://example.com/", headers, nil, true); err == nil {
+		t.Errorf("Expected an error for a streamed body without GetBody")
+	}
+}
+
+// TestAsCurlCommandSigned tests that exported commands carry the signature
+func TestAsCurlCommandSigned(t *testing.T) {
+	transport := NewTransport()
+	transport.Signer = &RequestSigner{Key: []byte("k")}
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	cmd, err := AsCurlCommand(req, transport)
+	if err != nil {
+		t.Fatalf("AsCurlCommand failed: %v", err)
+	}
+	if !strings.Contains(cmd, "X-Signature: ") {
+		t.Errorf("Expected a signature header in %s", cmd)
+	}
+}
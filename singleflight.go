@@ -0,0 +1,186 @@
+package curlhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Singleflight coalesces concurrent identical GET requests into a single
+// upstream request and gives every caller its own copy of the response.
+// Crawlers that meet the same link several times in a burst fetch it once.
+// Install it with Transport.Use(sf.Middleware()).
+//
+// Requests are identical when their normalized URL and the headers that
+// commonly change the response (Accept*, Authorization, Cookie) match.
+// Range requests are never coalesced. Shared responses are fully read into
+// memory and carry a NoteCoalesced note for every caller but the first.
+type Singleflight struct {
+	// Key, if set, replaces the default request key. Requests with the same
+	// key are coalesced; an empty key disables coalescing for the request.
+	Key func(*http.Request) string
+
+	// KeyFunc, if set, names the resource in the default key in place of
+	// the normalized URL, for example StripTrackingParams
+	KeyFunc KeyFunc
+
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is an upstream request shared by several callers
+type flightCall struct {
+	done chan struct{}
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+// coalescedHeaders are the request headers that are part of the default key
+var coalescedHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language", "Authorization", "Cookie"}
+
+// Middleware returns middleware that coalesces identical in-flight GETs
+func (s *Singleflight) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			key := s.key(req)
+			if key == "" {
+				return next.RoundTrip(req)
+			}
+
+			s.mu.Lock()
+			if s.calls == nil {
+				s.calls = make(map[string]*flightCall)
+			}
+			if call, ok := s.calls[key]; ok {
+				s.mu.Unlock()
+				return s.wait(next, req, call)
+			}
+			call := &flightCall{done: make(chan struct{})}
+			s.calls[key] = call
+			s.mu.Unlock()
+
+			s.do(next, req, call)
+
+			s.mu.Lock()
+			delete(s.calls, key)
+			s.mu.Unlock()
+
+			if call.err != nil {
+				return nil, call.err
+			}
+			return call.response(req, false), nil
+		})
+	}
+}
+
+// key returns the coalescing key for req, or "" if it must not be shared
+func (s *Singleflight) key(req *http.Request) string {
+	if s.Key != nil {
+		return s.Key(req)
+	}
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return ""
+	}
+	return requestKey(req, s.KeyFunc)
+}
+
+// requestKey identifies a GET by its resource key, the headers that
+// commonly change the response and the exit chosen with WithProxy
+func requestKey(req *http.Request, key KeyFunc) string {
+	var b strings.Builder
+	b.WriteString(keyOrDefault(key, req))
+	for _, name := range coalescedHeaders {
+		for _, value := range req.Header.Values(name) {
+			fmt.Fprintf(&b, "\n%s: %s", name, value)
+		}
+	}
+	// Exits chosen with WithProxy may see different responses
+	if proxy, ok := req.Context().Value(proxyKey{}).(*url.URL); ok {
+		fmt.Fprintf(&b, "\nproxy: %v", proxy)
+	}
+	return b.String()
+}
+
+// do performs the shared request and buffers its body
+func (s *Singleflight) do(next http.RoundTripper, req *http.Request, call *flightCall) {
+	defer close(call.done)
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		call.err = err
+		return
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		call.err = fmt.Errorf("failed to read shared response body: %w", err)
+		return
+	}
+	resp.Body.Close()
+	call.resp, call.body = resp, body
+}
+
+// wait returns a copy of the shared response once it is available. If the
+// first caller's request was canceled, the waiter performs its own request.
+func (s *Singleflight) wait(next http.RoundTripper, req *http.Request, call *flightCall) (*http.Response, error) {
+	select {
+	case <-call.done:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	if call.err != nil {
+		if errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded) {
+			return next.RoundTrip(req)
+		}
+		return nil, call.err
+	}
+	return call.response(req, true), nil
+}
+
+// response returns a private copy of the shared response for req
+func (c *flightCall) response(req *http.Request, coalesced bool) *http.Response {
+	body := newResponseBody(c.body)
+	if m := metaOf(c.resp); m != nil {
+		body.notes = append([]ResponseNote(nil), m.notes...)
+		body.timings, body.conn, body.sizes = m.timings, m.conn, m.sizes
+		body.target, body.attempts = m.target, m.attempts
+		body.rawHeader = m.rawHeader
+	}
+	if coalesced {
+		body.addNote(NoteCoalesced, "response shared from an identical in-flight request")
+	}
+
+	resp := *c.resp
+	resp.Header = c.resp.Header.Clone()
+	resp.Trailer = c.resp.Trailer.Clone()
+	resp.Body = body
+	resp.ContentLength = int64(len(c.body))
+	resp.Request = req
+	return &resp
+}
+
+// NormalizeURL returns a canonical form of u for comparing URLs: the scheme
+// and host are lower-cased, default ports and the fragment are removed,
+// an empty path becomes "/" and query parameters are sorted by name.
+func NormalizeURL(u *url.URL) string {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	if port := n.Port(); (n.Scheme == "http" && port == "80") || (n.Scheme == "https" && port == "443") {
+		n.Host = strings.TrimSuffix(n.Host, ":"+port)
+	}
+	n.Fragment, n.RawFragment = "", ""
+	if n.Path == "" && n.Opaque == "" {
+		n.Path = "/"
+	}
+	if n.RawQuery != "" {
+		// Encode sorts by name and keeps the order of repeated values
+		n.RawQuery = n.Query().Encode()
+	}
+	return n.String()
+}
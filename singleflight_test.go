@@ -0,0 +1,109 @@
+package curlhttp
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestSingleflightCoalesces tests that concurrent identical GETs share one request
+func TestSingleflightCoalesces(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		<-release
+		return stubResponse("shared").RoundTrip(req)
+	})
+	sf := &Singleflight{}
+	rt := Chain(next, sf.Middleware())
+
+	const callers = 5
+	var wg sync.WaitGroup
+	bodies := make([]string, callers)
+	coalesced := make([]bool, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "http://Example.com:80/page?b=2&a=1#top", nil)
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Errorf("RoundTrip failed: %v", err)
+				return
+			}
+			data, _ := io.ReadAll(resp.Body)
+			bodies[i], coalesced[i] = string(data), HasNote(resp, NoteCoalesced)
+		}(i)
+	}
+
+	// Let every caller join the flight before answering
+	for {
+		sf.mu.Lock()
+		n := len(sf.calls)
+		sf.mu.Unlock()
+		if n == 1 {
+			break
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n > callers {
+		t.Fatalf("Expected at most %d upstream calls, got %d", callers, n)
+	}
+	shared := 0
+	for i := range bodies {
+		if bodies[i] != "shared" {
+			t.Errorf("Expected body shared, got %q", bodies[i])
+		}
+		if coalesced[i] {
+			shared++
+		}
+	}
+	if int(calls.Load())+shared != callers {
+		t.Errorf("Expected every caller to be upstream or coalesced, got %d calls and %d coalesced", calls.Load(), shared)
+	}
+}
+
+// TestSingleflightKey tests which requests are eligible for coalescing
+func TestSingleflightKey(t *testing.T) {
+	sf := &Singleflight{}
+	get, _ := http.NewRequest("GET", "http://example.com/a", nil)
+	post, _ := http.NewRequest("POST", "http://example.com/a", nil)
+	ranged, _ := http.NewRequest("GET", "http://example.com/a", nil)
+	ranged.Header.Set("Range", "bytes=0-10")
+	authed, _ := http.NewRequest("GET", "http://example.com/a", nil)
+	authed.Header.Set("Authorization", "Bearer x")
+
+	if sf.key(get) == "" {
+		t.Error("Expected GET to be coalesced")
+	}
+	if sf.key(post) != "" || sf.key(ranged) != "" {
+		t.Error("Expected POST and Range requests not to be coalesced")
+	}
+	if sf.key(get) == sf.key(authed) {
+		t.Error("Expected Authorization to be part of the key")
+	}
+}
+
+// TestNormalizeURL tests URL canonicalization
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"HTTP://Example.COM:80", "http://example.com/"},
+		{"https://example.com:443/a?b=2&a=1#frag", "https://example.com/a?a=1&b=2"},
+		{"https://example.com:8443/a", "https://example.com:8443/a"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.input)
+		if got := NormalizeURL(u); got != tt.want {
+			t.Errorf("NormalizeURL(%s): expected %s, got %s", tt.input, tt.want, got)
+		}
+	}
+}
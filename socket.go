@@ -0,0 +1,66 @@
+package curlhttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// errSocketIO is returned by socketConn's Read and Write; curl owns the I/O
+var errSocketIO = errors.New("I/O on curl sockets is not supported")
+
+// socketConn is the syscall.RawConn handed to SocketControl. Only Control
+// is usable, to set socket options.
+type socketConn uintptr
+
+func (c socketConn) Control(f func(fd uintptr)) error {
+	f(uintptr(c))
+	return nil
+}
+
+func (c socketConn) Read(func(fd uintptr) bool) error {
+	return errSocketIO
+}
+
+func (c socketConn) Write(func(fd uintptr) bool) error {
+	return errSocketIO
+}
+
+// controlsSockets reports whether new connections need socket options set
+// by controlSocket
+func (t *Transport) controlsSockets() bool {
+	return t.SocketControl != nil || t.TCPUserTimeout > 0 || t.KeepAliveCount > 0 && t.keepAliveSeconds() > 0
+}
+
+// controlSocket applies TCPUserTimeout and KeepAliveCount and calls
+// SocketControl for the connection of the transfer in progress on easy, if
+// the transfer opened it. It runs from the header callback, so the
+// connection is already established.
+func (t *Transport) controlSocket(easy curlEngine) error {
+	if getinfoInt(easy, infoNumConnects) == 0 {
+		// A reused connection was set up by the request that opened it
+		return nil
+	}
+	fd := getinfoInt(easy, infoLastSocket)
+	if fd < 0 {
+		return errors.New("failed to control socket: socket not available")
+	}
+	if err := t.setTCPOptions(uintptr(fd)); err != nil {
+		return fmt.Errorf("failed to control socket: %w", err)
+	}
+	if t.SocketControl == nil {
+		return nil
+	}
+	ip := getinfoString(easy, infoPrimaryIP)
+	network := "tcp4"
+	if strings.Contains(ip, ":") {
+		network = "tcp6"
+	}
+	address := net.JoinHostPort(ip, strconv.FormatInt(getinfoInt(easy, infoPrimaryPort), 10))
+	if err := t.SocketControl(network, address, socketConn(fd)); err != nil {
+		return fmt.Errorf("failed to control socket: %w", err)
+	}
+	return nil
+}
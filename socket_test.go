@@ -0,0 +1,80 @@
+package curlhttp
+
+import (
+	"errors"
+	"net/http"
+	"syscall"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// newSocketEngine returns a fake whose transfer opened a connection on fd
+func newSocketEngine(fd int64) *fakeEngine {
+	fake := newFakeEngine("ok")
+	fake.info = map[curl.CurlInfo]interface{}{
+		infoNumConnects: int64(1),
+		infoLastSocket:  fd,
+		infoPrimaryIP:   "2001:db8::1",
+		infoPrimaryPort: int64(443),
+	}
+	return fake
+}
+
+// TestSocketControl tests that SocketControl sees new connections only
+func TestSocketControl(t *testing.T) {
+	fake := newSocketEngine(42)
+	transport := newFakeTransport(fake)
+	var calls int
+	var gotNetwork, gotAddress string
+	var gotFD uintptr
+	transport.SocketControl = func(network, address string, c syscall.RawConn) error {
+		calls++
+		gotNetwork, gotAddress = network, address
+		if err := c.Control(func(fd uintptr) { gotFD = fd }); err != nil {
+			t.Errorf("Control failed: %v", err)
+		}
+		if err := c.Read(func(uintptr) bool { return true }); err == nil {
+			t.Error("Expected Read on a curl socket to fail")
+		}
+		return nil
+	}
+
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if calls != 1 || gotNetwork != "tcp6" || gotAddress != "[2001:db8::1]:443" || gotFD != 42 {
+		t.Errorf("Expected one call for tcp6 [2001:db8::1]:443 on fd 42, got %d calls for %s %s on fd %d", calls, gotNetwork, gotAddress, gotFD)
+	}
+
+	// A reused connection was already configured
+	fake.info[infoNumConnects] = int64(0)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no call for a reused connection, got %d calls", calls)
+	}
+}
+
+// TestSocketControlError tests that a failing SocketControl aborts the request
+func TestSocketControlError(t *testing.T) {
+	errMark := errors.New("operation not permitted")
+	for _, streaming := range []bool{false, true} {
+		transport := newFakeTransport(newSocketEngine(7))
+		transport.StreamResponses = streaming
+		transport.SocketControl = func(network, address string, c syscall.RawConn) error {
+			return errMark
+		}
+
+		req, _ := http.NewRequest("GET", "https://example.com/", nil)
+		resp, err := transport.RoundTrip(req)
+		if !errors.Is(err, errMark) {
+			t.Errorf("streaming=%v: Expected the SocketControl error, got %v", streaming, err)
+		}
+		if resp != nil {
+			t.Errorf("streaming=%v: Expected no response", streaming)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package curlhttp
+
+import (
+	"sync/atomic"
+)
+
+// TransportStats is a snapshot of a Transport's handle pool and connection
+// usage, as returned by Transport.Stats. Counters are cumulative since the
+// Transport was created.
+type TransportStats struct {
+	// IdleHandles is the number of handles waiting in the pool and
+	// MaxPoolSize the number the pool can hold.
+	IdleHandles int
+	MaxPoolSize int
+
+	// HandlesCreated and HandlesDestroyed count curl easy handles allocated
+	// and cleaned up, whether as temporary overflow handles, on expiry or
+	// through CloseIdleConnections.
+	HandlesCreated   int64
+	HandlesDestroyed int64
+
+	// OpenHandles is the number of live handles, idle or in use.
+	OpenHandles int64
+
+	// AcquisitionsBlocked counts requests that had to wait for a handle
+	// because all of them were in use.
+	AcquisitionsBlocked int64
+
+	// PoolExhausted counts requests failed with ErrPoolExhausted because
+	// no handle or request slot was available in time.
+	PoolExhausted int64
+
+	// PoolMisses counts requests that found no idle handle and had to
+	// create one. A high ratio to Requests suggests raising the pool size.
+	PoolMisses int64
+
+	// InFlight is the number of requests currently being performed.
+	InFlight int64
+
+	// Requests counts completed curl transfers, successful or not.
+	Requests int64
+
+	// ConnectionsOpened and ConnectionsReused count transfers that opened
+	// a new connection and transfers served by a pooled connection.
+	ConnectionsOpened int64
+	ConnectionsReused int64
+
+	// DeadConnectionRetries counts idempotent requests resent because a
+	// reused connection had been closed by the server.
+	DeadConnectionRetries int64
+
+	// BytesSent and BytesReceived count request and response bytes over
+	// all transfers. See TransferSizes for what is included and
+	// Transport.TrafficByHost for a breakdown.
+	BytesSent     int64
+	BytesReceived int64
+}
+
+// transportStats holds the Transport's live counters
+type transportStats struct {
+	acquisitionsBlocked atomic.Int64
+	poolExhausted       atomic.Int64
+	handlesCreated      atomic.Int64
+	handlesDestroyed    atomic.Int64
+	poolMisses          atomic.Int64
+	inFlight            atomic.Int64
+	requests            atomic.Int64
+	connectionsOpened   atomic.Int64
+	connectionsReused   atomic.Int64
+	deadConnRetries     atomic.Int64
+	bytesSent           atomic.Int64
+	bytesReceived       atomic.Int64
+}
+
+// Stats returns a snapshot of the Transport's pool and connection metrics
+func (t *Transport) Stats() TransportStats {
+	pool := t.handles()
+	return TransportStats{
+		IdleHandles:           len(pool.idle),
+		MaxPoolSize:           cap(pool.idle),
+		HandlesCreated:        t.stats.handlesCreated.Load(),
+		HandlesDestroyed:      t.stats.handlesDestroyed.Load(),
+		OpenHandles:           t.stats.handlesCreated.Load() - t.stats.handlesDestroyed.Load(),
+		AcquisitionsBlocked:   t.stats.acquisitionsBlocked.Load(),
+		PoolExhausted:         t.stats.poolExhausted.Load(),
+		PoolMisses:            t.stats.poolMisses.Load(),
+		InFlight:              t.stats.inFlight.Load(),
+		Requests:              t.stats.requests.Load(),
+		ConnectionsOpened:     t.stats.connectionsOpened.Load(),
+		ConnectionsReused:     t.stats.connectionsReused.Load(),
+		DeadConnectionRetries: t.stats.deadConnRetries.Load(),
+		BytesSent:             t.stats.bytesSent.Load(),
+		BytesReceived:         t.stats.bytesReceived.Load(),
+	}
+}
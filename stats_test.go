@@ -0,0 +1,61 @@
+package curlhttp
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// TestTransportStats tests pool and connection counters
+func TestTransportStats(t *testing.T) {
+	fake := newFakeEngine("")
+	fake.info = map[curl.CurlInfo]interface{}{infoNumConnects: int64(1)}
+	transport := newFakeTransport(fake)
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", "http://example.com", nil)
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		fake.info[infoNumConnects] = int64(0)
+	}
+
+	stats := transport.Stats()
+	if stats.Requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", stats.Requests)
+	}
+	if stats.HandlesCreated != 1 || stats.PoolMisses != 1 {
+		t.Errorf("Expected 1 handle created and 1 pool miss, got %d and %d", stats.HandlesCreated, stats.PoolMisses)
+	}
+	if stats.IdleHandles != 1 {
+		t.Errorf("Expected 1 idle handle, got %d", stats.IdleHandles)
+	}
+	if stats.MaxPoolSize != 10 {
+		t.Errorf("Expected max pool size 10, got %d", stats.MaxPoolSize)
+	}
+	if stats.ConnectionsOpened != 1 || stats.ConnectionsReused != 2 {
+		t.Errorf("Expected 1 opened and 2 reused connections, got %d and %d", stats.ConnectionsOpened, stats.ConnectionsReused)
+	}
+	if stats.InFlight != 0 {
+		t.Errorf("Expected no requests in flight, got %d", stats.InFlight)
+	}
+}
+
+// TestTransportStatsPoolFull tests that handles discarded by a full pool are counted
+func TestTransportStatsPoolFull(t *testing.T) {
+	transport := NewTransportWithPoolSize(1)
+	transport.PoolOverflow = PoolOverflowCreate
+	transport.newEngine = func() curlEngine { return newFakeEngine("") }
+
+	first, _ := transport.getCurlHandle(context.Background())
+	second, _ := transport.getCurlHandle(context.Background())
+	transport.returnCurlHandle(first)
+	transport.returnCurlHandle(second)
+
+	stats := transport.Stats()
+	if stats.HandlesCreated != 2 || stats.HandlesDestroyed != 1 {
+		t.Errorf("Expected 2 created and 1 destroyed, got %d and %d", stats.HandlesCreated, stats.HandlesDestroyed)
+	}
+}
@@ -0,0 +1,279 @@
+package curlhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+type streamResponseKey struct{}
+
+// WithResponseStreaming returns a context that makes the Transport stream
+// the responses of requests carrying it, as StreamResponses does for every
+// request
+func WithResponseStreaming(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamResponseKey{}, true)
+}
+
+// streamsResponse reports whether req's response is streamed
+func (t *Transport) streamsResponse(req *http.Request) bool {
+	streamed, _ := req.Context().Value(streamResponseKey{}).(bool)
+	return t.StreamResponses || streamed
+}
+
+// streamSink receives body data from curl's write callback and passes it to
+// the response body through a pipe, so the caller reads the body while the
+// transfer is still running. Writes block until the data is read, which
+// keeps curl from buffering more than one chunk.
+type streamSink struct {
+	pr        *io.PipeReader
+	pw        *io.PipeWriter
+	written   int64
+	started   bool
+	start     func()      // delivers the response; called once headers are complete
+	cancelled atomic.Bool // set when the request is cancelled
+}
+
+// newStreamSink creates a sink with its pipe
+func newStreamSink() *streamSink {
+	pr, pw := io.Pipe()
+	return &streamSink{pr: pr, pw: pw}
+}
+
+// begin delivers the response if that has not happened yet
+func (s *streamSink) begin() {
+	if !s.started {
+		s.started = true
+		s.start()
+	}
+}
+
+// writeDataToStream is the callback function for writing response data to a streamSink
+func writeDataToStream(ptr []byte, userdata interface{}) bool {
+	sink, ok := userdata.(*streamSink)
+	if !ok {
+		return false
+	}
+	// Headers normally complete first; deliver now if they never did
+	sink.begin()
+	n, err := sink.pw.Write(ptr)
+	sink.written += int64(n)
+	// A failed write means the body was closed; returning false aborts
+	// the transfer
+	return err == nil
+}
+
+// abortCancelled is curl's progress callback for streamed transfers. It
+// aborts the transfer once the request is cancelled or the body closed,
+// including while curl is still waiting for the response headers or the
+// server sends nothing.
+func (s *streamSink) abortCancelled(dltotal, dlnow, ultotal, ulnow float64, userdata interface{}) bool {
+	return !s.cancelled.Load()
+}
+
+// streamEnd is what the transfer goroutine learns once the transfer is over
+type streamEnd struct {
+	timings *Timings
+	sizes   *TransferSizes
+	trailer http.Header
+	notes   []ResponseNote
+}
+
+// streamBody is the Body of streamed responses. Timings, trailers and
+// notes about the end of the transfer are only complete once Read has
+// returned io.EOF.
+type streamBody struct {
+	responseMeta
+	sink *streamSink
+	resp *http.Response
+	done chan struct{} // closed when the transfer has finished
+
+	end       streamEnd // written by the transfer goroutine before done is closed
+	published sync.Once
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+	n, err := b.sink.pr.Read(p)
+	if err == io.EOF {
+		// The transfer goroutine filled end before closing the pipe
+		b.publish()
+	}
+	return n, err
+}
+
+// Close stops the transfer if it is still running
+func (b *streamBody) Close() error {
+	b.sink.cancelled.Store(true)
+	return b.sink.pr.Close()
+}
+
+// meta returns the metadata, including the end of the transfer once it
+// has finished
+func (b *streamBody) meta() *responseMeta {
+	select {
+	case <-b.done:
+		b.publish()
+	default:
+	}
+	return &b.responseMeta
+}
+
+// publish moves the end of the transfer into the metadata and trailers
+// seen by the caller
+func (b *streamBody) publish() {
+	b.published.Do(func() {
+		b.timings = b.end.timings
+		b.sizes = b.end.sizes
+		b.notes = append(b.notes, b.end.notes...)
+		fillTrailers(b.resp, b.end.trailer)
+	})
+}
+
+// performStreaming runs the configured transfer on its own goroutine and
+// returns as soon as the response headers are complete. The handle is
+// returned to the pool when the transfer ends, once the body has been read
+// or closed.
+func (t *Transport) performStreaming(req *http.Request, easy curlEngine, route proxyRoute, parser *headerParser, sink *streamSink, body []byte, collectCerts bool) (*http.Response, error) {
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	ready := make(chan result, 1)
+	respBody := &streamBody{sink: sink, done: make(chan struct{})}
+	var resp *http.Response
+
+	// start runs on the transfer goroutine, inside curl's callbacks, so it
+	// may still query the handle
+	sink.start = func() {
+		code := parser.status
+		if code == 0 {
+			code = int(getinfoInt(easy, infoResponseCode))
+		}
+		contentLength := int64(-1)
+		if declared, ok := declaredContentLength(req.Method, code, parser.header); ok {
+			contentLength = declared
+		} else if req.Method == "HEAD" || code == 204 || code == 304 {
+			contentLength = 0
+		}
+
+		conn := collectConnInfo(easy, route.proxy)
+		respBody.conn = conn
+		t.recordResolution(req, conn)
+		if conn.Reused {
+			t.stats.connectionsReused.Add(1)
+		} else {
+			t.stats.connectionsOpened.Add(1)
+		}
+		resp = buildResponse(code, parser, respBody, contentLength)
+		respBody.resp = resp
+		ready <- result{resp: resp}
+	}
+	parser.onComplete = sink.begin
+
+	// Abort the transfer when the request is cancelled or the body closed
+	if err := easy.Setopt(curl.OPT_XFERINFOFUNCTION, sink.abortCancelled); err != nil {
+		return nil, fmt.Errorf("failed to set progress function: %w", err)
+	}
+	if err := easy.Setopt(curl.OPT_NOPROGRESS, false); err != nil {
+		return nil, fmt.Errorf("failed to enable progress function: %w", err)
+	}
+	stop := context.AfterFunc(req.Context(), func() {
+		sink.cancelled.Store(true)
+		sink.pr.CloseWithError(req.Context().Err())
+	})
+
+	go func() {
+		defer close(respBody.done)
+		defer t.returnCurlHandle(easy)
+		defer stop()
+
+		t.stats.inFlight.Add(1)
+		performErr := t.performInNamespace(easy.Perform)
+		t.stats.inFlight.Add(-1)
+		t.stats.requests.Add(1)
+		sizes := t.recordTraffic(req, easy)
+
+		runtime.KeepAlive(body)
+		runtime.KeepAlive(sink)
+		runtime.KeepAlive(parser)
+
+		if !sink.started {
+			if parser.err != nil {
+				sink.pw.Close()
+				ready <- result{err: parser.err}
+				return
+			}
+			var curlErr curl.CurlError
+			if performErr != nil && (!errors.As(performErr, &curlErr) || curlErr != curl.CurlError(curl.E_PARTIAL_FILE)) {
+				sink.pw.Close()
+				ready <- result{err: t.requestFailed(req, easy, route.proxy, performErr)}
+				return
+			}
+			sink.begin()
+		}
+
+		t.observeConnection(req, easy, route, collectCerts)
+		respBody.end.timings = collectTimings(easy)
+		respBody.end.sizes = sizes
+		respBody.end.trailer = parser.trailer
+
+		if declared, ok := declaredContentLength(req.Method, resp.StatusCode, resp.Header); ok && declared != sink.written {
+			if _, err := applyContentLengthPolicy(t.ContentLengthPolicy, declared, sink.written); err != nil {
+				sink.pw.CloseWithError(err)
+				return
+			}
+			respBody.end.notes = append(respBody.end.notes, ResponseNote{Kind: NoteContentLengthMismatch, Message: fmt.Sprintf("Content-Length declared %d bytes but %d were received", declared, sink.written)})
+		} else if performErr != nil {
+			sink.pw.CloseWithError(fmt.Errorf("request failed: %w", performErr))
+			return
+		}
+		sink.pw.Close()
+	}()
+
+	select {
+	case res := <-ready:
+		return res.resp, res.err
+	case <-req.Context().Done():
+		// The progress callback aborts the transfer, which then returns
+		// the handle
+		return nil, req.Context().Err()
+	}
+}
+
+// announcedTrailers returns the trailer names a response declared in its
+// Trailer header, with nil values as net/http does, or nil if there are none
+func announcedTrailers(header http.Header) http.Header {
+	var trailer http.Header
+	for _, value := range header.Values("Trailer") {
+		for _, name := range strings.Split(value, ",") {
+			if name = trimOWS(name); name != "" {
+				if trailer == nil {
+					trailer = make(http.Header)
+				}
+				trailer[http.CanonicalHeaderKey(name)] = nil
+			}
+		}
+	}
+	return trailer
+}
+
+// fillTrailers copies trailer fields received after the body into resp
+func fillTrailers(resp *http.Response, received http.Header) {
+	if len(received) == 0 {
+		return
+	}
+	if resp.Trailer == nil {
+		resp.Trailer = make(http.Header, len(received))
+	}
+	for name, values := range received {
+		resp.Trailer[name] = values
+	}
+}
@@ -0,0 +1,291 @@
+package curlhttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// chunkedEngine delivers its body in chunks followed by trailer lines
+type chunkedEngine struct {
+	*fakeEngine
+	chunks   []string
+	trailers []string
+	aborted  bool
+}
+
+func (e *chunkedEngine) Perform() error {
+	e.performed = e.opts
+	header, _ := e.opts[curl.OPT_HEADERFUNCTION].(func([]byte, interface{}) bool)
+	write, _ := e.opts[curl.OPT_WRITEFUNCTION].(func([]byte, interface{}) bool)
+	for _, line := range e.headers {
+		header([]byte(line), e.opts[curl.OPT_HEADERDATA])
+	}
+	for _, chunk := range e.chunks {
+		if !write([]byte(chunk), e.opts[curl.OPT_WRITEDATA]) {
+			e.aborted = true
+			return curl.CurlError(curl.E_WRITE_ERROR)
+		}
+	}
+	for _, line := range e.trailers {
+		header([]byte(line), e.opts[curl.OPT_HEADERDATA])
+	}
+	return nil
+}
+
+// newChunkedEngine answers 200 OK with chunks and an X-Checksum trailer
+func newChunkedEngine(chunks ...string) *chunkedEngine {
+	engine := &chunkedEngine{fakeEngine: newFakeEngine(""), chunks: chunks}
+	engine.headers = []string{
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: text/plain\r\n",
+		"Transfer-Encoding: chunked\r\n",
+		"Trailer: X-Checksum\r\n",
+		"\r\n",
+	}
+	engine.trailers = []string{"X-Checksum: abc123\r\n", "\r\n"}
+	return engine
+}
+
+// TestStreamResponses tests that streamed bodies, trailers and handles are delivered
+func TestStreamResponses(t *testing.T) {
+	engine := newChunkedEngine("first ", "second")
+	transport := NewTransport()
+	transport.StreamResponses = true
+	transport.newEngine = func() curlEngine { return engine }
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if resp.ContentLength != -1 {
+		t.Errorf("Expected unknown ContentLength, got %d", resp.ContentLength)
+	}
+	if len(resp.TransferEncoding) != 1 || resp.Header.Get("Transfer-Encoding") != "" {
+		t.Errorf("Expected chunked in TransferEncoding only, got %v and %q", resp.TransferEncoding, resp.Header.Get("Transfer-Encoding"))
+	}
+	if _, ok := resp.Trailer["X-Checksum"]; !ok {
+		t.Error("Expected announced trailer before the body is read")
+	}
+	// Metadata may be read while the transfer is still running
+	ResponseExtra(resp)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	resp.Body.Close()
+	if string(body) != "first second" {
+		t.Errorf("Expected 'first second', got %q", body)
+	}
+	if got := resp.Trailer.Get("X-Checksum"); got != "abc123" {
+		t.Errorf("Expected trailer abc123, got %q", got)
+	}
+	if extra, _ := ResponseExtra(resp); extra.Timings == nil {
+		t.Error("Expected timings once the body has been read")
+	}
+
+	<-resp.Body.(*streamBody).done
+	if idle := transport.Stats().IdleHandles; idle != 1 {
+		t.Errorf("Expected handle back in the pool, got %d idle", idle)
+	}
+}
+
+// TestStreamBodyCloseAborts tests that closing a streamed body stops the transfer
+func TestStreamBodyCloseAborts(t *testing.T) {
+	engine := newChunkedEngine("a", "b", "c")
+	transport := NewTransport()
+	transport.StreamResponses = true
+	transport.MaxConcurrentRequests = 1
+	transport.newEngine = func() curlEngine { return engine }
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	buf := make([]byte, 1)
+	if _, err := resp.Body.Read(buf); err != nil {
+		t.Fatalf("Failed to read first chunk: %v", err)
+	}
+	resp.Body.Close()
+
+	select {
+	case <-resp.Body.(*streamBody).done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected transfer to end after Close")
+	}
+	if !engine.aborted {
+		t.Error("Expected transfer to be aborted")
+	}
+
+	// The concurrency slot is released once the transfer has ended
+	release, err := transport.acquireRequestSlot(limitRequest(t, "http://example.com/", time.Second))
+	if err != nil {
+		t.Fatalf("Expected slot to be released, got %v", err)
+	}
+	release()
+}
+
+// stalledEngine stops sending, without or after its response headers; it
+// polls the progress callback until the callback aborts the transfer
+type stalledEngine struct {
+	*fakeEngine
+	sendHeaders bool
+	done        chan struct{}
+}
+
+func (e *stalledEngine) Perform() error {
+	defer close(e.done)
+	if header, ok := e.opts[curl.OPT_HEADERFUNCTION].(func([]byte, interface{}) bool); ok && e.sendHeaders {
+		for _, line := range e.headers {
+			header([]byte(line), e.opts[curl.OPT_HEADERDATA])
+		}
+	}
+	progress, _ := e.opts[curl.OPT_XFERINFOFUNCTION].(func(float64, float64, float64, float64, interface{}) bool)
+	if progress == nil || e.opts[curl.OPT_NOPROGRESS] != false {
+		return curl.CurlError(curl.E_OPERATION_TIMEDOUT)
+	}
+	for progress(0, 0, 0, 0, nil) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	return curl.CurlError(curl.E_ABORTED_BY_CALLBACK)
+}
+
+// TestStreamCancelBeforeHeaders tests that cancelling a streamed request
+// while it waits for headers returns at once and aborts the transfer
+func TestStreamCancelBeforeHeaders(t *testing.T) {
+	engine := &stalledEngine{fakeEngine: newFakeEngine(""), done: make(chan struct{})}
+	transport := NewTransport()
+	transport.StreamResponses = true
+	transport.newEngine = func() curlEngine { return engine }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com/", nil)
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	select {
+	case <-engine.done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the transfer to be aborted")
+	}
+}
+
+// TestStreamCloseIdle tests that closing the body of a stream the server
+// has gone quiet on aborts the transfer
+func TestStreamCloseIdle(t *testing.T) {
+	engine := &stalledEngine{fakeEngine: newFakeEngine(""), sendHeaders: true, done: make(chan struct{})}
+	engine.headers = []string{"HTTP/1.1 200 OK\r\n", "Content-Type: text/event-stream\r\n", "\r\n"}
+	transport := NewTransport()
+	transport.StreamResponses = true
+	transport.newEngine = func() curlEngine { return engine }
+
+	req, _ := http.NewRequest("GET", "http://example.com/events", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if extra, ok := ResponseExtra(resp); !ok || extra.Timings != nil {
+		t.Errorf("Expected no timings while the transfer runs, got %+v", extra)
+	}
+	resp.Body.Close()
+	select {
+	case <-engine.done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Close to abort the idle transfer")
+	}
+}
+
+// TestBufferedTrailers tests that trailers are kept out of the header map
+func TestBufferedTrailers(t *testing.T) {
+	engine := newChunkedEngine("body")
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return engine }
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("X-Checksum") != "" {
+		t.Error("Expected trailer not to be merged into the header")
+	}
+	if got := resp.Trailer.Get("X-Checksum"); got != "abc123" {
+		t.Errorf("Expected trailer abc123, got %q", got)
+	}
+}
+
+// TestHopByHopRequestHeaders tests that connection-specific headers are not sent
+func TestHopByHopRequestHeaders(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set("Connection", "keep-alive, X-Hop")
+	req.Header.Set("X-Hop", "1")
+	req.Header.Set("Keep-Alive", "timeout=5")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Te", "trailers")
+	req.Header.Set("X-End", "1")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	resp.Body.Close()
+
+	sent := strings.Join(fake.performed[curl.OPT_HTTPHEADER].([]string), "\n")
+	for _, name := range []string{"Connection", "X-Hop", "Keep-Alive", "Upgrade"} {
+		if strings.Contains(sent, name+":") {
+			t.Errorf("Expected %s not to be sent, got %q", name, sent)
+		}
+	}
+	for _, line := range []string{"Te: trailers", "X-End: 1"} {
+		if !strings.Contains(sent, line) {
+			t.Errorf("Expected %q to be sent, got %q", line, sent)
+		}
+	}
+}
+
+// TestReverseProxyStreaming tests the Transport behind httputil.ReverseProxy
+func TestReverseProxyStreaming(t *testing.T) {
+	engine := newChunkedEngine("streamed ", "through ", "proxy")
+	transport := NewTransport()
+	transport.StreamResponses = true
+	transport.newEngine = func() curlEngine { return engine }
+
+	origin, _ := url.Parse("http://origin.example")
+	proxy := httputil.NewSingleHostReverseProxy(origin)
+	proxy.Transport = transport
+	proxy.FlushInterval = -1
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request through proxy failed: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	if string(body) != "streamed through proxy" {
+		t.Errorf("Expected proxied body, got %q", body)
+	}
+	if got := resp.Trailer.Get("X-Checksum"); got != "abc123" {
+		t.Errorf("Expected trailer to pass through the proxy, got %q", got)
+	}
+}
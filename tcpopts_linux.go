@@ -0,0 +1,25 @@
+//go:build linux
+
+package curlhttp
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// setTCPOptions sets TCPUserTimeout and KeepAliveCount on a socket
+func (t *Transport) setTCPOptions(fd uintptr) error {
+	if t.TCPUserTimeout > 0 {
+		ms := max(int(t.TCPUserTimeout.Milliseconds()), 1)
+		if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, ms); err != nil {
+			return fmt.Errorf("failed to set TCP user timeout: %w", err)
+		}
+	}
+	if t.KeepAliveCount > 0 && t.keepAliveSeconds() > 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_KEEPCNT, t.KeepAliveCount); err != nil {
+			return fmt.Errorf("failed to set keep-alive probe count: %w", err)
+		}
+	}
+	return nil
+}
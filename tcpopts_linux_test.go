@@ -0,0 +1,47 @@
+//go:build linux
+
+package curlhttp
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// TestTCPOptions tests that TCPUserTimeout and KeepAliveCount reach the socket
+func TestTCPOptions(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	file, err := conn.(*net.TCPConn).File()
+	if err != nil {
+		t.Fatalf("File failed: %v", err)
+	}
+	defer file.Close()
+	fd := int(file.Fd())
+
+	transport := newFakeTransport(newSocketEngine(int64(fd)))
+	transport.TCPUserTimeout = 20 * time.Second
+	transport.KeepAliveCount = 3
+
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if got, _ := unix.GetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT); got != 20000 {
+		t.Errorf("Expected TCP_USER_TIMEOUT 20000ms, got %d", got)
+	}
+	if got, _ := unix.GetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPCNT); got != 3 {
+		t.Errorf("Expected TCP_KEEPCNT 3, got %d", got)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package curlhttp
+
+import (
+	"fmt"
+)
+
+// setTCPOptions reports that TCPUserTimeout and KeepAliveCount are unavailable on this platform
+func (t *Transport) setTCPOptions(fd uintptr) error {
+	if t.TCPUserTimeout > 0 || t.KeepAliveCount > 0 && t.keepAliveSeconds() > 0 {
+		return fmt.Errorf("TCPUserTimeout and KeepAliveCount are only supported on Linux")
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package curlhttp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// ResponseTee copies every response body to a sink as the caller reads it,
+// for crawl archiving and offline debugging. The caller reads the body as
+// usual; only the bytes it reads are copied, and the sink is closed with
+// the body. Install it with Transport.Use(t.Middleware()).
+type ResponseTee struct {
+	// Sink returns the writer for the body of resp, or nil to skip it.
+	// The returned writer is closed when the caller closes the body.
+	Sink func(req *http.Request, resp *http.Response) (io.WriteCloser, error)
+
+	// OnError, if set, receives errors from Sink and from writing or
+	// closing sinks. They never fail the caller's reads; a sink that fails
+	// to write gets no more of the body.
+	OnError func(req *http.Request, err error)
+}
+
+// Middleware returns middleware that tees each response body to its sink
+func (t *ResponseTee) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err != nil || t.Sink == nil || resp.Body == nil || resp.Body == http.NoBody {
+				return resp, err
+			}
+			sink, err := t.Sink(req, resp)
+			if err != nil {
+				t.report(req, fmt.Errorf("failed to open response sink: %w", err))
+				return resp, nil
+			}
+			if sink != nil {
+				resp.Body = &teeBody{ReadCloser: resp.Body, sink: sink, req: req, tee: t}
+			}
+			return resp, nil
+		})
+	}
+}
+
+// report passes err to OnError, if set
+func (t *ResponseTee) report(req *http.Request, err error) {
+	if t.OnError != nil {
+		t.OnError(req, err)
+	}
+}
+
+// FileSink returns a ResponseTee sink writing each body to a new file in
+// dir, named after the request's host with a unique suffix
+func FileSink(dir string) func(req *http.Request, resp *http.Response) (io.WriteCloser, error) {
+	return func(req *http.Request, resp *http.Response) (io.WriteCloser, error) {
+		f, err := os.CreateTemp(dir, req.URL.Hostname()+"-*.body")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create body file: %w", err)
+		}
+		return f, nil
+	}
+}
+
+// teeBody writes what is read from the body to sink
+type teeBody struct {
+	io.ReadCloser
+	sink io.WriteCloser
+	req  *http.Request
+	tee  *ResponseTee
+
+	mu     sync.Mutex
+	failed bool
+	closed bool
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.mu.Lock()
+		if !b.failed && !b.closed {
+			if _, writeErr := b.sink.Write(p[:n]); writeErr != nil {
+				b.failed = true
+				b.tee.report(b.req, fmt.Errorf("failed to write response sink: %w", writeErr))
+			}
+		}
+		b.mu.Unlock()
+	}
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		if closeErr := b.sink.Close(); closeErr != nil {
+			b.tee.report(b.req, fmt.Errorf("failed to close response sink: %w", closeErr))
+		}
+	}
+	return err
+}
+
+// meta keeps the metadata of Transport responses reachable
+func (b *teeBody) meta() *responseMeta {
+	return innerMeta(b.ReadCloser)
+}
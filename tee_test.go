@@ -0,0 +1,123 @@
+package curlhttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// bufferSink is an in-memory sink recording whether it was closed
+type bufferSink struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (s *bufferSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+// failingSink fails every write
+type failingSink struct{}
+
+func (failingSink) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+func (failingSink) Close() error                { return nil }
+
+// TestResponseTee tests that bodies reach both the caller and the sink
+func TestResponseTee(t *testing.T) {
+	sink := &bufferSink{}
+	var sinkURL string
+	tee := &ResponseTee{
+		Sink: func(req *http.Request, resp *http.Response) (io.WriteCloser, error) {
+			sinkURL = req.URL.String()
+			return sink, nil
+		},
+	}
+	transport := newFakeTransport(newFakeEngine("archived body"))
+	transport.Use(tee.Middleware())
+
+	req, _ := http.NewRequest("GET", "http://example.com/page", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if metaOf(resp) == nil {
+		t.Error("Expected response metadata to survive teeing")
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != "archived body" {
+		t.Errorf("Expected 'archived body', got %q", body)
+	}
+	if sink.String() != "archived body" {
+		t.Errorf("Expected sink to hold 'archived body', got %q", sink.String())
+	}
+	if !sink.closed {
+		t.Error("Expected sink to be closed with the body")
+	}
+	if sinkURL != "http://example.com/page" {
+		t.Errorf("Expected sink for http://example.com/page, got %q", sinkURL)
+	}
+}
+
+// TestResponseTeeSinkErrors tests that failing sinks are reported without failing reads
+func TestResponseTeeSinkErrors(t *testing.T) {
+	var reported []error
+	tee := &ResponseTee{
+		Sink: func(req *http.Request, resp *http.Response) (io.WriteCloser, error) {
+			return failingSink{}, nil
+		},
+		OnError: func(req *http.Request, err error) {
+			reported = append(reported, err)
+		},
+	}
+	transport := newFakeTransport(newFakeEngine("still readable"))
+	transport.Use(tee.Middleware())
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "still readable" {
+		t.Errorf("Expected 'still readable' without error, got %q, %v", body, err)
+	}
+	if len(reported) != 1 || !strings.Contains(reported[0].Error(), "disk full") {
+		t.Errorf("Expected one reported write error, got %v", reported)
+	}
+}
+
+// TestFileSink tests that each body is written to its own file
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	tee := &ResponseTee{Sink: FileSink(dir)}
+	transport := newFakeTransport(newFakeEngine("on disk"))
+	transport.Use(tee.Middleware())
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "example.com-*.body"))
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 body files, got %d", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil || string(data) != "on disk" {
+		t.Errorf("Expected file to hold 'on disk', got %q, %v", data, err)
+	}
+}
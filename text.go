@@ -0,0 +1,164 @@
+package curlhttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// DefaultMaxTextSize is the largest response body Text accepts
+const DefaultMaxTextSize = 10 << 20
+
+var (
+	// ErrTextTooLarge is returned by Text for bodies over the size limit
+	ErrTextTooLarge = errors.New("text response too large")
+
+	// ErrUnsupportedCharset is returned by Text for charsets it cannot
+	// decode
+	ErrUnsupportedCharset = errors.New("unsupported charset")
+)
+
+// metaCharsetPattern finds <meta charset="..."> and the charset parameter
+// of <meta http-equiv="Content-Type" content="...">
+var metaCharsetPattern = regexp.MustCompile(`(?i)<meta\s[^>]*charset\s*=\s*["']?\s*([a-z0-9_.:-]+)`)
+
+// metaSniffSize is how much of a body is searched for a meta charset, as
+// browsers do
+const metaSniffSize = 1024
+
+// Text reads resp's body as text and returns it as UTF-8, reading at most
+// DefaultMaxTextSize bytes, and closes the body. See TextLimit.
+func Text(resp *http.Response) (string, error) {
+	return TextLimit(resp, DefaultMaxTextSize)
+}
+
+// TextLimit reads resp's body, converts it to UTF-8 and closes the body.
+// The charset is taken from a byte order mark, then the Content-Type
+// header, then a <meta> tag in HTML; without any, valid UTF-8 is kept and
+// anything else is read as windows-1252, the browser default. Charsets are
+// looked up by their WHATWG labels, as browsers do, so legacy encodings such
+// as Shift_JIS, GBK, EUC-KR and KOI8-R are decoded too; unknown labels
+// return ErrUnsupportedCharset.
+// Bodies over limit bytes return a *BodyTooLargeError matching
+// ErrTextTooLarge.
+func TextLimit(resp *http.Response, limit int64) (string, error) {
+	defer resp.Body.Close()
+
+	data, err := readLimited(resp.Body, limit, ErrTextTooLarge)
+	var tooLarge *BodyTooLargeError
+	if errors.As(err, &tooLarge) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read text response: %w", err)
+	}
+	return decodeText(data, DetectCharset(resp.Header.Get("Content-Type"), data))
+}
+
+// DetectCharset returns the charset of body, served with contentType, in
+// lower case. It returns "" when nothing declares one.
+func DetectCharset(contentType string, body []byte) string {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8"
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err == nil && params["charset"] != "" {
+		return strings.ToLower(strings.Trim(params["charset"], `"' `))
+	}
+	if err != nil || mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		head := body[:min(len(body), metaSniffSize)]
+		if m := metaCharsetPattern.FindSubmatch(head); m != nil {
+			return strings.ToLower(string(m[1]))
+		}
+	}
+	return ""
+}
+
+// decodeText converts data in charset to a UTF-8 string
+func decodeText(data []byte, charset string) (string, error) {
+	switch charset {
+	case "utf-8", "utf8", "unicode-1-1-utf-8":
+		return string(bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})), nil
+	case "utf-16", "utf-16le", "utf-16be", "unicode", "unicodefffe":
+		return decodeUTF16(data, charset), nil
+	case "windows-1252", "cp1252", "x-cp1252", "iso-8859-1", "iso8859-1", "latin1", "l1",
+		"us-ascii", "ascii", "iso-ir-100", "ibm819", "cp819":
+		return decodeWindows1252(data), nil
+	case "":
+		if utf8.Valid(data) {
+			return string(data), nil
+		}
+		return decodeWindows1252(data), nil
+	default:
+		enc, err := htmlindex.Get(charset)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedCharset, charset)
+		}
+		text, err := enc.NewDecoder().Bytes(data)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode %s text: %w", charset, err)
+		}
+		return string(text), nil
+	}
+}
+
+// decodeUTF16 decodes UTF-16, honoring a byte order mark over charset
+func decodeUTF16(data []byte, charset string) string {
+	bigEndian := charset == "utf-16be" || charset == "unicodefffe"
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		bigEndian, data = true, data[2:]
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		bigEndian, data = false, data[2:]
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	text := string(utf16.Decode(units))
+	if len(data)%2 != 0 {
+		text += string(utf8.RuneError)
+	}
+	return text
+}
+
+// windows1252High maps bytes 0x80-0x9F of windows-1252; the rest of the
+// charset matches Unicode
+var windows1252High = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// decodeWindows1252 decodes windows-1252
+func decodeWindows1252(data []byte) string {
+	var b strings.Builder
+	b.Grow(len(data))
+	for _, c := range data {
+		switch {
+		case c < 0x80:
+			b.WriteByte(c)
+		case c < 0xA0:
+			b.WriteRune(windows1252High[c-0x80])
+		default:
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
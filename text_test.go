@@ -0,0 +1,67 @@
+package curlhttp
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestDetectCharset tests the precedence of BOM, header and meta tag
+func TestDetectCharset(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		want        string
+	}{
+		{"text/html; charset=Shift_JIS", "\xEF\xBB\xBFhi", "utf-8"},
+		{"text/plain; charset=\"ISO-8859-1\"", "", "iso-8859-1"},
+		{"text/html", `<head><meta charset="windows-1252"></head>`, "windows-1252"},
+		{"text/html", `<meta http-equiv="Content-Type" content="text/html; charset=euc-jp">`, "euc-jp"},
+		{"application/json", `<meta charset="latin1">`, ""},
+		{"", "\xFF\xFEh\x00", "utf-16le"},
+	}
+	for _, tt := range tests {
+		if got := DetectCharset(tt.contentType, []byte(tt.body)); got != tt.want {
+			t.Errorf("DetectCharset(%q, %q) = %q, expected %q", tt.contentType, tt.body, got, tt.want)
+		}
+	}
+}
+
+// TestText tests transcoding to UTF-8
+func TestText(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		want        string
+	}{
+		{"text/plain; charset=utf-8", "héllo", "héllo"},
+		{"text/plain; charset=iso-8859-1", "caf\xe9 \x80", "café €"},
+		{"text/html", "<p>na\xefve \x93quoted\x94</p>", "<p>naïve “quoted”</p>"},
+		{"text/plain", "\xFE\xFF\x00h\x00\xe9", "hé"},
+		{"text/plain; charset=utf-16le", "h\x00\xe9\x00", "hé"},
+		{"text/plain", "\xEF\xBB\xBFbom", "bom"},
+		{"text/plain; charset=Shift_JIS", "\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd", "こんにちは"},
+		{"text/plain; charset=gbk", "\xd6\xd0\xce\xc4", "中文"},
+		{"text/plain; charset=euc-kr", "\xc7\xd1\xb1\xb9", "한국"},
+		{"text/html", `<meta charset="koi8-r">` + "\xd0\xd2\xc9\xd7\xc5\xd4", `<meta charset="koi8-r">привет`},
+	}
+	for _, tt := range tests {
+		got, err := Text(jsonResponse(200, tt.contentType, tt.body))
+		if err != nil {
+			t.Errorf("Text(%q) failed: %v", tt.body, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Text(%q) = %q, expected %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+// TestTextErrors tests the size limit and unsupported charsets
+func TestTextErrors(t *testing.T) {
+	if _, err := TextLimit(jsonResponse(200, "text/plain", "0123456789"), 5); !errors.Is(err, ErrTextTooLarge) {
+		t.Errorf("Expected ErrTextTooLarge, got %v", err)
+	}
+	if _, err := Text(jsonResponse(200, "text/plain; charset=x-unknown", "x")); !errors.Is(err, ErrUnsupportedCharset) {
+		t.Errorf("Expected ErrUnsupportedCharset, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package curlhttp
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// TransferSizes counts the bytes of one transfer, or the total of several,
+// as curl reports them: header bytes as sent and received, including
+// those of proxy CONNECT exchanges, and body bytes as carried on the
+// wire. TLS records, HTTP/2 and HTTP/3 framing and TCP overhead are not
+// included, so bandwidth billed by a proxy is slightly higher.
+type TransferSizes struct {
+	RequestHeader  int64
+	RequestBody    int64
+	ResponseHeader int64
+	ResponseBody   int64
+
+	// Transfers is the number of curl transfers counted
+	Transfers int64
+}
+
+// Sent returns the request bytes
+func (s TransferSizes) Sent() int64 {
+	return s.RequestHeader + s.RequestBody
+}
+
+// Received returns the response bytes
+func (s TransferSizes) Received() int64 {
+	return s.ResponseHeader + s.ResponseBody
+}
+
+// add adds the counts of o to s
+func (s *TransferSizes) add(o TransferSizes) {
+	s.RequestHeader += o.RequestHeader
+	s.RequestBody += o.RequestBody
+	s.ResponseHeader += o.ResponseHeader
+	s.ResponseBody += o.ResponseBody
+	s.Transfers += o.Transfers
+}
+
+// ResponseSizes returns the bytes sent and received for a response
+// produced by Transport. It reports false for responses from other
+// RoundTrippers. For streamed responses the sizes are known once the body
+// has been read to the end.
+func ResponseSizes(resp *http.Response) (TransferSizes, bool) {
+	if m := metaOf(resp); m != nil && m.sizes != nil {
+		return *m.sizes, true
+	}
+	return TransferSizes{}, false
+}
+
+// getinfoSize reads a byte count, which curl reports as a double or a
+// curl_off_t depending on the info
+func getinfoSize(easy curlEngine, info curl.CurlInfo) int64 {
+	value, err := easy.Getinfo(uint32(info))
+	if err != nil {
+		return 0
+	}
+	switch n := value.(type) {
+	case float64:
+		return int64(n)
+	case int64:
+		return n
+	}
+	return 0
+}
+
+// collectSizes reads the byte counts of the last transfer
+func collectSizes(easy curlEngine) *TransferSizes {
+	return &TransferSizes{
+		RequestHeader:  getinfoSize(easy, infoRequestSize),
+		RequestBody:    getinfoSize(easy, infoSizeUpload),
+		ResponseHeader: getinfoSize(easy, infoHeaderSize),
+		ResponseBody:   getinfoSize(easy, infoSizeDownload),
+		Transfers:      1,
+	}
+}
+
+// trafficCounter totals TransferSizes per host
+type trafficCounter struct {
+	mu     sync.Mutex
+	byHost map[string]*TransferSizes
+}
+
+// recordTraffic reads the byte counts of the transfer just performed for
+// req, adds them to the Transport's totals and returns them. Failed
+// transfers are counted too, as the bytes were still exchanged.
+func (t *Transport) recordTraffic(req *http.Request, easy curlEngine) *TransferSizes {
+	sizes := collectSizes(easy)
+	t.stats.bytesSent.Add(sizes.Sent())
+	t.stats.bytesReceived.Add(sizes.Received())
+
+	host := strings.ToLower(req.URL.Hostname())
+	t.traffic.mu.Lock()
+	defer t.traffic.mu.Unlock()
+	if t.traffic.byHost == nil {
+		t.traffic.byHost = make(map[string]*TransferSizes)
+	}
+	total := t.traffic.byHost[host]
+	if total == nil {
+		total = &TransferSizes{}
+		t.traffic.byHost[host] = total
+	}
+	total.add(*sizes)
+	return sizes
+}
+
+// TrafficByHost returns the bytes sent to and received from each host,
+// keyed by lower-case host name, since the Transport was created or
+// ResetTraffic was last called
+func (t *Transport) TrafficByHost() map[string]TransferSizes {
+	t.traffic.mu.Lock()
+	defer t.traffic.mu.Unlock()
+	totals := make(map[string]TransferSizes, len(t.traffic.byHost))
+	for host, total := range t.traffic.byHost {
+		totals[host] = *total
+	}
+	return totals
+}
+
+// ResetTraffic clears the per-host totals of TrafficByHost and returns
+// them, for reporting usage by billing period. The cumulative BytesSent
+// and BytesReceived of Stats are not reset.
+func (t *Transport) ResetTraffic() map[string]TransferSizes {
+	t.traffic.mu.Lock()
+	defer t.traffic.mu.Unlock()
+	totals := make(map[string]TransferSizes, len(t.traffic.byHost))
+	for host, total := range t.traffic.byHost {
+		totals[host] = *total
+	}
+	t.traffic.byHost = nil
+	return totals
+}
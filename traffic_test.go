@@ -0,0 +1,85 @@
+package curlhttp
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// newSizedEngine returns a fake engine reporting fixed transfer sizes
+func newSizedEngine() *fakeEngine {
+	fake := newFakeEngine("payload")
+	fake.info = map[curl.CurlInfo]interface{}{
+		infoRequestSize:  int64(120),
+		infoSizeUpload:   float64(30),
+		infoHeaderSize:   int64(200),
+		infoSizeDownload: float64(7),
+	}
+	return fake
+}
+
+// TestResponseSizes tests that each response reports its transfer sizes
+func TestResponseSizes(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		transport := newFakeTransport(newSizedEngine())
+		transport.StreamResponses = stream
+
+		req, _ := http.NewRequest("POST", "http://example.com/", strings.NewReader("body"))
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		sizes, ok := ResponseSizes(resp)
+		want := TransferSizes{RequestHeader: 120, RequestBody: 30, ResponseHeader: 200, ResponseBody: 7, Transfers: 1}
+		if !ok || sizes != want {
+			t.Errorf("Expected sizes %+v (stream %v), got %+v", want, stream, sizes)
+		}
+		if sizes.Sent() != 150 || sizes.Received() != 207 {
+			t.Errorf("Expected 150 sent and 207 received, got %d and %d", sizes.Sent(), sizes.Received())
+		}
+		if extra, _ := ResponseExtra(resp); extra.Sizes == nil || *extra.Sizes != want {
+			t.Errorf("Expected sizes in Extra, got %+v", extra.Sizes)
+		}
+	}
+}
+
+// TestTrafficByHost tests the per-host totals and their reset
+func TestTrafficByHost(t *testing.T) {
+	transport := newFakeTransport(newSizedEngine())
+	for _, rawURL := range []string{"http://a.example.com/1", "http://A.example.com/2", "http://b.example.com/"} {
+		req, _ := http.NewRequest("GET", rawURL, nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	traffic := transport.TrafficByHost()
+	if a := traffic["a.example.com"]; a.Transfers != 2 || a.Received() != 414 {
+		t.Errorf("Expected 2 transfers and 414 bytes for a.example.com, got %+v", a)
+	}
+	if b := traffic["b.example.com"]; b.Transfers != 1 || b.Sent() != 150 {
+		t.Errorf("Expected 1 transfer and 150 bytes for b.example.com, got %+v", b)
+	}
+	stats := transport.Stats()
+	if stats.BytesSent != 450 || stats.BytesReceived != 621 {
+		t.Errorf("Expected 450 bytes sent and 621 received, got %d and %d", stats.BytesSent, stats.BytesReceived)
+	}
+
+	if reset := transport.ResetTraffic(); len(reset) != 2 {
+		t.Errorf("Expected ResetTraffic to return 2 hosts, got %d", len(reset))
+	}
+	if n := len(transport.TrafficByHost()); n != 0 {
+		t.Errorf("Expected no hosts after reset, got %d", n)
+	}
+	if transport.Stats().BytesSent != 450 {
+		t.Errorf("Expected cumulative stats to survive the reset")
+	}
+}
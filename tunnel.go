@@ -0,0 +1,291 @@
+package curlhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// tunnelEngine is a curl handle that can exchange raw data on its
+// connection after a CONNECT_ONLY transfer
+type tunnelEngine interface {
+	curlEngine
+	Send(buf []byte) (int, error)
+	Recv(buf []byte) (int, error)
+}
+
+// maxTunnelPoll bounds the wait between attempts while a tunnel has no
+// data to read or cannot accept more to write
+const maxTunnelPoll = 20 * time.Millisecond
+
+// TunnelConn is a connection established by curl and handed over for raw
+// use, with the impersonated TLS session when dialed with an https URL. It
+// implements net.Conn.
+//
+// The binding does not expose the socket, so reads and writes poll the
+// connection and wake up at most every 20ms while it is idle.
+type TunnelConn struct {
+	t      *Transport
+	handle *pooledHandle
+	engine tunnelEngine
+	local  net.Addr
+	remote net.Addr
+
+	mu            sync.Mutex // serializes use of the handle
+	closed        bool
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+// DialTunnel connects to the host and port of rawURL and returns the
+// connection for use with custom protocols. With an https URL curl performs
+// the impersonated TLS handshake first, offering only http/1.1 through ALPN
+// so the server never switches the connection to HTTP/2; with http the
+// connection is plain TCP. Cancelling ctx aborts the connect and handshake.
+// When Proxy is set the connection is tunnelled through it with
+// CONNECT; WithProxy on ctx overrides it. No HTTP request is sent to the
+// origin, but RequestPolicy is consulted with a CONNECT request for rawURL
+// and may refuse or rewrite it.
+func (t *Transport) DialTunnel(ctx context.Context, rawURL string) (*TunnelConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tunnel URL: %w", err)
+	}
+	// RequestPolicy sees the tunnel as a CONNECT request for its URL
+	req, err := http.NewRequestWithContext(ctx, http.MethodConnect, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build tunnel request: %w", err)
+	}
+	if req, err = t.applyRequestPolicy(req); err != nil {
+		return nil, err
+	}
+	u = req.URL
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported tunnel scheme %q", u.Scheme)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := t.resolveTarget(); err != nil {
+		return nil, err
+	}
+	route, err := t.route(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := t.createCurlHandle()
+	if err != nil {
+		return nil, err
+	}
+	engine, ok := handle.curlEngine.(tunnelEngine)
+	if !ok {
+		t.destroyHandle(handle, "tunnel unsupported")
+		return nil, errors.New("curl handle does not support raw connections")
+	}
+
+	if err := t.connectTunnel(ctx, handle, u, route); err != nil {
+		t.destroyHandle(handle, "tunnel failed")
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		t.destroyHandle(handle, "tunnel cancelled")
+		return nil, err
+	}
+
+	info := collectConnInfo(handle, route.proxy)
+	return &TunnelConn{
+		t:      t,
+		handle: handle,
+		engine: engine,
+		local:  tunnelAddr(info.LocalIP, info.LocalPort),
+		remote: tunnelAddr(info.PrimaryIP, info.PrimaryPort),
+	}, nil
+}
+
+// connectTunnel sets up a connect-only transfer to u on route and performs
+// it, aborting when ctx is done
+func (t *Transport) connectTunnel(ctx context.Context, easy curlEngine, u *url.URL, route proxyRoute) error {
+	if err := easy.Setopt(curl.OPT_URL, u.String()); err != nil {
+		return fmt.Errorf("failed to set URL: %w", err)
+	}
+	if err := easy.Setopt(curl.OPT_CONNECT_ONLY, true); err != nil {
+		return fmt.Errorf("failed to set connect only: %w", err)
+	}
+	if u.Scheme == "https" {
+		// The caller speaks its own protocol, never HTTP/2 framing
+		if err := easy.Setopt(curl.OPT_HTTP_VERSION, HTTPVersion1_1); err != nil {
+			return fmt.Errorf("failed to set HTTP version: %w", err)
+		}
+	}
+	abortDone := func(dltotal, dlnow, ultotal, ulnow float64, userdata interface{}) bool {
+		return ctx.Err() == nil
+	}
+	if err := easy.Setopt(curl.OPT_XFERINFOFUNCTION, abortDone); err != nil {
+		return fmt.Errorf("failed to set progress callback: %w", err)
+	}
+	if err := t.setProxy(easy, route); err != nil {
+		return err
+	}
+	if route.proxy != nil {
+		if err := easy.Setopt(curl.OPT_HTTPPROXYTUNNEL, true); err != nil {
+			return fmt.Errorf("failed to enable proxy tunnel: %w", err)
+		}
+	}
+	if err := t.applySocketBinding(easy); err != nil {
+		return err
+	}
+	if err := t.performInNamespace(easy.Perform); err != nil {
+		return fmt.Errorf("failed to establish tunnel: %w", t.checkProxyError(route.proxy, easy, t.netError(route.proxy, u.Hostname(), easy, err)))
+	}
+	return nil
+}
+
+// tunnelAddr builds a TCP address from curl's connection info
+func tunnelAddr(ip string, port int) net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP(ip), Port: port}
+}
+
+// Read reads data received on the connection
+func (c *TunnelConn) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	wait := time.Millisecond
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return 0, net.ErrClosed
+		}
+		n, err := c.engine.Recv(p)
+		deadline := c.readDeadline
+		c.mu.Unlock()
+
+		switch {
+		case err == nil && n == 0:
+			return 0, io.EOF
+		case err == nil:
+			return n, nil
+		case !wouldBlock(err):
+			return n, fmt.Errorf("failed to read from tunnel: %w", err)
+		}
+		if wait, err = pollWait(wait, deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Write sends p on the connection, waiting until all of it is accepted
+func (c *TunnelConn) Write(p []byte) (int, error) {
+	written := 0
+	wait := time.Millisecond
+	for written < len(p) {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return written, net.ErrClosed
+		}
+		n, err := c.engine.Send(p[written:])
+		deadline := c.writeDeadline
+		c.mu.Unlock()
+
+		written += n
+		if err == nil {
+			wait = time.Millisecond
+			continue
+		}
+		if !wouldBlock(err) {
+			return written, fmt.Errorf("failed to write to tunnel: %w", err)
+		}
+		if wait, err = pollWait(wait, deadline); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// wouldBlock reports whether err is curl's CURLE_AGAIN, meaning the
+// connection has nothing to read or cannot accept more to write yet
+func wouldBlock(err error) bool {
+	var curlErr curl.CurlError
+	return errors.As(err, &curlErr) && curlErr == curl.CurlError(curl.E_AGAIN)
+}
+
+// pollWait sleeps before the next attempt, backing off up to maxTunnelPoll,
+// and fails once deadline has passed
+func pollWait(wait time.Duration, deadline time.Time) (time.Duration, error) {
+	if !deadline.IsZero() {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return wait, os.ErrDeadlineExceeded
+		}
+		if wait > remaining {
+			wait = remaining
+		}
+	}
+	time.Sleep(wait)
+	if wait *= 2; wait > maxTunnelPoll {
+		wait = maxTunnelPoll
+	}
+	return wait, nil
+}
+
+// Close closes the connection and releases its curl handle
+func (c *TunnelConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	c.closed = true
+	c.t.destroyHandle(c.handle, "tunnel closed")
+	return nil
+}
+
+// LocalAddr returns the local address of the connection
+func (c *TunnelConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr returns the address curl connected to, which is the proxy when
+// the tunnel goes through one
+func (c *TunnelConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline sets the read and write deadlines
+func (c *TunnelConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline, c.writeDeadline = t, t
+	return nil
+}
+
+// SetReadDeadline sets the deadline for Read calls
+func (c *TunnelConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for Write calls
+func (c *TunnelConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	return nil
+}
@@ -0,0 +1,186 @@
+package curlhttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	curl "github.com/BridgeSenseDev/go-curl-impersonate"
+)
+
+// fakeTunnelEngine is a fake whose connection echoes what it is sent,
+// accepting at most two bytes per Send
+type fakeTunnelEngine struct {
+	*fakeEngine
+	mu      sync.Mutex
+	pending []byte
+	eof     bool
+}
+
+func (e *fakeTunnelEngine) Send(buf []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := len(buf)
+	if n > 2 {
+		n = 2
+	}
+	e.pending = append(e.pending, buf[:n]...)
+	return n, nil
+}
+
+func (e *fakeTunnelEngine) Recv(buf []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.pending) == 0 {
+		if e.eof {
+			return 0, nil
+		}
+		return 0, curl.CurlError(curl.E_AGAIN)
+	}
+	n := copy(buf, e.pending)
+	e.pending = e.pending[n:]
+	return n, nil
+}
+
+// TestDialTunnel tests a connect-only tunnel through a proxy
+func TestDialTunnel(t *testing.T) {
+	engine := &fakeTunnelEngine{fakeEngine: newFakeEngine("")}
+	engine.info = map[curl.CurlInfo]interface{}{
+		infoPrimaryIP:   "10.0.0.1",
+		infoPrimaryPort: int64(3128),
+	}
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return engine }
+	transport.Proxy, _ = url.Parse("http://proxy.example:3128")
+
+	conn, err := transport.DialTunnel(context.Background(), "https://origin.example:8443")
+	if err != nil {
+		t.Fatalf("DialTunnel failed: %v", err)
+	}
+	var _ net.Conn = conn
+
+	if engine.performed[curl.OPT_CONNECT_ONLY] != true || engine.performed[curl.OPT_HTTPPROXYTUNNEL] != true {
+		t.Errorf("Expected connect-only proxy tunnel, got %v", engine.performed)
+	}
+	if got := engine.performed[curl.OPT_URL]; got != "https://origin.example:8443" {
+		t.Errorf("Expected tunnel URL, got %v", got)
+	}
+	if got := engine.performed[curl.OPT_HTTP_VERSION]; got != HTTPVersion1_1 {
+		t.Errorf("Expected an https tunnel to be limited to HTTP/1.1, got %v", got)
+	}
+	if got := conn.RemoteAddr().String(); got != "10.0.0.1:3128" {
+		t.Errorf("Expected remote address 10.0.0.1:3128, got %s", got)
+	}
+
+	if n, err := conn.Write([]byte("hello")); err != nil || n != 5 {
+		t.Fatalf("Expected 5 bytes written, got %d, %v", n, err)
+	}
+	buf := make([]byte, 16)
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "hello" {
+		t.Errorf("Expected to read hello, got %q, %v", buf[:n], err)
+	}
+
+	if err := conn.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if engine.cleanups != 1 {
+		t.Errorf("Expected handle cleanup, got %d", engine.cleanups)
+	}
+	if _, err := conn.Read(buf); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Expected net.ErrClosed after Close, got %v", err)
+	}
+}
+
+// TestTunnelReadDeadline tests that an idle read honors its deadline and EOF is reported
+func TestTunnelReadDeadline(t *testing.T) {
+	engine := &fakeTunnelEngine{fakeEngine: newFakeEngine("")}
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return engine }
+
+	conn, err := transport.DialTunnel(context.Background(), "http://origin.example:7000")
+	if err != nil {
+		t.Fatalf("DialTunnel failed: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(30 * time.Millisecond))
+	buf := make([]byte, 4)
+	if _, err := conn.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected os.ErrDeadlineExceeded, got %v", err)
+	}
+
+	conn.SetReadDeadline(time.Time{})
+	engine.mu.Lock()
+	engine.eof = true
+	engine.mu.Unlock()
+	if _, err := conn.Read(buf); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+// hangingTunnelEngine is a tunnel fake whose connect never completes; it
+// polls the progress callback like curl does until the callback aborts
+type hangingTunnelEngine struct {
+	*fakeTunnelEngine
+}
+
+func (e *hangingTunnelEngine) Perform() error {
+	progress, _ := e.opts[curl.OPT_XFERINFOFUNCTION].(func(float64, float64, float64, float64, interface{}) bool)
+	for progress == nil || progress(0, 0, 0, 0, nil) {
+		time.Sleep(time.Millisecond)
+	}
+	return curl.CurlError(curl.E_ABORTED_BY_CALLBACK)
+}
+
+// TestDialTunnelCancel tests that cancelling the context aborts a hung connect
+func TestDialTunnelCancel(t *testing.T) {
+	engine := &hangingTunnelEngine{&fakeTunnelEngine{fakeEngine: newFakeEngine("")}}
+	transport := NewTransport()
+	transport.newEngine = func() curlEngine { return engine }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := transport.DialTunnel(ctx, "https://origin.example"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the dial to be aborted with the context, got %v", err)
+	}
+	if engine.cleanups != 1 {
+		t.Errorf("Expected the handle to be destroyed, got %d cleanups", engine.cleanups)
+	}
+}
+
+// TestDialTunnelErrors tests rejected URLs, denied tunnels and engines
+// without raw access
+func TestDialTunnelErrors(t *testing.T) {
+	fake := newFakeEngine("")
+	transport := newFakeTransport(fake)
+	if _, err := transport.DialTunnel(context.Background(), "ftp://origin.example"); err == nil {
+		t.Error("Expected error for unsupported scheme")
+	}
+	if _, err := transport.DialTunnel(context.Background(), "https://origin.example"); err == nil {
+		t.Error("Expected error for an engine without Send and Recv")
+	}
+
+	var method string
+	transport.RequestPolicy = func(req *http.Request) (*http.Request, error) {
+		method = req.Method
+		return (&URLAllowlist{Ports: []int{443}}).Check(req)
+	}
+	_, err := transport.DialTunnel(context.Background(), "http://origin.example:6379")
+	if !errors.Is(err, ErrRequestDenied) {
+		t.Errorf("Expected the policy to deny the tunnel, got %v", err)
+	}
+	if method != http.MethodConnect {
+		t.Errorf("Expected the policy to see a CONNECT request, got %q", method)
+	}
+	if fake.performed != nil {
+		t.Error("Expected the denied tunnel not to be performed")
+	}
+}
@@ -0,0 +1,112 @@
+package curlhttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// wireURL returns the URL handed to curl for u, made to match what
+// net/http puts on the wire:
+//
+//   - internationalized host names are converted to their ASCII (punycode)
+//     form with the UTS #46 mapping net/http applies before dialing and in
+//     the Host header.
+//   - the path is escaped as by URL.EscapedPath and the fragment is
+//     dropped, since neither client sends it.
+//   - spaces in the raw query, which net/http sends as they are but curl
+//     rejects, are sent as %20; other bytes pass through unchanged.
+//   - control characters in the query are rejected, as net/http does.
+func wireURL(u *url.URL) (string, error) {
+	host, err := asciiHostPort(u.Host)
+	if err != nil {
+		return "", err
+	}
+	if strings.ContainsFunc(u.RawQuery, isCTL) {
+		return "", errors.New("control character in URL query")
+	}
+
+	var b strings.Builder
+	b.WriteString(u.Scheme)
+	b.WriteString("://")
+	if u.User != nil {
+		b.WriteString(u.User.String())
+		b.WriteByte('@')
+	}
+	b.WriteString(host)
+	switch {
+	case strings.HasPrefix(u.Opaque, "//"):
+		// scheme://host/path written out as an opaque URL
+		_, path, _ := strings.Cut(strings.TrimPrefix(u.Opaque, "//"), "/")
+		b.WriteString("/" + path)
+	case u.Opaque != "":
+		b.WriteString(u.Opaque)
+	default:
+		path := u.EscapedPath()
+		if path == "" {
+			path = "/"
+		} else if path[0] != '/' {
+			b.WriteByte('/')
+		}
+		b.WriteString(path)
+	}
+	if u.ForceQuery || u.RawQuery != "" {
+		b.WriteByte('?')
+		b.WriteString(strings.ReplaceAll(u.RawQuery, " ", "%20"))
+	}
+	return b.String(), nil
+}
+
+// isCTL reports whether r is an ASCII control character
+func isCTL(r rune) bool {
+	return r < ' ' || r == 0x7f
+}
+
+// asciiHostPort converts the host of a host[:port] to its ASCII form
+func asciiHostPort(hostport string) (string, error) {
+	if strings.HasPrefix(hostport, "[") || isASCII(hostport) && !strings.ContainsFunc(hostport, isCTL) {
+		return hostport, nil
+	}
+	host, port := hostport, ""
+	if h, p, err := net.SplitHostPort(hostport); err == nil {
+		host, port = h, p
+	}
+	ascii, err := idnaToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("invalid host %q: %w", host, err)
+	}
+	if port != "" {
+		return net.JoinHostPort(ascii, port), nil
+	}
+	return ascii, nil
+}
+
+// idnaToASCII converts a domain name to its ASCII form with the IDNA
+// lookup profile net/http uses. ASCII names are only lowercased.
+func idnaToASCII(host string) (string, error) {
+	if !utf8.ValidString(host) {
+		return "", errors.New("host is not valid UTF-8")
+	}
+	if strings.ContainsFunc(host, isCTL) {
+		return "", errors.New("control character in host")
+	}
+	if isASCII(host) {
+		return strings.ToLower(host), nil
+	}
+	return idna.Lookup.ToASCII(host)
+}
+
+// isASCII reports whether s only contains ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
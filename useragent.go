@@ -0,0 +1,132 @@
+package curlhttp
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// UserAgentPolicy decides between a User-Agent set on the request and the
+// one of the impersonation target
+type UserAgentPolicy int
+
+const (
+	// UserAgentRequest sends the User-Agent set on the request in place of
+	// the target's, and derives client hints from it. Requests without one
+	// send the target's. This is the default.
+	UserAgentRequest UserAgentPolicy = iota
+
+	// UserAgentTarget always sends the target's User-Agent, ignoring any
+	// set on the request
+	UserAgentTarget
+
+	// UserAgentStrict sends a User-Agent set on the request only if it
+	// names the browser, major version and device class the target's TLS
+	// and HTTP/2 fingerprint belong to, and fails the request with a
+	// *UserAgentMismatchError otherwise. A User-Agent claiming Chrome 120
+	// over a Chrome 136 handshake, or any non-browser User-Agent, is
+	// rejected.
+	UserAgentStrict
+)
+
+// UserAgentMismatchError is returned under UserAgentStrict for a
+// User-Agent that contradicts the impersonation target
+type UserAgentMismatchError struct {
+	UserAgent string
+	Target    string
+	Reason    string
+}
+
+func (e *UserAgentMismatchError) Error() string {
+	return fmt.Sprintf("User-Agent %q contradicts impersonation target %s: %s", e.UserAgent, e.Target, e.Reason)
+}
+
+var (
+	firefoxVersionPattern = regexp.MustCompile(`Firefox/(\d+)`)
+	safariVersionPattern  = regexp.MustCompile(`Version/(\d+)(?:\.(\d+))?.* Safari/`)
+)
+
+// userAgentBrowser returns the browser family, as in target names, and
+// the major version a User-Agent claims, or "" if it names no known
+// browser
+func userAgentBrowser(userAgent string) (family string, major int) {
+	if m := edgeVersionPattern.FindStringSubmatch(userAgent); m != nil {
+		major, _ = strconv.Atoi(strings.SplitN(m[1], ".", 2)[0])
+		return "edge", major
+	}
+	if m := firefoxVersionPattern.FindStringSubmatch(userAgent); m != nil {
+		major, _ = strconv.Atoi(m[1])
+		return "firefox", major
+	}
+	if m := chromeVersionPattern.FindStringSubmatch(userAgent); m != nil {
+		major, _ = strconv.Atoi(m[1])
+		return "chrome", major
+	}
+	if m := safariVersionPattern.FindStringSubmatch(userAgent); m != nil {
+		major, _ = strconv.Atoi(m[1])
+		return "safari", major
+	}
+	return "", 0
+}
+
+// checkUserAgent reports why userAgent contradicts target, or "" if it
+// does not
+func checkUserAgent(userAgent, target string) string {
+	family, variant, version := splitTarget(target)
+	claimed, major := userAgentBrowser(userAgent)
+	switch {
+	case claimed == "":
+		return "not a browser User-Agent"
+	case claimed != family:
+		return fmt.Sprintf("claims %s, handshake is %s", claimed, family)
+	case major != version/1_000_000:
+		return fmt.Sprintf("claims version %d, handshake is version %d", major, version/1_000_000)
+	}
+
+	mobile := strings.Contains(userAgent, "Mobile") || strings.Contains(userAgent, "Android")
+	switch variant {
+	case "android":
+		if !strings.Contains(userAgent, "Android") {
+			return "handshake is Android"
+		}
+	case "ios":
+		if !strings.Contains(userAgent, "iPhone") && !strings.Contains(userAgent, "iPad") {
+			return "handshake is iOS"
+		}
+	default:
+		if mobile {
+			return "claims a mobile device, handshake is desktop"
+		}
+	}
+	return ""
+}
+
+// checkUserAgentPolicy fails req under UserAgentStrict when the User-Agent
+// it sets contradicts the target it is sent with
+func (t *Transport) checkUserAgentPolicy(req *http.Request) error {
+	if t.UserAgentPolicy != UserAgentStrict {
+		return nil
+	}
+	userAgent := req.Header.Get("User-Agent")
+	if userAgent == "" {
+		return nil
+	}
+	target, err := t.requestTarget(req)
+	if err != nil {
+		return err
+	}
+	if reason := checkUserAgent(userAgent, target); reason != "" {
+		return &UserAgentMismatchError{UserAgent: userAgent, Target: target, Reason: reason}
+	}
+	return nil
+}
+
+// applyUserAgentPolicy removes the request's User-Agent under
+// UserAgentTarget, so the target's is sent
+func (t *Transport) applyUserAgentPolicy(headers map[string]string) {
+	if t.UserAgentPolicy == UserAgentTarget {
+		delete(headers, "User-Agent")
+	}
+}
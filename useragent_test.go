@@ -0,0 +1,92 @@
+package curlhttp
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+const (
+	chrome136UA = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/136.0.0.0 Safari/537.36"
+	chrome120UA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+)
+
+// TestUserAgentPolicy tests which User-Agent each policy sends
+func TestUserAgentPolicy(t *testing.T) {
+	tests := []struct {
+		policy UserAgentPolicy
+		sent   string
+	}{
+		{UserAgentRequest, "my-agent/1.0"},
+		{UserAgentTarget, chrome136UA},
+	}
+	for _, tt := range tests {
+		transport := NewTransport()
+		transport.UserAgentPolicy = tt.policy
+		req, _ := http.NewRequest("GET", "https://example.com/", nil)
+		req.Header.Set("User-Agent", "my-agent/1.0")
+		headers := transport.requestHeaders(req)
+		if headers["User-Agent"] != tt.sent {
+			t.Errorf("Policy %d: expected User-Agent %q, got %q", tt.policy, tt.sent, headers["User-Agent"])
+		}
+	}
+}
+
+// TestUserAgentStrict tests that contradicting User-Agents fail the request
+func TestUserAgentStrict(t *testing.T) {
+	fake := newFakeEngine("ok")
+	transport := newFakeTransport(fake)
+	transport.UserAgentPolicy = UserAgentStrict
+
+	send := func(userAgent string) error {
+		req, _ := http.NewRequest("GET", "https://example.com/", nil)
+		if userAgent != "" {
+			req.Header.Set("User-Agent", userAgent)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := send(chrome136UA); err != nil {
+		t.Errorf("Expected a matching User-Agent to be sent, got %v", err)
+	}
+	if err := send(""); err != nil {
+		t.Errorf("Expected requests without a User-Agent to be sent, got %v", err)
+	}
+	var mismatch *UserAgentMismatchError
+	if err := send(chrome120UA); !errors.As(err, &mismatch) || mismatch.Target != "chrome136" {
+		t.Errorf("Expected a UserAgentMismatchError for Chrome 120, got %v", err)
+	}
+	if err := send("curl/8.0"); !errors.As(err, &mismatch) {
+		t.Errorf("Expected a UserAgentMismatchError for curl, got %v", err)
+	}
+}
+
+// TestCheckUserAgent tests browser, version and device checks
+func TestCheckUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		target    string
+		ok        bool
+	}{
+		{chrome136UA, "chrome136", true},
+		{chrome120UA, "chrome136", false},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/101.0.4951.64 Safari/537.36 Edg/101.0.1210.47", "edge101", true},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/101.0.4951.64 Safari/537.36 Edg/101.0.1210.47", "chrome101", false},
+		{"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Mobile Safari/537.36", "chrome131_android", true},
+		{"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Mobile Safari/537.36", "chrome131", false},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:133.0) Gecko/20100101 Firefox/133.0", "firefox133", true},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15", "safari17_0", true},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1", "safari17_2_ios", true},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15", "safari17_2_ios", false},
+		{"Googlebot/2.1", "chrome136", false},
+	}
+	for _, tt := range tests {
+		if reason := checkUserAgent(tt.userAgent, tt.target); (reason == "") != tt.ok {
+			t.Errorf("%s with %q: expected ok=%v, got %q", tt.target, tt.userAgent, tt.ok, reason)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+// Package vcr records HTTP interactions to cassette files and replays them,
+// so tests can exercise code built on curlhttp deterministically and without
+// network access.
+//
+// A Recorder is an http.RoundTripper. In record mode it forwards requests to
+// a real RoundTripper (usually a *curlhttp.Transport) and stores each
+// request/response pair, together with the impersonation settings in effect.
+// In replay mode it answers from the cassette and never touches the network.
+//
+//	rec, err := vcr.New("testdata/login.json", vcr.ModeReplayOrRecord, curlhttp.NewTransport())
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer rec.Stop()
+//
+//	client := &http.Client{Transport: rec}
+package vcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	curlhttp "github.com/dstockton/go-curl-impersonate-net-http-wrapper"
+)
+
+// CassetteVersion is the format version written to new cassettes
+const CassetteVersion = 1
+
+// ErrInteractionNotFound is returned in replay mode when no recorded
+// interaction matches a request.
+var ErrInteractionNotFound = errors.New("vcr: no matching interaction in cassette")
+
+// Mode controls whether a Recorder talks to the network.
+type Mode int
+
+const (
+	// ModeReplayOrRecord replays matching interactions and records any
+	// request that has no match yet.
+	ModeReplayOrRecord Mode = iota
+
+	// ModeRecord always performs real requests and records them,
+	// discarding any interactions previously stored in the cassette.
+	ModeRecord
+
+	// ModeReplay only replays; unmatched requests fail with ErrInteractionNotFound.
+	ModeReplay
+
+	// ModePassthrough forwards every request without recording or replaying.
+	ModePassthrough
+)
+
+// RecordedRequest is the stored form of an outgoing request
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the stored form of a response
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Status     string      `json:"status"`
+	Proto      string      `json:"proto"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+}
+
+// Impersonation records the browser impersonation settings used for an interaction
+type Impersonation struct {
+	Target            string `json:"target"`
+	UseDefaultHeaders bool   `json:"use_default_headers"`
+	HttpVersion       int    `json:"http_version,omitempty"`
+}
+
+// Interaction is a single recorded request/response pair
+type Interaction struct {
+	Request       RecordedRequest  `json:"request"`
+	Response      RecordedResponse `json:"response"`
+	Impersonation *Impersonation   `json:"impersonation,omitempty"`
+	Duration      time.Duration    `json:"duration"`
+	RecordedAt    time.Time        `json:"recorded_at"`
+}
+
+// Cassette is the on-disk collection of interactions
+type Cassette struct {
+	Version      int            `json:"version"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Load reads a cassette from path
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path, creating parent directories as needed
+func (c *Cassette) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Matcher reports whether a recorded request matches an outgoing request.
+// body holds the outgoing request body, already read.
+type Matcher func(req *http.Request, body []byte, recorded RecordedRequest) bool
+
+// MatchMethodURL matches on method and full URL. It is the default matcher.
+func MatchMethodURL(req *http.Request, body []byte, recorded RecordedRequest) bool {
+	return req.Method == recorded.Method && req.URL.String() == recorded.URL
+}
+
+// MatchMethodURLBody matches on method, full URL and exact body bytes
+func MatchMethodURLBody(req *http.Request, body []byte, recorded RecordedRequest) bool {
+	return MatchMethodURL(req, body, recorded) && bytes.Equal(body, recorded.Body)
+}
+
+// Recorder is an http.RoundTripper that records and replays interactions.
+type Recorder struct {
+	// Matcher selects recorded interactions for replay. Defaults to MatchMethodURL.
+	Matcher Matcher
+
+	// KeepSensitiveHeaders stores headers such as Authorization, Cookie and
+	// Set-Cookie verbatim. By default their values are recorded as
+	// "<redacted>" (see curlhttp.IsSensitiveHeader) so cassettes can be
+	// committed without leaking credentials.
+	KeepSensitiveHeaders bool
+
+	mode     Mode
+	path     string
+	real     http.RoundTripper
+	cassette *Cassette
+	used     []bool
+	dirty    bool
+	mu       sync.Mutex
+}
+
+// New creates a Recorder backed by the cassette at path. real performs
+// requests that are not replayed; it may be nil in ModeReplay.
+func New(path string, mode Mode, real http.RoundTripper) (*Recorder, error) {
+	cassette := &Cassette{Version: CassetteVersion}
+	if mode == ModeReplay || mode == ModeReplayOrRecord {
+		loaded, err := Load(path)
+		switch {
+		case err == nil:
+			cassette = loaded
+		case errors.Is(err, os.ErrNotExist) && mode == ModeReplayOrRecord:
+			// Start with an empty cassette
+		default:
+			return nil, err
+		}
+	}
+	if real == nil && mode != ModeReplay {
+		return nil, fmt.Errorf("vcr: a real RoundTripper is required in mode %d", mode)
+	}
+
+	return &Recorder{
+		mode:     mode,
+		path:     path,
+		real:     real,
+		cassette: cassette,
+		used:     make([]bool, len(cassette.Interactions)),
+	}, nil
+}
+
+// NewMock creates a replay-only Recorder serving the given interactions,
+// useful for tests that build their fixtures in code.
+func NewMock(interactions ...*Interaction) *Recorder {
+	return &Recorder{
+		mode:     ModeReplay,
+		cassette: &Cassette{Version: CassetteVersion, Interactions: interactions},
+		used:     make([]bool, len(interactions)),
+	}
+}
+
+// Cassette returns the recorder's cassette
+func (r *Recorder) Cassette() *Cassette {
+	return r.cassette
+}
+
+// Stop saves newly recorded interactions to the cassette file
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.dirty || r.path == "" {
+		return nil
+	}
+	r.dirty = false
+	return r.cassette.Save(r.path)
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.mode == ModePassthrough {
+		return r.real.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("vcr: failed to read request body: %w", err)
+		}
+	}
+
+	if r.mode != ModeRecord {
+		if interaction := r.take(req, body); interaction != nil {
+			return interaction.response(req), nil
+		}
+		if r.mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL)
+		}
+	}
+
+	return r.record(req, body)
+}
+
+// take returns the first unused interaction matching req and marks it used.
+// Interactions are consumed in cassette order so repeated identical
+// requests replay their recorded responses in sequence.
+func (r *Recorder) take(req *http.Request, body []byte) *Interaction {
+	match := r.Matcher
+	if match == nil {
+		match = MatchMethodURL
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		if !r.used[i] && match(req, body, interaction.Request) {
+			r.used[i] = true
+			return interaction
+		}
+	}
+	return nil
+}
+
+// record performs req with the real RoundTripper and stores the result
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	outgoing := req.Clone(req.Context())
+	if body != nil {
+		outgoing.Body = io.NopCloser(bytes.NewReader(body))
+		outgoing.ContentLength = int64(len(body))
+	}
+
+	start := time.Now()
+	resp, err := r.real.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("vcr: failed to read response body: %w", err)
+	}
+
+	interaction := &Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: r.recordHeader(req.Header),
+			Body:   body,
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Proto:      resp.Proto,
+			Header:     r.recordHeader(resp.Header),
+			Body:       respBody,
+		},
+		Impersonation: impersonationOf(r.real),
+		Duration:      time.Since(start),
+		RecordedAt:    start.UTC(),
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.used = append(r.used, true)
+	r.dirty = true
+	r.mu.Unlock()
+
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+// recordHeader returns the copy of h stored in the cassette
+func (r *Recorder) recordHeader(h http.Header) http.Header {
+	header := h.Clone()
+	if r.KeepSensitiveHeaders {
+		return header
+	}
+	for name, values := range header {
+		if curlhttp.IsSensitiveHeader(name) {
+			redacted := make([]string, len(values))
+			for i := range redacted {
+				redacted[i] = "<redacted>"
+			}
+			header[name] = redacted
+		}
+	}
+	return header
+}
+
+// impersonationOf extracts impersonation settings from a curlhttp Transport
+func impersonationOf(rt http.RoundTripper) *Impersonation {
+	t, ok := rt.(*curlhttp.Transport)
+	if !ok {
+		return nil
+	}
+	return &Impersonation{
+		Target:            t.ImpersonateTarget,
+		UseDefaultHeaders: t.UseDefaultHeaders,
+		HttpVersion:       t.HttpVersion,
+	}
+}
+
+// response builds a fresh http.Response from the recorded data
+func (i *Interaction) response(req *http.Request) *http.Response {
+	proto := i.Response.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok {
+		major, minor = 1, 1
+	}
+	header := i.Response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	status := i.Response.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode))
+	}
+
+	return &http.Response{
+		Status:        status,
+		StatusCode:    i.Response.StatusCode,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(i.Response.Body)),
+		ContentLength: int64(len(i.Response.Body)),
+		Request:       req,
+	}
+}
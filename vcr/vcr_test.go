@@ -0,0 +1,181 @@
+package vcr
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newCountingServer returns a server echoing the request body and counting hits
+func newCountingServer(hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(hits, 1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Hit", string(rune('0'+n)))
+		w.Write([]byte(r.Method + " " + string(body)))
+	}))
+}
+
+// TestRecordThenReplay tests that recorded interactions replay without the network
+func TestRecordThenReplay(t *testing.T) {
+	var hits int32
+	server := newCountingServer(&hits)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, err := New(path, ModeRecord, http.DefaultTransport)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	client := &http.Client{Transport: rec}
+	resp, err := client.Post(server.URL+"/echo", "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("Recording request failed: %v", err)
+	}
+	recorded, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	replay, err := New(path, ModeReplay, nil)
+	if err != nil {
+		t.Fatalf("New replay failed: %v", err)
+	}
+	replay.Matcher = MatchMethodURLBody
+	client = &http.Client{Transport: replay}
+	resp, err = client.Post(server.URL+"/echo", "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("Replay request failed: %v", err)
+	}
+	replayed, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(replayed) != string(recorded) {
+		t.Errorf("Expected replayed body %q, got %q", recorded, replayed)
+	}
+	if resp.Header.Get("X-Hit") != "1" {
+		t.Errorf("Expected recorded header X-Hit: 1, got %q", resp.Header.Get("X-Hit"))
+	}
+	if atomic.LoadInt32(&hits) != 1 {
+		t.Errorf("Expected exactly 1 server hit, got %d", hits)
+	}
+
+	// A different body does not match with MatchMethodURLBody
+	_, err = client.Post(server.URL+"/echo", "text/plain", strings.NewReader("other"))
+	if !errors.Is(err, ErrInteractionNotFound) {
+		t.Errorf("Expected ErrInteractionNotFound, got %v", err)
+	}
+}
+
+// TestReplayOrRecord tests that only unmatched requests hit the network
+func TestReplayOrRecord(t *testing.T) {
+	var hits int32
+	server := newCountingServer(&hits)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	for i := 0; i < 2; i++ {
+		rec, err := New(path, ModeReplayOrRecord, http.DefaultTransport)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		resp, err := (&http.Client{Transport: rec}).Get(server.URL + "/a")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		resp.Body.Close()
+		if err := rec.Stop(); err != nil {
+			t.Fatalf("Stop failed: %v", err)
+		}
+	}
+
+	if atomic.LoadInt32(&hits) != 1 {
+		t.Errorf("Expected 1 server hit across two runs, got %d", hits)
+	}
+}
+
+// TestMockSequentialReplay tests that identical requests consume interactions in order
+func TestMockSequentialReplay(t *testing.T) {
+	mock := NewMock(
+		&Interaction{
+			Request:  RecordedRequest{Method: "GET", URL: "http://example.com/poll"},
+			Response: RecordedResponse{StatusCode: 202, Body: []byte("pending")},
+		},
+		&Interaction{
+			Request:  RecordedRequest{Method: "GET", URL: "http://example.com/poll"},
+			Response: RecordedResponse{StatusCode: 200, Body: []byte("done")},
+		},
+	)
+	client := &http.Client{Transport: mock}
+
+	for _, want := range []int{202, 200} {
+		resp, err := client.Get("http://example.com/poll")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("Expected status %d, got %d", want, resp.StatusCode)
+		}
+	}
+
+	if _, err := client.Get("http://example.com/poll"); !errors.Is(err, ErrInteractionNotFound) {
+		t.Errorf("Expected ErrInteractionNotFound once exhausted, got %v", err)
+	}
+}
+
+// TestRecordRedactsSensitiveHeaders tests that credentials are not stored
+// unless KeepSensitiveHeaders is set
+func TestRecordRedactsSensitiveHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Request-Id", "42")
+	}))
+	defer server.Close()
+
+	for _, keep := range []bool{false, true} {
+		rec, err := New("", ModeRecord, http.DefaultTransport)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		rec.KeepSensitiveHeaders = keep
+
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Cookie", "session=secret")
+		req.Header.Set("Accept", "text/html")
+		resp, err := rec.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.Header.Get("Set-Cookie") != "session=secret" {
+			t.Errorf("Expected the live response to keep Set-Cookie, got %q", resp.Header.Get("Set-Cookie"))
+		}
+
+		recorded := rec.Cassette().Interactions[0]
+		want := "<redacted>"
+		if keep {
+			want = "Bearer token"
+		}
+		if got := recorded.Request.Header.Get("Authorization"); got != want {
+			t.Errorf("keep=%v: expected Authorization %q, got %q", keep, want, got)
+		}
+		if got := recorded.Request.Header.Get("Cookie"); keep != (got == "session=secret") {
+			t.Errorf("keep=%v: unexpected recorded Cookie %q", keep, got)
+		}
+		if got := recorded.Response.Header.Get("Set-Cookie"); keep != (got == "session=secret") {
+			t.Errorf("keep=%v: unexpected recorded Set-Cookie %q", keep, got)
+		}
+		if recorded.Request.Header.Get("Accept") != "text/html" || recorded.Response.Header.Get("X-Request-Id") != "42" {
+			t.Errorf("keep=%v: expected other headers to be recorded verbatim", keep)
+		}
+	}
+}